@@ -0,0 +1,223 @@
+// androidkey implements the Android Key (WebAuthn spec section 8.4) attestation statement format
+package androidkey
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+func init() {
+	protocol.RegisterFormat("android-key", verifyAndroidKey)
+	protocol.RegisterFormatFields("android-key", "alg", "sig", "x5c")
+}
+
+var extensionIDKeyDescription = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 1, 17}
+
+// Tags and values of the AuthorizationList, as defined in
+// https://source.android.com/security/keystore/attestation#schema
+const (
+	authorizationListTagPurpose         = 1
+	authorizationListTagAllApplications = 600
+	authorizationListTagOrigin          = 702
+
+	kmPurposeSign     = 2
+	kmOriginGenerated = 0
+)
+
+// keyDescription represents the KeyDescription structure in the key attestation extension data.
+type keyDescription struct {
+	AttestationVersion       int
+	AttestationSecurityLevel asn1.Enumerated
+	KeymasterVersion         int
+	KeymasterSecurityLevel   asn1.Enumerated
+	AttestationChallenge     []byte
+	UniqueID                 []byte
+	SoftwareEnforced         asn1.RawValue
+	TeeEnforced              asn1.RawValue
+}
+
+// authorizationList contains the fields of the AuthorizationList that are used for verification.
+type authorizationList struct {
+	Purpose         []int
+	AllApplications bool
+	Origin          *int
+}
+
+func verifyAndroidKey(a protocol.Attestation, clientDataHash []byte) (protocol.AttestationType, []*x509.Certificate, error) {
+	// Verify that attStmt is valid CBOR conforming to the syntax defined above and perform CBOR decoding on it to
+	// extract the contained fields.
+	rawAlg, ok := a.AttStmt["alg"]
+	if !ok {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("missing alg for android-key").WithStep(protocol.AttestationStepStatement)
+	}
+	algInt, ok := rawAlg.(int64)
+	if !ok {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid alg for android-key, is of invalid type %T", rawAlg).WithStep(protocol.AttestationStepStatement)
+	}
+
+	alg := protocol.COSEAlgorithmIdentifier(algInt)
+
+	rawSig, ok := a.AttStmt["sig"]
+	if !ok {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("missing sig for android-key").WithStep(protocol.AttestationStepStatement)
+	}
+	sig, ok := rawSig.([]byte)
+	if !ok {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("invalid sig for android-key").WithStep(protocol.AttestationStepStatement)
+	}
+
+	rawX5c, ok := a.AttStmt["x5c"]
+	if !ok {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("missing x5c for android-key").WithStep(protocol.AttestationStepStatement)
+	}
+	x5c, ok := rawX5c.([]interface{})
+	if !ok || len(x5c) == 0 {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("invalid x5c for android-key").WithStep(protocol.AttestationStepStatement)
+	}
+
+	credCert, ok := x5c[0].([]byte)
+	if !ok {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("invalid x5c for android-key").WithStep(protocol.AttestationStepStatement)
+	}
+
+	cert, err := protocol.ParseCertificate(credCert)
+	if err != nil {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid x5c for android-key: %v", err).WithStep(protocol.AttestationStepCertificate)
+	}
+
+	// Verify that sig is a valid signature over the concatenation of authenticatorData and clientDataHash using the
+	// public key in the first certificate in x5c with the algorithm specified in alg.
+	var sigAlg x509.SignatureAlgorithm
+	switch alg {
+	case protocol.ES256:
+		sigAlg = x509.ECDSAWithSHA256
+	case protocol.RS256:
+		sigAlg = x509.SHA256WithRSA
+	default:
+		return "", nil, protocol.ErrUnsupportedAlgorithm.WithDebugf("unsupported alg %d for android-key", alg)
+	}
+
+	signedBytes := make([]byte, 0, len(a.AuthData.Raw)+len(clientDataHash))
+	signedBytes = append(signedBytes, a.AuthData.Raw...)
+	signedBytes = append(signedBytes, clientDataHash...)
+	if alg == protocol.ES256 {
+		if _, _, err := protocol.ParseECDSASignature(sig); err != nil {
+			return "", nil, protocol.ErrSignatureInvalid.WithDebugf("invalid signature for android-key: %v", err).WithCause(err)
+		}
+	}
+	if err := cert.CheckSignature(sigAlg, signedBytes, sig); err != nil {
+		return "", nil, protocol.ErrSignatureInvalid.WithDebugf("invalid signature for android-key: %v", err).WithCause(err)
+	}
+
+	// Verify that the public key in the first certificate in x5c matches the credentialPublicKey in the
+	// attestedCredentialData in authenticatorData.
+	if !protocol.PublicKeyEqual(cert.PublicKey, a.AuthData.AttestedCredentialData.COSEKey) {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("certificate public key does not match credential public key for android-key").WithStep(protocol.AttestationStepCredentialPublicKey)
+	}
+
+	var extension []byte
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(extensionIDKeyDescription) {
+			extension = ext.Value
+			break
+		}
+	}
+	if extension == nil {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("missing key description extension for android-key").WithStep(protocol.AttestationStepKeyDescription)
+	}
+
+	var desc keyDescription
+	if rest, err := asn1.Unmarshal(extension, &desc); err != nil {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid key description for android-key: %v", err).WithStep(protocol.AttestationStepKeyDescription)
+	} else if len(rest) != 0 {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("invalid key description for android-key: trailing data").WithStep(protocol.AttestationStepKeyDescription)
+	}
+
+	// Verify that the attestationChallenge field in the attestation certificate extension data is identical to
+	// clientDataHash.
+	if !bytes.Equal(desc.AttestationChallenge, clientDataHash) {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("attestation challenge does not match client data hash for android-key").WithStep(protocol.AttestationStepNonce)
+	}
+
+	softwareEnforced, err := parseAuthorizationList(desc.SoftwareEnforced)
+	if err != nil {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid software enforced authorization list for android-key: %v", err).WithStep(protocol.AttestationStepKeyDescription)
+	}
+	teeEnforced, err := parseAuthorizationList(desc.TeeEnforced)
+	if err != nil {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid TEE enforced authorization list for android-key: %v", err).WithStep(protocol.AttestationStepKeyDescription)
+	}
+
+	// The AuthorizationList.allApplications field is not present on either authorization list (softwareEnforced nor
+	// teeEnforced), since PublicKeyCredential MUST be scoped to the RP ID.
+	if softwareEnforced.AllApplications || teeEnforced.AllApplications {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("allApplications is present for android-key").WithStep(protocol.AttestationStepKeyDescription)
+	}
+
+	// For the following, use the union of teeEnforced and softwareEnforced.
+
+	// The value in the AuthorizationList.origin field is equal to KM_ORIGIN_GENERATED.
+	origin := teeEnforced.Origin
+	if origin == nil {
+		origin = softwareEnforced.Origin
+	}
+	if origin == nil || *origin != kmOriginGenerated {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("key origin is not generated for android-key").WithStep(protocol.AttestationStepKeyDescription)
+	}
+
+	// The value in the AuthorizationList.purpose field is equal to KM_PURPOSE_SIGN.
+	var purposeSign bool
+	for _, purpose := range append(teeEnforced.Purpose, softwareEnforced.Purpose...) {
+		if purpose == kmPurposeSign {
+			purposeSign = true
+			break
+		}
+	}
+	if !purposeSign {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("key purpose is not sign for android-key").WithStep(protocol.AttestationStepKeyDescription)
+	}
+
+	// If successful, return implementation-specific values representing attestation type Basic and attestation trust
+	// path x5c.
+	trustPath, err := a.Certificates()
+	if err != nil {
+		return "", nil, err
+	}
+	return protocol.AttestationTypeBasic, trustPath, nil
+}
+
+// parseAuthorizationList parses the fields that are needed from an AuthorizationList. Since the list contains many
+// optional fields that are not needed, it is parsed element by element.
+func parseAuthorizationList(raw asn1.RawValue) (*authorizationList, error) {
+	var elements []asn1.RawValue
+	if _, err := asn1.Unmarshal(raw.FullBytes, &elements); err != nil {
+		return nil, err
+	}
+
+	l := &authorizationList{}
+	for _, element := range elements {
+		if element.Class != asn1.ClassContextSpecific {
+			continue
+		}
+
+		switch element.Tag {
+		case authorizationListTagPurpose:
+			if _, err := asn1.UnmarshalWithParams(element.Bytes, &l.Purpose, "set"); err != nil {
+				return nil, err
+			}
+		case authorizationListTagAllApplications:
+			l.AllApplications = true
+		case authorizationListTagOrigin:
+			var origin int
+			if _, err := asn1.Unmarshal(element.Bytes, &origin); err != nil {
+				return nil, err
+			}
+			l.Origin = &origin
+		}
+	}
+
+	return l, nil
+}
@@ -0,0 +1,48 @@
+package androidkey_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+func TestIsValidAttestation(t *testing.T) {
+	for i := range attestationRequests {
+		t.Run(fmt.Sprintf("Run %d", i), func(t *testing.T) {
+			r := protocol.CredentialCreationOptions{}
+			if err := json.Unmarshal([]byte(attestationRequests[i]), &r); err != nil {
+				t.Fatal(err)
+			}
+
+			b := protocol.AttestationResponse{}
+			if err := json.Unmarshal([]byte(attestationResponses[i]), &b); err != nil {
+				t.Fatal(err)
+			}
+
+			p, err := protocol.ParseAttestationResponse(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d, err := protocol.IsValidAttestation(p, r.PublicKey.Challenge, "", "")
+			if err != nil {
+				e := protocol.ToWebAuthnError(err)
+				t.Fatal(fmt.Sprintf("%s, %s: %s", e.Name, e.Description, e.Debug))
+			}
+
+			if !d {
+				t.Fatal("is not valid")
+			}
+		})
+	}
+}
+
+var attestationRequests = []string{
+	`{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"2I1V2LitsmZ/eh+aQbyTqVq2jTc9syLYauN3/Q5XVyc=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`,
+}
+
+var attestationResponses = []string{
+	`{"id":"VeI2YA5eyJ95VKxqGnR86HOtqBGOw5QZ5hu1V9ZVMBw","rawId":"VeI2YA5eyJ95VKxqGnR86HOtqBGOw5QZ5hu1V9ZVMBw=","response":{"attestationObject":"o2dhdHRTdG10o2NhbGcmY3NpZ1hIMEYCIQCfosP2TSibKKDQypqMB7YCRiC6It/tbiJuJ8XaLTO/WgIhAM66uCKRk4nhv3Vl4CFZ5dnkIVPRsPkYtNWF9UmuplN9Y3g1Y4JZAd8wggHbMIIBgaADAgECAgECMAoGCCqGSM49BAMCMCUxIzAhBgNVBAMTGkFuZHJvaWQgS2V5c3RvcmUgVGVzdCBSb290MB4XDTIwMDEwMTAwMDAwMFoXDTQwMDEwMTAwMDAwMFowHzEdMBsGA1UEAxMUQW5kcm9pZCBLZXlzdG9yZSBLZXkwWTATBgcqhkjOPQIBBggqhkjOPQMBBwNCAAQEWzUG7pAWXVJ52DmlUwNpFK+UjyODVtB8041To6RGJIU52u9xtOl6C76WRDQRinVcOruCLaxVA8tQgrWn2Ibfo4GnMIGkMB8GA1UdIwQYMBaAFBRErmHiLM4d5FSKqI8kNykDGjtZMIGABgorBgEEAdZ5AgERBHIwcAIBAwoBAQIBBAoBAQQg64lJkg9ggAmXDE9pJHIXDl3HqPJEiI0RFU7RLSIF9asEADAMv4U9CAIGAXSHboAAMDChBTEDAgECogMCAQOjBAICAQClBTEDAgEEqgMCAQEFAL+FPgMCAQC/hUEFAgMBhqAwCgYIKoZIzj0EAwIDSAAwRQIhAJ9nIR2TfhTD0vOAg7juM/qjenC9FM6xO2UsftAnsRANAiABVls6lC6weAMtbc4y/wDJnWYSw8Z96BUwCXosEAPD4lkBfjCCAXowggEhoAMCAQICAQEwCgYIKoZIzj0EAwIwJTEjMCEGA1UEAxMaQW5kcm9pZCBLZXlzdG9yZSBUZXN0IFJvb3QwHhcNMjAwMTAxMDAwMDAwWhcNNDUwMTAxMDAwMDAwWjAlMSMwIQYDVQQDExpBbmRyb2lkIEtleXN0b3JlIFRlc3QgUm9vdDBZMBMGByqGSM49AgEGCCqGSM49AwEHA0IABDc4EZJ022Rw+ZPDRxJ+s4bNaRdu5wdLeLnOLKXs7TSwR3oUCWAamMpRBijiYLqC1MHkH/dg8tuvdqOqeV3RE5ujQjBAMA4GA1UdDwEB/wQEAwICBDAPBgNVHRMBAf8EBTADAQH/MB0GA1UdDgQWBBQURK5h4izOHeRUiqiPJDcpAxo7WTAKBggqhkjOPQQDAgNHADBEAiAWNPsvQnsJlMQN6JRessNE/91PIzft8c7mOx5k0TlCAQIgWomwLg2td3WhSj20dn2/tSOnyQU73RGv5+ywVzwmJsVoYXV0aERhdGFYpEmWDeWIDoxodDQXD2R2YFuP5K65ooYyx5lc87qDHZdjRQAAAAAAAAAAAAAAAAAAAAAAAAAAACBV4jZgDl7In3lUrGoadHzoc62oEY7DlBnmG7VX1lUwHKUiWCCFOdrvcbTpegu+lkQ0EYp1XDq7gi2sVQPLUIK1p9iG3yFYIARbNQbukBZdUnnYOaVTA2kUr5SPI4NW0HzTjVOjpEYkIAEBAgMmY2ZtdGthbmRyb2lkLWtleQ==","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoiMkkxVjJMaXRzbVpfZWgtYVFieVRxVnEyalRjOXN5TFlhdU4zX1E1WFZ5YyIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`,
+}
@@ -13,11 +13,13 @@ import (
 	"github.com/keycloud/webauthn/protocol"
 )
 
-// Now is used to overwrite the time at which the certificate is verified and is just used for tests.
-var now = time.Now
+// defaultMaxResponseAge is the maximum difference between the timestamp of a SafetyNet response and the time at which
+// it is verified, unless it is set by protocol.AttestationVerificationOptions.
+const defaultMaxResponseAge = 60 * time.Second
 
 func init() {
-	protocol.RegisterFormat("android-safetynet", verifyAndroidSafetynet)
+	protocol.RegisterFormatWithOptions("android-safetynet", verifyAndroidSafetynet)
+	protocol.RegisterFormatFields("android-safetynet", "ver", "response")
 }
 
 type AndroidSafetyNetAttestionResponse struct {
@@ -30,73 +32,91 @@ type AndroidSafetyNetAttestionResponse struct {
 	BasicIntegrity             bool     `json:"basicIntegrity"`
 }
 
-func verifyAndroidSafetynet(a protocol.Attestation, clientDataHash []byte) error {
+func verifyAndroidSafetynet(a protocol.Attestation, clientDataHash []byte, opts protocol.AttestationVerificationOptions) (protocol.AttestationType, []*x509.Certificate, error) {
 	// Verify that response is a valid SafetyNet response of version ver.
 	rawVer, ok := a.AttStmt["ver"]
 	if !ok {
-		return protocol.ErrInvalidAttestation.WithDebug("missing ver for android-safetynet")
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("missing ver for android-safetynet").WithStep(protocol.AttestationStepStatement)
 	}
 	ver, ok := rawVer.(string)
 	if !ok {
-		return protocol.ErrInvalidAttestation.WithDebugf("invalid ver for android-safetynet, is of invalid type %T", rawVer)
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid ver for android-safetynet, is of invalid type %T", rawVer).WithStep(protocol.AttestationStepStatement)
 	}
 
 	if ver == "" {
-		return protocol.ErrInvalidAttestation.WithDebug("invalid ver for android-safetynet")
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("invalid ver for android-safetynet").WithStep(protocol.AttestationStepStatement)
 	}
 
 	rawResponse, ok := a.AttStmt["response"]
 	if !ok {
-		return protocol.ErrInvalidAttestation.WithDebug("missing response for android-safetynet")
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("missing response for android-safetynet").WithStep(protocol.AttestationStepStatement)
 	}
 	responseBytes, ok := rawResponse.([]byte)
 	if !ok {
-		return protocol.ErrInvalidAttestation.WithDebugf("invalid response for android-safetynet, is of invalid type %T", responseBytes)
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid response for android-safetynet, is of invalid type %T", responseBytes).WithStep(protocol.AttestationStepStatement)
 	}
 
 	response, err := jose.ParseSigned(string(responseBytes))
 	if err != nil {
-		return protocol.ErrInvalidAttestation.WithDebugf("invalid response for android-safetynet: %v", err)
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid response for android-safetynet: %v", err).WithStep(protocol.AttestationStepStatement)
 	}
 
 	if len(response.Signatures) != 1 {
-		return protocol.ErrInvalidAttestation.WithDebugf("invalid response for android-safetynet: more or less than 1 signature")
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid response for android-safetynet: more or less than 1 signature").WithStep(protocol.AttestationStepStatement)
 	}
 
-	// Verify that the attestation certificate is issued to the hostname "attest.android.com"
+	// Verify that the attestation certificate is issued to the hostname "attest.android.com",
+	// and that it chains up to the roots of Google.
 	cert, err := response.Signatures[0].Protected.Certificates(x509.VerifyOptions{
 		DNSName:     "attest.android.com",
-		CurrentTime: now(),
+		Roots:       roots,
+		CurrentTime: opts.Now(),
 	})
 	if err != nil {
-		return protocol.ErrInvalidAttestation.WithDebugf("invalid response for android-safetynet: %v", err).WithCause(err)
+		return "", nil, protocol.ErrCertChainInvalid.WithDebugf("invalid response for android-safetynet: %v", err).WithCause(err)
 	}
 	leaf := cert[0][0]
 
 	payload, err := response.Verify(leaf.PublicKey)
 	if err != nil {
-		return protocol.ErrInvalidAttestation.WithDebugf("invalid response for android-safetynet: %v", err).WithCause(err)
+		return "", nil, protocol.ErrSignatureInvalid.WithDebugf("invalid response for android-safetynet: %v", err).WithCause(err)
 	}
 
 	attestationResponse := AndroidSafetyNetAttestionResponse{}
 
 	if err := json.Unmarshal(payload, &attestationResponse); err != nil {
-		return protocol.ErrInvalidAttestation.WithDebugf("invalid response for android-safetynet: %v", err)
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid response for android-safetynet: %v", err).WithStep(protocol.AttestationStepStatement)
 	}
 
 	// Verify that the nonce in the response is identical to the SHA-256 hash of the concatenation of authenticatorData and clientDataHash.
-	nonceBytes := append(a.AuthData.Raw, clientDataHash...)
+	nonceBytes := make([]byte, 0, len(a.AuthData.Raw)+len(clientDataHash))
+	nonceBytes = append(nonceBytes, a.AuthData.Raw...)
+	nonceBytes = append(nonceBytes, clientDataHash...)
 	expectedNonce := sha256.Sum256(nonceBytes)
 
 	if !bytes.Equal(expectedNonce[:], attestationResponse.Nonce) {
-		return protocol.ErrInvalidAttestation.WithDebugf("invalid response for android-safetynet: invalid nonce")
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid response for android-safetynet: invalid nonce").WithStep(protocol.AttestationStepNonce)
 	}
 
-	// Verify that the ctsProfileMatch attribute in the payload of response is true.
-	if !attestationResponse.CtsProfileMatch {
-		return protocol.ErrInvalidAttestation.WithDebugf("invalid response for android-safetynet: does not match CTS profile")
+	// Verify that the timestampMs of the response is recent, such that it is not replayed.
+	timestamp := time.Unix(0, attestationResponse.TimestampMs*int64(time.Millisecond))
+	maxAge := opts.SafetyNetMaxResponseAge
+	if maxAge <= 0 {
+		maxAge = defaultMaxResponseAge
+	}
+	if age := opts.Now().Sub(timestamp); age > maxAge || age < -maxAge {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid response for android-safetynet: timestamp %v is not within %v", timestamp, maxAge).WithStep(protocol.AttestationStepTimestamp)
+	}
+
+	// Verify that the ctsProfileMatch attribute in the payload of response is true, or the basicIntegrity attribute if
+	// basic integrity suffices.
+	if !opts.AllowBasicIntegrity && !attestationResponse.CtsProfileMatch {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid response for android-safetynet: does not match CTS profile").WithStep(protocol.AttestationStepDeviceIntegrity)
+	}
+	if opts.AllowBasicIntegrity && !attestationResponse.BasicIntegrity {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid response for android-safetynet: does not pass basic integrity").WithStep(protocol.AttestationStepDeviceIntegrity)
 	}
 
 	// If successful, return attestation type Basic with the attestation trust path set to the above attestation certificate.
-	return nil
+	return protocol.AttestationTypeBasic, cert[0], nil
 }
@@ -1,44 +1,280 @@
 package androidsafetynet
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
 	"testing"
 	"time"
 
+	"gopkg.in/square/go-jose.v2"
+
 	"github.com/keycloud/webauthn/protocol"
 )
 
-func TestIsValidAttestation(t *testing.T) {
-	now = func() time.Time {
-		return time.Date(2018, 10, 24, 18, 39, 21, 0, time.UTC)
+// useTestRoots replaces the roots by the intermediate of the test vectors, since their root, GlobalSign Root CA - R2,
+// has expired.
+func useTestRoots(t *testing.T) func() {
+	testRoots := x509.NewCertPool()
+	if !testRoots.AppendCertsFromPEM([]byte(testRoot)) {
+		t.Fatal("unable to parse test root")
 	}
 
+	originalRoots := roots
+	roots = testRoots
+	return func() { roots = originalRoots }
+}
+
+func parseAttestation(t *testing.T, i int) (protocol.ParsedAttestationResponse, []byte) {
+	r := protocol.CredentialCreationOptions{}
+	if err := json.Unmarshal([]byte(attestationRequests[i]), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	b := protocol.AttestationResponse{}
+	if err := json.Unmarshal([]byte(attestationResponses[i]), &b); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := protocol.ParseAttestationResponse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p, r.PublicKey.Challenge
+}
+
+func TestIsValidAttestation(t *testing.T) {
+	defer useTestRoots(t)()
+	opts := protocol.AttestationVerificationOptions{CurrentTime: time.Date(2018, 10, 24, 18, 37, 41, 0, time.UTC)}
+
 	for i := range attestationRequests {
 		t.Run(fmt.Sprintf("Run %d", i), func(t *testing.T) {
-			r := protocol.CredentialCreationOptions{}
-			if err := json.Unmarshal([]byte(attestationRequests[i]), &r); err != nil {
-				t.Fatal(err)
+			p, challenge := parseAttestation(t, i)
+
+			if _, _, err := protocol.VerifyAttestationWithOptions(p, challenge, "", "", opts); err != nil {
+				e := protocol.ToWebAuthnError(err)
+				t.Fatal(fmt.Sprintf("%s, %s: %s", e.Name, e.Description, e.Debug))
 			}
+		})
+	}
+}
 
-			b := protocol.AttestationResponse{}
-			if err := json.Unmarshal([]byte(attestationResponses[i]), &b); err != nil {
-				t.Fatal(err)
+func TestVerifyBatch(t *testing.T) {
+	defer useTestRoots(t)()
+
+	responses := make([]protocol.ParsedAttestationResponse, len(attestationRequests))
+	for i := range responses {
+		responses[i], _ = parseAttestation(t, i)
+	}
+
+	for _, test := range []struct {
+		name        string
+		currentTime time.Time
+		valid       bool
+	}{
+		{"At registration", time.Date(2018, 10, 24, 18, 37, 41, 0, time.UTC), true},
+		{"Stale response", time.Date(2018, 10, 24, 18, 39, 21, 0, time.UTC), false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			results := protocol.VerifyBatch(context.Background(), responses, protocol.BatchVerificationOptions{
+				Attestation: protocol.AttestationVerificationOptions{CurrentTime: test.currentTime},
+			})
+			for i, result := range results {
+				if test.valid && result.Err != nil {
+					e := protocol.ToWebAuthnError(result.Err)
+					t.Fatalf("result %d: %s, %s: %s", i, e.Name, e.Description, e.Debug)
+				} else if !test.valid && protocol.ToWebAuthnError(result.Err).Step != protocol.AttestationStepTimestamp {
+					t.Fatalf("result %d: expected error at step %q, got %v", i, protocol.AttestationStepTimestamp, result.Err)
+				}
+				if test.valid && result.AttestationType != protocol.AttestationTypeBasic {
+					t.Fatalf("result %d: expected basic attestation, got %q", i, result.AttestationType)
+				}
 			}
+		})
+	}
+}
 
-			p, err := protocol.ParseAttestationResponse(b)
-			if err != nil {
-				t.Fatal(err)
+func TestIsValidAttestationInvalid(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		now       time.Time
+		testRoots bool
+		err       *protocol.Error
+		step      protocol.AttestationStep
+	}{
+		{"Stale response", time.Date(2018, 10, 24, 18, 39, 21, 0, time.UTC), true, protocol.ErrInvalidAttestation, protocol.AttestationStepTimestamp},
+		{"Future response", time.Date(2018, 10, 24, 18, 35, 0, 0, time.UTC), true, protocol.ErrInvalidAttestation, protocol.AttestationStepTimestamp},
+		{"Untrusted root", time.Date(2018, 10, 24, 18, 37, 41, 0, time.UTC), false, protocol.ErrCertChainInvalid, protocol.AttestationStepCertificateChain},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if test.testRoots {
+				defer useTestRoots(t)()
 			}
+			p, challenge := parseAttestation(t, 0)
 
-			d, err := protocol.IsValidAttestation(p, r.PublicKey.Challenge, "", "")
-			if err != nil {
-				e := protocol.ToWebAuthnError(err)
-				t.Fatal(fmt.Sprintf("%s, %s: %s", e.Name, e.Description, e.Debug))
+			opts := protocol.AttestationVerificationOptions{CurrentTime: test.now}
+			_, _, err := protocol.VerifyAttestationWithOptions(p, challenge, "", "", opts)
+			if err == nil || protocol.ToWebAuthnError(err).Name != test.err.Name {
+				t.Fatalf("expected %v, got %v", test.err, err)
 			}
+			if step := protocol.ToWebAuthnError(err).Step; step != test.step {
+				t.Fatalf("expected step %q, got %q", test.step, step)
+			}
+		})
+	}
+}
+
+func TestVerifyCertificateChain(t *testing.T) {
+	defer useTestRoots(t)()
+	now := time.Date(2018, 10, 24, 18, 37, 41, 0, time.UTC)
+
+	p, challenge := parseAttestation(t, 0)
 
-			if !d {
-				t.Fatal("is not valid")
+	_, trustPath, err := protocol.VerifyAttestationWithOptions(p, challenge, "", "", protocol.AttestationVerificationOptions{CurrentTime: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The certificates of android-safetynet are contained in the response rather than in x5c, so the trust path must
+	// be verified against the roots of the Relying Party
+	if _, err := protocol.VerifyCertificateChain(trustPath, roots, now); err != nil {
+		e := protocol.ToWebAuthnError(err)
+		t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+	}
+	if _, err := protocol.VerifyCertificateChain(trustPath, x509.NewCertPool(), now); !errors.Is(err, protocol.ErrCertChainInvalid) {
+		t.Fatalf("expected ErrCertChainInvalid, got %v", err)
+	}
+}
+
+func TestIsValidAttestationMaxResponseAge(t *testing.T) {
+	defer useTestRoots(t)()
+
+	p, challenge := parseAttestation(t, 0)
+
+	opts := protocol.AttestationVerificationOptions{
+		SafetyNetMaxResponseAge: 5 * time.Minute,
+		CurrentTime:             time.Date(2018, 10, 24, 18, 39, 21, 0, time.UTC),
+	}
+	if _, _, err := protocol.VerifyAttestationWithOptions(p, challenge, "", "", opts); err != nil {
+		e := protocol.ToWebAuthnError(err)
+		t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+	}
+}
+
+// newTestAttestation returns an attestation with a SafetyNet response with the given attributes, which is signed by a
+// new certificate for attest.android.com, and the client data hash that it is valid for. The roots are replaced by
+// the root of the certificate until the returned function is called.
+func newTestAttestation(t *testing.T, ctsProfileMatch, basicIntegrity bool) (protocol.Attestation, []byte, func()) {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "SafetyNet Test Root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := x509.ParseCertificate(rootDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "attest.android.com"},
+		DNSNames:     []string{"attest.android.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, root, &key.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authData := protocol.AuthenticatorData{Raw: make([]byte, 37)}
+	clientDataHash := make([]byte, 32)
+	nonce := sha256.Sum256(append(append([]byte{}, authData.Raw...), clientDataHash...))
+
+	payload, err := json.Marshal(AndroidSafetyNetAttestionResponse{
+		Nonce:           nonce[:],
+		TimestampMs:     time.Now().UnixNano() / int64(time.Millisecond),
+		CtsProfileMatch: ctsProfileMatch,
+		BasicIntegrity:  basicIntegrity,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, (&jose.SignerOptions{}).WithHeader("x5c", []string{base64.StdEncoding.EncodeToString(leafDER)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testRoots := x509.NewCertPool()
+	testRoots.AddCert(root)
+	originalRoots := roots
+	roots = testRoots
+
+	return protocol.Attestation{
+		Fmt:      "android-safetynet",
+		AuthData: authData,
+		AttStmt:  map[string]interface{}{"ver": "14366018", "response": []byte(response)},
+	}, clientDataHash, func() { roots = originalRoots }
+}
+
+func TestIsValidAttestationIntegrity(t *testing.T) {
+	for _, test := range []struct {
+		name                            string
+		requireCTSProfileMatch          bool
+		ctsProfileMatch, basicIntegrity bool
+		valid                           bool
+	}{
+		{"CTS profile match", true, true, true, true},
+		{"Basic integrity with CTS profile match required", true, false, true, false},
+		{"Basic integrity", false, false, true, true},
+		{"No integrity", false, false, false, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			a, clientDataHash, restore := newTestAttestation(t, test.ctsProfileMatch, test.basicIntegrity)
+			defer restore()
+
+			opts := protocol.AttestationVerificationOptions{AllowBasicIntegrity: !test.requireCTSProfileMatch}
+			_, _, err := verifyAndroidSafetynet(a, clientDataHash, opts)
+			if test.valid && err != nil {
+				e := protocol.ToWebAuthnError(err)
+				t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+			} else if !test.valid && !errors.Is(err, protocol.ErrInvalidAttestation) {
+				t.Fatalf("expected ErrInvalidAttestation, got %v", err)
 			}
 		})
 	}
@@ -51,3 +287,30 @@ var attestationRequests = []string{
 var attestationResponses = []string{
 	`{"id":"ARKBRFD84uLN6qG_rHsV0K2Bh9Lj3_HaJsXdC_DpPslKO6ZWmD38-hz90Lf_MzELErMa9AqR21Sr9brNzE2un1U","rawId":"ARKBRFD84uLN6qG/rHsV0K2Bh9Lj3/HaJsXdC/DpPslKO6ZWmD38+hz90Lf/MzELErMa9AqR21Sr9brNzE2un1U=","response":{"attestationObject":"o2NmbXRxYW5kcm9pZC1zYWZldHluZXRnYXR0U3RtdKJjdmVyaDE0MzY2MDE5aHJlc3BvbnNlWRS9ZXlKaGJHY2lPaUpTVXpJMU5pSXNJbmcxWXlJNld5Sk5TVWxHYTJwRFEwSkljV2RCZDBsQ1FXZEpVVkpZY205T01GcFBaRkpyUWtGQlFVRkJRVkIxYm5wQlRrSm5hM0ZvYTJsSE9YY3dRa0ZSYzBaQlJFSkRUVkZ6ZDBOUldVUldVVkZIUlhkS1ZsVjZSV1ZOUW5kSFFURlZSVU5vVFZaU01qbDJXako0YkVsR1VubGtXRTR3U1VaT2JHTnVXbkJaTWxaNlRWSk5kMFZSV1VSV1VWRkVSWGR3U0ZaR1RXZFJNRVZuVFZVNGVFMUNORmhFVkVVMFRWUkJlRTFFUVROTlZHc3dUbFp2V0VSVVJUVk5WRUYzVDFSQk0wMVVhekJPVm05M1lrUkZURTFCYTBkQk1WVkZRbWhOUTFaV1RYaEZla0ZTUW1kT1ZrSkJaMVJEYTA1b1lrZHNiV0l6U25WaFYwVjRSbXBCVlVKblRsWkNRV05VUkZVeGRtUlhOVEJaVjJ4MVNVWmFjRnBZWTNoRmVrRlNRbWRPVmtKQmIxUkRhMlIyWWpKa2MxcFRRazFVUlUxNFIzcEJXa0puVGxaQ1FVMVVSVzFHTUdSSFZucGtRelZvWW0xU2VXSXliR3RNYlU1MllsUkRRMEZUU1hkRVVWbEtTMjlhU1doMlkwNUJVVVZDUWxGQlJHZG5SVkJCUkVORFFWRnZRMmRuUlVKQlRtcFlhM293WlVzeFUwVTBiU3N2UnpWM1QyOHJXRWRUUlVOeWNXUnVPRGh6UTNCU04yWnpNVFJtU3pCU2FETmFRMWxhVEVaSWNVSnJOa0Z0V2xaM01rczVSa2N3VHpseVVsQmxVVVJKVmxKNVJUTXdVWFZ1VXpsMVowaEROR1ZuT1c5MmRrOXRLMUZrV2pKd09UTllhSHAxYmxGRmFGVlhXRU40UVVSSlJVZEtTek5UTW1GQlpucGxPVGxRVEZNeU9XaE1ZMUYxV1ZoSVJHRkROMDlhY1U1dWIzTnBUMGRwWm5NNGRqRnFhVFpJTDNob2JIUkRXbVV5YkVvck4wZDFkSHBsZUV0d2VIWndSUzkwV2xObVlsazVNRFZ4VTJ4Q2FEbG1jR293TVRWamFtNVJSbXRWYzBGVmQyMUxWa0ZWZFdWVmVqUjBTMk5HU3pSd1pYWk9UR0Y0UlVGc0swOXJhV3hOZEVsWlJHRmpSRFZ1Wld3MGVFcHBlWE0wTVROb1lXZHhWekJYYUdnMVJsQXpPV2hIYXpsRkwwSjNVVlJxWVhwVGVFZGtkbGd3YlRaNFJsbG9hQzh5VmsxNVdtcFVORXQ2VUVwRlEwRjNSVUZCWVU5RFFXeG5kMmRuU2xWTlFUUkhRVEZWWkVSM1JVSXZkMUZGUVhkSlJtOUVRVlJDWjA1V1NGTlZSVVJFUVV0Q1oyZHlRbWRGUmtKUlkwUkJWRUZOUW1kT1ZraFNUVUpCWmpoRlFXcEJRVTFDTUVkQk1WVmtSR2RSVjBKQ1VYRkNVWGRIVjI5S1FtRXhiMVJMY1hWd2J6UlhObmhVTm1veVJFRm1RbWRPVmtoVFRVVkhSRUZYWjBKVFdUQm1hSFZGVDNaUWJTdDRaMjU0YVZGSE5rUnlabEZ1T1V0NlFtdENaMmR5UW1kRlJrSlJZMEpCVVZKWlRVWlpkMHAzV1VsTGQxbENRbEZWU0UxQlIwZEhNbWd3WkVoQk5reDVPWFpaTTA1M1RHNUNjbUZUTlc1aU1qbHVUREprTUdONlJuWk5WRUZ5UW1kbmNrSm5SVVpDVVdOM1FXOVpabUZJVWpCalJHOTJURE5DY21GVE5XNWlNamx1VERKa2VtTnFTWFpTTVZKVVRWVTRlRXh0VG5sa1JFRmtRbWRPVmtoU1JVVkdha0ZWWjJoS2FHUklVbXhqTTFGMVdWYzFhMk50T1hCYVF6VnFZakl3ZDBsUldVUldVakJuUWtKdmQwZEVRVWxDWjFwdVoxRjNRa0ZuU1hkRVFWbExTM2RaUWtKQlNGZGxVVWxHUVhwQmRrSm5UbFpJVWpoRlMwUkJiVTFEVTJkSmNVRm5hR2cxYjJSSVVuZFBhVGgyV1ROS2MweHVRbkpoVXpWdVlqSTVia3d3WkZWVmVrWlFUVk0xYW1OdGQzZG5aMFZGUW1kdmNrSm5SVVZCWkZvMVFXZFJRMEpKU0RGQ1NVaDVRVkJCUVdSM1EydDFVVzFSZEVKb1dVWkpaVGRGTmt4TldqTkJTMUJFVjFsQ1VHdGlNemRxYW1RNE1FOTVRVE5qUlVGQlFVRlhXbVJFTTFCTVFVRkJSVUYzUWtsTlJWbERTVkZEVTFwRFYyVk1Tblp6YVZaWE5rTm5LMmRxTHpsM1dWUktVbnAxTkVocGNXVTBaVmswWXk5dGVYcHFaMGxvUVV4VFlta3ZWR2g2WTNweGRHbHFNMlJyTTNaaVRHTkpWek5NYkRKQ01HODNOVWRSWkdoTmFXZGlRbWRCU0ZWQlZtaFJSMjFwTDFoM2RYcFVPV1ZIT1ZKTVNTdDRNRm95ZFdKNVdrVldla0UzTlZOWlZtUmhTakJPTUVGQlFVWnRXRkU1ZWpWQlFVRkNRVTFCVW1wQ1JVRnBRbU5EZDBFNWFqZE9WRWRZVURJM09IbzBhSEl2ZFVOSWFVRkdUSGx2UTNFeVN6QXJlVXhTZDBwVlltZEpaMlk0WjBocWRuQjNNbTFDTVVWVGFuRXlUMll6UVRCQlJVRjNRMnR1UTJGRlMwWlZlVm8zWmk5UmRFbDNSRkZaU2t0dldrbG9kbU5PUVZGRlRFSlJRVVJuWjBWQ1FVazVibFJtVWt0SlYyZDBiRmRzTTNkQ1REVTFSVlJXTm10aGVuTndhRmN4ZVVGak5VUjFiVFpZVHpReGExcDZkMG8yTVhkS2JXUlNVbFF2VlhORFNYa3hTMFYwTW1Nd1JXcG5iRzVLUTBZeVpXRjNZMFZYYkV4UldUSllVRXg1Um1wclYxRk9ZbE5vUWpGcE5GY3lUbEpIZWxCb2RETnRNV0kwT1doaWMzUjFXRTAyZEZnMVEzbEZTRzVVYURoQ2IyMDBMMWRzUm1sb2VtaG5iamd4Ukd4a2IyZDZMMHN5VlhkTk5sTTJRMEl2VTBWNGEybFdabllyZW1KS01ISnFkbWM1TkVGc1pHcFZabFYzYTBrNVZrNU5ha1ZRTldVNGVXUkNNMjlNYkRabmJIQkRaVVkxWkdkbVUxZzBWVGw0TXpWdmFpOUpTV1F6VlVVdlpGQndZaTl4WjBkMmMydG1aR1Y2ZEcxVmRHVXZTMU50Y21sM1kyZFZWMWRsV0daVVlra3plbk5wYTNkYVltdHdiVkpaUzIxcVVHMW9kalJ5YkdsNlIwTkhkRGhRYmpod2NUaE5Na3RFWmk5UU0ydFdiM1F6WlRFNFVUMGlMQ0pOU1VsRlUycERRMEY2UzJkQmQwbENRV2RKVGtGbFR6QnRjVWRPYVhGdFFrcFhiRkYxUkVGT1FtZHJjV2hyYVVjNWR6QkNRVkZ6UmtGRVFrMU5VMEYzU0dkWlJGWlJVVXhGZUdSSVlrYzVhVmxYZUZSaFYyUjFTVVpLZG1JelVXZFJNRVZuVEZOQ1UwMXFSVlJOUWtWSFFURlZSVU5vVFV0U01uaDJXVzFHYzFVeWJHNWlha1ZVVFVKRlIwRXhWVVZCZUUxTFVqSjRkbGx0Um5OVk1teHVZbXBCWlVaM01IaE9la0V5VFZSVmQwMUVRWGRPUkVwaFJuY3dlVTFVUlhsTlZGVjNUVVJCZDA1RVNtRk5SVWw0UTNwQlNrSm5UbFpDUVZsVVFXeFdWRTFTTkhkSVFWbEVWbEZSUzBWNFZraGlNamx1WWtkVloxWklTakZqTTFGblZUSldlV1J0YkdwYVdFMTRSWHBCVWtKblRsWkNRVTFVUTJ0a1ZWVjVRa1JSVTBGNFZIcEZkMmRuUldsTlFUQkhRMU54UjFOSllqTkVVVVZDUVZGVlFVRTBTVUpFZDBGM1oyZEZTMEZ2U1VKQlVVUlJSMDA1UmpGSmRrNHdOWHByVVU4NUszUk9NWEJKVW5aS2VucDVUMVJJVnpWRWVrVmFhRVF5WlZCRGJuWlZRVEJSYXpJNFJtZEpRMlpMY1VNNVJXdHpRelJVTW1aWFFsbHJMMnBEWmtNelVqTldXazFrVXk5a1RqUmFTME5GVUZwU2NrRjZSSE5wUzFWRWVsSnliVUpDU2pWM2RXUm5lbTVrU1UxWlkweGxMMUpIUjBac05YbFBSRWxMWjJwRmRpOVRTa2d2VlV3clpFVmhiSFJPTVRGQ2JYTkxLMlZSYlUxR0t5dEJZM2hIVG1oeU5UbHhUUzg1YVd3M01Va3laRTQ0UmtkbVkyUmtkM1ZoWldvMFlsaG9jREJNWTFGQ1ltcDRUV05KTjBwUU1HRk5NMVEwU1N0RWMyRjRiVXRHYzJKcWVtRlVUa001ZFhwd1JteG5UMGxuTjNKU01qVjRiM2x1VlhoMk9IWk9iV3R4TjNwa1VFZElXR3Q0VjFrM2IwYzVhaXRLYTFKNVFrRkNhemRZY2twbWIzVmpRbHBGY1VaS1NsTlFhemRZUVRCTVMxY3dXVE42Tlc5Nk1rUXdZekYwU2t0M1NFRm5UVUpCUVVkcVoyZEZlazFKU1VKTWVrRlBRbWRPVmtoUk9FSkJaamhGUWtGTlEwRlpXWGRJVVZsRVZsSXdiRUpDV1hkR1FWbEpTM2RaUWtKUlZVaEJkMFZIUTBOelIwRlJWVVpDZDAxRFRVSkpSMEV4VldSRmQwVkNMM2RSU1UxQldVSkJaamhEUVZGQmQwaFJXVVJXVWpCUFFrSlpSVVpLYWxJclJ6UlJOamdyWWpkSFEyWkhTa0ZpYjA5ME9VTm1NSEpOUWpoSFFURlZaRWwzVVZsTlFtRkJSa3AyYVVJeFpHNUlRamRCWVdkaVpWZGlVMkZNWkM5alIxbFpkVTFFVlVkRFEzTkhRVkZWUmtKM1JVSkNRMnQzU25wQmJFSm5aM0pDWjBWR1FsRmpkMEZaV1ZwaFNGSXdZMFJ2ZGt3eU9XcGpNMEYxWTBkMGNFeHRaSFppTW1OMldqTk9lVTFxUVhsQ1owNVdTRkk0UlV0NlFYQk5RMlZuU21GQmFtaHBSbTlrU0ZKM1QyazRkbGt6U25OTWJrSnlZVk0xYm1JeU9XNU1NbVI2WTJwSmRsb3pUbmxOYVRWcVkyMTNkMUIzV1VSV1VqQm5Ra1JuZDA1cVFUQkNaMXB1WjFGM1FrRm5TWGRMYWtGdlFtZG5ja0puUlVaQ1VXTkRRVkpaWTJGSVVqQmpTRTAyVEhrNWQyRXlhM1ZhTWpsMlduazVlVnBZUW5aak1td3dZak5LTlV4NlFVNUNaMnR4YUd0cFJ6bDNNRUpCVVhOR1FVRlBRMEZSUlVGSGIwRXJUbTV1TnpoNU5uQlNhbVE1V0d4UlYwNWhOMGhVWjJsYUwzSXpVazVIYTIxVmJWbElVRkZ4TmxOamRHazVVRVZoYW5aM1VsUXlhVmRVU0ZGeU1ESm1aWE54VDNGQ1dUSkZWRlYzWjFwUksyeHNkRzlPUm5ab2MwODVkSFpDUTA5SllYcHdjM2RYUXpsaFNqbDRhblUwZEZkRVVVZzRUbFpWTmxsYVdpOVlkR1ZFVTBkVk9WbDZTbkZRYWxrNGNUTk5SSGh5ZW0xeFpYQkNRMlkxYnpodGR5OTNTalJoTWtjMmVIcFZjalpHWWpaVU9FMWpSRTh5TWxCTVVrdzJkVE5OTkZSNmN6TkJNazB4YWpaaWVXdEtXV2s0ZDFkSlVtUkJka3RNVjFwMUwyRjRRbFppZWxsdGNXMTNhMjAxZWt4VFJGYzFia2xCU21KRlRFTlJRMXAzVFVnMU5uUXlSSFp4YjJaNGN6WkNRbU5EUmtsYVZWTndlSFUyZURaMFpEQldOMU4yU2tORGIzTnBjbE50U1dGMGFpODVaRk5UVmtSUmFXSmxkRGh4THpkVlN6UjJORnBWVGpnd1lYUnVXbm94ZVdjOVBTSmRmUS5leUp1YjI1alpTSTZJbEJQT1U0MWVrY3pZbTlOUms1Nk5UbHFWRU01ZG1vdlp6QkxlalExTjJoRVMyOTRTREZzZURSbVlWazlJaXdpZEdsdFpYTjBZVzF3VFhNaU9qRTFOREEwTURZeU16RTBOellzSW1Gd2ExQmhZMnRoWjJWT1lXMWxJam9pWTI5dExtZHZiMmRzWlM1aGJtUnliMmxrTG1kdGN5SXNJbUZ3YTBScFoyVnpkRk5vWVRJMU5pSTZJbVZSWXl0MmVsVmpaSGd3UmxaT1RIWllTSFZIY0VRd0sxSTRNRGR6VlVWMmNDdEtaV3hsV1ZwemFVRTlJaXdpWTNSelVISnZabWxzWlUxaGRHTm9JanAwY25WbExDSmhjR3REWlhKMGFXWnBZMkYwWlVScFoyVnpkRk5vWVRJMU5pSTZXeUk0VURGelZ6QkZVRXBqYzJ4M04xVjZVbk5wV0V3Mk5IY3JUelV3UldRclVrSkpRM1JoZVRGbk1qUk5QU0pkTENKaVlYTnBZMGx1ZEdWbmNtbDBlU0k2ZEhKMVpYMC5qaFE5a3RMLVVCdEJpX2NQNXd4SGRFejJZWXNGMXBLWXpqOEZUZXdXbVVvWE5CTWJSOWRBaEdDSnJCZ2w4RGZNSzFrMUJFQXdQUzRTMWJVczBXQ3haYmN4cWJtcS12UF9OWHI1QjlDQXkxUUpxdC1tRlRMUm5EZkZ1a2hfQjdZMUxEZUJaaGYtc1E4WnBfQUlncHRnYlBWa2Z6TE1PQVpkeE5xVk91dmU0YmJTSjQ5bWQwVklBbDkwc3h1YXVUT0x5bFpxN2ZhYXRZMGFqQ1VKZkNpRUJiLVZxSUhOZmQySExhaUZwcjVxRUFPeU8tQmx1V204TmVfSWZiMnFkTVZBa2p1a1YyVmZheElLbG93a05HZ2ZycjFjVVpJNE5oVTNfeDhLTjRGclpQd29tT2ZFdmlHWk9tZFRvNnNPSXpROTJVYkx2MXlGYUw1TDZIZ1I2Z1NnX0FoYXV0aERhdGFYxSpD77HzPafHbmULkXmwl2mw9P/lQRkLyNgWFO1qQIjVRQAAAAAAAAAAAAAAAAAAAAAAAAAAAEEBEoFEUPzi4s3qob+sexXQrYGH0uPf8domxd0L8Ok+yUo7plaYPfz6HP3Qt/8zMQsSsxr0CpHbVKv1us3MTa6fVaUBAgMmIAEhWCDavINo/+JM4T1eJeKjaZ+vGa2Do7YVh2EyD0vtmoZrrCJYIOtAindNbNXogAQxBAJii2Vd1Wl5rZb9KPak8J6iTKle","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoiZDNjWTFJNm4xYXI2Z0xwREVoVGk1bkJnUDF4d0lHc2I2SE1fTlI4UEsxbyIsIm9yaWdpbiI6Imh0dHBzOlwvXC9iMzk5ZmEwMC5uZ3Jvay5pbyIsImFuZHJvaWRQYWNrYWdlTmFtZSI6ImNvbS5hbmRyb2lkLmNocm9tZSJ9"},"type":"public-key"}`,
 }
+
+// testRoot is GTS CA 1O1, the intermediate certificate of the test vectors.
+var testRoot = `-----BEGIN CERTIFICATE-----
+MIIESjCCAzKgAwIBAgINAeO0mqGNiqmBJWlQuDANBgkqhkiG9w0BAQsFADBMMSAw
+HgYDVQQLExdHbG9iYWxTaWduIFJvb3QgQ0EgLSBSMjETMBEGA1UEChMKR2xvYmFs
+U2lnbjETMBEGA1UEAxMKR2xvYmFsU2lnbjAeFw0xNzA2MTUwMDAwNDJaFw0yMTEy
+MTUwMDAwNDJaMEIxCzAJBgNVBAYTAlVTMR4wHAYDVQQKExVHb29nbGUgVHJ1c3Qg
+U2VydmljZXMxEzARBgNVBAMTCkdUUyBDQSAxTzEwggEiMA0GCSqGSIb3DQEBAQUA
+A4IBDwAwggEKAoIBAQDQGM9F1IvN05zkQO9+tN1pIRvJzzyOTHW5DzEZhD2ePCnv
+UA0Qk28FgICfKqC9EksC4T2fWBYk/jCfC3R3VZMdS/dN4ZKCEPZRrAzDsiKUDzRr
+mBBJ5wudgzndIMYcLe/RGGFl5yODIKgjEv/SJH/UL+dEaltN11BmsK+eQmMF++Ac
+xGNhr59qM/9il71I2dN8FGfcddwuaej4bXhp0LcQBbjxMcI7JP0aM3T4I+DsaxmK
+FsbjzaTNC9uzpFlgOIg7rR25xoynUxv8vNmkq7zdPGHXkxWY7oG9j+JkRyBABk7X
+rJfoucBZEqFJJSPk7XA0LKW0Y3z5oz2D0c1tJKwHAgMBAAGjggEzMIIBLzAOBgNV
+HQ8BAf8EBAMCAYYwHQYDVR0lBBYwFAYIKwYBBQUHAwEGCCsGAQUFBwMCMBIGA1Ud
+EwEB/wQIMAYBAf8CAQAwHQYDVR0OBBYEFJjR+G4Q68+b7GCfGJAboOt9Cf0rMB8G
+A1UdIwQYMBaAFJviB1dnHB7AagbeWbSaLd/cGYYuMDUGCCsGAQUFBwEBBCkwJzAl
+BggrBgEFBQcwAYYZaHR0cDovL29jc3AucGtpLmdvb2cvZ3NyMjAyBgNVHR8EKzAp
+MCegJaAjhiFodHRwOi8vY3JsLnBraS5nb29nL2dzcjIvZ3NyMi5jcmwwPwYDVR0g
+BDgwNjA0BgZngQwBAgIwKjAoBggrBgEFBQcCARYcaHR0cHM6Ly9wa2kuZ29vZy9y
+ZXBvc2l0b3J5LzANBgkqhkiG9w0BAQsFAAOCAQEAGoA+Nnn78y6pRjd9XlQWNa7H
+TgiZ/r3RNGkmUmYHPQq6Scti9PEajvwRT2iWTHQr02fesqOqBY2ETUwgZQ+lltoN
+FvhsO9tvBCOIazpswWC9aJ9xju4tWDQH8NVU6YZZ/XteDSGU9YzJqPjY8q3MDxrz
+mqepBCf5o8mw/wJ4a2G6xzUr6Fb6T8McDO22PLRL6u3M4Tzs3A2M1j6bykJYi8wW
+IRdAvKLWZu/axBVbzYmqmwkm5zLSDW5nIAJbELCQCZwMH56t2Dvqofxs6BBcCFIZ
+USpxu6x6td0V7SvJCCosirSmIatj/9dSSVDQibet8q/7UK4v4ZUN80atnZz1yg==
+-----END CERTIFICATE-----`
@@ -0,0 +1,89 @@
+// apple implements the Apple Anonymous (WebAuthn spec section 8.8) attestation statement format
+package apple
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+func init() {
+	protocol.RegisterFormat("apple", verifyApple)
+	// The attestation statement is defined to only contain x5c, but Apple devices include alg as well
+	protocol.RegisterFormatFields("apple", "x5c", "alg")
+}
+
+var extensionIDNonce = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 8, 2}
+
+// nonceExtension is the value of the nonce extension of the credential certificate, which is
+// SEQUENCE { nonce [1] OCTET STRING }.
+type nonceExtension struct {
+	Nonce []byte `asn1:"tag:1,explicit"`
+}
+
+func verifyApple(a protocol.Attestation, clientDataHash []byte) (protocol.AttestationType, []*x509.Certificate, error) {
+	// Verify that attStmt is valid CBOR conforming to the syntax defined above and perform CBOR decoding on it to
+	// extract the contained fields.
+	trustPath, err := a.Certificates()
+	if err != nil {
+		return "", nil, err
+	}
+	if len(trustPath) == 0 {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("missing x5c for apple").WithStep(protocol.AttestationStepStatement)
+	}
+	credCert := trustPath[0]
+
+	// Concatenate authenticatorData and clientDataHash to form nonceToHash, and perform SHA-256 hash of nonceToHash
+	// to produce nonce.
+	nonceToHash := make([]byte, 0, len(a.AuthData.Raw)+len(clientDataHash))
+	nonceToHash = append(nonceToHash, a.AuthData.Raw...)
+	nonceToHash = append(nonceToHash, clientDataHash...)
+	nonce := sha256.Sum256(nonceToHash)
+
+	// Verify that nonce equals the value of the extension with OID 1.2.840.113635.100.8.2 in credCert.
+	extension, err := parseNonceExtension(credCert)
+	if err != nil {
+		return "", nil, err
+	}
+	if !bytes.Equal(extension, nonce[:]) {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("nonce does not match authenticator data and client data hash for apple").WithStep(protocol.AttestationStepNonce)
+	}
+
+	// Verify that the credential public key equals the Subject Public Key of credCert.
+	if !protocol.PublicKeyEqual(credCert.PublicKey, a.AuthData.AttestedCredentialData.COSEKey) {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("certificate public key does not match credential public key for apple").WithStep(protocol.AttestationStepCredentialPublicKey)
+	}
+
+	// If successful, return implementation-specific values representing attestation type Anonymization CA and
+	// attestation trust path x5c.
+	return protocol.AttestationTypeAnonCA, trustPath, nil
+}
+
+// parseNonceExtension returns the nonce of the nonce extension of cert. The extension must be DER encoded exactly,
+// without any trailing data.
+func parseNonceExtension(cert *x509.Certificate) ([]byte, error) {
+	var value []byte
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(extensionIDNonce) {
+			value = ext.Value
+			break
+		}
+	}
+	if value == nil {
+		return nil, protocol.ErrInvalidAttestation.WithDebug("missing nonce extension for apple").WithStep(protocol.AttestationStepNonce)
+	}
+
+	var extension nonceExtension
+	if rest, err := asn1.Unmarshal(value, &extension); err != nil {
+		return nil, protocol.ErrInvalidAttestation.WithDebugf("invalid nonce extension for apple: %v", err).WithCause(err).WithStep(protocol.AttestationStepNonce)
+	} else if len(rest) != 0 {
+		return nil, protocol.ErrInvalidAttestation.WithDebug("invalid nonce extension for apple: trailing data").WithStep(protocol.AttestationStepNonce)
+	}
+	if len(extension.Nonce) != sha256.Size {
+		return nil, protocol.ErrInvalidAttestation.WithDebugf("invalid nonce extension for apple: nonce of %d bytes", len(extension.Nonce)).WithStep(protocol.AttestationStepNonce)
+	}
+	return extension.Nonce, nil
+}
@@ -0,0 +1,170 @@
+package apple_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	_ "github.com/keycloud/webauthn/attestation/apple"
+	"github.com/keycloud/webauthn/protocol"
+	"github.com/keycloud/webauthn/testutil"
+	"github.com/ugorji/go/codec"
+)
+
+func TestIsValidAttestation(t *testing.T) {
+	b := protocol.AttestationResponse{}
+	if err := json.Unmarshal([]byte(attestationResponse), &b); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := protocol.ParseAttestationResponse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge, err := base64.RawURLEncoding.DecodeString("kOwMvE2mQO6ou0B0jjD0VA")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attestationType, trustPath, err := protocol.VerifyAttestation(p, challenge, "", "https://6cc3c9e7967a.ngrok.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attestationType != protocol.AttestationTypeAnonCA {
+		t.Fatalf("expected attestation type anonca, got %s", attestationType)
+	}
+	if len(trustPath) != 2 || trustPath[1].Subject.CommonName != "Apple WebAuthn CA 1" {
+		t.Fatalf("unexpected trust path %v", trustPath)
+	}
+
+	t.Run("Wrong nonce", func(t *testing.T) {
+		clientDataHash := sha256.Sum256([]byte("other"))
+		_, _, err := p.Response.Attestation.Verify("", clientDataHash[:])
+		if e := protocol.ToWebAuthnError(err); !errors.Is(err, protocol.ErrInvalidAttestation) || e.Step != protocol.AttestationStepNonce {
+			t.Fatalf("expected ErrInvalidAttestation with nonce step, got %v", err)
+		}
+	})
+}
+
+func TestIsValidAttestationNonceExtension(t *testing.T) {
+	authenticator, err := testutil.NewVirtualAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := &protocol.CredentialCreationOptions{}
+	options.PublicKey.Challenge = []byte("challenge")
+	options.PublicKey.User.ID = []byte("user")
+	response, err := authenticator.Register("https://localhost", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var attestationObject map[string]interface{}
+	if err := codec.NewDecoderBytes(response.Response.AttestationObject, &codec.CborHandle{}).Decode(&attestationObject); err != nil {
+		t.Fatal(err)
+	}
+	toHash := append([]byte{}, attestationObject["authData"].([]byte)...)
+	clientDataHash := sha256.Sum256(response.Response.ClientDataJSON)
+	nonce := sha256.Sum256(append(toHash, clientDataHash[:]...))
+
+	marshal := func(v interface{}, params string) []byte {
+		b, err := asn1.MarshalWithParams(v, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	valid := marshal(struct {
+		Nonce []byte `asn1:"tag:1,explicit"`
+	}{nonce[:]}, "")
+
+	for _, test := range []struct {
+		name      string
+		extension []byte
+		valid     bool
+	}{
+		{"Valid", valid, true},
+		{"Missing", nil, false},
+		{"Empty", []byte{}, false},
+		{"Not a sequence", marshal(nonce[:], ""), false},
+		{"Wrong tag", marshal(struct {
+			Nonce []byte `asn1:"tag:2,explicit"`
+		}{nonce[:]}, ""), false},
+		{"Not explicitly tagged", marshal(struct {
+			Nonce []byte `asn1:"tag:1"`
+		}{nonce[:]}, ""), false},
+		{"Truncated", valid[:len(valid)-1], false},
+		{"Truncated length", valid[:3], false},
+		{"Trailing data", append(append([]byte{}, valid...), 0x00), false},
+		{"Short nonce", marshal(struct {
+			Nonce []byte `asn1:"tag:1,explicit"`
+		}{nonce[:16]}, ""), false},
+		{"Invalid length", []byte{0x30, 0x84, 0xff, 0xff, 0xff, 0xff, 0xa1, 0x00}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var extensions []pkix.Extension
+			if test.extension != nil {
+				extensions = []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 8, 2}, Value: test.extension}}
+			}
+
+			attestationObject["fmt"] = "apple"
+			attestationObject["attStmt"] = map[string]interface{}{
+				"x5c": []interface{}{newTestCertificate(t, &authenticator.PrivateKey.PublicKey, extensions)},
+			}
+			var raw []byte
+			if err := codec.NewEncoderBytes(&raw, &codec.CborHandle{}).Encode(attestationObject); err != nil {
+				t.Fatal(err)
+			}
+
+			attestation, err := protocol.ParseAttestationObject(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _, err = attestation.Verify("localhost", clientDataHash[:])
+			if test.valid {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if e := protocol.ToWebAuthnError(err); !errors.Is(err, protocol.ErrInvalidAttestation) || e.Step != protocol.AttestationStepNonce {
+				t.Fatalf("expected ErrInvalidAttestation with nonce step, got %v", err)
+			}
+		})
+	}
+}
+
+func newTestCertificate(t *testing.T, publicKey *ecdsa.PublicKey, extensions []pkix.Extension) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "Test Apple Attestation"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+
+		ExtraExtensions: extensions,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, publicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+var attestationResponse = `{"rawId":"U5cxFNxLbU9-SAi1K7k9atYwXhghkAMbxpL__VPtBlw","id":"U5cxFNxLbU9-SAi1K7k9atYwXhghkAMbxpL__VPtBlw","response":{"clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoia093TXZFMm1RTzZvdTBCMGpqRDBWQSIsIm9yaWdpbiI6Imh0dHBzOi8vNmNjM2M5ZTc5NjdhLm5ncm9rLmlvIn0","attestationObject":"o2NmbXRlYXBwbGVnYXR0U3RtdKJjYWxnJmN4NWOCWQJIMIICRDCCAcmgAwIBAgIGAXUCfWGDMAoGCCqGSM49BAMCMEgxHDAaBgNVBAMME0FwcGxlIFdlYkF1dGhuIENBIDExEzARBgNVBAoMCkFwcGxlIEluYy4xEzARBgNVBAgMCkNhbGlmb3JuaWEwHhcNMjAxMDA3MDk0NjEyWhcNMjAxMDA4MDk1NjEyWjCBkTFJMEcGA1UEAwxANjEyNzZmYzAyZDNmZThkMTZiMzNiNTU0OWQ4MTkyMzZjODE3NDZhODNmMmU5NGE2ZTRiZWUxYzcwZjgxYjViYzEaMBgGA1UECwwRQUFBIENlcnRpZmljYXRpb24xEzARBgNVBAoMCkFwcGxlIEluYy4xEzARBgNVBAgMCkNhbGlmb3JuaWEwWTATBgcqhkjOPQIBBggqhkjOPQMBBwNCAAR5_lkIu1EpyAk4t1TATSs0DvpmFbmHaYv1naTlPqPm_vsD2qEnDVgE6KthwVqsokNcfb82nXHKFcUjsABKG3W3o1UwUzAMBgNVHRMBAf8EAjAAMA4GA1UdDwEB_wQEAwIE8DAzBgkqhkiG92NkCAIEJjAkoSIEIJxgAhVAs-GYNN_jfsYkRcieGylPeSzka5QTwyMO84aBMAoGCCqGSM49BAMCA2kAMGYCMQDaHBjrI75xAF7SXzyF5zSQB_Lg9PjTdyye-w7stiqy84K6lmo8d3fIptYjLQx81bsCMQCvC8MSN-aewiaU0bMsdxRbdDerCJJj3xJb3KZwloevJ3daCmCcrZrAPYfLp2kDOshZAjgwggI0MIIBuqADAgECAhBWJVOVx6f7QOviKNgmCFO2MAoGCCqGSM49BAMDMEsxHzAdBgNVBAMMFkFwcGxlIFdlYkF1dGhuIFJvb3QgQ0ExEzARBgNVBAoMCkFwcGxlIEluYy4xEzARBgNVBAgMCkNhbGlmb3JuaWEwHhcNMjAwMzE4MTgzODAxWhcNMzAwMzEzMDAwMDAwWjBIMRwwGgYDVQQDDBNBcHBsZSBXZWJBdXRobiBDQSAxMRMwEQYDVQQKDApBcHBsZSBJbmMuMRMwEQYDVQQIDApDYWxpZm9ybmlhMHYwEAYHKoZIzj0CAQYFK4EEACIDYgAEgy6HLyYUkYECJbn1_Na7Y3i19V8_ywRbxzWZNHX9VJBE35v-GSEXZcaaHdoFCzjUUINAGkNPsk0RLVbD4c-_y5iR_sBpYIG--Wy8d8iN3a9Gpa7h3VFbWvqrk76cCyaRo2YwZDASBgNVHRMBAf8ECDAGAQH_AgEAMB8GA1UdIwQYMBaAFCbXZNnFeMJaZ9Gn3msS0Btj8cbXMB0GA1UdDgQWBBTrroLE_6GsW1HUzyRhBQC-Y713iDAOBgNVHQ8BAf8EBAMCAQYwCgYIKoZIzj0EAwMDaAAwZQIxAN2LGjSBpfrZ27TnZXuEHhRMJ7dbh2pBhsKxR1dQM3In7-VURX72SJUMYy5cSD5wwQIwLIpgRNwgH8_lm8NNKTDBSHhR2WDtanXx60rKvjjNJbiX0MgFvvDH94sHpXHG6A4HaGF1dGhEYXRhWJhWHo8_bWPQzAMKYRIrGXu__PkMUfuqHM4RH7Jea4WDgkUAAAAAAAAAAAAAAAAAAAAAAAAAAAAUomGfdaNI-cYgWrq2klNk97zkcg-lAQIDJiABIVggef5ZCLtRKcgJOLdUwE0rNA76ZhW5h2mL9Z2k5T6j5v4iWCD7A9qhJw1YBOirYcFarKJDXH2_Np1xyhXFI7AASht1tw"},"type":"public-key"}`
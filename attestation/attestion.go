@@ -2,7 +2,11 @@
 package attestation
 
 import (
+	_ "github.com/keycloud/webauthn/attestation/androidkey"
 	_ "github.com/keycloud/webauthn/attestation/androidsafetynet"
+	_ "github.com/keycloud/webauthn/attestation/apple"
 	_ "github.com/keycloud/webauthn/attestation/fido"
+	_ "github.com/keycloud/webauthn/attestation/none"
 	_ "github.com/keycloud/webauthn/attestation/packed"
+	_ "github.com/keycloud/webauthn/attestation/tpm"
 )
@@ -11,73 +11,78 @@ import (
 
 func init() {
 	protocol.RegisterFormat("fido-u2f", verifyFIDO)
+	protocol.RegisterFormatFields("fido-u2f", "sig", "x5c")
 }
 
-func verifyFIDO(a protocol.Attestation, clientDataHash []byte) error {
+func verifyFIDO(a protocol.Attestation, clientDataHash []byte) (protocol.AttestationType, []*x509.Certificate, error) {
 	rawSig, ok := a.AttStmt["sig"]
 	if !ok {
-		return protocol.ErrInvalidAttestation.WithDebug("missing sig for fido-u2f")
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("missing sig for fido-u2f").WithStep(protocol.AttestationStepStatement)
 	}
 	sig, ok := rawSig.([]byte)
 	if !ok {
-		return protocol.ErrInvalidAttestation.WithDebug("invalid sig for fido-u2f")
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("invalid sig for fido-u2f").WithStep(protocol.AttestationStepStatement)
 	}
 
 	rawX5c, ok := a.AttStmt["x5c"]
 	if !ok {
-		return protocol.ErrInvalidAttestation.WithDebug("missing x5c for fido-u2f")
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("missing x5c for fido-u2f").WithStep(protocol.AttestationStepStatement)
 	}
 	x5c, ok := rawX5c.([]interface{})
 	if !ok {
-		return protocol.ErrInvalidAttestation.WithDebug("invalid x5c for fido-u2f")
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("invalid x5c for fido-u2f").WithStep(protocol.AttestationStepStatement)
 	}
 
 	// Check that x5c has exactly one element
 	if len(x5c) != 1 {
-		return protocol.ErrInvalidAttestation.WithDebug("invalid x5c for fido-u2f")
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("invalid x5c for fido-u2f").WithStep(protocol.AttestationStepStatement)
 	}
 
 	// let attCert be that element
 	attCert, ok := x5c[0].([]byte)
 	if !ok {
-		return protocol.ErrInvalidAttestation.WithDebug("invalid x5c for fido-u2f")
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("invalid x5c for fido-u2f").WithStep(protocol.AttestationStepStatement)
 	}
 
 	// Let certificate public key be the public key conveyed by attCert
-	cert, err := x509.ParseCertificate(attCert)
+	cert, err := protocol.ParseCertificate(attCert)
 	if err != nil {
-		return protocol.ErrInvalidAttestation.WithDebugf("invalid x5c for fido-u2f: %v", err)
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid x5c for fido-u2f: %v", err).WithStep(protocol.AttestationStepCertificate)
 	}
 
 	// If certificate public key is not an Elliptic Curve (EC) public key over the P-256 curve, terminate
 	// this algorithm and return an appropriate error
-	if cert.PublicKeyAlgorithm != x509.ECDSA {
-		return protocol.ErrInvalidAttestation.WithDebug("x5c public key algorithm is invalid")
+	certPublicKey, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok || cert.PublicKeyAlgorithm != x509.ECDSA {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("x5c public key algorithm is invalid").WithStep(protocol.AttestationStepCertificate)
 	}
 
-	if cert.PublicKey.(*ecdsa.PublicKey).Curve != elliptic.P256() {
-		return protocol.ErrInvalidAttestation.WithDebug("x5c signature algorithm is invalid")
+	if certPublicKey.Curve != elliptic.P256() {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("x5c public key curve is invalid").WithStep(protocol.AttestationStepCertificate)
 	}
 
+	// The client data hash and the RP ID hash, which is the application parameter of U2F, are SHA-256 hashes
+	if len(clientDataHash) != 32 || len(a.AuthData.RPIDHash) != 32 {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("invalid hash length for fido-u2f").WithStep(protocol.AttestationStepStatement)
+	}
+
+	// U2F only supports uncompressed P-256 credential public keys
 	publicKey, ok := a.AuthData.AttestedCredentialData.COSEKey.(*ecdsa.PublicKey)
 	if !ok {
-		return protocol.ErrInvalidAttestation.WithDebug("COSE public key algorithm is invalid")
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("COSE public key algorithm is invalid").WithStep(protocol.AttestationStepCredentialPublicKey)
 	}
-
-	x := publicKey.X.Bytes()
-	y := publicKey.Y.Bytes()
-
-	if len(x) != 32 {
-		return protocol.ErrInvalidAttestation.WithDebug("COSE public key x is invalid")
+	if publicKey.Curve != elliptic.P256() {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("COSE public key curve is invalid").WithStep(protocol.AttestationStepCredentialPublicKey)
 	}
-	if len(y) != 32 {
-		return protocol.ErrInvalidAttestation.WithDebug("COSE public key y is invalid")
+	if !publicKey.Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("COSE public key is not on the curve").WithStep(protocol.AttestationStepCredentialPublicKey)
 	}
 
-	// Let publicKeyU2F be the concatenation 0x04 || x || y
-	publicKeyU2F := []byte{0x04}
-	publicKeyU2F = append(publicKeyU2F, x...)
-	publicKeyU2F = append(publicKeyU2F, y...)
+	// Let publicKeyU2F be the concatenation 0x04 || x || y, in which x and y are padded to 32 bytes
+	publicKeyU2F := elliptic.Marshal(publicKey.Curve, publicKey.X, publicKey.Y)
+	if len(publicKeyU2F) != 65 {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("COSE public key is invalid").WithStep(protocol.AttestationStepCredentialPublicKey)
+	}
 
 	// Let verificationData be the concatenation of (0x00 || rpIdHash || clientDataHash || credentialId || publicKeyU2F)
 	verificationData := []byte{0x00}
@@ -87,9 +92,13 @@ func verifyFIDO(a protocol.Attestation, clientDataHash []byte) error {
 	verificationData = append(verificationData, publicKeyU2F...)
 
 	// Verify the sig using verificationData and certificate public key per [SEC1].
+	if _, _, err := protocol.ParseECDSASignature(sig); err != nil {
+		return "", nil, protocol.ErrSignatureInvalid.WithDebugf("invalid signature for fido-u2f: %v", err).WithCause(err)
+	}
 	if err := cert.CheckSignature(x509.ECDSAWithSHA256, verificationData, sig); err != nil {
-		return protocol.ErrInvalidSignature.WithDebug(err.Error())
+		return "", nil, protocol.ErrSignatureInvalid.WithDebugf("invalid signature for fido-u2f: %v", err).WithCause(err)
 	}
 
-	return nil
+	// If successful, return attestation type Basic with the attestation trust path set to x5c.
+	return protocol.AttestationTypeBasic, []*x509.Certificate{cert}, nil
 }
@@ -1,8 +1,13 @@
 package fido_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"testing"
 
 	"github.com/keycloud/webauthn/protocol"
@@ -39,6 +44,61 @@ func TestIsValidAttestation(t *testing.T) {
 	}
 }
 
+func TestIsValidAttestationMalformed(t *testing.T) {
+	b := protocol.AttestationResponse{}
+	if err := json.Unmarshal([]byte(attestationResponses[0]), &b); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := protocol.ParseAttestationResponse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientDataHash := sha256.Sum256(b.Response.ClientDataJSON)
+
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name   string
+		modify func(a *protocol.Attestation, clientDataHash *[]byte)
+	}{
+		{"Missing sig", func(a *protocol.Attestation, _ *[]byte) { delete(a.AttStmt, "sig") }},
+		{"Invalid sig", func(a *protocol.Attestation, _ *[]byte) { a.AttStmt["sig"] = "sig" }},
+		{"Missing x5c", func(a *protocol.Attestation, _ *[]byte) { delete(a.AttStmt, "x5c") }},
+		{"Empty x5c", func(a *protocol.Attestation, _ *[]byte) { a.AttStmt["x5c"] = []interface{}{} }},
+		{"Invalid certificate", func(a *protocol.Attestation, _ *[]byte) { a.AttStmt["x5c"] = []interface{}{[]byte{0x30, 0x00}} }},
+		{"Invalid clientDataHash", func(_ *protocol.Attestation, clientDataHash *[]byte) { *clientDataHash = (*clientDataHash)[:16] }},
+		{"Missing public key", func(a *protocol.Attestation, _ *[]byte) { a.AuthData.AttestedCredentialData.COSEKey = nil }},
+		{"P-384 public key", func(a *protocol.Attestation, _ *[]byte) {
+			a.AuthData.AttestedCredentialData.COSEKey = &p384Key.PublicKey
+		}},
+		{"Public key not on curve", func(a *protocol.Attestation, _ *[]byte) {
+			a.AuthData.AttestedCredentialData.COSEKey = &ecdsa.PublicKey{Curve: elliptic.P256(), X: big.NewInt(1), Y: big.NewInt(1)}
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			a := p.Response.Attestation
+			a.AttStmt = make(map[string]interface{})
+			for k, v := range p.Response.Attestation.AttStmt {
+				a.AttStmt[k] = v
+			}
+			hash := clientDataHash[:]
+			test.modify(&a, &hash)
+
+			err := a.IsValid("", hash)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if e := protocol.ToWebAuthnError(err); e.Name != protocol.ErrInvalidAttestation.Name {
+				t.Fatalf("expected %s, got %s: %s", protocol.ErrInvalidAttestation.Name, e.Name, e.Debug)
+			}
+		})
+	}
+}
+
 var attestationRequests = []string{
 	`{"publicKey":{"rp":{"name":"accountsvc"},"user":{"id":"MTAwNjg1ODU4NDE3ODI5NDc4NA==","name":"Koen Vlaswinkel","displayName":"Koen Vlaswinkel"},"pubKeyCredParams":[{"type":"public-key","alg":-7}],"timeout":10000,"attestation":"direct","challenge":"+1jQysnwaIjNU+GrwRp4PWNBMlX0i9/caRkcKd7LPj8="}}`,
 	`{"publicKey":{"rp":{"name":"webauthn-demo"},"user":{"name":"koen","id":"a29lbg==","displayName":"koen"},"challenge":"2HzAlPIGskbn53hBJZeH3kZ6XfcHWMnzbATVG/FSgkI=","pubKeyCredParams":[{"type":"public-key","alg":-7}],"timeout":30000,"authenticatorSelection":{"requireResidentKey":false},"attestation":"direct"}}`,
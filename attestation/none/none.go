@@ -0,0 +1,25 @@
+// none implements the None (WebAuthn spec section 8.7) attestation statement format
+package none
+
+import (
+	"crypto/x509"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+func init() {
+	protocol.RegisterFormat("none", verifyNone)
+	protocol.RegisterFormatFields("none")
+}
+
+func verifyNone(a protocol.Attestation, clientDataHash []byte) (protocol.AttestationType, []*x509.Certificate, error) {
+	// The none attestation statement format is used to replace any authenticator-provided attestation statement when
+	// a WebAuthn Relying Party indicates it does not wish to receive attestation information. The attestation
+	// statement is therefore always empty.
+	if len(a.AttStmt) != 0 {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("attStmt is not empty for none")
+	}
+
+	// If successful, return attestation type None with an empty trust path.
+	return protocol.AttestationTypeNone, nil, nil
+}
@@ -0,0 +1,48 @@
+package none_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+func TestIsValidAttestation(t *testing.T) {
+	for i := range attestationRequests {
+		t.Run(fmt.Sprintf("Run %d", i), func(t *testing.T) {
+			r := protocol.CredentialCreationOptions{}
+			if err := json.Unmarshal([]byte(attestationRequests[i]), &r); err != nil {
+				t.Fatal(err)
+			}
+
+			b := protocol.AttestationResponse{}
+			if err := json.Unmarshal([]byte(attestationResponses[i]), &b); err != nil {
+				t.Fatal(err)
+			}
+
+			p, err := protocol.ParseAttestationResponse(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d, err := protocol.IsValidAttestation(p, r.PublicKey.Challenge, "", "")
+			if err != nil {
+				e := protocol.ToWebAuthnError(err)
+				t.Fatal(fmt.Sprintf("%s, %s: %s", e.Name, e.Description, e.Debug))
+			}
+
+			if !d {
+				t.Fatal("is not valid")
+			}
+		})
+	}
+}
+
+var attestationRequests = []string{
+	`{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"esRDxHxnBSivUj1bb2DqZhsJnhOOB/Ppzy7cHbu9FUA=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`,
+}
+
+var attestationResponses = []string{
+	`{"id":"ZHU1ToZO13vXawL67oTQWkBFI_W3jnHMRkPfRRrEAJk","rawId":"ZHU1ToZO13vXawL67oTQWkBFI/W3jnHMRkPfRRrEAJk=","response":{"attestationObject":"o2dhdHRTdG10oGhhdXRoRGF0YVikSZYN5YgOjGh0NBcPZHZgW4/krrmihjLHmVzzuoMdl2NFAAAAAAAAAAAAAAAAAAAAAAAAAAAAIGR1NU6GTtd712sC+u6E0FpARSP1t45xzEZD30UaxACZpSJYIOtDwmgCVQ1aXtQX7hoyr9LQq4pRaR9wd44GI//cRqdBIVggKfspD0Y1JXf9NFhZpqBrXAZTN2bUQUqN+U6HO9pF338gAQECAyZjZm10ZG5vbmU=","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoiZXNSRHhIeG5CU2l2VWoxYmIyRHFaaHNKbmhPT0JfUHB6eTdjSGJ1OUZVQSIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`,
+}
@@ -3,83 +3,124 @@ package packed
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
+	"crypto/rsa"
 	"crypto/sha256"
+	_ "crypto/sha512" // for crypto.SHA384 and crypto.SHA512
 	"crypto/x509"
 	"encoding/asn1"
-	"math/big"
+	"time"
 
+	"github.com/keycloud/webauthn/cose"
 	"github.com/keycloud/webauthn/protocol"
 )
 
 func init() {
-	protocol.RegisterFormat("packed", verifyPacked)
+	protocol.RegisterFormatWithOptions("packed", verifyPacked)
+	protocol.RegisterFormatFields("packed", "alg", "sig", "x5c", "ecdaaKeyId")
 }
 
 var extensionIDFIDOGenCAAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
 
-func verifyPacked(a protocol.Attestation, clientDataHash []byte) error {
+func verifyPacked(a protocol.Attestation, clientDataHash []byte, opts protocol.AttestationVerificationOptions) (protocol.AttestationType, []*x509.Certificate, error) {
 	rawAlg, ok := a.AttStmt["alg"]
 	if !ok {
-		return protocol.ErrInvalidAttestation.WithDebug("missing alg for packed")
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("missing alg for packed").WithStep(protocol.AttestationStepStatement)
 	}
 	algInt, ok := rawAlg.(int64)
 	if !ok {
-		return protocol.ErrInvalidAttestation.WithDebugf("invalid alg for packed, is of invalid type %T", rawAlg)
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid alg for packed, is of invalid type %T", rawAlg).WithStep(protocol.AttestationStepStatement)
 	}
 
 	alg := protocol.COSEAlgorithmIdentifier(algInt)
 
 	rawSig, ok := a.AttStmt["sig"]
 	if !ok {
-		return protocol.ErrInvalidAttestation.WithDebug("missing sig for packed")
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("missing sig for packed").WithStep(protocol.AttestationStepStatement)
 	}
 	sig, ok := rawSig.([]byte)
 	if !ok {
-		return protocol.ErrInvalidAttestation.WithDebug("invalid sig for packed")
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("invalid sig for packed").WithStep(protocol.AttestationStepStatement)
 	}
 
 	// 2. If x5c is present, this indicates that the attestation type is not ECDAA. In this case:
 	if _, ok := a.AttStmt["x5c"]; ok {
-		return verifyBasic(a, clientDataHash, alg, sig)
+		if err := verifyBasic(a, clientDataHash, alg, sig, opts); err != nil {
+			return "", nil, err
+		}
+
+		// If successful, return attestation type Basic and attestation trust path x5c.
+		trustPath, err := a.Certificates()
+		if err != nil {
+			return "", nil, err
+		}
+		return protocol.AttestationTypeBasic, trustPath, nil
 	}
 
 	// 3. If ecdaaKeyId is present, then the attestation type is ECDAA. In this case:
 	if _, ok := a.AttStmt["ecdaaKeyId"]; ok {
-		return verifyECDAA(a, clientDataHash, alg, sig)
+		return "", nil, verifyECDAA(a, clientDataHash, alg, sig)
 	}
 
 	// 4. If neither x5c nor ecdaaKeyId is present, self attestation is in use.
-	return verifySelf(a, clientDataHash, alg, sig)
+	if err := verifySelf(a, clientDataHash, alg, sig); err != nil {
+		return "", nil, err
+	}
+
+	// If successful, return implementation-specific values representing attestation type Self and an empty attestation trust path.
+	return protocol.AttestationTypeSelf, nil, nil
 }
 
-func verifyBasic(a protocol.Attestation, clientDataHash []byte, alg protocol.COSEAlgorithmIdentifier, sig []byte) error {
+func verifyBasic(a protocol.Attestation, clientDataHash []byte, alg protocol.COSEAlgorithmIdentifier, sig []byte, opts protocol.AttestationVerificationOptions) error {
 	x5c, ok := a.AttStmt["x5c"].([]interface{})
-	if !ok {
-		return protocol.ErrInvalidAttestation.WithDebug("invalid x5c for packed")
+	if !ok || len(x5c) == 0 {
+		return protocol.ErrInvalidAttestation.WithDebug("invalid x5c for packed").WithStep(protocol.AttestationStepStatement)
 	}
-
-	// let attCert be that element
-	attestnCert, ok := x5c[0].([]byte)
-	if !ok {
-		return protocol.ErrInvalidAttestation.WithDebug("invalid x5c for packed")
+	for _, rawCert := range x5c {
+		if _, ok := rawCert.([]byte); !ok {
+			return protocol.ErrInvalidAttestation.WithDebugf("invalid x5c for packed, contains element of invalid type %T", rawCert).WithStep(protocol.AttestationStepStatement)
+		}
 	}
 
+	// let attCert be the first element
+	attestnCert := x5c[0].([]byte)
+
 	// Let certificate public key be the public key conveyed by attCert
-	cert, err := x509.ParseCertificate(attestnCert)
+	cert, err := protocol.ParseCertificate(attestnCert)
 	if err != nil {
-		return protocol.ErrInvalidAttestation.WithDebugf("invalid x5c for packed: %v", err)
+		return protocol.ErrInvalidAttestation.WithDebugf("invalid x5c for packed: %v", err).WithStep(protocol.AttestationStepCertificate)
 	}
 
 	// 2.1 Verify that sig is a valid signature over the concatenation of authenticatorData and clientDataHash using
 	// the attestation public key in attestnCert with the algorithm specified in alg.
-	signedBytes := append(a.AuthData.Raw, clientDataHash...)
-	if err := cert.CheckSignature(cert.SignatureAlgorithm, signedBytes, sig); err != nil {
-		// Fallback to ECDSAWithSA256 if signature algorithm is incorret, as is the case with Yubico's keys
-		err = cert.CheckSignature(x509.ECDSAWithSHA256, signedBytes, sig)
+	signedBytes := make([]byte, 0, len(a.AuthData.Raw)+len(clientDataHash))
+	signedBytes = append(signedBytes, a.AuthData.Raw...)
+	signedBytes = append(signedBytes, clientDataHash...)
+	if _, ok := cert.PublicKey.(*ecdsa.PublicKey); ok {
+		if _, _, err := protocol.ParseECDSASignature(sig); err != nil {
+			return protocol.ErrSignatureInvalid.WithDebug(err.Error()).WithCause(err)
+		}
+	}
+	sigAlg := alg.SignatureAlgorithm()
+	if sigAlg == x509.UnknownSignatureAlgorithm && !opts.LenientSignatureAlgorithm {
+		return protocol.ErrUnsupportedAlgorithm.WithDebugf("unsupported alg %d for packed", alg)
+	}
+	if err := cert.CheckSignature(sigAlg, signedBytes, sig); err != nil {
+		if !opts.LenientSignatureAlgorithm {
+			return protocol.ErrSignatureInvalid.WithDebugf("invalid signature for packed with alg %d: %v", alg, err).WithCause(err)
+		}
+
+		// Fallback to the signature algorithm of the certificate and to ECDSAWithSHA256, as is needed for some of
+		// Yubico's keys.
+		err = cert.CheckSignature(cert.SignatureAlgorithm, signedBytes, sig)
+		if err != nil && sigAlg != x509.ECDSAWithSHA256 {
+			err = cert.CheckSignature(x509.ECDSAWithSHA256, signedBytes, sig)
+		}
 		if err != nil {
-			return protocol.ErrInvalidAttestation.WithDebugf("invalid signature for packed: %v", err)
+			return protocol.ErrSignatureInvalid.WithDebugf("invalid signature for packed: %v", err).WithCause(err)
 		}
 	}
 
@@ -87,12 +128,38 @@ func verifyBasic(a protocol.Attestation, clientDataHash []byte, alg protocol.COS
 
 	// Version MUST be set to 3 (which is indicated by an ASN.1 INTEGER with value 2).
 	if cert.Version != 3 {
-		return protocol.ErrInvalidAttestation.WithDebug("invalid version for certificate")
+		return protocol.ErrInvalidAttestation.WithDebug("invalid version for certificate").WithStep(protocol.AttestationStepCertificateVersion)
+	}
+
+	// The certificate must be valid at the time of the registration, which is not checked by CheckSignature. An
+	// expired batch certificate suggests that the authenticator model is no longer maintained or has been compromised.
+	if t := opts.Now(); t.Before(cert.NotBefore) || t.After(cert.NotAfter) {
+		return protocol.ErrCertExpired.WithDebugf("certificate is valid from %s to %s, but registration is at %s", cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339), t.Format(time.RFC3339))
 	}
 
 	// The Basic Constraints extension MUST have the CA component set to false.
 	if cert.IsCA {
-		return protocol.ErrInvalidAttestation.WithDebug("CA is set for certificate")
+		return protocol.ErrInvalidAttestation.WithDebug("CA is set for certificate").WithStep(protocol.AttestationStepCertificateCA)
+	}
+
+	// Subject-C: ISO 3166 code specifying the country where the Authenticator vendor is incorporated
+	if len(cert.Subject.Country) == 0 || len(cert.Subject.Country[0]) != 2 {
+		return protocol.ErrInvalidAttestation.WithDebug("invalid country in certificate subject").WithStep(protocol.AttestationStepCertificateSubject)
+	}
+
+	// Subject-O: Legal name of the Authenticator vendor
+	if len(cert.Subject.Organization) == 0 || cert.Subject.Organization[0] == "" {
+		return protocol.ErrInvalidAttestation.WithDebug("missing organization in certificate subject").WithStep(protocol.AttestationStepCertificateSubject)
+	}
+
+	// Subject-OU: Literal string "Authenticator Attestation"
+	if len(cert.Subject.OrganizationalUnit) != 1 || cert.Subject.OrganizationalUnit[0] != "Authenticator Attestation" {
+		return protocol.ErrInvalidAttestation.WithDebugf("invalid organizational unit %q in certificate subject", cert.Subject.OrganizationalUnit).WithStep(protocol.AttestationStepCertificateSubject)
+	}
+
+	// Subject-CN: A UTF8String of the vendor's choosing
+	if cert.Subject.CommonName == "" {
+		return protocol.ErrInvalidAttestation.WithDebug("missing common name in certificate subject").WithStep(protocol.AttestationStepCertificateSubject)
 	}
 
 	var aaguidValue []byte
@@ -104,7 +171,7 @@ func verifyBasic(a protocol.Attestation, clientDataHash []byte, alg protocol.COS
 		if ext.Id.Equal(extensionIDFIDOGenCAAAGUID) {
 			// The extension MUST NOT be marked as critical.
 			if ext.Critical {
-				return protocol.ErrInvalidAttestation.WithDebugf("extension id-fido-gen-ce-aaguid is present, but is marked as critical")
+				return protocol.ErrInvalidAttestation.WithDebugf("extension id-fido-gen-ce-aaguid is present, but is marked as critical").WithStep(protocol.AttestationStepAAGUID)
 			}
 			aaguidValue = ext.Value
 		}
@@ -117,53 +184,115 @@ func verifyBasic(a protocol.Attestation, clientDataHash []byte, alg protocol.COS
 		// be wrapped in two OCTET STRINGS to be valid
 		var aaguid []byte
 		if _, err := asn1.Unmarshal(aaguidValue, &aaguid); err != nil {
-			return protocol.ErrInvalidAttestation.WithDebugf("invalid AAGUID: %v", err)
+			return protocol.ErrInvalidAttestation.WithDebugf("invalid AAGUID: %v", err).WithStep(protocol.AttestationStepAAGUID)
 		}
 
 		if !bytes.Equal(a.AuthData.AttestedCredentialData.AAGUID, aaguid) {
-			return protocol.ErrInvalidAttestation.WithDebugf("invalid AAGUID")
+			return protocol.ErrAAGUIDMismatch.WithDebugf("invalid AAGUID")
 		}
 
 	}
 
-	// If successful, return attestation type Basic and attestation trust path x5c.
 	return nil
 }
 
 func verifyECDAA(a protocol.Attestation, clientDataHash []byte, alg protocol.COSEAlgorithmIdentifier, sig []byte) error {
-	return protocol.ErrInvalidAttestation.WithDebugf("unsupported packed format ECDAA")
+	return protocol.ErrInvalidAttestation.WithDebugf("unsupported packed format ECDAA").WithStep(protocol.AttestationStepStatement)
+}
+
+// ecdsaAlgorithms maps the supported ECDSA algorithms to their curve and hash function.
+var ecdsaAlgorithms = map[protocol.COSEAlgorithmIdentifier]struct {
+	curve elliptic.Curve
+	hash  crypto.Hash
+}{
+	protocol.ES256:  {elliptic.P256(), crypto.SHA256},
+	protocol.ES384:  {elliptic.P384(), crypto.SHA384},
+	protocol.ES512:  {elliptic.P521(), crypto.SHA512},
+	protocol.ES256K: {cose.Secp256k1(), crypto.SHA256},
+}
+
+// rsaPSSAlgorithms maps the supported RSASSA-PSS algorithms to their hash function.
+var rsaPSSAlgorithms = map[protocol.COSEAlgorithmIdentifier]crypto.Hash{
+	protocol.PS256: crypto.SHA256,
+	protocol.PS384: crypto.SHA384,
+	protocol.PS512: crypto.SHA512,
 }
 
 func verifySelf(a protocol.Attestation, clientDataHash []byte, alg protocol.COSEAlgorithmIdentifier, sig []byte) error {
 	// 4.1 Validate that alg matches the algorithm of the credentialPublicKey in authenticatorData.
+	keyAlg, err := cose.Algorithm(a.AuthData.AttestedCredentialData.CredentialPublicKey)
+	if err != nil {
+		return protocol.ErrInvalidAttestation.WithDebugf("invalid credential public key for packed: %v", err).WithStep(protocol.AttestationStepCredentialPublicKey)
+	}
+	if protocol.COSEAlgorithmIdentifier(keyAlg) != alg {
+		return protocol.ErrAlgMismatch.WithDebugf("algorithm %d does not match the credential public key algorithm %d", alg, keyAlg)
+	}
 
 	// 4.2 Verify that sig is a valid signature over the concatenation of authenticatorData and clientDataHash using
 	// the credential public key with alg.
-	signedBytes := append(a.AuthData.Raw, clientDataHash...)
+	signedBytes := make([]byte, 0, len(a.AuthData.Raw)+len(clientDataHash))
+	signedBytes = append(signedBytes, a.AuthData.Raw...)
+	signedBytes = append(signedBytes, clientDataHash...)
 
 	switch v := a.AuthData.AttestedCredentialData.COSEKey.(type) {
 	case *ecdsa.PublicKey:
-		// Right now, only EC256 is supported
-		if alg != protocol.ES256 || v.Curve != elliptic.P256() {
-			return protocol.ErrInvalidAttestation.WithDebugf("unsupported packed self attestation ECDSA key curve %s", v.Curve.Params().Name)
+		params, ok := ecdsaAlgorithms[alg]
+		if !ok || v.Curve != params.curve {
+			return protocol.ErrUnsupportedAlgorithm.WithDebugf("unsupported packed self attestation ECDSA key curve %s for algorithm %d", v.Curve.Params().Name, alg)
+		}
+
+		// 6.4.5.1 Signature Formats for Packed Attestation ES256, which also applies to ES384 and ES512
+		r, s, err := protocol.ParseECDSASignature(sig)
+		if err != nil {
+			return protocol.ErrSignatureInvalid.WithDebug(err.Error()).WithCause(err)
+		}
+
+		h := params.hash.New()
+		h.Write(signedBytes)
+		if !ecdsa.Verify(v, h.Sum(nil), r, s) {
+			return protocol.ErrSignatureInvalid.WithDebugf("invalid signature for packed")
+		}
+	case *rsa.PublicKey:
+		if hash, ok := rsaPSSAlgorithms[alg]; ok {
+			// The salt length of RSASSA-PSS signatures is equal to the length of the hash, see RFC 8230
+			h := hash.New()
+			h.Write(signedBytes)
+			if err := rsa.VerifyPSS(v, hash, h.Sum(nil), sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}); err != nil {
+				return protocol.ErrSignatureInvalid.WithDebugf("invalid signature for packed: %v", err).WithCause(err)
+			}
+			break
 		}
 
-		// 6.4.5.1 Signature Formats for Packed Attestation ES256
-		signature := make([]*big.Int, 2)
-		if rest, err := asn1.Unmarshal(sig, signature); err != nil {
-			return protocol.ErrInvalidAttestation.WithDebugf("invalid ECDSA signature: %v", err).WithCause(err)
-		} else if rest != nil {
-			return protocol.ErrInvalidAttestation.WithDebugf("invalid ECDSA signature: too much data")
+		// Besides RSASSA-PSS, only RS256 is supported
+		if alg != protocol.RS256 {
+			return protocol.ErrUnsupportedAlgorithm.WithDebugf("unsupported packed self attestation RSA algorithm %d", alg)
 		}
 
+		// 6.4.5.2 Signature Formats for Packed Attestation RS256
 		hash := sha256.Sum256(signedBytes)
-		if !ecdsa.Verify(v, hash[:], signature[0], signature[1]) {
-			return protocol.ErrInvalidAttestation.WithDebugf("invalid signature for packed")
+		if err := rsa.VerifyPKCS1v15(v, crypto.SHA256, hash[:], sig); err != nil {
+			return protocol.ErrSignatureInvalid.WithDebugf("invalid signature for packed: %v", err).WithCause(err)
+		}
+	case ed25519.PublicKey:
+		if alg != protocol.EdDSA {
+			return protocol.ErrUnsupportedAlgorithm.WithDebugf("unsupported packed self attestation EdDSA algorithm %d", alg)
+		}
+
+		// EdDSA signatures are not ASN.1 encoded, but are the raw 64-byte signature
+		if !ed25519.Verify(v, signedBytes, sig) {
+			return protocol.ErrSignatureInvalid.WithDebugf("invalid signature for packed")
+		}
+	case cose.Ed448PublicKey:
+		if alg != protocol.EdDSA {
+			return protocol.ErrUnsupportedAlgorithm.WithDebugf("unsupported packed self attestation EdDSA algorithm %d", alg)
+		}
+
+		if !v.Verify(signedBytes, sig) {
+			return protocol.ErrSignatureInvalid.WithDebugf("invalid signature for packed")
 		}
 	default:
-		return protocol.ErrInvalidAttestation.WithDebugf("unsupported packed self attestation public key type %T", a.AuthData.AttestedCredentialData.COSEKey)
+		return protocol.ErrUnsupportedAlgorithm.WithDebugf("unsupported packed self attestation public key type %T", a.AuthData.AttestedCredentialData.COSEKey)
 	}
 
-	// If successful, return implementation-specific values representing attestation type Self and an empty attestation trust path.
 	return nil
 }
@@ -1,9 +1,20 @@
 package packed_test
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
 	"testing"
+	"time"
 
 	"github.com/keycloud/webauthn/protocol"
 )
@@ -39,10 +50,333 @@ func TestIsValidAttestation(t *testing.T) {
 	}
 }
 
+func TestIsValidAttestationAlgMismatch(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		i    int
+		alg  protocol.COSEAlgorithmIdentifier
+	}{
+		{"ES256 key with ES384", 1, protocol.ES384},
+		{"RS256 key with PS256", 2, protocol.PS256},
+		{"RS256 key with ES256", 2, protocol.ES256},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			b := protocol.AttestationResponse{}
+			if err := json.Unmarshal([]byte(attestationResponses[test.i]), &b); err != nil {
+				t.Fatal(err)
+			}
+
+			p, err := protocol.ParseAttestationResponse(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			a := p.Response.Attestation
+			a.AttStmt["alg"] = int64(test.alg)
+
+			clientDataHash := sha256.Sum256(b.Response.ClientDataJSON)
+			if err := a.IsValid("", clientDataHash[:]); !errors.Is(err, protocol.ErrAlgMismatch) {
+				t.Fatalf("expected ErrAlgMismatch, got %v", err)
+			}
+		})
+	}
+}
+
+func TestIsValidAttestationMalformed(t *testing.T) {
+	b := protocol.AttestationResponse{}
+	if err := json.Unmarshal([]byte(attestationResponses[0]), &b); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := protocol.ParseAttestationResponse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientDataHash := sha256.Sum256(b.Response.ClientDataJSON)
+
+	for _, test := range []struct {
+		name    string
+		attStmt map[string]interface{}
+		step    protocol.AttestationStep
+	}{
+		{"Invalid alg", map[string]interface{}{"alg": "ES256"}, protocol.AttestationStepStatement},
+		{"Invalid sig", map[string]interface{}{"sig": "sig"}, protocol.AttestationStepStatement},
+		{"Invalid x5c", map[string]interface{}{"x5c": []byte{0x30, 0x00}}, protocol.AttestationStepStatement},
+		{"Empty x5c", map[string]interface{}{"x5c": []interface{}{}}, protocol.AttestationStepStatement},
+		{"Invalid x5c element", map[string]interface{}{"x5c": []interface{}{"certificate"}}, protocol.AttestationStepStatement},
+		{"Invalid intermediate", map[string]interface{}{"x5c": append(p.Response.Attestation.AttStmt["x5c"].([]interface{}), nil)}, protocol.AttestationStepStatement},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			a := p.Response.Attestation
+			a.AttStmt = make(map[string]interface{})
+			for k, v := range p.Response.Attestation.AttStmt {
+				a.AttStmt[k] = v
+			}
+			for k, v := range test.attStmt {
+				a.AttStmt[k] = v
+			}
+
+			err := a.IsValid("", clientDataHash[:])
+			if !errors.Is(err, protocol.ErrInvalidAttestation) {
+				t.Fatalf("expected %v, got %v", protocol.ErrInvalidAttestation, err)
+			}
+			if step := protocol.ToWebAuthnError(err).Step; step != test.step {
+				t.Fatalf("expected step %q, got %q", test.step, step)
+			}
+		})
+	}
+}
+
+func TestIsValidAttestationInvalidSubject(t *testing.T) {
+	for i, debug := range []string{
+		`invalid organizational unit [] in certificate subject`,
+		`invalid organizational unit ["Attestation"] in certificate subject`,
+		`invalid country in certificate subject`,
+		`missing organization in certificate subject`,
+		`missing common name in certificate subject`,
+	} {
+		t.Run(fmt.Sprintf("Run %d", i), func(t *testing.T) {
+			r := protocol.CredentialCreationOptions{}
+			if err := json.Unmarshal([]byte(invalidSubjectAttestationRequests[i]), &r); err != nil {
+				t.Fatal(err)
+			}
+
+			b := protocol.AttestationResponse{}
+			if err := json.Unmarshal([]byte(invalidSubjectAttestationResponses[i]), &b); err != nil {
+				t.Fatal(err)
+			}
+
+			p, err := protocol.ParseAttestationResponse(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = protocol.IsValidAttestation(p, r.PublicKey.Challenge, "", "")
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if e := protocol.ToWebAuthnError(err); e.Name != protocol.ErrInvalidAttestation.Name || e.Debug != debug {
+				t.Fatalf("expected %s: %s, got %s: %s", protocol.ErrInvalidAttestation.Name, debug, e.Name, e.Debug)
+			} else if e.Step != protocol.AttestationStepCertificateSubject {
+				t.Fatalf("expected step %q, got %q", protocol.AttestationStepCertificateSubject, e.Step)
+			}
+		})
+	}
+}
+
+func TestIsValidAttestationLenientSignatureAlgorithm(t *testing.T) {
+	// The certificate declares ECDSAWithSHA384, while alg specifies ES256
+	key, der := newTestAttestationCertificate(t, x509.ECDSAWithSHA384)
+
+	raw := make([]byte, 37)
+	raw[32] = byte(protocol.AuthenticatorDataFlagUserPresent)
+	raw = append(raw, make([]byte, 16)...)
+	clientDataHash := make([]byte, 32)
+
+	h := crypto.SHA384.New()
+	h.Write(raw)
+	h.Write(clientDataHash)
+	sig, err := key.Sign(rand.Reader, h.Sum(nil), crypto.SHA384)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := protocol.Attestation{
+		Fmt: "packed",
+		AuthData: protocol.AuthenticatorData{
+			Flags:                  protocol.AuthenticatorDataFlagUserPresent,
+			AttestedCredentialData: protocol.AttestedCredentialData{AAGUID: make([]byte, 16)},
+			Raw:                    raw,
+		},
+		AttStmt: map[string]interface{}{"alg": int64(protocol.ES256), "sig": sig, "x5c": []interface{}{der}},
+	}
+
+	if err := a.IsValid("", clientDataHash); !errors.Is(err, protocol.ErrSignatureInvalid) {
+		t.Fatalf("expected ErrSignatureInvalid, got %v", err)
+	}
+
+	opts := protocol.AttestationVerificationOptions{LenientSignatureAlgorithm: true}
+	if _, _, err := a.VerifyWithOptions("", clientDataHash, opts); err != nil {
+		e := protocol.ToWebAuthnError(err)
+		t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+	}
+}
+
+func TestIsValidAttestationCertificateValidity(t *testing.T) {
+	key, der := newTestAttestationCertificate(t, x509.ECDSAWithSHA256)
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw := make([]byte, 37)
+	raw[32] = byte(protocol.AuthenticatorDataFlagUserPresent)
+	raw = append(raw, make([]byte, 16)...)
+	clientDataHash := make([]byte, 32)
+
+	h := sha256.New()
+	h.Write(raw)
+	h.Write(clientDataHash)
+	sig, err := key.Sign(rand.Reader, h.Sum(nil), crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := protocol.Attestation{
+		Fmt: "packed",
+		AuthData: protocol.AuthenticatorData{
+			Flags:                  protocol.AuthenticatorDataFlagUserPresent,
+			AttestedCredentialData: protocol.AttestedCredentialData{AAGUID: make([]byte, 16)},
+			Raw:                    raw,
+		},
+		AttStmt: map[string]interface{}{"alg": int64(protocol.ES256), "sig": sig, "x5c": []interface{}{der}},
+	}
+
+	for _, test := range []struct {
+		name string
+		now  time.Time
+		err  error
+	}{
+		{"Valid", cert.NotBefore.Add(time.Minute), nil},
+		{"Not yet valid", cert.NotBefore.Add(-time.Minute), protocol.ErrCertExpired},
+		{"Expired", cert.NotAfter.Add(time.Minute), protocol.ErrCertExpired},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := a.VerifyWithOptions("", clientDataHash, protocol.AttestationVerificationOptions{CurrentTime: test.now})
+			if test.err == nil {
+				if err != nil {
+					e := protocol.ToWebAuthnError(err)
+					t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+				}
+				return
+			}
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+			if e := protocol.ToWebAuthnError(err); e.Step != protocol.AttestationStepCertificateValidity {
+				t.Fatalf("expected step %s, got %s", protocol.AttestationStepCertificateValidity, e.Step)
+			}
+		})
+	}
+}
+
+// newTestAttestationCertificate creates a self-signed attestation certificate that meets the packed certificate
+// requirements, of which the signature algorithm is sigAlg.
+func newTestAttestationCertificate(t *testing.T, sigAlg x509.SignatureAlgorithm) (*ecdsa.PrivateKey, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Country:            []string{"NL"},
+			Organization:       []string{"WebAuthn Test"},
+			OrganizationalUnit: []string{"Authenticator Attestation"},
+			CommonName:         "WebAuthn Test Batch",
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		SignatureAlgorithm:    sigAlg,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, der
+}
+
 var attestationRequests = []string{
 	`{"publicKey":{"rp":{"name":"webauthn-demo"},"user":{"name":"koen","id":"a29lbg==","displayName":"koen"},"challenge":"JUtlYcgpkSiFNzsThDYuOrtSVY1VeLofM+mWTRCCXqU=","pubKeyCredParams":[{"type":"public-key","alg":-7}],"timeout":30000,"authenticatorSelection":{"requireResidentKey":false},"attestation":"direct"}}`,
+	// Self attestation ES256
+	`{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"dhv/lKdwufRRdVeZE7CCwYd6cKvkhiQMHuiU/pVdOhY=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`,
+	// Self attestation RS256. The RS256 vectors are created with a software key, since no captured registration of an
+	// RSA authenticator such as Windows Hello with packed attestation is available.
+	`{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"/MPI6RNUluGwydElXUcWGeOgTckek6SSt8tfiARrRM0=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`,
+	// Basic attestation RS256
+	`{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"9NJ0KcwX+isHWut1rvx17GeLtjQ/VOAtOYaj3rQyQL8=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`,
+	// Self attestation EdDSA
+	`{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"+jIx3d/XlI1nxYScktBaWB5TzNobau77VkdxCVnV9Ws=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`,
+	// Self attestation ES384
+	`{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"pAwbJtt6qi14ZLS+QDfnuiwFv3KqFaMLQIMMwiSfUpI=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`,
+	// Self attestation PS256
+	`{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"nSahooaXP1SKTjVWBabP5ANXCMebCSRZdos4Xln2I3M=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`,
 }
 
 var attestationResponses = []string{
 	`{"id":"SNBSJTt1DHEuG9XBd6lfc4XXqxkppWfFbt4P5sRVQEPIPANIHHCmPo1AwY5pkUGcpVL3W-uHyWEn4vbgzp34Qw","rawId":"SNBSJTt1DHEuG9XBd6lfc4XXqxkppWfFbt4P5sRVQEPIPANIHHCmPo1AwY5pkUGcpVL3W+uHyWEn4vbgzp34Qw==","response":{"attestationObject":"o2NmbXRmcGFja2VkZ2F0dFN0bXSjY2FsZyZjc2lnWEcwRQIgFls/elhmdZmqEBEKafdcyvQPDrTdBRMW92v6RKJj1bACIQCZ+46sXn65dMEpPuGxvMUruV5i7XN25ctFV/iAi3wSomN4NWOBWQLCMIICvjCCAaagAwIBAgIEdIb9wjANBgkqhkiG9w0BAQsFADAuMSwwKgYDVQQDEyNZdWJpY28gVTJGIFJvb3QgQ0EgU2VyaWFsIDQ1NzIwMDYzMTAgFw0xNDA4MDEwMDAwMDBaGA8yMDUwMDkwNDAwMDAwMFowbzELMAkGA1UEBhMCU0UxEjAQBgNVBAoMCVl1YmljbyBBQjEiMCAGA1UECwwZQXV0aGVudGljYXRvciBBdHRlc3RhdGlvbjEoMCYGA1UEAwwfWXViaWNvIFUyRiBFRSBTZXJpYWwgMTk1NTAwMzg0MjBZMBMGByqGSM49AgEGCCqGSM49AwEHA0IABJVd8633JH0xde/9nMTzGk6HjrrhgQlWYVD7OIsuX2Unv1dAmqWBpQ0KxS8YRFwKE1SKE1PIpOWacE5SO8BN6+2jbDBqMCIGCSsGAQQBgsQKAgQVMS4zLjYuMS40LjEuNDE0ODIuMS4xMBMGCysGAQQBguUcAgEBBAQDAgUgMCEGCysGAQQBguUcAQEEBBIEEPigEfOMCk0VgAYXER+e3H0wDAYDVR0TAQH/BAIwADANBgkqhkiG9w0BAQsFAAOCAQEAMVxIgOaaUn44Zom9af0KqG9J655OhUVBVW+q0As6AIod3AH5bHb2aDYakeIyyBCnnGMHTJtuekbrHbXYXERIn4aKdkPSKlyGLsA/A+WEi+OAfXrNVfjhrh7iE6xzq0sg4/vVJoywe4eAJx0fS+Dl3axzTTpYl71Nc7p/NX6iCMmdik0pAuYJegBcTckE3AoYEg4K99AM/JaaKIblsbFh8+3LxnemeNf7UwOczaGGvjS6UzGVI0Odf9lKcPIwYhuTxM5CaNMXTZQ7xq4/yTfC3kPWtE4hFT34UJJflZBiLrxG4OsYxkHw/n5vKgmpspB3GfYuYTWhkDKiE8CYtyg87mhhdXRoRGF0YVjESZYN5YgOjGh0NBcPZHZgW4/krrmihjLHmVzzuoMdl2NBAAAAA/igEfOMCk0VgAYXER+e3H0AQEjQUiU7dQxxLhvVwXepX3OF16sZKaVnxW7eD+bEVUBDyDwDSBxwpj6NQMGOaZFBnKVS91vrh8lhJ+L24M6d+EOlAQIDJiABIVggLxxTguKmjCV4N5OMqd2Sl9AIxSltaPevmQxSqnyNlAciWCDEHOaQDaZ6pC2gC+Z0KS4Ln/XQiJp0X1BmTd+K+FdqSg==","clientDataJSON":"eyJjaGFsbGVuZ2UiOiJKVXRsWWNncGtTaUZOenNUaERZdU9ydFNWWTFWZUxvZk0tbVdUUkNDWHFVIiwibmV3X2tleXNfbWF5X2JlX2FkZGVkX2hlcmUiOiJkbyBub3QgY29tcGFyZSBjbGllbnREYXRhSlNPTiBhZ2FpbnN0IGEgdGVtcGxhdGUuIFNlZSBodHRwczovL2dvby5nbC95YWJQZXgiLCJvcmlnaW4iOiJodHRwOi8vbG9jYWxob3N0OjkwMDAiLCJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIn0="},"type":"public-key"}`,
+	// Self attestation ES256
+	`{"id":"A4NznfFdxYL3gitPR3bYYTZ6zr6xR7Tw3YazBjWaqq8","rawId":"A4NznfFdxYL3gitPR3bYYTZ6zr6xR7Tw3YazBjWaqq8=","response":{"attestationObject":"o2dhdHRTdG10omNhbGcmY3NpZ1hHMEUCIBNz3gP/HyFp9cl+etlEZ/hMrXW0dLuDB8P6d1y14naNAiEAta69tg11nr3+zJ4xsIYkgKNlXITElsmIAlPVVWjFCt9oYXV0aERhdGFYpEmWDeWIDoxodDQXD2R2YFuP5K65ooYyx5lc87qDHZdjRQAAAAAAAAAAAAAAAAAAAAAAAAAAACADg3Od8V3FgveCK09HdthhNnrOvrFHtPDdhrMGNZqqr6UiWCAuPOzXhYkC9VBWvGJMsWdKRLITYK7B6SPiOwuL/92zcyFYIFrj5JJpwaClBTom4Wb0xF/Lf3NbT6Lk34VW2B4JsHfEIAEBAgMmY2ZtdGZwYWNrZWQ=","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoiZGh2X2xLZHd1ZlJSZFZlWkU3Q0N3WWQ2Y0t2a2hpUU1IdWlVX3BWZE9oWSIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`,
+	// Self attestation RS256
+	`{"id":"AluIDyWKMzQi2OOhDGojF-UX3L6selLjuxlkaBHMBUE","rawId":"AluIDyWKMzQi2OOhDGojF+UX3L6selLjuxlkaBHMBUE=","response":{"attestationObject":"o2dhdHRTdG10omNhbGc5AQBjc2lnWQEAf1sZyGZ8PAk+snUCFrZYojH7bDzfdd9DTYxpsbUc5xSbdUoO30+t0YdbqxaxwvlusxCmeCUaVjbYT3pURBLhiG2EgrCYRNcxcpZ0LSOHN5fmL+MxJfwpBA9R0MeQpCE+PpAn8qhAed5ng5ZR5I4qQGhBH24V+wDzZscu7xepeBFzYrMwWXOAsgd//OmTjIh9hPvUUGbOTtHkaMM/ZufigxZ3rZTxlPUU0LRhYkrnmv8WLliUov4to1aV4DZ+eadfuvgxLzXW0kYqAKy5vFJ7UUD8CVclUxgQ53C08Sx/qopG6Plgs/Y+8izVtgEPGJPrM+7DsqAfIX4ebJXrjNG7N2hhdXRoRGF0YVkBZ0mWDeWIDoxodDQXD2R2YFuP5K65ooYyx5lc87qDHZdjRQAAAAAAAAAAAAAAAAAAAAAAAAAAACACW4gPJYozNCLY46EMaiMX5Rfcvqx6UuO7GWRoEcwFQaQhQwEAASBZAQCrIxHnVvom7Tv+izb/FnDaQ9xRY6RmuWH5AEQmSHrvxGKjdDMDRlKWvG6JaiCUOaP0mdg+zMBm0L8GOWBTJdkuKrHzWfOusDurXdm5bsbuXU2ntdeUTK2fJoSyh0lTjRnGpQyd/6lRxB8anAwFOfVQpEeOWo2BizvN9mda3BKG27nh3vmfdoSXo7aOs9/5+EUBNcxxAkDnOVm5OpDWkU4vw2yaWy8i0/1+LyF6kIUmkpUYtzaCu6L9+eySp7JH+sizjLrddv2IluIk4aph/4u8Sjn/mjJBvpMS7un0X9uPlwQTP0f3pIAjsqMWYzEOtiiAVvw+Mz2CZJg2SdmcPHGJAQMDOQEAY2ZtdGZwYWNrZWQ=","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoiX01QSTZSTlVsdUd3eWRFbFhVY1dHZU9nVGNrZWs2U1N0OHRmaUFSclJNMCIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`,
+	// Basic attestation RS256
+	`{"id":"KsXVQYnaw2m_m-wnwCNBvEqJeKFOCzLKz2lko1gJKd4","rawId":"KsXVQYnaw2m/m+wnwCNBvEqJeKFOCzLKz2lko1gJKd4=","response":{"attestationObject":"o2dhdHRTdG10o2NhbGc5AQBjc2lnWQEAFFdLNTNkJsW0hVVbIlfS4JfR3SuO+MWS7slb4rMh18CeaZbBOZzV8VkGxCuIKtKGv6BpduMFs0XwihpkppqNQzoclJUd3uY+wF6oq/G+Dhb1MUjArEYCK7nh6ByoUbSuWkKNFdvaOn8rdkP7onOqkobZ+0GFWygehC6m48vbybTSuxCaiTNr0uMjuM3sDU7x4KPkkHexrY+stvC56wSSwrDwQ9keCtT8Mse3JB6vBvvqrsxXWNXs4g23lKvZm28lu0RVQ+LLaB2k/OWoZfKBckWQ4gjbUuFVV1S6QrSm2bqJkzulTlOTWxI1f61QYHtzI/Tlwt5+h+/Uu0Tssk2URGN4NWOBWQNZMIIDVTCCAj2gAwIBAgIBAzANBgkqhkiG9w0BAQsFADBCMQswCQYDVQQGEwJOTDEWMBQGA1UEChMNV2ViQXV0aG4gVGVzdDEbMBkGA1UEAxMSV2ViQXV0aG4gVGVzdCBSb290MB4XDTIwMDEwMTAwMDAwMFoXDTQwMDEwMTAwMDAwMFowZzELMAkGA1UEBhMCTkwxFjAUBgNVBAoTDVdlYkF1dGhuIFRlc3QxIjAgBgNVBAsTGUF1dGhlbnRpY2F0b3IgQXR0ZXN0YXRpb24xHDAaBgNVBAMTE1dlYkF1dGhuIFRlc3QgQmF0Y2gwggEiMA0GCSqGSIb3DQEBAQUAA4IBDwAwggEKAoIBAQC3vxg0CLzEShnQ81UB0WtUBY6VzDDJnK1YBPS1ATE6lZsFLDcfR2UxnpRKsHg3+lZ4S3uvOMV/8C6fFTz9KYx2OXW3rnNDILmzuyKGuktVo9tBmywB4br5pY/UvHWon2cyv0Rk5gjaJc09gSCG3JYhbp40EgMDJDn/6tahAj7Ivx7y9PWnmheOhojJmkvul3lRbyHWAb9bKIe7PY5W8JXGi0Tv1wb9bV0ZhKv1FVolovzgVi0T5Lx2MEoIwy3b7bu2ZTa8cgk9qeqLIEz2c+rPYHzbLYAHSfZjCRshObKYwe4CoBDBcyP5OKe9ITAO0+FbiPyXWVt9QwfbNgeMW0KhAgMBAAGjMTAvMAwGA1UdEwEB/wQCMAAwHwYDVR0jBBgwFoAUiBAqYg6dyOCoCMIL1/GWnT29WbwwDQYJKoZIhvcNAQELBQADggEBABjzq01OaDt0FprSvikM57+TpUU8/QOpgyCZXCl4CO6/eGA0zhfWcjXjXQ0BcDHFDA5QiuPbie0WxvTi3IlOVdIbf/jyIF1eE+1w00GyzopIkUV8/Fec8gPv22q4CABEUAeawuGBYhLaPGLTvxBVVesk+E8obDwvZpB1UyikqGTuuzuM6ZLEJ6RunABEV5CRlXk+sgW7irms922aEQ0okwp8SO3UA+guaiRW8YsSQQQzHHc+8+payUJA/RrWTQdSoMFUQGPbFigNVaZZDMGWAZHomsaiPs5Ee81kul7aXiG4Rro1dXl9p6dgsfb0LXcoqNXrQmbv6etBZDQ/6qScZ79oYXV0aERhdGFYpEmWDeWIDoxodDQXD2R2YFuP5K65ooYyx5lc87qDHZdjRQAAAADuPGxbQsVr72pk+kOo4yNYACAqxdVBidrDab+b7CfAI0G8Sol4oU4LMsrPaWSjWAkp3qUiWCAiN9Sws8CMojJY53TNaZ8ls2N5n2AieO5TXjMBHquYdSFYIAtLTnWlxB9kMddGnv10JUJ80oojoIG6IpjwaZJFlZaDIAEBAgMmY2ZtdGZwYWNrZWQ=","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoiOU5KMEtjd1gtaXNIV3V0MXJ2eDE3R2VMdGpRX1ZPQXRPWWFqM3JReVFMOCIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`,
+	// Self attestation EdDSA
+	`{"id":"nt5UGe2JBToDliJJpB2o2ZsKmQLs1SBF6MWAs6VXA1A","rawId":"nt5UGe2JBToDliJJpB2o2ZsKmQLs1SBF6MWAs6VXA1A=","response":{"attestationObject":"o2dhdHRTdG10omNhbGcnY3NpZ1hAsYSeOpqXwXRg+EupJR3d5H4OQibW3S1pwxuMsv5EiFhyJRFotai2x5+POUkjeHsY8oZrTNGkaddMQ4/UTY1SDmhhdXRoRGF0YViBSZYN5YgOjGh0NBcPZHZgW4/krrmihjLHmVzzuoMdl2NFAAAAAAAAAAAAAAAAAAAAAAAAAAAAIJ7eVBntiQU6A5YiSaQdqNmbCpkC7NUgRejFgLOlVwNQpCFYIM4mxobMkiUuuO/MBMSoGhWMBZWgMrz/jz8dTUvFRgBSIAYBAQMnY2ZtdGZwYWNrZWQ=","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoiLWpJeDNkX1hsSTFueFlTY2t0QmFXQjVUek5vYmF1NzdWa2R4Q1ZuVjlXcyIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`,
+	// Self attestation ES384
+	`{"id":"o7JOxMIo7Nx7FtqJbM5WdVANLAXJWpO5lVM8NVMjkFs","rawId":"o7JOxMIo7Nx7FtqJbM5WdVANLAXJWpO5lVM8NVMjkFs=","response":{"attestationObject":"o2dhdHRTdG10omNhbGc4ImNzaWdYZjBkAjAsw5Iy+Un3I/hVEyyUmF/OYThJp9UdFc9ELtH1zJB9khyXq7qcojeyQx8+mICAxQUCMCfShe9UzqdDEHBjwAJENvuiH5YAOrNE5oxs/TcoyKgo4pzkb0eRT090E1htyh7352hhdXRoRGF0YVjFSZYN5YgOjGh0NBcPZHZgW4/krrmihjLHmVzzuoMdl2NFAAAAAAAAAAAAAAAAAAAAAAAAAAAAIKOyTsTCKOzcexbaiWzOVnVQDSwFyVqTuZVTPDVTI5BbpSJYMKDsdiGY2z0hfpvB6FQ3pMZH+e/dCI5NfetK5e83Cn2J64Vdc8Jw3qBSROa/oiO6MyFYMLl4pqtSuNGTtYXQt6x3JR4hy9pXT71CG8hEswy78u3ufm2x+tV9OCbzGrmVGw8zuSACAQIDOCJjZm10ZnBhY2tlZA==","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoicEF3Ykp0dDZxaTE0WkxTLVFEZm51aXdGdjNLcUZhTUxRSU1Nd2lTZlVwSSIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`,
+	// Self attestation PS256
+	`{"id":"0B8zWiV5fKkDgvfciiS0vIAYoMrWuzMwyXfTUTpXUFs","rawId":"0B8zWiV5fKkDgvfciiS0vIAYoMrWuzMwyXfTUTpXUFs=","response":{"attestationObject":"o2dhdHRTdG10omNhbGc4JGNzaWdZAQCkS/hwYnClJb44O32RciSV5CEnLWJJGj76lGtFHeA9izwNhtL/ylSBsHQlAhfoagEKqjUwZE70XOcVAd0JuOf5g/rsF+s0oNI892DXCcr7Of7L5K2eHOTpFIXZhnLVUef/uv13EgQNtEDnTtEWp1o1ON03Jy90dGYo6eaVz9bfRhRfYdn5BvK3o+HqiQ3AkxFVufOULuAmkdfhBM3jNbkqxmUC5gPGTOqxnnk14Y8kmArxorPnJwPQtwm9c4Wc0LMDxAgG76DLzaf3fBqircPHYQJ44IH6cWnlFnYcoKRA3EkenmgB4F0m7Kqucl5vyMIKaCMBIDmMwUwUHPpxLKDyaGF1dGhEYXRhWQFmSZYN5YgOjGh0NBcPZHZgW4/krrmihjLHmVzzuoMdl2NFAAAAAAAAAAAAAAAAAAAAAAAAAAAAINAfM1oleXypA4L33IoktLyAGKDK1rszMMl301E6V1BbpCFDAQABIFkBALOej4ALNhg733PfqF1Y/6BAKytqlP99DQycXP4f1EdvjMiqmYqfUdEqgIMvisouGQo/0RxLzuS+f3tSm8p6GB2X06RGhCVO05vAaqvKjBkjZbPj+V8GW7hpum4PhGV+9QnlTg9XnP6zloWADg85EJHd/CSjwYNBikbsROuNXYFZyUsD+DmXZt8zjY3VhgG89xA54+mWjrf2FMp0w+TAWEi7oAMH1VFAVaY0rjL+OWXQdWqBWS9BX9lKGWIOMNnlIu+NM+i0P/cvhR7XK7LjV8hLOoSJ2pfvlvRiGnTACkmpaX1l9XbHX1PRE1DJDv5M6NlzN9jkZxc2Ey6ISuJXSXEBAwM4JGNmbXRmcGFja2Vk","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoiblNhaG9vYVhQMVNLVGpWV0JhYlA1QU5YQ01lYkNTUlpkb3M0WGxuMkkzTSIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`,
+}
+
+var invalidSubjectAttestationRequests = []string{
+	// Missing Subject-OU
+	`{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"ib2o/Ksi4xiN61vx5wyIasO8toXzV4OdrZSqmiSuL+c=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`,
+	// Invalid Subject-OU
+	`{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"zG4uJ8aIvFoQQ7+tMjYwx3jykHE3J7WyymKIfM9BIsM=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`,
+	// Missing Subject-C
+	`{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"0i2X7PoZefo4lHDqK/iGjm4AprYXHtDtqSf2vso5YM4=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`,
+	// Missing Subject-O
+	`{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"oNzWsfSCN3Z6cw7iim/u5ti3tQDasELSPfzNlylgHmE=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`,
+	// Missing Subject-CN
+	`{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"BsclKSsfS5uWARGyC0oWlP9zOZco/xu1ejiimZGYkEI=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`,
+}
+
+var invalidSubjectAttestationResponses = []string{
+	// Missing Subject-OU
+	`{"id":"gVQ_BBMoBrSuvS5ohj8-aqNdrVgVQvIBLDK6zZxjO6Q","rawId":"gVQ/BBMoBrSuvS5ohj8+aqNdrVgVQvIBLDK6zZxjO6Q=","response":{"attestationObject":"o2dhdHRTdG10o2NhbGc5AQBjc2lnWQEAC3G8FVVUyQjFfaeEUPcPWhtZ1T80nNMuEPCxYl5Os4qn1CCjIuP2XBHsZ2e5RQUizCu6vECDaHGfK3DWBnEy1GfPWxsz+R5hWqF19YQN5URr4LX2o5xHoQDmr12yOzxjHCj+SYl2m1cVh+fXaw9IHFONfRTrUyQIFNhsxBSMmwJIhrH9PE1hSpuZXlNI1AvU3lRE7aDFDfqjBGqRhGfdrI9UEdDYHfTmhj7bfLMBZFNPkGd2PI0hna4qINaxoKDtA/UZYBH2xyHw+F+oL/AKSb+jik7mcsqS1Kd0u4EmFJ8PCvrG7A1nRYyIObs8XyC6nVLh1fhCJIMT2hJxrgypymN4NWOBWQM1MIIDMTCCAhmgAwIBAgIBAzANBgkqhkiG9w0BAQsFADBCMQswCQYDVQQGEwJOTDEWMBQGA1UEChMNV2ViQXV0aG4gVGVzdDEbMBkGA1UEAxMSV2ViQXV0aG4gVGVzdCBSb290MB4XDTIwMDEwMTAwMDAwMFoXDTQwMDEwMTAwMDAwMFowQzELMAkGA1UEBhMCTkwxFjAUBgNVBAoTDVdlYkF1dGhuIFRlc3QxHDAaBgNVBAMTE1dlYkF1dGhuIFRlc3QgQmF0Y2gwggEiMA0GCSqGSIb3DQEBAQUAA4IBDwAwggEKAoIBAQDeJkLj6oMSGvB3d8ZWMxRBYu0UxDsCms1ZG25W+gkaHgRmO9/iMZFTx9ST+SsdZ5lFJOon0vmD1LV9n4pPRjTVP3yYhhVFokPAN2ig6nY3VCiUyXfVkPMCg6ctZPnLYKvWve4QyXPEbL8zIePVusn19n3YWCNN120GUjTpAQoxPdUrLkxtq/4umE9dZneMGjsV991qBb7pXlfsg02Y1OCMrh3FVzO4bVP+S3Fu6H4lysmaJ/19cgtg+inltNmxS/reIhCLU84R2KpbMikOdeSm4B3C0ROoVy6Hb6Hg6E9YfYwU7Ik4sXc6sUMCaCA2TrIPrPdf99uhv8SFuwpFuT+ZAgMBAAGjMTAvMAwGA1UdEwEB/wQCMAAwHwYDVR0jBBgwFoAUoHLwBOjnlul4X2KxJsFPRNupBCAwDQYJKoZIhvcNAQELBQADggEBAIrF1XXQrBiAPz01BnXe6CXpD/drkq3orAeEP5ITweBcqXAJPangLmr4V9QT6ejZJkPU60WskO+pUOpD1SJLYdcJ/UXyvtWEtSi1ogUv7U8idFPBuODAu3XXltA9j8v35oqcjYkvseWhv5JVtKLcTXXUffY8p3u+wV15DbupSVctEPg+MCdjgZMmjlcFw1k+gFD5GJ4KQenLg57pzFw+SzgCT5QuhjNNDAclR0VZaMwsmtKf2No10F9hibuZXE5jgMkSyeLd8irKm46uvI2Z0JJ9++l/nTpDoY7lRx+6HvMyuFkhw6F+m1qK0Bf2y5R8XI4dOGHvEL3kYVKFrgvjS9NoYXV0aERhdGFYpEmWDeWIDoxodDQXD2R2YFuP5K65ooYyx5lc87qDHZdjRQAAAADrYJoEJp43UqyLLznBvGKqACCBVD8EEygGtK69LmiGPz5qo12tWBVC8gEsMrrNnGM7pKUiWCBDIKI5oNiK255BasJWpD6xzfn5M+/3L3yRc2fH3VWJIyFYIC7vFsKQvIt406Fle3N0XYx+SJx6fn+cny/vMifSw7eIIAEBAgMmY2ZtdGZwYWNrZWQ=","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoiaWIyb19Lc2k0eGlONjF2eDV3eUlhc084dG9YelY0T2RyWlNxbWlTdUwtYyIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`,
+	// Invalid Subject-OU
+	`{"id":"Uzm52F3p-IzFw5GSWZwEEN8gucUyl0k3C8T9U1SAwDs","rawId":"Uzm52F3p+IzFw5GSWZwEEN8gucUyl0k3C8T9U1SAwDs=","response":{"attestationObject":"o2dhdHRTdG10o2NhbGc5AQBjc2lnWQEARBMIxLV+aroG+M2hW3hjf/c2vY6PBU7GryxzHhVXEt7eUwfahBrlHLUFQZe/qoUKPrpGcNduQGvKS5cnsQBaDt8AIh9HZCLFMCQYEOMBRtmp9EpLiDnX/5yqaviORSQEBnsYq3+C4YjAd6Nev8PrQ+pfg3UXHuN9+/LdYTrzZzpv8xGINr92ttFbM5lHBJ9fxgGUMdVSAT9JaBKglAjEv3oBzHwTlreZLsIiI0RsVF8oVAmdVw8fu+8m+5hpzccKNlMyNyjdPsM6bOwaUHx7YETSfOn7T6KVgcqto5Z8t1FeSZFDWpgsoTu9vnsHq75B0DHamVj389N+7kl5wICcgGN4NWOBWQNLMIIDRzCCAi+gAwIBAgIBAzANBgkqhkiG9w0BAQsFADBCMQswCQYDVQQGEwJOTDEWMBQGA1UEChMNV2ViQXV0aG4gVGVzdDEbMBkGA1UEAxMSV2ViQXV0aG4gVGVzdCBSb290MB4XDTIwMDEwMTAwMDAwMFoXDTQwMDEwMTAwMDAwMFowWTELMAkGA1UEBhMCTkwxFjAUBgNVBAoTDVdlYkF1dGhuIFRlc3QxFDASBgNVBAsTC0F0dGVzdGF0aW9uMRwwGgYDVQQDExNXZWJBdXRobiBUZXN0IEJhdGNoMIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAvpGl5hQRwCwq8qpz9Ze1hIuV4P8TiRrlRKIlBnqeoCzcM7qaZ2pVSTjLDD0V0pCP6SBBXVBzlZr4iljAdVm/yhe86HFbln4J6ypQKvPbvQYBseDXmt71UCVLLC2wM6nheLUCwPLi52gAR5AuZs8NfHicRvhvuaDIYZvO5ZObahn1r3yc+QnILLcVco/S1aB6QaR2ejrACkxK/5eeLHOuj+aGk1JIMplxem6VZ+x2sQ3TGlB9T+b3zxUAiiiRdCJ4wTEoMCXM1wCUg9i8XQkn6SFvKNttW8KIMgbPaEhUYk4wLqlvlAZIPUv64BJW/lmRJeVI2bm87YgrIdRPxt88SQIDAQABozEwLzAMBgNVHRMBAf8EAjAAMB8GA1UdIwQYMBaAFO17XxZ6F8rpF98p8BEzouGY4V8PMA0GCSqGSIb3DQEBCwUAA4IBAQA/yj6WnVzx5GEn2JRqRCJM3PyIYeVWkqvsbmj+M98kvV3Cj7roHQFf1WOL6QFhYcSl5pNn+hx8swjZi/eB8vx+X/cRMwD9N8gK+3nSRedANdLUnBlSd/j+zYVOUiPj7QbOqAGlUccIByjtjVEU4ueD0EpH+ZwGezeO78dW3ybCJNQl+fiecbBkWXMcprfyMiqXDMbUieX0zQ1ganjYKH96YKjBJg2oVWGZ71QAMc9rhTAY8fHXzvu0jkWmPyuh0mgP694cRCB0QS8mcGivgwl60qE5c1KyNIMK4RjsJc7lr9LxOJzQ1vTdhQm5FTO10IBehWy7sf/uaGiONl2WWSaHaGF1dGhEYXRhWKRJlg3liA6MaHQ0Fw9kdmBbj+SuuaKGMseZXPO6gx2XY0UAAAAA2KJoMOekJdUkuQoZGU2+igAgUzm52F3p+IzFw5GSWZwEEN8gucUyl0k3C8T9U1SAwDulIlggqJj5aZ5OmUKTqG2ApBf0DUF+DAZy54cjv1VUPVWDk98hWCBcJhQZ5Vztx6iyaSed39r5FY3nwBlAPc8uUBy/xvQ2QCABAQIDJmNmbXRmcGFja2Vk","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoiekc0dUo4YUl2Rm9RUTctdE1qWXd4M2p5a0hFM0o3V3l5bUtJZk05QklzTSIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`,
+	// Missing Subject-C
+	`{"id":"v8zWmI7qv3nZlIM4NZR_mgJ0e7zpUD6MA8_5s0y02P4","rawId":"v8zWmI7qv3nZlIM4NZR/mgJ0e7zpUD6MA8/5s0y02P4=","response":{"attestationObject":"o2dhdHRTdG10o2NhbGc5AQBjc2lnWQEAqhxUaYcMsyrNnGkrcbfiuWIwRG3kmSOzjEXZjx7sVy04EWbcx97sdduNQ3VhbbiIBvdRa4PrQ/qiDuTGcf9Z2pf8CZrphmy4CLOGJa1D9AovBR5zDceITL0fWkGhTULrdGJmopftF/nxw2Lvj0faU3pgpuQsmY1NT6iv9HIzLxYHYeK/PcZ8tKdMDazQphTKXMr37JLTRstkVAnS5j5knAQqlvUasTEOUtDmNhHbnWszemVSSOiaNVh+AD5YT7ydOrFX/+/z0U1awltwySJJ5wp2AzYX33SgpeOxT2Ci7A0HAUdJ+MS6lXDaqZoer6GcCkabVi6FL8wdl34r4Ulhb2N4NWOBWQNMMIIDSDCCAjCgAwIBAgIBAzANBgkqhkiG9w0BAQsFADBCMQswCQYDVQQGEwJOTDEWMBQGA1UEChMNV2ViQXV0aG4gVGVzdDEbMBkGA1UEAxMSV2ViQXV0aG4gVGVzdCBSb290MB4XDTIwMDEwMTAwMDAwMFoXDTQwMDEwMTAwMDAwMFowWjEWMBQGA1UEChMNV2ViQXV0aG4gVGVzdDEiMCAGA1UECxMZQXV0aGVudGljYXRvciBBdHRlc3RhdGlvbjEcMBoGA1UEAxMTV2ViQXV0aG4gVGVzdCBCYXRjaDCCASIwDQYJKoZIhvcNAQEBBQADggEPADCCAQoCggEBAL9tsbEk2lVro11BrDVAxHrMVx4ALLsmMS5LcktbfauoA9DeaKWxvid4+5LtGnVQHponbBkGVWwWhrL1A07SsF2Rr8MrO5BdvsobfREtwnL0nn/dGsHgPKbWhdxbZJO6IaqY6QggBLVVQVu8UOaGO7wBQa8nSTYEsDsnhqaC9JipL7XJkZcJqZvKax4GBPAukJQHa1utXnqypFuRtQ6rsDU0VwiK2cK5VLKo79TfXvRHMEzyIq5DeldbWXhWWbGzT/oxPKxNDpQiVTcIbC03dJpBcR2Dblg4OeMTpSExGexmoTdIGOw/YP4Rq/7Iuy7xY3qIhpJS/qw37vARD0kPP5kCAwEAAaMxMC8wDAYDVR0TAQH/BAIwADAfBgNVHSMEGDAWgBRt9UU9cA6CQKnb4qfKKPd3doABoDANBgkqhkiG9w0BAQsFAAOCAQEAznC/Df/U+c30a20/UjIND3lEowdAxaIjmHBLYBHQ506NW/qRP7GmXpI4yPbuGLT8yk92Jpn3nFEaKTe8QawrO4cKafSSphEzk+zNMb3NSuH/NbwZ2n6J6E1OCtK65uk92KX9qu1wDW9igwd17trdrldE7l5MpYBUS/2LJN9Hg5BT8oML6vJZ72+lrxj+08g2MIOAiGi6XDHntZt5GlX94D9MYWSVYSrpQDFE6kZn8388xklEo4hNN6ZWKkhLi8TH9EPVpkHIe/ktLAw3pbJRaixToGSjPQ66eL91jqVUaHLEC7L4nhWZ3QcOBxme7KwkKbZBAodNTAyjRPXgNBmn92hhdXRoRGF0YVikSZYN5YgOjGh0NBcPZHZgW4/krrmihjLHmVzzuoMdl2NFAAAAACLz0SR8QpxL3jVetxL3hckAIL/M1piO6r952ZSDODWUf5oCdHu86VA+jAPP+bNMtNj+pSJYIJ/2/JQ95/c/EUgXFNEi+uEm0iRr2lO+VtGVf5dmqQmVIVggZ8Btbsl2vDQ9LQQLx6hnYeRSzuT2XmJiD05iR9IIOM0gAQECAyZjZm10ZnBhY2tlZA==","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoiMGkyWDdQb1plZm80bEhEcUtfaUdqbTRBcHJZWEh0RHRxU2YydnNvNVlNNCIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`,
+	// Missing Subject-O
+	`{"id":"cZPzX6mMBD28bskJBQD9cVBJmk_dVY_Q7jhIhOsAiZA","rawId":"cZPzX6mMBD28bskJBQD9cVBJmk/dVY/Q7jhIhOsAiZA=","response":{"attestationObject":"o2dhdHRTdG10o2NhbGc5AQBjc2lnWQEAEIWWioSCGxcohpOpQ8Cj1G5hIh0vcYls+sxF8LFyYLR/t88IoZE1OC2LvWRuDDl2+NATHLNBqqw7mhz4gam9AoxE2doWSmOZrLTJk0d2OQVfrEr9jHl+amWtw23xuZstBc3wmzWTJAfDwnhsj6eKlIbP5EtKXYH+rj2Y4NH4yIjgmW35PX4YN7KG7hjkkTvImmN41mM+SpT63RPVBXTySxotXIVg8q+cEtoxDAgeLWAkKju37Yaz3/pSKW6O4Ppi0lBH1WlMTLzAZKpBK4VFuPmUOMNOKAXuycciItWdzq1BseFtb1wZp/3yRG3LjxRMELXDQPjGc3efTphnGFTG5WN4NWOBWQNBMIIDPTCCAiWgAwIBAgIBAzANBgkqhkiG9w0BAQsFADBCMQswCQYDVQQGEwJOTDEWMBQGA1UEChMNV2ViQXV0aG4gVGVzdDEbMBkGA1UEAxMSV2ViQXV0aG4gVGVzdCBSb290MB4XDTIwMDEwMTAwMDAwMFoXDTQwMDEwMTAwMDAwMFowTzELMAkGA1UEBhMCTkwxIjAgBgNVBAsTGUF1dGhlbnRpY2F0b3IgQXR0ZXN0YXRpb24xHDAaBgNVBAMTE1dlYkF1dGhuIFRlc3QgQmF0Y2gwggEiMA0GCSqGSIb3DQEBAQUAA4IBDwAwggEKAoIBAQDun7VXOPliflueUBqVePMO6d8BU14WmEd6tshpH0mIPCIP4hxS4wmSVHVw72gIYoY09twsMDlGKV5XM4OIgREIti74vxcHdyyyalviXrqAmXIK8CVxV7+SyqPKxqP4I8LY334l7JgPHxKx6t37jrz3DTbDzsHB3O3F2phAHW5qDihnrDlcVL7RvwMMNn5W3K/sOl4shKNY1vJnvYb9DOQIPdJLC2JmqAovHYZ9JTjgRuD4gS3k19Xq3U4JK7hprJUQshycw52CtpwT0WloiGZIo6mEMbbEc7bgo3clMggozTsUOvhlq9hDf2kHB7z9s7demqa6XyktR5KJ2YGTeHG5AgMBAAGjMTAvMAwGA1UdEwEB/wQCMAAwHwYDVR0jBBgwFoAUTfV/QkfRYMl/QMv/B/uc3eGy4CMwDQYJKoZIhvcNAQELBQADggEBAL7b4OV3HPFrpXo6gjXqYDkn4A3LfFcS6L7qpCkEK+xd2L5XRKxzjdPEkw75hx2qZSc80DYfWdbg7CeaiurwEOL4CT+L2Vu7CD+NqEW6whT7bDJ/iGNHZPii0zbhcE/Z5PfQo8xiOBCzaCKE5CSqfF40vrp4KJ/X0ZkGLDCt6isLcm6QBY/vo+3wXGGYq2C8reYQMHDSRRzW4cc/ci9JpvYzFozt9y6gnLpzHbok48iXCwwOifyKTDAnR5FhbWOECKfMjEf9bGTPvqLYiA78oPXfLUyIHDgfqxuTZtcHRMExpU2/Af/FQNlpyhZwJqPRBnjRnG4LVlEOa+DPsW5DNQpoYXV0aERhdGFYpEmWDeWIDoxodDQXD2R2YFuP5K65ooYyx5lc87qDHZdjRQAAAADQuRef5HaQ7dmAHXzgIrrfACBxk/NfqYwEPbxuyQkFAP1xUEmaT91Vj9DuOEiE6wCJkKUiWCAHC0ii4HGwM88rt0GXw44NQpr0utxsuuwwfuIo1J8geiFYIMH0CAHq2ksHmXOm4c9R4nwmXoP6pVBdPan2sCs+tuFiIAEBAgMmY2ZtdGZwYWNrZWQ=","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoib056V3NmU0NOM1o2Y3c3aWltX3U1dGkzdFFEYXNFTFNQZnpObHlsZ0htRSIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`,
+	// Missing Subject-CN
+	`{"id":"cq5A-Z1STfxZ4yXVidt89wqmeKdYZ9WFrUbCt_0ILgM","rawId":"cq5A+Z1STfxZ4yXVidt89wqmeKdYZ9WFrUbCt/0ILgM=","response":{"attestationObject":"o2dhdHRTdG10o2NhbGc5AQBjc2lnWQEANgkijHd8OZNcFmG2grj9kPv2+4pXeQ4qQ72pYql0AUhWd3nVnc7qrXz/rYtXkEUd+JWeBk57qWOvAaxhRtVR5j0ZTCKdZ45YWAiBoBdvKkTqA0TiCNebJhgj/8PLeS+MXXukaAL1+uRhRWbDgNHP1SA+JQshGV1r5i4CgujXhi9MJip5Dj76Us/Ma1aOS7sRbIFNeMarP7NqGWakqIKr63FiCLV07glQHvEhcLIVH+Lu1RD6iKz2p9pbk01LjDYDUFAXfozbaUZAWhUBruKrvui6MXesrQGCsUy08XsQEUGt1nmEI7SiakvVW2X3uCNX/ZNaLgYJy9PCrKOlVbdx+mN4NWOBWQM7MIIDNzCCAh+gAwIBAgIBAzANBgkqhkiG9w0BAQsFADBCMQswCQYDVQQGEwJOTDEWMBQGA1UEChMNV2ViQXV0aG4gVGVzdDEbMBkGA1UEAxMSV2ViQXV0aG4gVGVzdCBSb290MB4XDTIwMDEwMTAwMDAwMFoXDTQwMDEwMTAwMDAwMFowSTELMAkGA1UEBhMCTkwxFjAUBgNVBAoTDVdlYkF1dGhuIFRlc3QxIjAgBgNVBAsTGUF1dGhlbnRpY2F0b3IgQXR0ZXN0YXRpb24wggEiMA0GCSqGSIb3DQEBAQUAA4IBDwAwggEKAoIBAQCtgfNuKJGVCwkcjTrK03gOip9eNulBil41I7McFIu9T8ZhRWCIl/LW4SxQBA50ux2K9V/aW6M3CnFJ23z9iKe7RfjZoPP8fEZ4rgC8tfHTzd3R1/BGhKfJHD8tRRBDrw/MmQIDXuZlg+6m3IIIiIvVpq4uCegj31t7aGjxgfOhVSp8c219jQ8ZGFWCVuEkER1udtwdo56+gVg+AtNnP37wgn2FqzLXj5ybV0zZdpUtllsYyEja6XFQOM58y3Oz8+/Pd1lMWIwOsJSRUFnxnB1SuNq62mieuWwMzZqUW6sQHqg0koJrItmjWNClsJvoTMYwTnFNczQTevsUunFapb+RAgMBAAGjMTAvMAwGA1UdEwEB/wQCMAAwHwYDVR0jBBgwFoAUaQATm4hu2W60d6ySg8SsudDWrtkwDQYJKoZIhvcNAQELBQADggEBAHqUIfDFEouJHL+hg1D4rHHYT6k442VSQgnMdni1hfOd4Xf1p3HuI+RumzjOsLsozARfKQa34tE656dpfPNsjLxqxbZ/EjJxC/ec51kEPImVKF4ndmpOxuN9wx9E4jL3usx1IzsCt9d9eiI4zMf51I/fjkn2pvinIxzuGRJttpC1RpGlImhchr7OzmyHi+K8Cg7gRXfCBD1+bLVU1KCQ2rYDWPrSVJs5wJeCAzUe6BTW8BAqH02Y9dCsKHLNVreUV5DZ1dSULkcYI28mIf7iwR512L4O4zk081C8LAdRFEoER6WJNxlZyIviqZnwFl0MomJLpuqJouOooYlImdvm47toYXV0aERhdGFYpEmWDeWIDoxodDQXD2R2YFuP5K65ooYyx5lc87qDHZdjRQAAAADUGztmkij/IN2GtMpqmB4bACByrkD5nVJN/FnjJdWJ23z3CqZ4p1hn1YWtRsK3/QguA6UiWCCFvYhHwvJlsTNeWT2XvxRoKsits1Nf9IpVEFyYuUp+VCFYIDT+vM5mFKeUCvxfUHohoYhFCQOMeg4qBokMW3DEchKnIAEBAgMmY2ZtdGZwYWNrZWQ=","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoiQnNjbEtTc2ZTNXVXQVJHeUMwb1dsUDl6T1pjb194dTFlamlpbVpHWWtFSSIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`,
+}
+
+func TestIsValidAttestationAuthDataCapacity(t *testing.T) {
+	for i := range attestationRequests {
+		t.Run(fmt.Sprintf("Run %d", i), func(t *testing.T) {
+			r := protocol.CredentialCreationOptions{}
+			if err := json.Unmarshal([]byte(attestationRequests[i]), &r); err != nil {
+				t.Fatal(err)
+			}
+
+			b := protocol.AttestationResponse{}
+			if err := json.Unmarshal([]byte(attestationResponses[i]), &b); err != nil {
+				t.Fatal(err)
+			}
+
+			p, err := protocol.ParseAttestationResponse(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The signed data must not be appended to the raw authenticator data in place if it has spare capacity
+			raw := p.Response.Attestation.AuthData.Raw
+			buf := bytes.Repeat([]byte{0xaa}, len(raw)+64)
+			copy(buf, raw)
+			p.Response.Attestation.AuthData.Raw = buf[:len(raw)]
+
+			if _, err := protocol.IsValidAttestation(p, r.PublicKey.Challenge, "", ""); err != nil {
+				e := protocol.ToWebAuthnError(err)
+				t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+			}
+			if !bytes.Equal(buf[len(raw):], bytes.Repeat([]byte{0xaa}, 64)) {
+				t.Fatal("spare capacity of the authenticator data has been overwritten")
+			}
+		})
+	}
 }
@@ -0,0 +1,221 @@
+package tpm
+
+import (
+	"crypto"
+	"encoding/binary"
+	"fmt"
+)
+
+// TPM constants, as defined in [TPMv2-Part2].
+const (
+	tpmGeneratedValue     = 0xff544347
+	tpmSTAttestCertify    = 0x8017
+	tpmAlgRSA             = 0x0001
+	tpmAlgSHA1            = 0x0004
+	tpmAlgSHA256          = 0x000B
+	tpmAlgSHA384          = 0x000C
+	tpmAlgSHA512          = 0x000D
+	tpmAlgNull            = 0x0010
+	tpmAlgECC             = 0x0023
+	tpmECCNISTP256        = 0x0003
+	tpmECCNISTP384        = 0x0004
+	tpmECCNISTP521        = 0x0005
+	tpmDefaultRSAExponent = 65537
+)
+
+// hashAlgorithms maps TPM_ALG_ID hash algorithms to their crypto.Hash.
+var hashAlgorithms = map[uint16]crypto.Hash{
+	tpmAlgSHA1:   crypto.SHA1,
+	tpmAlgSHA256: crypto.SHA256,
+	tpmAlgSHA384: crypto.SHA384,
+	tpmAlgSHA512: crypto.SHA512,
+}
+
+// reader reads the big-endian TPM structures. After the first error, all reads return zero values and the error is
+// kept in err.
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.buf) {
+		r.err = fmt.Errorf("unexpected end of data")
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *reader) uint8() uint8 {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *reader) uint16() uint16 {
+	b := r.next(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *reader) uint32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *reader) uint64() uint64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+// sized reads a TPM2B structure, which is a 16-bit size followed by that amount of bytes.
+func (r *reader) sized() []byte {
+	return r.next(int(r.uint16()))
+}
+
+// done returns the first error that occurred, or an error if not all data has been read.
+func (r *reader) done() error {
+	if r.err != nil {
+		return r.err
+	}
+	if len(r.buf) != 0 {
+		return fmt.Errorf("%d bytes of trailing data", len(r.buf))
+	}
+	return nil
+}
+
+// publicArea represents the TPMT_PUBLIC structure, [TPMv2-Part2] section 12.2.4.
+type publicArea struct {
+	Type             uint16
+	NameAlg          uint16
+	ObjectAttributes uint32
+	AuthPolicy       []byte
+
+	// RSA parameters and unique, only if Type is TPM_ALG_RSA
+	KeyBits  uint16
+	Exponent uint32
+	Modulus  []byte
+
+	// ECC parameters and unique, only if Type is TPM_ALG_ECC
+	CurveID uint16
+	X       []byte
+	Y       []byte
+}
+
+func parsePublicArea(b []byte) (*publicArea, error) {
+	r := &reader{buf: b}
+	p := &publicArea{}
+
+	p.Type = r.uint16()
+	p.NameAlg = r.uint16()
+	p.ObjectAttributes = r.uint32()
+	p.AuthPolicy = r.sized()
+
+	switch p.Type {
+	case tpmAlgRSA:
+		// TPMS_RSA_PARMS
+		readSymmetric(r)
+		readScheme(r)
+		p.KeyBits = r.uint16()
+		p.Exponent = r.uint32()
+		// TPM2B_PUBLIC_KEY_RSA
+		p.Modulus = r.sized()
+	case tpmAlgECC:
+		// TPMS_ECC_PARMS
+		readSymmetric(r)
+		readScheme(r)
+		p.CurveID = r.uint16()
+		readScheme(r) // kdf
+		// TPMS_ECC_POINT
+		p.X = r.sized()
+		p.Y = r.sized()
+	default:
+		return nil, fmt.Errorf("unsupported type %#04x", p.Type)
+	}
+
+	if err := r.done(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// readSymmetric reads a TPMT_SYM_DEF_OBJECT, of which the values are not needed.
+func readSymmetric(r *reader) {
+	if r.uint16() != tpmAlgNull {
+		r.uint16() // keyBits
+		r.uint16() // mode
+	}
+}
+
+// readScheme reads a TPMT_RSA_SCHEME, TPMT_ECC_SCHEME or TPMT_KDF_SCHEME, of which the values are not needed.
+func readScheme(r *reader) {
+	if r.uint16() != tpmAlgNull {
+		r.uint16() // hashAlg
+	}
+}
+
+// attestationData represents the TPMS_ATTEST structure, [TPMv2-Part2] section 10.12.8, where attested is a
+// TPMS_CERTIFY_INFO structure.
+type attestationData struct {
+	Magic           uint32
+	Type            uint16
+	QualifiedSigner []byte
+	ExtraData       []byte
+	Clock           uint64
+	ResetCount      uint32
+	RestartCount    uint32
+	Safe            uint8
+	FirmwareVersion uint64
+	// TPMS_CERTIFY_INFO
+	Name          []byte
+	QualifiedName []byte
+}
+
+func parseAttestationData(b []byte) (*attestationData, error) {
+	r := &reader{buf: b}
+	a := &attestationData{}
+
+	a.Magic = r.uint32()
+	a.Type = r.uint16()
+	a.QualifiedSigner = r.sized()
+	a.ExtraData = r.sized()
+	// TPMS_CLOCK_INFO
+	a.Clock = r.uint64()
+	a.ResetCount = r.uint32()
+	a.RestartCount = r.uint32()
+	a.Safe = r.uint8()
+	a.FirmwareVersion = r.uint64()
+
+	if a.Type != tpmSTAttestCertify {
+		// attested would be of another type, so don't try to parse it
+		if r.err != nil {
+			return nil, r.err
+		}
+		return a, nil
+	}
+
+	a.Name = r.sized()
+	a.QualifiedName = r.sized()
+
+	if err := r.done(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
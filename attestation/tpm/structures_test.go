@@ -0,0 +1,24 @@
+package tpm
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+func TestVerifyPublicKeyUnsupportedType(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// TPM_ALG_KEYEDHASH is not a type of asymmetric keys, so it can never match the credential public key
+	p := &publicArea{Type: 0x0008}
+	if err := verifyPublicKey(p, &key.PublicKey); !errors.Is(err, protocol.ErrUnsupportedAlgorithm) {
+		t.Fatalf("expected ErrUnsupportedAlgorithm, got %v", err)
+	}
+}
@@ -0,0 +1,407 @@
+// tpm implements the TPM (WebAuthn spec section 8.3) attestation statement format
+package tpm
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha1" // register hash functions
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"strings"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+func init() {
+	protocol.RegisterFormat("tpm", verifyTPM)
+	protocol.RegisterFormatFields("tpm", "ver", "alg", "x5c", "ecdaaKeyId", "sig", "certInfo", "pubArea")
+}
+
+var (
+	extensionIDFIDOGenCAAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+	extensionIDSubjectAltName  = asn1.ObjectIdentifier{2, 5, 29, 17}
+
+	oidTCGKPAIKCertificate  = asn1.ObjectIdentifier{2, 23, 133, 8, 3}
+	oidTCGAtTPMManufacturer = asn1.ObjectIdentifier{2, 23, 133, 2, 1}
+	oidTCGAtTPMModel        = asn1.ObjectIdentifier{2, 23, 133, 2, 2}
+	oidTCGAtTPMVersion      = asn1.ObjectIdentifier{2, 23, 133, 2, 3}
+)
+
+// algorithms contains the hash that is used for each supported alg.
+var algorithms = map[protocol.COSEAlgorithmIdentifier]crypto.Hash{
+	protocol.RS1:   crypto.SHA1,
+	protocol.RS256: crypto.SHA256,
+	protocol.ES256: crypto.SHA256,
+	protocol.PS256: crypto.SHA256,
+	protocol.PS384: crypto.SHA384,
+	protocol.PS512: crypto.SHA512,
+}
+
+func verifyTPM(a protocol.Attestation, clientDataHash []byte) (protocol.AttestationType, []*x509.Certificate, error) {
+	// Verify that attStmt is valid CBOR conforming to the syntax defined above and perform CBOR decoding on it to
+	// extract the contained fields.
+	rawVer, ok := a.AttStmt["ver"]
+	if !ok {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("missing ver for tpm").WithStep(protocol.AttestationStepStatement)
+	}
+	ver, ok := rawVer.(string)
+	if !ok {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid ver for tpm, is of invalid type %T", rawVer).WithStep(protocol.AttestationStepStatement)
+	}
+	if ver != "2.0" {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("unsupported ver %q for tpm", ver).WithStep(protocol.AttestationStepStatement)
+	}
+
+	rawAlg, ok := a.AttStmt["alg"]
+	if !ok {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("missing alg for tpm").WithStep(protocol.AttestationStepStatement)
+	}
+	algInt, ok := rawAlg.(int64)
+	if !ok {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid alg for tpm, is of invalid type %T", rawAlg).WithStep(protocol.AttestationStepStatement)
+	}
+
+	alg := protocol.COSEAlgorithmIdentifier(algInt)
+	hash, ok := algorithms[alg]
+	if !ok {
+		return "", nil, protocol.ErrUnsupportedAlgorithm.WithDebugf("unsupported alg %d for tpm", alg)
+	}
+
+	rawSig, ok := a.AttStmt["sig"]
+	if !ok {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("missing sig for tpm").WithStep(protocol.AttestationStepStatement)
+	}
+	sig, ok := rawSig.([]byte)
+	if !ok {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("invalid sig for tpm").WithStep(protocol.AttestationStepStatement)
+	}
+
+	rawCertInfo, ok := a.AttStmt["certInfo"]
+	if !ok {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("missing certInfo for tpm").WithStep(protocol.AttestationStepStatement)
+	}
+	certInfo, ok := rawCertInfo.([]byte)
+	if !ok {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("invalid certInfo for tpm").WithStep(protocol.AttestationStepStatement)
+	}
+
+	rawPubArea, ok := a.AttStmt["pubArea"]
+	if !ok {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("missing pubArea for tpm").WithStep(protocol.AttestationStepStatement)
+	}
+	pubAreaBytes, ok := rawPubArea.([]byte)
+	if !ok {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("invalid pubArea for tpm").WithStep(protocol.AttestationStepStatement)
+	}
+
+	// Verify that the public key specified by the parameters and unique fields of pubArea is identical to the
+	// credentialPublicKey in the attestedCredentialData in authenticatorData.
+	pubArea, err := parsePublicArea(pubAreaBytes)
+	if err != nil {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid pubArea for tpm: %v", err).WithStep(protocol.AttestationStepStatement)
+	}
+	if err := verifyPublicKey(pubArea, a.AuthData.AttestedCredentialData.COSEKey); err != nil {
+		return "", nil, err
+	}
+
+	// Concatenate authenticatorData and clientDataHash to form attToBeSigned.
+	attToBeSigned := make([]byte, 0, len(a.AuthData.Raw)+len(clientDataHash))
+	attToBeSigned = append(attToBeSigned, a.AuthData.Raw...)
+	attToBeSigned = append(attToBeSigned, clientDataHash...)
+
+	// Validate that certInfo is valid:
+	attest, err := parseAttestationData(certInfo)
+	if err != nil {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid certInfo for tpm: %v", err).WithStep(protocol.AttestationStepStatement)
+	}
+
+	// Verify that magic is set to TPM_GENERATED_VALUE.
+	if attest.Magic != tpmGeneratedValue {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid certInfo magic %#08x for tpm", attest.Magic).WithStep(protocol.AttestationStepStatement)
+	}
+
+	// Verify that type is set to TPM_ST_ATTEST_CERTIFY.
+	if attest.Type != tpmSTAttestCertify {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("invalid certInfo type %#04x for tpm", attest.Type).WithStep(protocol.AttestationStepStatement)
+	}
+
+	// Verify that extraData is set to the hash of attToBeSigned using the hash algorithm employed in "alg".
+	h := hash.New()
+	h.Write(attToBeSigned)
+	if !bytes.Equal(attest.ExtraData, h.Sum(nil)) {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebug("invalid certInfo extraData for tpm").WithStep(protocol.AttestationStepNonce)
+	}
+
+	// Verify that attested contains a TPMS_CERTIFY_INFO structure as specified in [TPMv2-Part2] section 10.12.3,
+	// whose name field contains a valid Name for pubArea, as computed using the algorithm in the nameAlg field of
+	// pubArea using the procedure specified in [TPMv2-Part1] section 16.
+	if err := verifyName(attest.Name, pubArea, pubAreaBytes); err != nil {
+		return "", nil, err
+	}
+
+	// If x5c is present, this indicates that the attestation type is not ECDAA. In this case:
+	if _, ok := a.AttStmt["x5c"]; ok {
+		if err := verifyAIK(a, alg, certInfo, sig); err != nil {
+			return "", nil, err
+		}
+
+		// If successful, return attestation type AttCA and attestation trust path x5c.
+		trustPath, err := a.Certificates()
+		if err != nil {
+			return "", nil, err
+		}
+		return protocol.AttestationTypeAttCA, trustPath, nil
+	}
+
+	// If ecdaaKeyId is present, then the attestation type is ECDAA.
+	if _, ok := a.AttStmt["ecdaaKeyId"]; ok {
+		return "", nil, protocol.ErrInvalidAttestation.WithDebugf("unsupported tpm format ECDAA").WithStep(protocol.AttestationStepStatement)
+	}
+
+	return "", nil, protocol.ErrInvalidAttestation.WithDebug("missing x5c or ecdaaKeyId for tpm").WithStep(protocol.AttestationStepStatement)
+}
+
+func verifyPublicKey(p *publicArea, key interface{}) error {
+	var expected interface{}
+	switch p.Type {
+	case tpmAlgRSA:
+		if _, ok := key.(*rsa.PublicKey); !ok {
+			return protocol.ErrInvalidAttestation.WithDebugf("pubArea key type RSA does not match credential public key type %T", key).WithStep(protocol.AttestationStepCredentialPublicKey)
+		}
+
+		exponent := int(p.Exponent)
+		if exponent == 0 {
+			exponent = tpmDefaultRSAExponent
+		}
+
+		expected = &rsa.PublicKey{N: big.NewInt(0).SetBytes(p.Modulus), E: exponent}
+	case tpmAlgECC:
+		if _, ok := key.(*ecdsa.PublicKey); !ok {
+			return protocol.ErrInvalidAttestation.WithDebugf("pubArea key type ECC does not match credential public key type %T", key).WithStep(protocol.AttestationStepCredentialPublicKey)
+		}
+
+		var curve elliptic.Curve
+		switch p.CurveID {
+		case tpmECCNISTP256:
+			curve = elliptic.P256()
+		case tpmECCNISTP384:
+			curve = elliptic.P384()
+		case tpmECCNISTP521:
+			curve = elliptic.P521()
+		default:
+			return protocol.ErrUnsupportedAlgorithm.WithDebugf("unsupported pubArea curve %#04x for tpm", p.CurveID)
+		}
+
+		expected = &ecdsa.PublicKey{Curve: curve, X: big.NewInt(0).SetBytes(p.X), Y: big.NewInt(0).SetBytes(p.Y)}
+	default:
+		return protocol.ErrUnsupportedAlgorithm.WithDebugf("unsupported pubArea type %#04x for tpm", p.Type)
+	}
+
+	if !protocol.PublicKeyEqual(expected, key) {
+		return protocol.ErrInvalidAttestation.WithDebug("pubArea key does not match credential public key").WithStep(protocol.AttestationStepCredentialPublicKey)
+	}
+
+	return nil
+}
+
+func verifyName(name []byte, p *publicArea, pubArea []byte) error {
+	if len(name) < 2 {
+		return protocol.ErrInvalidAttestation.WithDebug("invalid certInfo name for tpm").WithStep(protocol.AttestationStepStatement)
+	}
+
+	nameAlg := uint16(name[0])<<8 | uint16(name[1])
+	if nameAlg != p.NameAlg {
+		return protocol.ErrInvalidAttestation.WithDebugf("certInfo name algorithm %#04x does not match pubArea nameAlg %#04x", nameAlg, p.NameAlg).WithStep(protocol.AttestationStepStatement)
+	}
+
+	hash, ok := hashAlgorithms[nameAlg]
+	if !ok || !hash.Available() {
+		return protocol.ErrUnsupportedAlgorithm.WithDebugf("unsupported name algorithm %#04x for tpm", nameAlg)
+	}
+
+	h := hash.New()
+	h.Write(pubArea)
+	if !bytes.Equal(name[2:], h.Sum(nil)) {
+		return protocol.ErrInvalidAttestation.WithDebug("certInfo name does not match pubArea for tpm").WithStep(protocol.AttestationStepStatement)
+	}
+
+	return nil
+}
+
+func verifyAIK(a protocol.Attestation, alg protocol.COSEAlgorithmIdentifier, certInfo, sig []byte) error {
+	x5c, ok := a.AttStmt["x5c"].([]interface{})
+	if !ok || len(x5c) == 0 {
+		return protocol.ErrInvalidAttestation.WithDebug("invalid x5c for tpm").WithStep(protocol.AttestationStepStatement)
+	}
+
+	// let aikCert be the first element
+	aikCert, ok := x5c[0].([]byte)
+	if !ok {
+		return protocol.ErrInvalidAttestation.WithDebug("invalid x5c for tpm").WithStep(protocol.AttestationStepStatement)
+	}
+
+	cert, err := protocol.ParseCertificate(aikCert)
+	if err != nil {
+		return protocol.ErrInvalidAttestation.WithDebugf("invalid x5c for tpm: %v", err).WithStep(protocol.AttestationStepCertificate)
+	}
+
+	// Verify the sig is a valid signature over certInfo using the attestation public key in aikCert with the
+	// algorithm specified in alg.
+	if err := verifySignature(cert, alg, certInfo, sig); err != nil {
+		return protocol.ErrSignatureInvalid.WithDebugf("invalid signature for tpm: %v", err).WithCause(err)
+	}
+
+	// Verify that aikCert meets the requirements in §8.3.1 TPM attestation statement certificate requirements.
+
+	// Version MUST be set to 3.
+	if cert.Version != 3 {
+		return protocol.ErrInvalidAttestation.WithDebug("invalid version for certificate").WithStep(protocol.AttestationStepCertificateVersion)
+	}
+
+	// Subject field MUST be set to empty.
+	if len(cert.Subject.Names) != 0 {
+		return protocol.ErrInvalidAttestation.WithDebug("subject is not empty for certificate").WithStep(protocol.AttestationStepCertificateSubject)
+	}
+
+	// The Subject Alternative Name extension MUST be set as defined in [TPMv2-EK-Profile] section 3.2.9.
+	var sanFound bool
+	var aaguidValue []byte
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(extensionIDSubjectAltName):
+			if err := verifySubjectAltName(ext.Value); err != nil {
+				return err
+			}
+			sanFound = true
+		case ext.Id.Equal(extensionIDFIDOGenCAAAGUID):
+			// The extension MUST NOT be marked as critical.
+			if ext.Critical {
+				return protocol.ErrInvalidAttestation.WithDebugf("extension id-fido-gen-ce-aaguid is present, but is marked as critical").WithStep(protocol.AttestationStepAAGUID)
+			}
+			aaguidValue = ext.Value
+		}
+	}
+	if !sanFound {
+		return protocol.ErrInvalidAttestation.WithDebug("missing subject alternative name for certificate").WithStep(protocol.AttestationStepCertificateSubject)
+	}
+
+	// The Extended Key Usage extension MUST contain the "joint-iso-itu-t(2) internationalorganizations(23) 133
+	// tcg-kp(8) tcg-kp-AIKCertificate(3)" OID.
+	var ekuFound bool
+	for _, eku := range cert.UnknownExtKeyUsage {
+		if eku.Equal(oidTCGKPAIKCertificate) {
+			ekuFound = true
+			break
+		}
+	}
+	if !ekuFound {
+		return protocol.ErrInvalidAttestation.WithDebug("missing tcg-kp-AIKCertificate extended key usage for certificate").WithStep(protocol.AttestationStepCertificate)
+	}
+
+	// The Basic Constraints extension MUST have the CA component set to false.
+	if cert.IsCA {
+		return protocol.ErrInvalidAttestation.WithDebug("CA is set for certificate").WithStep(protocol.AttestationStepCertificateCA)
+	}
+
+	// If aikCert contains an extension with OID 1.3.6.1.4.1.45724.1.1.4 (id-fido-gen-ce-aaguid) verify that the value
+	// of this extension matches the aaguid in authenticatorData.
+	if len(aaguidValue) > 0 {
+		var aaguid []byte
+		if _, err := asn1.Unmarshal(aaguidValue, &aaguid); err != nil {
+			return protocol.ErrInvalidAttestation.WithDebugf("invalid AAGUID: %v", err).WithStep(protocol.AttestationStepAAGUID)
+		}
+
+		if !bytes.Equal(a.AuthData.AttestedCredentialData.AAGUID, aaguid) {
+			return protocol.ErrAAGUIDMismatch.WithDebugf("invalid AAGUID")
+		}
+	}
+
+	return nil
+}
+
+// verifySubjectAltName verifies that the directoryName of the subject alternative name contains the TPM
+// manufacturer, model and version.
+func verifySubjectAltName(value []byte) error {
+	var names []asn1.RawValue
+	if rest, err := asn1.Unmarshal(value, &names); err != nil {
+		return protocol.ErrInvalidAttestation.WithDebugf("invalid subject alternative name: %v", err).WithStep(protocol.AttestationStepCertificateSubject)
+	} else if len(rest) != 0 {
+		return protocol.ErrInvalidAttestation.WithDebug("invalid subject alternative name: trailing data").WithStep(protocol.AttestationStepCertificateSubject)
+	}
+
+	var manufacturer, model, version string
+	for _, name := range names {
+		// directoryName [4] Name
+		if name.Class != asn1.ClassContextSpecific || name.Tag != 4 {
+			continue
+		}
+
+		var rdns pkix.RDNSequence
+		if _, err := asn1.Unmarshal(name.Bytes, &rdns); err != nil {
+			return protocol.ErrInvalidAttestation.WithDebugf("invalid subject alternative name: %v", err).WithStep(protocol.AttestationStepCertificateSubject)
+		}
+
+		for _, rdn := range rdns {
+			for _, atv := range rdn {
+				value, ok := atv.Value.(string)
+				if !ok {
+					continue
+				}
+				switch {
+				case atv.Type.Equal(oidTCGAtTPMManufacturer):
+					manufacturer = value
+				case atv.Type.Equal(oidTCGAtTPMModel):
+					model = value
+				case atv.Type.Equal(oidTCGAtTPMVersion):
+					version = value
+				}
+			}
+		}
+	}
+
+	if !strings.HasPrefix(manufacturer, "id:") || model == "" || version == "" {
+		return protocol.ErrInvalidAttestation.WithDebug("invalid subject alternative name: missing TPM manufacturer, model or version").WithStep(protocol.AttestationStepCertificateSubject)
+	}
+
+	return nil
+}
+
+func verifySignature(cert *x509.Certificate, alg protocol.COSEAlgorithmIdentifier, signed, sig []byte) error {
+	switch alg {
+	case protocol.RS1:
+		// SHA-1 signatures are rejected by crypto/x509, but are still produced by many TPMs
+		pub, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return x509.ErrUnsupportedAlgorithm
+		}
+		h := crypto.SHA1.New()
+		h.Write(signed)
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA1, h.Sum(nil), sig)
+	case protocol.RS256:
+		return cert.CheckSignature(x509.SHA256WithRSA, signed, sig)
+	case protocol.ES256:
+		if _, _, err := protocol.ParseECDSASignature(sig); err != nil {
+			return err
+		}
+		return cert.CheckSignature(x509.ECDSAWithSHA256, signed, sig)
+	case protocol.PS256, protocol.PS384, protocol.PS512:
+		// The salt length of RSASSA-PSS signatures is equal to the length of the hash, see RFC 8230
+		pub, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return x509.ErrUnsupportedAlgorithm
+		}
+		hash := algorithms[alg]
+		h := hash.New()
+		h.Write(signed)
+		return rsa.VerifyPSS(pub, hash, h.Sum(nil), sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	default:
+		return x509.ErrUnsupportedAlgorithm
+	}
+}
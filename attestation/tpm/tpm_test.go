@@ -0,0 +1,52 @@
+package tpm_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+func TestIsValidAttestation(t *testing.T) {
+	for i := range attestationRequests {
+		t.Run(fmt.Sprintf("Run %d", i), func(t *testing.T) {
+			r := protocol.CredentialCreationOptions{}
+			if err := json.Unmarshal([]byte(attestationRequests[i]), &r); err != nil {
+				t.Fatal(err)
+			}
+
+			b := protocol.AttestationResponse{}
+			if err := json.Unmarshal([]byte(attestationResponses[i]), &b); err != nil {
+				t.Fatal(err)
+			}
+
+			p, err := protocol.ParseAttestationResponse(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d, err := protocol.IsValidAttestation(p, r.PublicKey.Challenge, "", "")
+			if err != nil {
+				e := protocol.ToWebAuthnError(err)
+				t.Fatal(fmt.Sprintf("%s, %s: %s", e.Name, e.Description, e.Debug))
+			}
+
+			if !d {
+				t.Fatal("is not valid")
+			}
+		})
+	}
+}
+
+var attestationRequests = []string{
+	`{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"ygf2s0OpURQ3h9dQ86dogPbadPfALiyD6YpYYHAr7+w=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`,
+	// AIK signature PS256
+	`{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"e4eqPEwgaCa+8sDI2URQGj4GUNMKTUrEZGVoa4DMLVY=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`,
+}
+
+var attestationResponses = []string{
+	`{"id":"Q5hzuud4fKNpfunPzH-SJPKs-5qe32Whs2f6Mijog9w","rawId":"Q5hzuud4fKNpfunPzH+SJPKs+5qe32Whs2f6Mijog9w=","response":{"attestationObject":"o2dhdHRTdG10pmNhbGc5AQBoY2VydEluZm9Yrf9UQ0eAFwAivZP3wlWTaUvIYryMG7x7a/+/TI1XoxmRpHGIt6N71ChqMwAgXwoGHPmr4iRLdzlsPPfk6k1x1auC/yoOmTp/4fjsMvQAAAAAAAAwOQAAAAEAAAACAQABAAIAAwAEACIAC8Q0fEeQJgnWRFUcrvW7kEQokz3A3d4a+oJQ9/mlAb0SACLN9VBRWpuOJ2NZg+61S6XMfuQO7mm2sx+WkIbvUW0OajAOZ3B1YkFyZWFZARYAAQALAAYEcgAAABAAEAgAAAAAAAEAygkmIc96QuC3SeDWlNU+0FY/42hkkqzwun2HDOg0FhQozevC4HXAaju7dcKuhK2r0oco1laRhEybxGEwt321DIrLuAvwvaSmlq4PmwHV4KLXGNbEEEC1gaSghGI2LfL7BJR+yTg/8wNK12ntRDs6uH9JIZCx7r7jd5h9Meas+cL+w3OuSujQG0LNdvub0JeB4DXCq74cdTPo0Ksze9EgeeJR1adSlIEwVE7lvxwo5vyboQCD9/gIfkv6HWsXRSbmTkUvlSaNsDiR4MX6ks4Ho1C40wfpc7dp4+sTqdzyu3dNGMlJkybCZWBMyHDStjuASXMgfEDaydTGBkWZrDyO6WNzaWdZAQB2U5HV3KLYnzA6Tsro4MzY04evdxxu0u1Hk5u7EoDAD1zmTzMnqx9A0+V0lpio8E5Jx0/iPZG4Cy6IQRGPUI9GOjz1bVunXFdfkjqKTBy3jxd5mnr/9WDl5e4saAM/WlUH7qF5wNonHPJ94/c92AT6p9+l/Enjq838GpxdXqsXgCiFM4eQ9Skl5+X4gZu3QGeMQ69YESxAfEA9O+akD0AzOgrJFcU0h5FYPuX4WWpkQYckH2axkZGw2EbUWYwX7dbAYg6byo5017P5+72x8Yfjrp5U+zLzDMJ3OyGZO5kZ+L7XhkszRM0pjtfTZuzSqXguYishUUJfbyl5lf133JwGY3ZlcmMyLjBjeDVjglkDezCCA3cwggJfoAMCAQICAQIwDQYJKoZIhvcNAQELBQAwRjELMAkGA1UEBhMCTkwxFjAUBgNVBAoTDVdlYkF1dGhuIFRlc3QxHzAdBgNVBAMTFldlYkF1dGhuIFRlc3QgVFBNIFJvb3QwHhcNMjAwMTAxMDAwMDAwWhcNNDAwMTAxMDAwMDAwWjAAMIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAvjYY1zUTxqCBJjFsxeHwRIND/ldk/uLYzIijPn5/G/j+HWNVb/9kQBhHFoqoRnUEfLKTOYN6JCVHBvUDDIQu/PgyjZwh1xEk8vnWpKI2N+DdAufsdsKVs50dkNquPwPc84+sPSJoeYCScO6LUYOPtp29j1O2mSZw5QB1xnsqgOaec991W/cZe9TUGe3ERwF9AzQ6FcFDYb+3NJbkgyuf0eQCpA4vwGt9l4spzZyA81C6xixAKNyJCTLRxuvKOigqHp44iuMiu2iOA1fU0YyysoxD2cgPrUgG0nRGI7xX4gxjOZfoiFfVAYIn2+N1INAVE2aQFtiMBLrM01nTK/XQkQIDAQABo4G1MIGyMBAGA1UdJQQJMAcGBWeBBQgDMAwGA1UdEwEB/wQCMAAwHwYDVR0jBBgwFoAU0JUFSgjcwZbm20KclSMOgA+GZOgwTAYDVR0RAQH/BEIwQKQ+MDwxOjAQBgVngQUCAhMHTlBDVDZ4eDAQBgVngQUCAxMHaWQ6MDAwNzAUBgVngQUCARMLaWQ6NEU1NDQzMDAwIQYLKwYBBAGC5RwBAQQEEgQQCJhwWMrcS4G24TDeUNy+ljANBgkqhkiG9w0BAQsFAAOCAQEAwNDti2+vKpgdL+d6BQGv3YWT5DNEE7UC8DoFS0y+WU6riPZHHjvoxjlcyqZNG4qZTa0q/fE6fx2j4LIOaWyAM1FdqF5kSCDlgqFvsleJjP7Mg2XFGAzrysGC7zqV2+3SJjo2GAvDShYB9dU9VkyugTI0HMKgktPqRuggdQ+nH+tmQbuwGudqXx4QnxAgsGMNBAv7J1PNHGQAeIe6fI3hSZQO3FfQmEJ0TMP0PA3ztKJsEa0mt6ws/at/qCmDPzQ8PXDqWYqjXx3r7bSXJKvP8kBzUPgHbRd98oSxaMCpBQTMf+OoHd4L4Dz4h4TRg3+Evw7m+599rnqtf3l1Wbf9d1kDTTCCA0kwggIxoAMCAQICAQEwDQYJKoZIhvcNAQELBQAwRjELMAkGA1UEBhMCTkwxFjAUBgNVBAoTDVdlYkF1dGhuIFRlc3QxHzAdBgNVBAMTFldlYkF1dGhuIFRlc3QgVFBNIFJvb3QwHhcNMjAwMTAxMDAwMDAwWhcNNDUwMTAxMDAwMDAwWjBGMQswCQYDVQQGEwJOTDEWMBQGA1UEChMNV2ViQXV0aG4gVGVzdDEfMB0GA1UEAxMWV2ViQXV0aG4gVGVzdCBUUE0gUm9vdDCCASIwDQYJKoZIhvcNAQEBBQADggEPADCCAQoCggEBAN5lSWkxEqE4ngVTFlw+1hInph0f2iAvE/Mx7qzJEM7fQqgTMY5VBOdVATa5AMUNXyRelZ3lsztCOAyhJfIZcf1PnVdgsJQxw/VmGmYfmjDvSD4qm0stp2F9TMRRcujdC60be8rHoesh90hFx3N4gIKV3FmMkkLUbaVm8WxWpp0dW3msk7YYgodoQgqYpg3n4vMl50JEw7A8xhOj4pJebj7RbM++oDzOxpr8movnYdyAC1Aj2+nGg1rZI0wWh928zpvI8qMhOshcwBGZwqTXpoj6CE6xL28gLrFMpQyS0wntyNQ9CPmeeabeL5yT9uhanS6kzxZ2W+A8470djS6sh+ECAwEAAaNCMEAwDgYDVR0PAQH/BAQDAgIEMA8GA1UdEwEB/wQFMAMBAf8wHQYDVR0OBBYEFNCVBUoI3MGW5ttCnJUjDoAPhmToMA0GCSqGSIb3DQEBCwUAA4IBAQCTtfXl7Ie/RkvNIugS6Yt6upn3BmYehBXVGeaH+BH/rTdfvoMwC2fiZMS7kiK5fSguEt+WudIozSYbRx3sf6LRJPabFoeT3i4gvwyYVf7HpyqN2R7N61fOkWRATRLjb5CqbWxXUCWR6o7VujI5Ak/pcXdziUOwNmBTX64GwGgyCBwDTpzgKAt+cw9vmoMGLIq5Ktmh+Bp1FLiYLrClEiSbM3r/8KdIqnytCO95iFGVDb5n/v02ZHyY9u/RfmyedMZ2amhz93VbBqJT8sbbtwngScEa5M+NtBLXUJn95opNodv1NjrNIOcgjtgx5ZmSkmQF0zSTWQLDk/CLmT5R5x7daGF1dGhEYXRhWQFnSZYN5YgOjGh0NBcPZHZgW4/krrmihjLHmVzzuoMdl2NFAAAAAAiYcFjK3EuBtuEw3lDcvpYAIEOYc7rneHyjaX7pz8x/kiTyrPuant9lobNn+jIo6IPcpCFDAQABIFkBAMoJJiHPekLgt0ng1pTVPtBWP+NoZJKs8Lp9hwzoNBYUKM3rwuB1wGo7u3XCroStq9KHKNZWkYRMm8RhMLd9tQyKy7gL8L2kppauD5sB1eCi1xjWxBBAtYGkoIRiNi3y+wSUfsk4P/MDStdp7UQ7Orh/SSGQse6+43eYfTHmrPnC/sNzrkro0BtCzXb7m9CXgeA1wqu+HHUz6NCrM3vRIHniUdWnUpSBMFRO5b8cKOb8m6EAg/f4CH5L+h1rF0Um5k5FL5UmjbA4keDF+pLOB6NQuNMH6XO3aePrE6nc8rt3TRjJSZMmwmVgTMhw0rY7gElzIHxA2snUxgZFmaw8jukBAwM5AQBjZm10Y3RwbQ==","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoieWdmMnMwT3BVUlEzaDlkUTg2ZG9nUGJhZFBmQUxpeUQ2WXBZWUhBcjctdyIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`,
+	// AIK signature PS256
+	`{"id":"zShLEGmHWv6oAd1Cz8Z3bUKbyZb55X5EUI6A9ysgWz4","rawId":"zShLEGmHWv6oAd1Cz8Z3bUKbyZb55X5EUI6A9ysgWz4=","response":{"attestationObject":"o2dhdHRTdG10pmNhbGc4JGhjZXJ0SW5mb1it/1RDR4AXACIWr8QRk/99Y/iO6NDX8v3OZwI/rTYr4H2ePQsvMxz7NdXVACDlAKoDhG5fCJkwHAPMdPSY8QXIXzRqZ2QGwhv6O6D4MwAAAAAAADA5AAAAAQAAAAIBAAEAAgADAAQAIgALGDw8MRROB9PWwN5K3BBOMzqfLpQXf5QTmCHdIEZjPRAAIoP6oGHpR7n5MbqIWrhRMzOtJm1/bWTJ/HJqZRgupyx58c5ncHViQXJlYVkBFgABAAsABgRyAAAAEAAQCAAAAAAAAQC+CGmFYymWBZPX5fz4oTLFE+dqvhVrUxbLe1tFDbLlmLg4I6vUEdsrG6sFEW4wyUFSurQW6NELyCsqaNgECPCPENqQiF75QaKIbmBkvKypEGWqUGLz+S5JaxNmPT9leQGJSChWT6C8YdXOU6X5dQ3DCvyBpjWDB3WSUyk4B7+Bqd7//Rc84HtaQYLN4OSw1brcuQhsN/3fZlGDE5MolPTr6KdqVuTAC9pZM107eIfx74TyVmn+9Qsz8L9ZhPkuh5pTQnGnNclQc2AIsnOW3f/w6nSmLDjS8AcDs6f/iQT/WdV/e1lJ7PscJAUK9Ul7w3sgKNsXKEFuK3h7mMsaJ/T5Y3NpZ1kBAIWk5cQ8GdJW/P9B0gVJz+fdXQfaOpRoVrrUMU96FGk1cYcto/VXxVujIxF8ZiWtbyWoKoP2kv1ihwdXTgK1fRwFQPM6hnR5x/01bezoqI69sRObQgKPf8cGokWEDwPFvyhruIc24e2vYmYpJ3orZFwIQL0kRmpkw7DONir6Fh+wtWXk+xF4x3CBx40GiRJ8qEujOkqkiJpTe7ujCzbI5P5HOpS1aj67CJRT2VLfFTeyO1NaRhWP8PclUVOFEA4E1cW4m3qNihvL6BdJhUJUkBU3sF3iOyEk6SIvPcew09mXAS0ucsZGIcZK6386S537n2LNlUIihjlYML++fXEGoAJjdmVyYzIuMGN4NWOCWQN7MIIDdzCCAl+gAwIBAgIBAjANBgkqhkiG9w0BAQsFADBGMQswCQYDVQQGEwJOTDEWMBQGA1UEChMNV2ViQXV0aG4gVGVzdDEfMB0GA1UEAxMWV2ViQXV0aG4gVGVzdCBUUE0gUm9vdDAeFw0yMDAxMDEwMDAwMDBaFw00MDAxMDEwMDAwMDBaMAAwggEiMA0GCSqGSIb3DQEBAQUAA4IBDwAwggEKAoIBAQC76s+UeUabxac7wF7DI6blRXFWjg+d/n2EJJVwMxj+WKrwCHGwr6GS0OhEg0hTj19R+xLlMFM61d6z+Xt62Fp3BehqQpPonMdBTMvwvRZppazmYT8h4AjsRW4+Av98Lk67sofGm02A9FG3QR3W/BeoaE2qz2l2ZqDKTIGlhIKW9ozBYcwC2muWJ9w5H5il8mVKU1IIc7M6bINTz5/lg0NOPJ/Mnmq/bgkFJ/iIK6WdPAh7KdcXER3SnqNWEw8r9R5MusfxtMJc9mj17+y/cTk28jme2wk0lnMQ1yHId5+5njJfI/4Wuy8dxDTorrGW3WjXunIEbiK2lwc7q2sLOOTZAgMBAAGjgbUwgbIwEAYDVR0lBAkwBwYFZ4EFCAMwDAYDVR0TAQH/BAIwADAfBgNVHSMEGDAWgBRbmyVwRYbozNHIVteqYjxUVY+QlDBMBgNVHREBAf8EQjBApD4wPDE6MBAGBWeBBQICEwdOUENUNnh4MBAGBWeBBQIDEwdpZDowMDA3MBQGBWeBBQIBEwtpZDo0RTU0NDMwMDAhBgsrBgEEAYLlHAEBBAQSBBAImHBYytxLgbbhMN5Q3L6WMA0GCSqGSIb3DQEBCwUAA4IBAQBLWBJkxKx0/OB4TaFZW1Lstcqo4s/yDX3bTZuUsgMc9lCo3RfSSKUQMLGT1fIMyiqc3DVRsK97fEaaSzjoIGYi0bdc+fOGE5TaYRL1P89bGVc8vwCXjjoCmChcpNtJfO3y4e6cCRQzZashuXPQHcZlh4GGzR43K0z4s+m/Ih7kONcz8HRAz0aLWv8hzyifVJ04Z2P1/JcHYYYuT/RY8w0aWBDUKUk/SPLIMgQSEbk6AyuVIEcE91QOHRJkfUIdstZgJHkOb7Vouu/o0CI+fQJONedxcjhB2qOIg9nmgy0X23sISLnXgX9b3tLscOkpRTrv76ifZUDkWO6U1av9DCARWQNNMIIDSTCCAjGgAwIBAgIBATANBgkqhkiG9w0BAQsFADBGMQswCQYDVQQGEwJOTDEWMBQGA1UEChMNV2ViQXV0aG4gVGVzdDEfMB0GA1UEAxMWV2ViQXV0aG4gVGVzdCBUUE0gUm9vdDAeFw0yMDAxMDEwMDAwMDBaFw00NTAxMDEwMDAwMDBaMEYxCzAJBgNVBAYTAk5MMRYwFAYDVQQKEw1XZWJBdXRobiBUZXN0MR8wHQYDVQQDExZXZWJBdXRobiBUZXN0IFRQTSBSb290MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAtieJAq4s5bkfDsXfU/L08MGTLmRA2XDPH7HAfg/mSSaq7fFyMH5sKd0aSJMX/koyBbEQPYQ0/PH77HclPJsYb2xr5SrQKeKLzGf+vevb7HtHUxbtTAcofTXiE4IWkHRxA0n/u1OJ429dxOiRtNDj6h/hoaGrPxgXHC1hbB+iIkTMV5/exp1jvym8fayFjVfBRP65ODvVoC5bIUgkN6cR1qb4PeTzP5yqjIleK4n2p9y6wO4DT7FnWpKZUmfQ4P+c3ZPb2z3hyIbRgGR6rJWRGhkFJdom7M1k2W8A9VcM79tc9aUfsiy5cdM0PZgeTs0zn9EO5+gV3a8EcjRNRl2KGQIDAQABo0IwQDAOBgNVHQ8BAf8EBAMCAgQwDwYDVR0TAQH/BAUwAwEB/zAdBgNVHQ4EFgQUW5slcEWG6MzRyFbXqmI8VFWPkJQwDQYJKoZIhvcNAQELBQADggEBAFhW9xKBLnc2SVhOsuT3xdzK+rswW76k+HPJ72u3bx3OfoqbxLaEdlShI6mB0DY5EAGoHablLGW2emZqe3JCpeFP9ZZagKFat/xT78cpGI/Pp0UT2s4ZCvVJSsTadhD/aT3Vkyn8ISWvuUpOCeqzS0zAIc0u3tx/gorCd3BZrPHzSXGRy+aYTeByUmbdyKgrE41JzceKJv1fU0m6zmhwaitSo+f3bjoAfir3Ki1YjJGJ7P1qgFodquPUVZ6izDHSacjWlyJNg8ozncJwMYLvq4Yt4M2NwWe4hfRIm6Pccq5nyiq5SYVzTgZbX6+QLUlAPPClAa40KDcl/a837MsheQFoYXV0aERhdGFZAWdJlg3liA6MaHQ0Fw9kdmBbj+SuuaKGMseZXPO6gx2XY0UAAAAACJhwWMrcS4G24TDeUNy+lgAgzShLEGmHWv6oAd1Cz8Z3bUKbyZb55X5EUI6A9ysgWz6kIUMBAAEgWQEAvghphWMplgWT1+X8+KEyxRPnar4Va1MWy3tbRQ2y5Zi4OCOr1BHbKxurBRFuMMlBUrq0FujRC8grKmjYBAjwjxDakIhe+UGiiG5gZLysqRBlqlBi8/kuSWsTZj0/ZXkBiUgoVk+gvGHVzlOl+XUNwwr8gaY1gwd1klMpOAe/gane//0XPOB7WkGCzeDksNW63LkIbDf932ZRgxOTKJT06+inalbkwAvaWTNdO3iH8e+E8lZp/vULM/C/WYT5LoeaU0JxpzXJUHNgCLJzlt3/8Op0piw40vAHA7On/4kE/1nVf3tZSez7HCQFCvVJe8N7ICjbFyhBbit4e5jLGif0+QEDAzkBAGNmbXRjdHBt","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoiZTRlcVBFd2dhQ2EtOHNESTJVUlFHajRHVU5NS1RVckVaR1ZvYTRETUxWWSIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`,
+}
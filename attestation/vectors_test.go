@@ -0,0 +1,100 @@
+package attestation_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/keycloud/webauthn/attestation"
+	"github.com/keycloud/webauthn/protocol"
+)
+
+// attestationVector is a test vector in testdata/vectors, of which each file contains the vectors of a single
+// attestation format. The vectors are recorded responses, of which the negative vectors have been modified, and are not
+// the official vectors of the FIDO Conformance Tools.
+type attestationVector struct {
+	// Name is the name of the vector, which is used as the name of the subtest.
+	Name string `json:"name"`
+	// Description describes what the vector tests.
+	Description string `json:"description"`
+	// Challenge is the challenge of the ceremony.
+	Challenge []byte `json:"challenge"`
+	// Origin is the origin that the client data must match, or empty if it is not checked.
+	Origin string `json:"origin"`
+	// Response is the response of navigator.credentials.create().
+	Response json.RawMessage `json:"response"`
+	// AttestationType is the type of a valid attestation.
+	AttestationType protocol.AttestationType `json:"attestationType"`
+	// Error is the name of the error with which an invalid attestation is rejected.
+	Error string `json:"error"`
+	// Step is the step of the attestation verification that fails for an invalid attestation, if any.
+	Step protocol.AttestationStep `json:"step"`
+}
+
+// TestAttestationVectors verifies the positive and negative vectors of all attestation formats. The android-safetynet vectors
+// are not included, since they require the roots that are replaced by the tests of its package.
+func TestAttestationVectors(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "vectors", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no attestation vectors")
+	}
+
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".json"), func(t *testing.T) {
+			b, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var vectors []attestationVector
+			if err := json.Unmarshal(b, &vectors); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, vector := range vectors {
+				vector := vector
+				t.Run(vector.Name, func(t *testing.T) {
+					attestationType, err := verifyAttestationVector(vector)
+					if vector.Error == "" {
+						if err != nil {
+							e := protocol.ToWebAuthnError(err)
+							t.Fatalf("%s: %s, %s: %s", vector.Description, e.Name, e.Description, e.Debug)
+						}
+						if attestationType != vector.AttestationType {
+							t.Fatalf("expected attestation type %q, got %q", vector.AttestationType, attestationType)
+						}
+						return
+					}
+
+					if err == nil {
+						t.Fatalf("%s: expected %s", vector.Description, vector.Error)
+					}
+					if e := protocol.ToWebAuthnError(err); e.Name != vector.Error || e.Step != vector.Step {
+						t.Fatalf("%s: expected %s at step %q, got %s at step %q: %s", vector.Description, vector.Error, vector.Step, e.Name, e.Step, e.Debug)
+					}
+				})
+			}
+		})
+	}
+}
+
+// verifyAttestationVector parses and verifies the response of the vector. Since the vectors are recorded for various
+// RP IDs, the RP ID hash is not checked.
+func verifyAttestationVector(vector attestationVector) (protocol.AttestationType, error) {
+	var resp protocol.AttestationResponse
+	if err := json.Unmarshal(vector.Response, &resp); err != nil {
+		return "", err
+	}
+
+	p, err := protocol.ParseAttestationResponse(resp)
+	if err != nil {
+		return "", err
+	}
+
+	attestationType, _, err := protocol.VerifyAttestation(p, vector.Challenge, "", vector.Origin)
+	return attestationType, err
+}
@@ -2,6 +2,7 @@ package cose
 
 import (
 	"bytes"
+	"crypto/ecdsa"
 	"fmt"
 
 	"github.com/ugorji/go/codec"
@@ -14,10 +15,51 @@ var (
 	ErrUnsupportedKeyType   = fmt.Errorf("cose: unsupported key type")
 	ErrUnsupportedAlgorithm = fmt.Errorf("cose: unsupported algorithm")
 	ErrInvalidFormat        = fmt.Errorf("cose: invalid format")
+	ErrCurveMismatch        = fmt.Errorf("cose: curve does not match the algorithm")
+	ErrInvalidCOSEKey       = fmt.Errorf("cose: invalid key")
 )
 
-// ParseCOSE parses a raw COSE key into a public key, either *ecdsa.PublicKey or *rsa.PublicKey.
+// ParseCOSE parses a raw COSE key into a public key, either *ecdsa.PublicKey, *rsa.PublicKey or ed25519.PublicKey.
+// Keys of the non-standard algorithms are an *ecdsa.PublicKey on the Secp256k1 curve or an Ed448PublicKey, which are
+// always accepted, so callers that do not accept them should check the key with IsNonStandardKey.
 func ParseCOSE(buf []byte) (interface{}, error) {
+	m, err := decode(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseCOSEMap(m)
+}
+
+// IsNonStandardKey reports whether a public key that is returned by ParseCOSE is a key of the ES256K algorithm, i.e.
+// ECDSA on the secp256k1 curve, or an EdDSA key on the Ed448 curve. They are not commonly used with WebAuthn, so
+// Relying Parties should only accept them if they are explicitly enabled.
+func IsNonStandardKey(key interface{}) bool {
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		return key.Curve == Secp256k1()
+	case Ed448PublicKey:
+		return true
+	}
+	return false
+}
+
+// Algorithm returns the algorithm of a raw COSE key, after validating that the key is valid for the algorithm.
+func Algorithm(buf []byte) (int64, error) {
+	m, err := decode(buf)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := ParseCOSEMap(m); err != nil {
+		return 0, err
+	}
+
+	// The algorithm has been validated by ParseCOSEMap
+	return m[3].(int64), nil
+}
+
+func decode(buf []byte) (map[int]interface{}, error) {
 	m := make(map[int]interface{})
 
 	cbor := codec.CborHandle{}
@@ -26,7 +68,7 @@ func ParseCOSE(buf []byte) (interface{}, error) {
 		return nil, err
 	}
 
-	return ParseCOSEMap(m)
+	return m, nil
 }
 
 // ParseCOSEMap parses a COSE key that has been decoded from it's CBOR format to a dictionary.
@@ -51,8 +93,12 @@ func ParseCOSEMap(m map[int]interface{}) (interface{}, error) {
 
 	// https://tools.ietf.org/html/rfc8152#section-13
 	switch kty {
+	case 1: // OKP
+		return parseEdDSA(alg, m)
 	case 2: // EC2
 		return parseECDSA(alg, m)
+	case 3: // RSA
+		return parseRSA(alg, m)
 	default:
 		return nil, ErrUnsupportedKeyType
 	}
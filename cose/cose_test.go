@@ -2,9 +2,20 @@ package cose_test
 
 import (
 	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"reflect"
 	"testing"
 
 	"github.com/keycloud/webauthn/cose"
+	"gopkg.in/square/go-jose.v2"
 )
 
 func TestParseCOSE(t *testing.T) {
@@ -16,4 +27,302 @@ func TestParseCOSE(t *testing.T) {
 	_ = key.(*ecdsa.PublicKey)
 }
 
+func TestParseCOSERSA(t *testing.T) {
+	key, err := cose.ParseCOSE(coseRSAKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key.(*rsa.PublicKey).E != 65537 {
+		t.Fatal("invalid exponent")
+	}
+}
+
+func TestParseCOSERSASHA1(t *testing.T) {
+	// The algorithm of the key is RS1 instead of RS256
+	key := append([]byte{}, coseRSAKey...)
+	key[len(key)-2], key[len(key)-1] = 255, 254
+
+	if _, err := cose.ParseCOSE(key); err != cose.ErrUnsupportedAlgorithm {
+		t.Fatalf("expected ErrUnsupportedAlgorithm, got %v", err)
+	}
+}
+
+func TestParseCOSEOKP(t *testing.T) {
+	key, err := cose.ParseCOSE(coseOKPKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(key.(ed25519.PublicKey)) != ed25519.PublicKeySize {
+		t.Fatal("invalid public key size")
+	}
+}
+
+func TestParseCOSECurveMismatch(t *testing.T) {
+	// The curve of the key is P-384, while its algorithm is ES256
+	key := append([]byte{}, coseKey...)
+	key[6] = 2
+
+	if _, err := cose.ParseCOSE(key); err != cose.ErrCurveMismatch {
+		t.Fatalf("expected ErrCurveMismatch, got %v", err)
+	}
+}
+
+func TestParseCOSECompressed(t *testing.T) {
+	expected, err := cose.ParseCOSE(coseKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// compressed returns coseKey with the y-coordinate replaced by the sign bit
+	compressed := func(sign bool) []byte {
+		key := append([]byte{}, coseKey[:43]...)
+		if sign {
+			return append(key, 0xf5)
+		}
+		return append(key, 0xf4)
+	}
+
+	x, y := expected.(*ecdsa.PublicKey).X, expected.(*ecdsa.PublicKey).Y
+	sign := y.Bit(0) == 1
+	key, err := cose.ParseCOSE(compressed(sign))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.(*ecdsa.PublicKey).X.Cmp(x) != 0 || key.(*ecdsa.PublicKey).Y.Cmp(y) != 0 {
+		t.Fatal("expected decompressed key to equal the uncompressed key")
+	}
+
+	key, err = cose.ParseCOSE(compressed(!sign))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.(*ecdsa.PublicKey).Y.Cmp(y) == 0 {
+		t.Fatal("expected decompressed key with the other sign to differ")
+	}
+
+	// There is no point with the x-coordinate 1 on P-256
+	invalid := compressed(sign)
+	copy(invalid[10:42], make([]byte, 32))
+	invalid[41] = 1
+	if _, err := cose.ParseCOSE(invalid); err != cose.ErrInvalidCOSEKey {
+		t.Fatalf("expected ErrInvalidCOSEKey, got %v", err)
+	}
+}
+
+func TestParseCOSENotOnCurve(t *testing.T) {
+	key := append([]byte{}, coseKey...)
+	key[len(key)-1] ^= 1
+
+	if _, err := cose.ParseCOSE(key); err != cose.ErrInvalidCOSEKey {
+		t.Fatalf("expected ErrInvalidCOSEKey, got %v", err)
+	}
+}
+
+func TestParseCOSENonStandard(t *testing.T) {
+	// 2G on secp256k1 and the public key of the first Ed448 test vector of RFC 8032
+	x, _ := hex.DecodeString("c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5")
+	y, _ := hex.DecodeString("1ae168fea63dc339a3c58419466ceaeef7f632653266d0e1236431a950cfe52a")
+	secp256k1Key := append(append(append([]byte{0xa5, 0x01, 0x02, 0x03, 0x38, 0x2e, 0x20, 0x08, 0x21, 0x58, 0x20}, x...), 0x22, 0x58, 0x20), y...)
+	ed448PublicKey, _ := hex.DecodeString("5fd7449b59b461fd2ce787ec616ad46a1da1342485a70e1f8a0ea75d80e96778edf124769b46c7061bd6783df1e50f6cd1fa1abeafe8256180")
+	ed448Key := append([]byte{0xa4, 0x01, 0x01, 0x03, 0x27, 0x20, 0x07, 0x21, 0x58, 0x39}, ed448PublicKey...)
+
+	for _, test := range []struct {
+		key         []byte
+		nonStandard bool
+	}{
+		{coseKey, false},
+		{secp256k1Key, true},
+		{ed448Key, true},
+	} {
+		key, err := cose.ParseCOSE(test.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cose.IsNonStandardKey(key) != test.nonStandard {
+			t.Fatalf("expected IsNonStandardKey to be %t for %T", test.nonStandard, key)
+		}
+	}
+
+	t.Run("ES256K", func(t *testing.T) {
+		key, err := cose.ParseCOSE(secp256k1Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicKey := key.(*ecdsa.PublicKey)
+		if publicKey.Curve != cose.Secp256k1() {
+			t.Fatalf("unexpected curve %s", publicKey.Curve.Params().Name)
+		}
+		if x, y := publicKey.Curve.ScalarBaseMult([]byte{2}); x.Cmp(publicKey.X) != 0 || y.Cmp(publicKey.Y) != 0 {
+			t.Fatal("expected public key to be 2G")
+		}
+
+		// The y-coordinate of 2G is even
+		compressed, err := cose.ParseCOSE(append(secp256k1Key[:44:44], 0xf4))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compressed.(*ecdsa.PublicKey).Y.Cmp(publicKey.Y) != 0 {
+			t.Fatal("expected decompressed key to equal the uncompressed key")
+		}
+
+		invalid := append([]byte{}, secp256k1Key...)
+		invalid[len(invalid)-1] ^= 1
+		if _, err := cose.ParseCOSE(invalid); err != cose.ErrInvalidCOSEKey {
+			t.Fatalf("expected ErrInvalidCOSEKey, got %v", err)
+		}
+
+		hash := sha256.Sum256([]byte("message"))
+		sig, err := ecdsa.SignASN1(rand.Reader, &ecdsa.PrivateKey{PublicKey: *publicKey, D: big.NewInt(2)}, hash[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ecdsa.VerifyASN1(publicKey, hash[:], sig) {
+			t.Fatal("expected signature to be valid")
+		}
+		hash[0] ^= 1
+		if ecdsa.VerifyASN1(publicKey, hash[:], sig) {
+			t.Fatal("expected signature to be invalid")
+		}
+
+		if alg, err := cose.Algorithm(secp256k1Key); err != nil || alg != -47 {
+			t.Fatalf("expected algorithm -47, got %d (%v)", alg, err)
+		}
+	})
+
+	t.Run("Ed448", func(t *testing.T) {
+		key, err := cose.ParseCOSE(ed448Key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicKey := key.(cose.Ed448PublicKey)
+
+		// The first two test vectors of RFC 8032
+		for _, test := range []struct {
+			publicKey cose.Ed448PublicKey
+			message   string
+			signature string
+		}{
+			{publicKey, "", "533a37f6bbe457251f023c0d88f976ae2dfb504a843e34d2074fd823d41a591f2b233f034f628281f2fd7a22ddd47d7828c59bd0a21bfd3980ff0d2028d4b18a9df63e006c5d1c2d345b925d8dc00b4104852db99ac5c7cdda8530a113a0f4dbb61149f05a7363268c71d95808ff2e652600"},
+			{mustDecodeHex("43ba28f430cdff456ae531545f7ecd0ac834a55d9358c0372bfa0c6c6798c0866aea01eb00742802b8438ea4cb82169c235160627b4c3a9480"), "03", "26b8f91727bd62897af15e41eb43c377efb9c610d48f2335cb0bd0087810f4352541b143c4b981b7e18f62de8ccdf633fc1bf037ab7cd779805e0dbcc0aae1cbcee1afb2e027df36bc04dcecbf154336c19f0af7e0a6472905e799f1953d2a0ff3348ab21aa4adafd1d234441cf807c03a00"},
+		} {
+			message, signature := mustDecodeHex(test.message), mustDecodeHex(test.signature)
+			if !test.publicKey.Verify(message, signature) {
+				t.Fatal("expected signature to be valid")
+			}
+			signature[0] ^= 1
+			if test.publicKey.Verify(message, signature) {
+				t.Fatal("expected signature to be invalid")
+			}
+		}
+
+		invalid := append([]byte{}, ed448Key...)
+		invalid[len(invalid)-1] = 0x01
+		if _, err := cose.ParseCOSE(invalid); err != cose.ErrInvalidCOSEKey {
+			t.Fatalf("expected ErrInvalidCOSEKey, got %v", err)
+		}
+	})
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestAlgorithm(t *testing.T) {
+	for _, test := range []struct {
+		key []byte
+		alg int64
+	}{
+		{coseKey, -7},
+		{coseRSAKey, -257},
+		{coseOKPKey, -8},
+	} {
+		alg, err := cose.Algorithm(test.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if alg != test.alg {
+			t.Fatalf("expected algorithm %d, got %d", test.alg, alg)
+		}
+	}
+
+	if _, err := cose.Algorithm([]byte{0xa0}); err != cose.ErrMissingKeyType {
+		t.Fatalf("expected ErrMissingKeyType, got %v", err)
+	}
+}
+
+func TestJWK(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		key  []byte
+	}{
+		{"ES256", coseKey},
+		{"RS256", coseRSAKey},
+		{"EdDSA", coseOKPKey},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			jwk, err := cose.JWK(test.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if jwk.Alg != test.name {
+				t.Fatalf("expected algorithm %s, got %s", test.name, jwk.Alg)
+			}
+
+			// The key must be the same key when it is parsed by a JOSE library
+			b, err := json.Marshal(jwk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var parsed jose.JSONWebKey
+			if err := json.Unmarshal(b, &parsed); err != nil {
+				t.Fatalf("%s: %v", b, err)
+			}
+			key, err := cose.ParseCOSE(test.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(parsed.Key, key) {
+				t.Fatalf("expected key %v, got %v", key, parsed.Key)
+			}
+		})
+	}
+
+	t.Run("Padded coordinates", func(t *testing.T) {
+		// Generate a key of which the x-coordinate has a leading zero byte
+		var privateKey *ecdsa.PrivateKey
+		for privateKey == nil || privateKey.X.BitLen() > 248 {
+			var err error
+			if privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+				t.Fatal(err)
+			}
+		}
+		x, y := make([]byte, 32), make([]byte, 32)
+		copy(x[32-len(privateKey.X.Bytes()):], privateKey.X.Bytes())
+		copy(y[32-len(privateKey.Y.Bytes()):], privateKey.Y.Bytes())
+		key := append(append(append([]byte{0xa5, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01, 0x21, 0x58, 0x20}, x...), 0x22, 0x58, 0x20), y...)
+
+		jwk, err := cose.JWK(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if jwk.Crv != "P-256" || jwk.X != base64.RawURLEncoding.EncodeToString(x) || jwk.Y != base64.RawURLEncoding.EncodeToString(y) {
+			t.Fatalf("unexpected key %+v", jwk)
+		}
+	})
+
+	if _, err := cose.JWK([]byte{0xa0}); err != cose.ErrMissingKeyType {
+		t.Fatalf("expected ErrMissingKeyType, got %v", err)
+	}
+}
+
 var coseKey = []byte{165, 1, 2, 3, 38, 32, 1, 33, 88, 32, 216, 135, 166, 35, 155, 95, 158, 137, 152, 93, 252, 213, 238, 69, 20, 97, 196, 158, 87, 181, 241, 175, 77, 207, 20, 244, 241, 201, 179, 138, 100, 239, 34, 88, 32, 163, 48, 62, 105, 84, 41, 231, 50, 219, 25, 77, 105, 244, 230, 187, 108, 215, 105, 155, 163, 198, 146, 133, 33, 252, 5, 101, 90, 174, 75, 99, 141}
+
+var coseRSAKey = []byte{164, 33, 67, 1, 0, 1, 32, 89, 1, 0, 171, 207, 117, 196, 123, 194, 243, 204, 117, 213, 123, 207, 216, 12, 58, 206, 249, 61, 171, 247, 221, 71, 230, 64, 194, 204, 112, 108, 223, 47, 167, 243, 47, 229, 244, 153, 219, 228, 35, 83, 129, 117, 77, 35, 106, 245, 141, 58, 96, 243, 49, 98, 201, 76, 110, 225, 185, 243, 167, 132, 124, 234, 97, 126, 194, 34, 41, 128, 60, 65, 142, 160, 146, 131, 10, 140, 44, 123, 28, 102, 53, 64, 141, 168, 248, 116, 131, 229, 88, 207, 108, 254, 226, 164, 170, 200, 204, 157, 206, 34, 243, 6, 42, 108, 161, 79, 73, 254, 6, 10, 157, 135, 192, 156, 1, 41, 154, 57, 198, 238, 132, 96, 242, 29, 168, 194, 130, 143, 247, 56, 124, 52, 39, 103, 230, 2, 247, 186, 191, 83, 81, 219, 108, 129, 51, 176, 53, 163, 83, 251, 132, 156, 79, 180, 122, 163, 143, 64, 81, 247, 112, 151, 136, 11, 96, 245, 136, 74, 169, 76, 152, 214, 56, 103, 216, 13, 151, 139, 198, 124, 188, 158, 138, 179, 64, 144, 112, 220, 81, 162, 49, 243, 19, 205, 232, 47, 150, 76, 14, 8, 103, 184, 0, 3, 208, 117, 202, 74, 245, 153, 159, 79, 97, 218, 34, 107, 112, 227, 120, 112, 213, 37, 59, 97, 33, 92, 35, 185, 135, 115, 43, 157, 245, 18, 244, 152, 28, 185, 71, 136, 201, 129, 129, 203, 41, 124, 16, 5, 82, 30, 19, 254, 194, 21, 25, 33, 1, 3, 3, 57, 1, 0}
+
+var coseOKPKey = []byte{164, 33, 88, 32, 185, 206, 2, 247, 17, 18, 208, 22, 7, 157, 137, 20, 188, 217, 183, 225, 87, 169, 118, 87, 39, 68, 93, 74, 148, 189, 54, 5, 120, 32, 190, 54, 32, 6, 1, 1, 3, 39}
@@ -4,21 +4,35 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 )
 
 func parseECDSA(alg int64, m map[int]interface{}) (interface{}, error) {
 	var curve elliptic.Curve
+	var crv uint64
 	switch alg {
 	case -7:
-		curve = elliptic.P256()
+		curve, crv = elliptic.P256(), 1
 	case -35:
-		curve = elliptic.P384()
+		curve, crv = elliptic.P384(), 2
 	case -36:
-		curve = elliptic.P521()
+		curve, crv = elliptic.P521(), 3
+	case -47:
+		curve, crv = Secp256k1(), 8
 	default:
 		return nil, ErrUnsupportedAlgorithm
 	}
 
+	// The curve must be the curve of the algorithm, see https://tools.ietf.org/html/rfc8152#section-13.1
+	rawCrv, ok := m[-1]
+	if !ok {
+		return nil, ErrInvalidFormat
+	}
+	if v, ok := rawCrv.(uint64); !ok || v != crv {
+		return nil, ErrCurveMismatch
+	}
+
 	rawD, ok := m[-4]
 	if !ok { // public key if there is no d
 		return parseECDSAPublicKey(curve, m)
@@ -46,17 +60,34 @@ func parseECDSAPublicKey(curve elliptic.Curve, m map[int]interface{}) (*ecdsa.Pu
 		return nil, ErrInvalidFormat
 	}
 
-	rawY, ok := m[-3]
-	if !ok {
+	// The coordinates are encoded with the byte length of the curve, for instance 66 bytes for P-521
+	size := (curve.Params().BitSize + 7) / 8
+	if len(xBytes) != size {
 		return nil, ErrInvalidFormat
 	}
-	yBytes, ok := rawY.([]byte)
-	if !ok {
+	x := big.NewInt(0).SetBytes(xBytes)
+
+	// The y-coordinate is either encoded, or is the sign bit of a compressed point, see
+	// https://tools.ietf.org/html/rfc8152#section-13.1.1
+	var y *big.Int
+	switch rawY := m[-3].(type) {
+	case []byte:
+		if len(rawY) != size {
+			return nil, ErrInvalidFormat
+		}
+		y = big.NewInt(0).SetBytes(rawY)
+	case bool:
+		y = decompressY(curve, x, rawY)
+		if y == nil {
+			return nil, ErrInvalidCOSEKey
+		}
+	default:
 		return nil, ErrInvalidFormat
 	}
 
-	x := big.NewInt(0).SetBytes(xBytes)
-	y := big.NewInt(0).SetBytes(yBytes)
+	if !curve.IsOnCurve(x, y) {
+		return nil, ErrInvalidCOSEKey
+	}
 
 	return &ecdsa.PublicKey{
 		Curve: curve,
@@ -64,3 +95,42 @@ func parseECDSAPublicKey(curve elliptic.Curve, m map[int]interface{}) (*ecdsa.Pu
 		Y:     y,
 	}, nil
 }
+
+// decompressY returns the y-coordinate of the point on curve with the x-coordinate x, of which the least significant
+// bit is set if sign is true, or nil if there is no such point.
+func decompressY(curve elliptic.Curve, x *big.Int, sign bool) *big.Int {
+	params := curve.Params()
+	if x.Cmp(params.P) >= 0 {
+		return nil
+	}
+
+	if curve == Secp256k1() {
+		// y² = x³ + b, which is not supported by elliptic.CurveParams
+		format := secp256k1.PubKeyFormatCompressedEven
+		if sign {
+			format = secp256k1.PubKeyFormatCompressedOdd
+		}
+		key, err := secp256k1.ParsePubKey(append([]byte{format}, padded(x, 32)...))
+		if err != nil {
+			return nil
+		}
+		return key.Y()
+	}
+
+	// y² = x³ - 3x + b
+	y := new(big.Int).Mul(x, x)
+	y.Mul(y, x)
+	threeX := new(big.Int).Lsh(x, 1)
+	threeX.Add(threeX, x)
+	y.Sub(y, threeX)
+	y.Add(y, params.B)
+	y.Mod(y, params.P)
+
+	if y.ModSqrt(y, params.P) == nil {
+		return nil
+	}
+	if sign != (y.Bit(0) == 1) {
+		y.Sub(params.P, y)
+	}
+	return y
+}
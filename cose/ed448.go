@@ -0,0 +1,36 @@
+package cose
+
+import (
+	"github.com/cloudflare/circl/ecc/goldilocks"
+	"github.com/cloudflare/circl/sign/ed448"
+)
+
+const (
+	// Ed448PublicKeySize is the size in bytes of an Ed448 public key.
+	Ed448PublicKeySize = ed448.PublicKeySize
+	// Ed448SignatureSize is the size in bytes of an Ed448 signature.
+	Ed448SignatureSize = ed448.SignatureSize
+)
+
+// Ed448PublicKey is an Ed448 public key of RFC 8032, as used by the EdDSA algorithm with the Ed448 curve.
+type Ed448PublicKey []byte
+
+// Verify reports whether sig is a valid Ed448 signature of message by the public key, without a context, see
+// https://tools.ietf.org/html/rfc8032#section-5.2.7. The implementation of github.com/cloudflare/circl is used.
+func (k Ed448PublicKey) Verify(message, sig []byte) bool {
+	if len(k) != Ed448PublicKeySize || len(sig) != Ed448SignatureSize {
+		return false
+	}
+	return ed448.Verify(ed448.PublicKey(k), message, sig, "")
+}
+
+// isEd448Point reports whether b is the encoding of a point on the Ed448 curve. The bits of the last byte other than
+// the sign bit of the x-coordinate belong to the y-coordinate and must be zero, since it is less than p, which is not
+// checked by goldilocks.FromBytes.
+func isEd448Point(b []byte) bool {
+	if len(b) != Ed448PublicKeySize || b[len(b)-1]&0x7f != 0 {
+		return false
+	}
+	_, err := goldilocks.FromBytes(b)
+	return err == nil
+}
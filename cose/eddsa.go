@@ -0,0 +1,60 @@
+package cose
+
+import (
+	"crypto/ed25519"
+)
+
+func parseEdDSA(alg int64, m map[int]interface{}) (interface{}, error) {
+	if alg != -8 {
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	rawCrv, ok := m[-1]
+	if !ok {
+		return nil, ErrInvalidFormat
+	}
+	crv, ok := rawCrv.(uint64)
+	if !ok {
+		return nil, ErrInvalidFormat
+	}
+
+	// https://tools.ietf.org/html/rfc8152#section-13.2
+	switch crv {
+	case 6: // Ed25519
+		return parseEd25519PublicKey(m)
+	case 7: // Ed448
+		return parseEd448PublicKey(m)
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+}
+
+func parseEd25519PublicKey(m map[int]interface{}) (ed25519.PublicKey, error) {
+	rawX, ok := m[-2]
+	if !ok {
+		return nil, ErrInvalidFormat
+	}
+	xBytes, ok := rawX.([]byte)
+	if !ok || len(xBytes) != ed25519.PublicKeySize {
+		return nil, ErrInvalidFormat
+	}
+
+	return ed25519.PublicKey(xBytes), nil
+}
+
+func parseEd448PublicKey(m map[int]interface{}) (Ed448PublicKey, error) {
+	rawX, ok := m[-2]
+	if !ok {
+		return nil, ErrInvalidFormat
+	}
+	xBytes, ok := rawX.([]byte)
+	if !ok || len(xBytes) != Ed448PublicKeySize {
+		return nil, ErrInvalidFormat
+	}
+
+	if !isEd448Point(xBytes) {
+		return nil, ErrInvalidCOSEKey
+	}
+
+	return Ed448PublicKey(xBytes), nil
+}
@@ -0,0 +1,101 @@
+package cose
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+)
+
+// JSONWebKey is a public key in the JSON Web Key format of RFC 7517, which is used by JOSE.
+type JSONWebKey struct {
+	// Kty is the key type, which is "EC", "RSA" or "OKP".
+	Kty string `json:"kty"`
+	// Alg is the JOSE name of the algorithm of the key, if it has one.
+	Alg string `json:"alg,omitempty"`
+	// Crv is the curve of EC and OKP keys.
+	Crv string `json:"crv,omitempty"`
+	// X is the base64url encoded x-coordinate of EC keys, or the public key of OKP keys.
+	X string `json:"x,omitempty"`
+	// Y is the base64url encoded y-coordinate of EC keys.
+	Y string `json:"y,omitempty"`
+	// N is the base64url encoded modulus of RSA keys.
+	N string `json:"n,omitempty"`
+	// E is the base64url encoded exponent of RSA keys.
+	E string `json:"e,omitempty"`
+}
+
+// joseAlgorithms are the JOSE names of the COSE algorithms, see https://tools.ietf.org/html/rfc7518#section-3.1 and
+// https://tools.ietf.org/html/rfc8812#section-3.2. RS1 has no JOSE name.
+var joseAlgorithms = map[int64]string{
+	-7:   "ES256",
+	-35:  "ES384",
+	-36:  "ES512",
+	-47:  "ES256K",
+	-8:   "EdDSA",
+	-37:  "PS256",
+	-38:  "PS384",
+	-39:  "PS512",
+	-257: "RS256",
+	-258: "RS384",
+	-259: "RS512",
+}
+
+// JWK converts a raw COSE key into a JSON Web Key, for instance to use the key in other systems. The key is validated
+// like it is by ParseCOSE.
+func JWK(buf []byte) (*JSONWebKey, error) {
+	m, err := decode(buf)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParseCOSEMap(m)
+	if err != nil {
+		return nil, err
+	}
+
+	encode := base64.RawURLEncoding.EncodeToString
+	// The algorithm has been validated by ParseCOSEMap
+	jwk := &JSONWebKey{Alg: joseAlgorithms[m[3].(int64)]}
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		jwk.Kty = "EC"
+		switch key.Curve {
+		case elliptic.P256():
+			jwk.Crv = "P-256"
+		case elliptic.P384():
+			jwk.Crv = "P-384"
+		case elliptic.P521():
+			jwk.Crv = "P-521"
+		case Secp256k1():
+			jwk.Crv = "secp256k1"
+		default:
+			return nil, ErrUnsupportedAlgorithm
+		}
+		// The coordinates must be padded to the size of the curve
+		size := (key.Curve.Params().BitSize + 7) / 8
+		jwk.X, jwk.Y = encode(padded(key.X, size)), encode(padded(key.Y, size))
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encode(key.N.Bytes())
+		jwk.E = encode(big.NewInt(int64(key.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty, jwk.Crv, jwk.X = "OKP", "Ed25519", encode(key)
+	case Ed448PublicKey:
+		jwk.Kty, jwk.Crv, jwk.X = "OKP", "Ed448", encode(key)
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+
+	return jwk, nil
+}
+
+// padded returns the big-endian bytes of v, padded with leading zeros to size bytes.
+func padded(v *big.Int, size int) []byte {
+	b := v.Bytes()
+	if len(b) >= size {
+		return b
+	}
+	return append(make([]byte, size-len(b)), b...)
+}
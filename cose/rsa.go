@@ -0,0 +1,48 @@
+package cose
+
+import (
+	"crypto/rsa"
+	"math/big"
+)
+
+// parseRSA parses an RSA public key. RS1 (-65535) is not accepted, since it is only used for the attestation signatures
+// of TPMs, which are not verified with a COSE key, and SHA-1 signatures are rejected by crypto/x509.
+func parseRSA(alg int64, m map[int]interface{}) (interface{}, error) {
+	switch alg {
+	case -257, -258, -259, -37, -38, -39:
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	return parseRSAPublicKey(m)
+}
+
+func parseRSAPublicKey(m map[int]interface{}) (*rsa.PublicKey, error) {
+	rawN, ok := m[-1]
+	if !ok {
+		return nil, ErrInvalidFormat
+	}
+	nBytes, ok := rawN.([]byte)
+	if !ok || len(nBytes) == 0 {
+		return nil, ErrInvalidFormat
+	}
+
+	rawE, ok := m[-2]
+	if !ok {
+		return nil, ErrInvalidFormat
+	}
+	eBytes, ok := rawE.([]byte)
+	if !ok || len(eBytes) == 0 || len(eBytes) > 4 {
+		return nil, ErrInvalidFormat
+	}
+
+	e := 0
+	for _, b := range eBytes {
+		e = e<<8 | int(b)
+	}
+
+	return &rsa.PublicKey{
+		N: big.NewInt(0).SetBytes(nBytes),
+		E: e,
+	}, nil
+}
@@ -0,0 +1,13 @@
+package cose
+
+import (
+	"crypto/elliptic"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// Secp256k1 returns the secp256k1 curve of SEC 2, which is used by the ES256K algorithm. Since elliptic.CurveParams
+// only implements curves with a = -3, the implementation of github.com/decred/dcrd/dcrec/secp256k1 is used.
+func Secp256k1() elliptic.Curve {
+	return secp256k1.S256()
+}
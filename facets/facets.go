@@ -0,0 +1,217 @@
+// facets implements the resolution of the trusted facets of a FIDO AppID, which lists the origins and applications
+// that may use the credentials of a legacy FIDO U2F application.
+//
+// See https://fidoalliance.org/specs/fido-v2.0-id-20180227/fido-appid-and-facets-v2.0-id-20180227.html
+package facets // import "github.com/keycloud/webauthn/facets"
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ContentType is the media type of a trusted facets document.
+const ContentType = "application/fido.trusted-apps+json"
+
+// DefaultCacheDuration is the default duration for which the trusted facets of an AppID are cached.
+const DefaultCacheDuration = time.Hour
+
+// maxDocumentSize is the maximum size of a trusted facets document.
+const maxDocumentSize = 1 << 20
+
+// ErrNotTrusted is returned by Resolver.Check if the facet is not a trusted facet of the AppID.
+var ErrNotTrusted = errors.New("facets: facet is not trusted by the AppID")
+
+// Version is the version of the FIDO protocol to which a list of trusted facets applies.
+type Version struct {
+	Major int `json:"major"`
+	Minor int `json:"minor"`
+}
+
+// TrustedFacets is a list of trusted facets for a version of the FIDO protocol.
+type TrustedFacets struct {
+	Version Version  `json:"version"`
+	IDs     []string `json:"ids"`
+}
+
+// Document is a trusted facets document, which is served at the URL of an AppID.
+type Document struct {
+	TrustedFacets []TrustedFacets `json:"trustedFacets"`
+}
+
+// Resolver resolves the trusted facets of AppIDs. The trusted facets document is downloaded over HTTPS and redirects
+// are only followed if they are authorized by the FIDO-AppID-Redirect-Authorized header. The zero value uses
+// http.DefaultClient and DefaultCacheDuration. A Resolver is safe for concurrent use.
+//
+// The facets are not restricted to the registrable domain of the AppID, since the Relying Party is expected to resolve
+// only the AppIDs that it controls.
+type Resolver struct {
+	// Client is the HTTP client that is used to download trusted facets documents. If it is nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+	// CacheDuration is the duration for which the trusted facets of an AppID are cached. If it is zero,
+	// DefaultCacheDuration is used.
+	CacheDuration time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	facets  []string
+	expires time.Time
+}
+
+// Check returns nil if facetID, for instance the origin "https://login.example.com", is one of the trusted facets of
+// appID, or ErrNotTrusted if it is not. An AppID that is not an HTTPS URL is only trusted by the facet that is equal to
+// it. If the trusted facets could not be resolved, another error is returned.
+func (r *Resolver) Check(ctx context.Context, appID, facetID string) error {
+	if appID == facetID {
+		return nil
+	}
+
+	facets, err := r.TrustedFacets(ctx, appID)
+	if err != nil {
+		return err
+	}
+
+	facetID = strings.TrimSuffix(facetID, "/")
+	for _, facet := range facets {
+		if facet == facetID {
+			return nil
+		}
+	}
+	return ErrNotTrusted
+}
+
+// TrustedFacets returns the trusted facets of appID for version 1.0 of the FIDO protocol, which is the version that is
+// used by the FIDO U2F JavaScript API. Web facets are returned as origins without a trailing slash, and entries that
+// are HTTPS URLs with a path are ignored.
+func (r *Resolver) TrustedFacets(ctx context.Context, appID string) ([]string, error) {
+	r.mu.Lock()
+	entry, ok := r.entries[appID]
+	r.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.facets, nil
+	}
+
+	doc, err := r.fetch(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	var facets []string
+	for _, trusted := range doc.TrustedFacets {
+		if trusted.Version.Major != 1 || trusted.Version.Minor != 0 {
+			continue
+		}
+		for _, id := range trusted.IDs {
+			if facet, ok := normalizeFacet(id); ok {
+				facets = append(facets, facet)
+			}
+		}
+	}
+
+	duration := r.CacheDuration
+	if duration == 0 {
+		duration = DefaultCacheDuration
+	}
+
+	r.mu.Lock()
+	if r.entries == nil {
+		r.entries = make(map[string]cacheEntry)
+	}
+	r.entries[appID] = cacheEntry{facets, time.Now().Add(duration)}
+	r.mu.Unlock()
+
+	return facets, nil
+}
+
+func (r *Resolver) fetch(ctx context.Context, appID string) (*Document, error) {
+	if u, err := url.Parse(appID); err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("facets: AppID %q is not an HTTPS URL", appID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, appID, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("facets: unable to download trusted facets of %s: %v", appID, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("facets: unexpected status %s for trusted facets of %s", resp.Status, appID)
+	}
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err != nil || mediaType != ContentType {
+		return nil, fmt.Errorf("facets: unexpected content type %q for trusted facets of %s", resp.Header.Get("Content-Type"), appID)
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDocumentSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("facets: unable to download trusted facets of %s: %v", appID, err)
+	}
+	if len(b) > maxDocumentSize {
+		return nil, fmt.Errorf("facets: trusted facets of %s are too large", appID)
+	}
+
+	doc := &Document{}
+	if err := json.Unmarshal(b, doc); err != nil {
+		return nil, fmt.Errorf("facets: invalid trusted facets of %s: %v", appID, err)
+	}
+	return doc, nil
+}
+
+// client returns a copy of the HTTP client that only follows redirects to HTTPS URLs that have been authorized by the
+// FIDO-AppID-Redirect-Authorized header.
+func (r *Resolver) client() *http.Client {
+	c := http.DefaultClient
+	if r.Client != nil {
+		c = r.Client
+	}
+
+	client := *c
+	checkRedirect := c.CheckRedirect
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if req.Response == nil || req.Response.Header.Get("FIDO-AppID-Redirect-Authorized") != "true" {
+			return fmt.Errorf("redirect to %s is not authorized", req.URL)
+		}
+		if req.URL.Scheme != "https" {
+			return fmt.Errorf("redirect to %s is not HTTPS", req.URL)
+		}
+		if checkRedirect != nil {
+			return checkRedirect(req, via)
+		}
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after 10 redirects")
+		}
+		return nil
+	}
+	return &client
+}
+
+// normalizeFacet returns the facet ID of a trusted facet, which is the origin of HTTPS facets. Other facets, such as
+// "android:apk-key-hash:...", are returned unchanged.
+func normalizeFacet(id string) (string, bool) {
+	if !strings.HasPrefix(id, "https://") {
+		return id, id != ""
+	}
+
+	u, err := url.Parse(id)
+	if err != nil || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return "", false
+	}
+	return "https://" + u.Host, true
+}
@@ -0,0 +1,87 @@
+package facets_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/keycloud/webauthn/facets"
+)
+
+const document = `{"trustedFacets":[{"version":{"major":1,"minor":0},"ids":["https://login.example.com","https://app.example.com/","https://example.com/path","android:apk-key-hash:key-hash"]},{"version":{"major":1,"minor":1},"ids":["https://other.example.com"]}]}`
+
+func TestResolver(t *testing.T) {
+	var requests int
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/app-id.json":
+			rw.Header().Set("Content-Type", facets.ContentType)
+			rw.Write([]byte(document))
+		case "/redirect":
+			rw.Header().Set("FIDO-AppID-Redirect-Authorized", "true")
+			http.Redirect(rw, r, "/app-id.json", http.StatusFound)
+		case "/unauthorized-redirect":
+			http.Redirect(rw, r, "/app-id.json", http.StatusFound)
+		case "/wrong-content-type":
+			rw.Header().Set("Content-Type", "application/json")
+			rw.Write([]byte(document))
+		default:
+			http.NotFound(rw, r)
+		}
+	}))
+	defer server.Close()
+
+	resolver := &facets.Resolver{Client: server.Client()}
+	appID := server.URL + "/app-id.json"
+
+	trusted, err := resolver.TrustedFacets(context.Background(), appID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"https://login.example.com", "https://app.example.com", "android:apk-key-hash:key-hash"}
+	if !reflect.DeepEqual(trusted, expected) {
+		t.Fatalf("expected trusted facets %v, got %v", expected, trusted)
+	}
+
+	for _, test := range []struct {
+		facetID string
+		err     error
+	}{
+		{"https://login.example.com", nil},
+		{"https://app.example.com/", nil},
+		{"https://example.com", facets.ErrNotTrusted},
+		{"https://other.example.com", facets.ErrNotTrusted},
+	} {
+		if err := resolver.Check(context.Background(), appID, test.facetID); err != test.err {
+			t.Fatalf("expected %v for %s, got %v", test.err, test.facetID, err)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("expected trusted facets to be cached, got %d requests", requests)
+	}
+
+	// An AppID that is not an HTTPS URL is trusted by the equal facet only
+	if err := resolver.Check(context.Background(), "https://login.example.com", "https://login.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := resolver.Check(context.Background(), "http://login.example.com", "https://login.example.com"); err == nil {
+		t.Fatal("expected error for AppID that is not an HTTPS URL")
+	}
+
+	t.Run("Authorized redirect", func(t *testing.T) {
+		if err := resolver.Check(context.Background(), server.URL+"/redirect", "https://login.example.com"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	for _, path := range []string{"/unauthorized-redirect", "/wrong-content-type", "/not-found"} {
+		t.Run(path, func(t *testing.T) {
+			if _, err := resolver.TrustedFacets(context.Background(), server.URL+path); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -3,8 +3,10 @@ module github.com/keycloud/webauthn
 go 1.13
 
 require (
+	github.com/cloudflare/circl v1.1.0
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.0.1
 	github.com/pkg/errors v0.9.1
 	github.com/ugorji/go/codec v1.1.7
-	golang.org/x/crypto v0.0.0-20200221231518-2aa609cf4a9d // indirect
+	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519
 	gopkg.in/square/go-jose.v2 v2.4.1
 )
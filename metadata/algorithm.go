@@ -0,0 +1,56 @@
+package metadata
+
+// coseAlgorithms maps the authentication algorithms of the FIDO Registry of Predefined Values to COSE algorithm
+// identifiers. Algorithms without a COSE algorithm identifier, such as "sm2_sm3_raw", are not listed.
+var coseAlgorithms = map[string]int64{
+	"secp256r1_ecdsa_sha256_raw": -7,
+	"secp256r1_ecdsa_sha256_der": -7,
+	"secp384r1_ecdsa_sha384_raw": -35,
+	"secp521r1_ecdsa_sha512_raw": -36,
+	"rsassa_pss_sha256_raw":      -37,
+	"rsassa_pss_sha256_der":      -37,
+	"rsassa_pss_sha384_raw":      -38,
+	"rsassa_pss_sha512_raw":      -39,
+	"secp256k1_ecdsa_sha256_raw": -47,
+	"secp256k1_ecdsa_sha256_der": -47,
+	"ed25519_eddsa_sha512_raw":   -8,
+	"ed448_eddsa_sha512_raw":     -8,
+	"rsassa_pkcsv15_sha256_raw":  -257,
+	"rsa_emsa_pkcs1_sha256_raw":  -257,
+	"rsa_emsa_pkcs1_sha256_der":  -257,
+	"rsassa_pkcsv15_sha384_raw":  -258,
+	"rsassa_pkcsv15_sha512_raw":  -259,
+	"rsassa_pkcsv15_sha1_raw":    -65535,
+}
+
+// COSEAlgorithms returns the COSE algorithm identifiers of the authentication algorithms supported by the
+// authenticator, for instance -7 for "secp256r1_ecdsa_sha256_raw". Algorithms without a COSE algorithm identifier are
+// omitted.
+func (m *MetadataStatement) COSEAlgorithms() []int64 {
+	var algorithms []int64
+	for _, name := range m.AuthenticationAlgorithms {
+		alg, ok := coseAlgorithms[name]
+		if !ok {
+			continue
+		}
+		if !containsAlgorithm(algorithms, alg) {
+			algorithms = append(algorithms, alg)
+		}
+	}
+	return algorithms
+}
+
+// SupportsAlgorithm returns whether the COSE algorithm identifier alg is one of the authentication algorithms supported
+// by the authenticator.
+func (m *MetadataStatement) SupportsAlgorithm(alg int64) bool {
+	return containsAlgorithm(m.COSEAlgorithms(), alg)
+}
+
+func containsAlgorithm(algorithms []int64, alg int64) bool {
+	for _, a := range algorithms {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,6 @@
+// metadata implements a client of the FIDO Metadata Service (MDS3), which publishes metadata statements and status
+// reports of FIDO authenticators in a signed BLOB. The metadata can be used to decide whether an authenticator is
+// trusted and which root certificates its attestations must chain up to.
+//
+// See https://fidoalliance.org/specs/mds/fido-metadata-service-v3.0-ps-20210518.html
+package metadata // import "github.com/keycloud/webauthn/metadata"
@@ -0,0 +1,216 @@
+package metadata
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+)
+
+// BLOB represents the payload of the metadata BLOB, i.e. the MetadataBLOBPayload dictionary.
+type BLOB struct {
+	// LegalHeader is the legal header of the BLOB.
+	LegalHeader string `json:"legalHeader"`
+	// Number is the serial number of the BLOB, which increases with every update.
+	Number int `json:"no"`
+	// NextUpdate is the date at which a new BLOB will be available at the latest, formatted as "2006-01-02".
+	NextUpdate string `json:"nextUpdate"`
+	// Entries contains the entries of all authenticators.
+	Entries []Entry `json:"entries"`
+}
+
+// Entry represents the metadata of a single authenticator, i.e. the MetadataBLOBPayloadEntry dictionary.
+type Entry struct {
+	// AAID is the Authenticator Attestation ID of UAF authenticators.
+	AAID string `json:"aaid,omitempty"`
+	// AAGUID is the AAGUID of FIDO2 authenticators, formatted as a UUID.
+	AAGUID string `json:"aaguid,omitempty"`
+	// AttestationCertificateKeyIdentifiers contains the hex encoded SHA-1 hashes of the public keys of the attestation
+	// certificates of U2F authenticators.
+	AttestationCertificateKeyIdentifiers []string `json:"attestationCertificateKeyIdentifiers,omitempty"`
+	// MetadataStatement is the metadata statement of the authenticator.
+	MetadataStatement *MetadataStatement `json:"metadataStatement,omitempty"`
+	// StatusReports contains the status reports of the authenticator, of which the latest is the current status.
+	StatusReports []StatusReport `json:"statusReports"`
+	// TimeOfLastStatusChange is the date of the last status change, formatted as "2006-01-02".
+	TimeOfLastStatusChange string `json:"timeOfLastStatusChange"`
+}
+
+// Status returns the current status of the authenticator, which is the status of the status report with the latest
+// effective date, or an empty status if there are no status reports.
+func (e *Entry) Status() AuthenticatorStatus {
+	var latest *StatusReport
+	for i := range e.StatusReports {
+		// The dates are formatted as "2006-01-02", so they can be compared as strings
+		if latest == nil || e.StatusReports[i].EffectiveDate >= latest.EffectiveDate {
+			latest = &e.StatusReports[i]
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	return latest.Status
+}
+
+// CertificationLevel returns the current certification status of the authenticator, which is the certification status
+// of the status report with the latest effective date, for instance StatusFIDOCertifiedL1. Status reports of other
+// statuses, such as StatusUpdateAvailable, are ignored. An empty status is returned if the authenticator has never
+// been certified.
+func (e *Entry) CertificationLevel() AuthenticatorStatus {
+	var latest *StatusReport
+	for i := range e.StatusReports {
+		if !e.StatusReports[i].Status.IsCertification() {
+			continue
+		}
+		if latest == nil || e.StatusReports[i].EffectiveDate >= latest.EffectiveDate {
+			latest = &e.StatusReports[i]
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	return latest.Status
+}
+
+// AuthenticatorStatus is the status of an authenticator, as reported in a StatusReport.
+type AuthenticatorStatus string
+
+// The authenticator statuses defined in the FIDO Metadata Service specification.
+const (
+	StatusNotFIDOCertified          AuthenticatorStatus = "NOT_FIDO_CERTIFIED"
+	StatusFIDOCertified             AuthenticatorStatus = "FIDO_CERTIFIED"
+	StatusUserVerificationBypass    AuthenticatorStatus = "USER_VERIFICATION_BYPASS"
+	StatusAttestationKeyCompromise  AuthenticatorStatus = "ATTESTATION_KEY_COMPROMISE"
+	StatusUserKeyRemoteCompromise   AuthenticatorStatus = "USER_KEY_REMOTE_COMPROMISE"
+	StatusUserKeyPhysicalCompromise AuthenticatorStatus = "USER_KEY_PHYSICAL_COMPROMISE"
+	StatusUpdateAvailable           AuthenticatorStatus = "UPDATE_AVAILABLE"
+	StatusRevoked                   AuthenticatorStatus = "REVOKED"
+	StatusSelfAssertionSubmitted    AuthenticatorStatus = "SELF_ASSERTION_SUBMITTED"
+	StatusFIDOCertifiedL1           AuthenticatorStatus = "FIDO_CERTIFIED_L1"
+	StatusFIDOCertifiedL1Plus       AuthenticatorStatus = "FIDO_CERTIFIED_L1plus"
+	StatusFIDOCertifiedL2           AuthenticatorStatus = "FIDO_CERTIFIED_L2"
+	StatusFIDOCertifiedL2Plus       AuthenticatorStatus = "FIDO_CERTIFIED_L2plus"
+	StatusFIDOCertifiedL3           AuthenticatorStatus = "FIDO_CERTIFIED_L3"
+	StatusFIDOCertifiedL3Plus       AuthenticatorStatus = "FIDO_CERTIFIED_L3plus"
+)
+
+// IsCertification returns whether the status is a certification status, i.e. whether it reports the outcome of a FIDO
+// certification instead of, for instance, a compromise or an update.
+func (s AuthenticatorStatus) IsCertification() bool {
+	switch s {
+	case StatusNotFIDOCertified, StatusFIDOCertified, StatusSelfAssertionSubmitted,
+		StatusFIDOCertifiedL1, StatusFIDOCertifiedL1Plus, StatusFIDOCertifiedL2, StatusFIDOCertifiedL2Plus,
+		StatusFIDOCertifiedL3, StatusFIDOCertifiedL3Plus:
+		return true
+	}
+	return false
+}
+
+// CompromisedStatuses are the statuses of authenticators that are known to be compromised or revoked, which Relying
+// Parties commonly reject.
+var CompromisedStatuses = []AuthenticatorStatus{
+	StatusUserVerificationBypass,
+	StatusAttestationKeyCompromise,
+	StatusUserKeyRemoteCompromise,
+	StatusUserKeyPhysicalCompromise,
+	StatusRevoked,
+}
+
+// StatusReport represents a status report of an authenticator.
+type StatusReport struct {
+	// Status is the status of the authenticator.
+	Status AuthenticatorStatus `json:"status"`
+	// EffectiveDate is the date since when the status is effective, formatted as "2006-01-02".
+	EffectiveDate string `json:"effectiveDate,omitempty"`
+	// AuthenticatorVersion is the version of the authenticator to which the status applies.
+	AuthenticatorVersion uint32 `json:"authenticatorVersion,omitempty"`
+	// Certificate is the base64 encoded attestation certificate to which the status applies, if any.
+	Certificate string `json:"certificate,omitempty"`
+	// URL is the URL where additional information may be found related to the status.
+	URL string `json:"url,omitempty"`
+	// CertificationDescriptor describes the certified externally visible features of the authenticator.
+	CertificationDescriptor string `json:"certificationDescriptor,omitempty"`
+	// CertificateNumber is the unique identifier of the issued certification.
+	CertificateNumber string `json:"certificateNumber,omitempty"`
+	// CertificationPolicyVersion is the version of the certification policy.
+	CertificationPolicyVersion string `json:"certificationPolicyVersion,omitempty"`
+	// CertificationRequirementsVersion is the version of the security requirements.
+	CertificationRequirementsVersion string `json:"certificationRequirementsVersion,omitempty"`
+}
+
+// Version represents a version of the UAF, U2F or FIDO2 protocol that is supported by an authenticator.
+type Version struct {
+	Major uint16 `json:"major"`
+	Minor uint16 `json:"minor"`
+}
+
+// MetadataStatement contains the description of an authenticator model, such as its capabilities and the root
+// certificates of its attestations.
+type MetadataStatement struct {
+	// LegalHeader is the legal header of the metadata statement.
+	LegalHeader string `json:"legalHeader,omitempty"`
+	// AAID is the Authenticator Attestation ID of UAF authenticators.
+	AAID string `json:"aaid,omitempty"`
+	// AAGUID is the AAGUID of FIDO2 authenticators, formatted as a UUID.
+	AAGUID string `json:"aaguid,omitempty"`
+	// AttestationCertificateKeyIdentifiers contains the hex encoded SHA-1 hashes of the public keys of the attestation
+	// certificates of U2F authenticators.
+	AttestationCertificateKeyIdentifiers []string `json:"attestationCertificateKeyIdentifiers,omitempty"`
+	// Description is a human-readable, short description of the authenticator, in English.
+	Description string `json:"description"`
+	// AlternativeDescriptions contains descriptions of the authenticator in other languages, by IETF language code.
+	AlternativeDescriptions map[string]string `json:"alternativeDescriptions,omitempty"`
+	// AuthenticatorVersion is the earliest authenticator version that matches the metadata statement.
+	AuthenticatorVersion uint32 `json:"authenticatorVersion"`
+	// ProtocolFamily is the authenticator protocol family, i.e. "uaf", "u2f" or "fido2".
+	ProtocolFamily string `json:"protocolFamily"`
+	// Schema is the version of the metadata statement schema.
+	Schema uint16 `json:"schema"`
+	// Upv contains the versions of the authenticator protocol that are supported by the authenticator.
+	Upv []Version `json:"upv"`
+	// AuthenticationAlgorithms contains the authentication algorithms supported by the authenticator, for instance
+	// "secp256r1_ecdsa_sha256_raw".
+	AuthenticationAlgorithms []string `json:"authenticationAlgorithms"`
+	// PublicKeyAlgAndEncodings contains the public key formats supported by the authenticator, for instance
+	// "cose".
+	PublicKeyAlgAndEncodings []string `json:"publicKeyAlgAndEncodings"`
+	// AttestationTypes contains the attestation types supported by the authenticator, for instance "basic_full".
+	AttestationTypes []string `json:"attestationTypes"`
+	// KeyProtection contains the key protection types used by the authenticator, for instance "hardware".
+	KeyProtection []string `json:"keyProtection"`
+	// IsKeyRestricted indicates whether the keys can only be used to sign valid FIDO signature assertions.
+	IsKeyRestricted bool `json:"isKeyRestricted,omitempty"`
+	// IsFreshUserVerificationRequired indicates whether the authenticator requires user verification for every
+	// operation.
+	IsFreshUserVerificationRequired bool `json:"isFreshUserVerificationRequired,omitempty"`
+	// MatcherProtection contains the matcher protection types used by the authenticator, for instance "on_chip".
+	MatcherProtection []string `json:"matcherProtection"`
+	// CryptoStrength is the security strength in bits of the cryptographic algorithms of the authenticator.
+	CryptoStrength uint16 `json:"cryptoStrength,omitempty"`
+	// AttachmentHint contains the ways the authenticator can be attached, for instance "internal" and "nfc".
+	AttachmentHint []string `json:"attachmentHint,omitempty"`
+	// AttestationRootCertificates contains the base64 encoded DER root certificates of the attestations of the
+	// authenticator.
+	AttestationRootCertificates []string `json:"attestationRootCertificates"`
+	// Icon is the icon of the authenticator, encoded as a data: URL.
+	Icon string `json:"icon,omitempty"`
+}
+
+// RootCertificates returns the attestation root certificates of the authenticator as a pool, which can be used to
+// verify the certificate chain of an attestation.
+func (m *MetadataStatement) RootCertificates() (*x509.CertPool, error) {
+	roots := x509.NewCertPool()
+	for _, rawCert := range m.AttestationRootCertificates {
+		der, err := base64.StdEncoding.DecodeString(rawCert)
+		if err != nil {
+			return nil, fmt.Errorf("metadata: invalid attestation root certificate: %v", err)
+		}
+
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("metadata: invalid attestation root certificate: %v", err)
+		}
+
+		roots.AddCert(cert)
+	}
+	return roots, nil
+}
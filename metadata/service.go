@@ -0,0 +1,273 @@
+package metadata
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+// DefaultURL is the URL of the BLOB published by the FIDO Alliance.
+const DefaultURL = "https://mds3.fidoalliance.org/"
+
+// retryDelay is the time after a failed load of the BLOB before lookups load it again.
+const retryDelay = 10 * time.Minute
+
+// ErrNotFound is returned by Service.Lookup if there is no metadata for an authenticator.
+var ErrNotFound = errors.New("metadata: authenticator not found")
+
+// Service provides the metadata of authenticators. The BLOB is loaded from its source on the first lookup and cached
+// until its nextUpdate date has passed, after which it is loaded again. Concurrent lookups share a single load, and
+// after a failed load, lookups return its error for ten minutes before the BLOB is loaded again. The BLOB is only
+// trusted if it is signed by the leaf certificate of its x5c header, of which the chain must verify up to the roots,
+// and if it is neither outdated nor older than the cached BLOB. The zero value uses DefaultURL, http.DefaultClient and RootCertificate. A Service is safe
+// for concurrent use.
+type Service struct {
+	// Source provides the BLOB, for instance FromFile for deployments without access to the FIDO Metadata Service. If
+	// it is nil, the BLOB is downloaded from URL using Client.
+	Source Source
+	// URL is the URL of the BLOB. If it is empty, DefaultURL is used.
+	URL string
+	// Client is the HTTP client that is used to download the BLOB. If it is nil, http.DefaultClient is used.
+	Client *http.Client
+	// Roots are the root certificates to which the certificate chain of the BLOB must verify. If it is nil,
+	// RootCertificate is used.
+	Roots *x509.CertPool
+	// Now returns the current time, against which the BLOB is considered outdated and its certificate chain is
+	// verified. If it is nil, time.Now is used.
+	Now func() time.Time
+
+	mu         sync.Mutex
+	number     int
+	nextUpdate time.Time
+	entries    map[string]*Entry
+	// loading is closed once the BLOB that is being loaded has been loaded, and is nil if no BLOB is being loaded
+	loading chan struct{}
+	// err is the error of the last failed load, which is returned by lookups until retryAfter
+	err        error
+	retryAfter time.Time
+}
+
+// Lookup returns the metadata statement of the authenticator with the given AAGUID. If the authenticator is unknown,
+// ErrNotFound is returned.
+func (s *Service) Lookup(aaguid []byte) (*MetadataStatement, error) {
+	return s.LookupContext(context.Background(), aaguid)
+}
+
+// LookupContext is like Lookup, but loading the BLOB, if it is needed, is aborted once ctx is done.
+func (s *Service) LookupContext(ctx context.Context, aaguid []byte) (*MetadataStatement, error) {
+	entry, err := s.lookupEntry(ctx, aaguid)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry.MetadataStatement == nil {
+		return nil, ErrNotFound
+	}
+
+	return entry.MetadataStatement, nil
+}
+
+// LookupEntry returns the entry of the authenticator with the given AAGUID, which contains its status reports and its
+// metadata statement, if any. If the authenticator is unknown, ErrNotFound is returned. Loading the BLOB, if it
+// is needed, is aborted once ctx is done.
+func (s *Service) LookupEntry(ctx context.Context, aaguid []byte) (*Entry, error) {
+	return s.lookupEntry(ctx, aaguid)
+}
+
+// Fetch loads the BLOB, regardless of whether the cached BLOB is outdated, for instance to load it when the
+// application starts instead of on the first lookup. Loading is aborted once ctx is done.
+func (s *Service) Fetch(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.loading != nil {
+		if err := s.wait(ctx); err != nil {
+			return err
+		}
+	}
+	return s.update(ctx)
+}
+
+// now returns the current time using Now, if it is set.
+func (s *Service) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *Service) lookupEntry(ctx context.Context, aaguid []byte) (*Entry, error) {
+	if len(aaguid) != 16 {
+		return nil, ErrNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Concurrent lookups share a single load, and a failed load is not retried before retryAfter
+	for s.entries == nil || !s.now().Before(s.nextUpdate) {
+		if s.loading != nil {
+			if err := s.wait(ctx); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if s.now().Before(s.retryAfter) {
+			return nil, s.err
+		}
+		if err := s.update(ctx); err != nil {
+			return nil, err
+		}
+		break
+	}
+
+	entry, ok := s.entries[protocol.FormatAAGUID(aaguid)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return entry, nil
+}
+
+// wait waits until the BLOB that is being loaded has been loaded, or until ctx is done. It must be called with s.mu
+// held, which is released while waiting.
+func (s *Service) wait(ctx context.Context) error {
+	loading := s.loading
+	s.mu.Unlock()
+	defer s.mu.Lock()
+
+	select {
+	case <-loading:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// update loads the BLOB and replaces the cached BLOB with it. It must be called with s.mu held and no BLOB being
+// loaded. The lock is released while the BLOB is loaded, such that lookups with another context can give up. If
+// loading fails for another reason than ctx, lookups return the error until the retry delay has passed.
+func (s *Service) update(ctx context.Context) error {
+	loading := make(chan struct{})
+	s.loading = loading
+	s.mu.Unlock()
+
+	blob, nextUpdate, err := s.load(ctx)
+
+	s.mu.Lock()
+	s.loading = nil
+	close(loading)
+
+	if err == nil && s.entries != nil && blob.Number < s.number {
+		err = fmt.Errorf("metadata: BLOB number %d is lower than the number %d of the cached BLOB", blob.Number, s.number)
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			s.err = err
+			s.retryAfter = s.now().Add(retryDelay)
+		}
+		return err
+	}
+
+	entries := make(map[string]*Entry, len(blob.Entries))
+	for i := range blob.Entries {
+		if aaguid := blob.Entries[i].AAGUID; aaguid != "" {
+			entries[strings.ToLower(aaguid)] = &blob.Entries[i]
+		}
+	}
+
+	s.entries = entries
+	s.number = blob.Number
+	s.nextUpdate = nextUpdate
+	s.err = nil
+	s.retryAfter = time.Time{}
+
+	return nil
+}
+
+// load loads, verifies and parses the BLOB and its nextUpdate date.
+func (s *Service) load(ctx context.Context) (*BLOB, time.Time, error) {
+	source := s.Source
+	if source == nil {
+		url := s.URL
+		if url == "" {
+			url = DefaultURL
+		}
+		source = FromURL(url, s.Client)
+	}
+
+	body, err := source.Load(ctx)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	roots := s.Roots
+	if roots == nil {
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM([]byte(RootCertificate)) {
+			return nil, time.Time{}, fmt.Errorf("metadata: invalid root certificate")
+		}
+	}
+
+	currentTime := s.now()
+	blob, err := parseBLOB(body, roots, currentTime)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	nextUpdate, err := time.Parse("2006-01-02", blob.NextUpdate)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("metadata: invalid nextUpdate: %v", err)
+	}
+
+	// A BLOB of which the nextUpdate date has passed, or that is older than the cached BLOB, may be replayed
+	y, m, d := currentTime.UTC().Date()
+	if nextUpdate.Before(time.Date(y, m, d, 0, 0, 0, 0, time.UTC)) {
+		return nil, time.Time{}, fmt.Errorf("metadata: BLOB is outdated since %s", blob.NextUpdate)
+	}
+
+	return blob, nextUpdate, nil
+}
+
+// parseBLOB parses the BLOB, which is a JWT of which the payload is a BLOB. The JWT must be signed by the leaf
+// certificate of its x5c header, of which the chain must verify up to roots at currentTime.
+func parseBLOB(b []byte, roots *x509.CertPool, currentTime time.Time) (*BLOB, error) {
+	jws, err := jose.ParseSigned(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, fmt.Errorf("metadata: invalid BLOB: %v", err)
+	}
+	if len(jws.Signatures) != 1 {
+		return nil, fmt.Errorf("metadata: invalid BLOB: expected a single signature, got %d", len(jws.Signatures))
+	}
+
+	chains, err := jws.Signatures[0].Protected.Certificates(x509.VerifyOptions{
+		Roots:       roots,
+		CurrentTime: currentTime,
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("metadata: invalid certificate chain of BLOB: %v", err)
+	}
+
+	payload, err := jws.Verify(chains[0][0].PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: invalid signature of BLOB: %v", err)
+	}
+
+	blob := &BLOB{}
+	if err := json.Unmarshal(payload, blob); err != nil {
+		return nil, fmt.Errorf("metadata: invalid BLOB payload: %v", err)
+	}
+
+	return blob, nil
+}
@@ -0,0 +1,482 @@
+package metadata
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+var testAAGUID = []byte{0xcb, 0x69, 0x48, 0x1e, 0x8f, 0xf7, 0x40, 0x39, 0x93, 0xec, 0x0a, 0x27, 0x29, 0xa1, 0x54, 0xa8}
+
+// testCA issues the certificate with which the test BLOBs are signed.
+type testCA struct {
+	roots *x509.CertPool
+	leaf  *x509.Certificate
+	key   *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "MDS Test Root"},
+		NotBefore:             time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2999, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, root, root, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "MDS Test Signer"},
+		NotBefore:    time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2999, 1, 1, 0, 0, 0, 0, time.UTC),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if der, err = x509.CreateCertificate(rand.Reader, leaf, root, &key.PublicKey, rootKey); err != nil {
+		t.Fatal(err)
+	}
+	if leaf, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	return &testCA{roots: roots, leaf: leaf, key: key}
+}
+
+// sign returns the BLOB signed with key, including the certificate of the CA in the x5c header if x5c is set.
+func (ca *testCA) sign(t *testing.T, blob *BLOB, key *ecdsa.PrivateKey, x5c bool) string {
+	opts := &jose.SignerOptions{}
+	if x5c {
+		opts.WithHeader("x5c", []string{base64.StdEncoding.EncodeToString(ca.leaf.Raw)})
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := json.Marshal(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// newTestServer returns a server that serves the BLOB, and a pointer to the number of requests.
+func newTestServer(blob *string) (*httptest.Server, *int) {
+	var requests int
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		requests++
+		rw.Write([]byte(*blob))
+	})), &requests
+}
+
+func TestServiceLookup(t *testing.T) {
+	now := func() time.Time {
+		return time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	ca := newTestCA(t)
+	blob := ca.sign(t, &BLOB{Number: 1, NextUpdate: "2021-07-01", Entries: []Entry{
+		{
+			AAGUID: "cb69481e-8ff7-4039-93ec-0a2729a154a8",
+			MetadataStatement: &MetadataStatement{
+				AAGUID:      "cb69481e-8ff7-4039-93ec-0a2729a154a8",
+				Description: "Test Authenticator",
+			},
+			StatusReports: []StatusReport{{Status: StatusFIDOCertified}},
+		},
+	}}, ca.key, true)
+	server, requests := newTestServer(&blob)
+	defer server.Close()
+
+	s := &Service{URL: server.URL, Roots: ca.roots, Now: now}
+
+	statement, err := s.Lookup(testAAGUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statement.Description != "Test Authenticator" {
+		t.Fatalf("invalid description %q", statement.Description)
+	}
+
+	if _, err := s.Lookup(make([]byte, 16)); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	if *requests != 1 {
+		t.Fatalf("expected BLOB to be cached, got %d requests", *requests)
+	}
+
+	s.Now = func() time.Time {
+		return time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	if _, err := s.Lookup(testAAGUID); err != nil {
+		t.Fatal(err)
+	}
+
+	if *requests != 2 {
+		t.Fatalf("expected BLOB to be updated after nextUpdate, got %d requests", *requests)
+	}
+}
+
+func TestServiceFetch(t *testing.T) {
+	now := func() time.Time {
+		return time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	ca := newTestCA(t)
+	blob := ca.sign(t, &BLOB{Number: 1, NextUpdate: "2021-07-01", Entries: []Entry{
+		{
+			AAGUID:            "cb69481e-8ff7-4039-93ec-0a2729a154a8",
+			MetadataStatement: &MetadataStatement{AAGUID: "cb69481e-8ff7-4039-93ec-0a2729a154a8"},
+		},
+	}}, ca.key, true)
+	server, requests := newTestServer(&blob)
+	defer server.Close()
+
+	s := &Service{URL: server.URL, Roots: ca.roots, Now: now}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.Fetch(ctx); err == nil {
+		t.Fatal("expected error for canceled context")
+	}
+	if _, err := s.LookupContext(ctx, testAAGUID); err == nil || err == ErrNotFound {
+		t.Fatalf("expected error for canceled context, got %v", err)
+	}
+	if *requests != 0 {
+		t.Fatalf("expected no requests, got %d", *requests)
+	}
+
+	if err := s.Fetch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.LookupContext(ctx, testAAGUID); err != nil {
+		t.Fatal(err)
+	}
+	if *requests != 1 {
+		t.Fatalf("expected fetched BLOB to be used, got %d requests", *requests)
+	}
+}
+
+func TestServiceVerify(t *testing.T) {
+	now := func() time.Time {
+		return time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	ca := newTestCA(t)
+	other := newTestCA(t)
+	entries := []Entry{{AAGUID: "cb69481e-8ff7-4039-93ec-0a2729a154a8"}}
+
+	for _, test := range []struct {
+		name string
+		blob string
+	}{
+		{"Unsigned", ca.sign(t, &BLOB{Number: 1, NextUpdate: "2021-07-01", Entries: entries}, ca.key, false)},
+		{"Invalid signature", ca.sign(t, &BLOB{Number: 1, NextUpdate: "2021-07-01", Entries: entries}, other.key, true)},
+		{"Untrusted certificate", other.sign(t, &BLOB{Number: 1, NextUpdate: "2021-07-01", Entries: entries}, other.key, true)},
+		{"Outdated", ca.sign(t, &BLOB{Number: 1, NextUpdate: "2021-05-31", Entries: entries}, ca.key, true)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			server, _ := newTestServer(&test.blob)
+			defer server.Close()
+
+			s := &Service{URL: server.URL, Roots: ca.roots, Now: now}
+			if _, err := s.LookupEntry(context.Background(), testAAGUID); err == nil || err == ErrNotFound {
+				t.Fatalf("expected error for invalid BLOB, got %v", err)
+			}
+		})
+	}
+
+	// The default root is not the root of the test certificate
+	blob := ca.sign(t, &BLOB{Number: 1, NextUpdate: "2021-07-01", Entries: entries}, ca.key, true)
+	server, _ := newTestServer(&blob)
+	defer server.Close()
+
+	if err := (&Service{URL: server.URL, Now: now}).Fetch(context.Background()); err == nil {
+		t.Fatal("expected error for BLOB that does not chain up to the FIDO root")
+	}
+
+	s := &Service{URL: server.URL, Roots: ca.roots, Now: now}
+	if err := s.Fetch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A BLOB with a lower number than the cached BLOB is rejected
+	blob = ca.sign(t, &BLOB{Number: 2, NextUpdate: "2021-07-01", Entries: entries}, ca.key, true)
+	if err := s.Fetch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	blob = ca.sign(t, &BLOB{Number: 1, NextUpdate: "2021-07-01"}, ca.key, true)
+	if err := s.Fetch(context.Background()); err == nil {
+		t.Fatal("expected error for BLOB with a lower number")
+	}
+	if _, err := s.LookupEntry(context.Background(), testAAGUID); err != nil {
+		t.Fatalf("expected cached BLOB to be used, got %v", err)
+	}
+}
+
+func TestServiceSource(t *testing.T) {
+	now := func() time.Time {
+		return time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	ca := newTestCA(t)
+	blob := ca.sign(t, &BLOB{Number: 1, NextUpdate: "2021-07-01", Entries: []Entry{
+		{
+			AAGUID:            "cb69481e-8ff7-4039-93ec-0a2729a154a8",
+			MetadataStatement: &MetadataStatement{AAGUID: "cb69481e-8ff7-4039-93ec-0a2729a154a8"},
+		},
+	}}, ca.key, true)
+
+	dir, err := ioutil.TempDir("", "metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "blob.jwt")
+	if err := ioutil.WriteFile(path, []byte(blob+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	server, _ := newTestServer(&blob)
+	defer server.Close()
+
+	for _, test := range []struct {
+		name   string
+		source Source
+	}{
+		{"URL", FromURL(server.URL, server.Client())},
+		{"File", FromFile(path)},
+		{"Reader", FromReader(strings.NewReader(blob))},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s := &Service{Source: test.source, Roots: ca.roots, Now: now}
+			if _, err := s.Lookup(testAAGUID); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Fetch(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	s := &Service{Source: FromFile(filepath.Join(dir, "missing.jwt")), Roots: ca.roots, Now: now}
+	if _, err := s.Lookup(testAAGUID); err == nil || err == ErrNotFound {
+		t.Fatalf("expected error for missing file, got %v", err)
+	}
+}
+
+// blockingSource is a Source that returns blob once release is closed, and counts the number of loads.
+type blockingSource struct {
+	blob    string
+	err     error
+	release chan struct{}
+	loads   int32
+}
+
+func (s *blockingSource) Load(ctx context.Context) ([]byte, error) {
+	atomic.AddInt32(&s.loads, 1)
+	<-s.release
+	return []byte(s.blob), s.err
+}
+
+func TestServiceConcurrentLookup(t *testing.T) {
+	now := func() time.Time {
+		return time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	ca := newTestCA(t)
+	source := &blockingSource{
+		blob: ca.sign(t, &BLOB{Number: 1, NextUpdate: "2021-07-01", Entries: []Entry{
+			{AAGUID: "cb69481e-8ff7-4039-93ec-0a2729a154a8"},
+		}}, ca.key, true),
+		release: make(chan struct{}),
+	}
+	s := &Service{Source: source, Roots: ca.roots, Now: now}
+
+	errs := make(chan error)
+	for i := 0; i < 10; i++ {
+		go func() {
+			_, err := s.LookupEntry(context.Background(), testAAGUID)
+			errs <- err
+		}()
+	}
+	for atomic.LoadInt32(&source.loads) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Lookups do not block on the lock while the BLOB is loaded, so they can give up once their context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.LookupEntry(ctx, testAAGUID); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	close(source.release)
+	for i := 0; i < 10; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if loads := atomic.LoadInt32(&source.loads); loads != 1 {
+		t.Fatalf("expected the BLOB to be loaded once, got %d loads", loads)
+	}
+}
+
+func TestServiceRetryAfter(t *testing.T) {
+	current := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	now := func() time.Time {
+		return current
+	}
+
+	errUnavailable := errors.New("unavailable")
+	source := &blockingSource{err: errUnavailable, release: make(chan struct{})}
+	close(source.release)
+	s := &Service{Source: source, Now: now}
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.LookupEntry(context.Background(), testAAGUID); err != errUnavailable {
+			t.Fatalf("expected error of the source, got %v", err)
+		}
+	}
+	if loads := atomic.LoadInt32(&source.loads); loads != 1 {
+		t.Fatalf("expected no retries before the retry delay, got %d loads", loads)
+	}
+
+	current = current.Add(retryDelay)
+	if _, err := s.LookupEntry(context.Background(), testAAGUID); err != errUnavailable {
+		t.Fatalf("expected error of the source, got %v", err)
+	}
+	if loads := atomic.LoadInt32(&source.loads); loads != 2 {
+		t.Fatalf("expected a retry after the retry delay, got %d loads", loads)
+	}
+}
+
+func TestSourceURLSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write(make([]byte, maxBLOBSize+1))
+	}))
+	defer server.Close()
+
+	_, err := FromURL(server.URL, server.Client()).Load(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Fatalf("expected error for too large BLOB, got %v", err)
+	}
+}
+
+func TestEntryStatus(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		reports  []StatusReport
+		expected AuthenticatorStatus
+	}{
+		{"No reports", nil, ""},
+		{"Single report", []StatusReport{{Status: StatusFIDOCertified}}, StatusFIDOCertified},
+		{"Latest report", []StatusReport{
+			{Status: StatusFIDOCertifiedL1, EffectiveDate: "2020-01-01"},
+			{Status: StatusRevoked, EffectiveDate: "2021-01-01"},
+		}, StatusRevoked},
+		{"Unordered reports", []StatusReport{
+			{Status: StatusUpdateAvailable, EffectiveDate: "2021-06-01"},
+			{Status: StatusUserVerificationBypass, EffectiveDate: "2021-01-01"},
+		}, StatusUpdateAvailable},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			entry := &Entry{StatusReports: test.reports}
+			if status := entry.Status(); status != test.expected {
+				t.Fatalf("expected status %q, got %q", test.expected, status)
+			}
+		})
+	}
+}
+
+func TestEntryCertificationLevel(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		reports  []StatusReport
+		expected AuthenticatorStatus
+	}{
+		{"No reports", nil, ""},
+		{"Not certified", []StatusReport{{Status: StatusUpdateAvailable}}, ""},
+		{"Latest certification", []StatusReport{
+			{Status: StatusFIDOCertifiedL2, EffectiveDate: "2021-01-01"},
+			{Status: StatusFIDOCertifiedL1, EffectiveDate: "2020-01-01"},
+		}, StatusFIDOCertifiedL2},
+		{"Other statuses", []StatusReport{
+			{Status: StatusFIDOCertifiedL1, EffectiveDate: "2020-01-01"},
+			{Status: StatusUpdateAvailable, EffectiveDate: "2021-01-01"},
+		}, StatusFIDOCertifiedL1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			entry := &Entry{StatusReports: test.reports}
+			if level := entry.CertificationLevel(); level != test.expected {
+				t.Fatalf("expected certification level %q, got %q", test.expected, level)
+			}
+		})
+	}
+}
+
+func TestMetadataStatementCOSEAlgorithms(t *testing.T) {
+	statement := &MetadataStatement{AuthenticationAlgorithms: []string{
+		"secp256r1_ecdsa_sha256_raw", "secp256r1_ecdsa_sha256_der", "ed25519_eddsa_sha512_raw", "sm2_sm3_raw",
+	}}
+	if algorithms := statement.COSEAlgorithms(); !reflect.DeepEqual(algorithms, []int64{-7, -8}) {
+		t.Fatalf("expected algorithms [-7 -8], got %v", algorithms)
+	}
+	if !statement.SupportsAlgorithm(-8) || statement.SupportsAlgorithm(-257) {
+		t.Fatal("invalid supported algorithms")
+	}
+
+	statement = &MetadataStatement{AuthenticationAlgorithms: []string{"rsa_emsa_pkcs1_sha256_raw", "rsa_emsa_pkcs1_sha256_der"}}
+	if algorithms := statement.COSEAlgorithms(); !reflect.DeepEqual(algorithms, []int64{-257}) {
+		t.Fatalf("expected algorithms [-257], got %v", algorithms)
+	}
+}
@@ -0,0 +1,112 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// maxBLOBSize is the maximum size of a downloaded BLOB, which is several megabytes for the FIDO Metadata Service.
+const maxBLOBSize = 32 << 20
+
+// Source provides the BLOB to a Service, which verifies and parses it. Load is called whenever the Service needs the
+// BLOB, i.e. on the first lookup, after the nextUpdate date of the cached BLOB has passed and on every Fetch.
+type Source interface {
+	// Load returns the BLOB as a JWT in compact serialization. Implementations should abort once ctx is done.
+	Load(ctx context.Context) ([]byte, error)
+}
+
+// FromURL returns a Source that downloads the BLOB from url using client. If client is nil, http.DefaultClient is used.
+// BLOBs that are larger than 32 MiB are rejected.
+func FromURL(url string, client *http.Client) Source {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &urlSource{url: url, client: client}
+}
+
+// FromFile returns a Source that reads the BLOB from the file at path, for instance a BLOB that has been downloaded in
+// advance for deployments without access to the FIDO Metadata Service. The file is read again whenever the BLOB is
+// loaded, so it can be replaced with a newer BLOB while the application is running.
+func FromFile(path string) Source {
+	return fileSource(path)
+}
+
+// FromReader returns a Source that reads the BLOB from r. Since r can only be read once, the same BLOB is returned
+// whenever it is loaded, which means that lookups fail once its nextUpdate date has passed.
+func FromReader(r io.Reader) Source {
+	return &readerSource{r: r}
+}
+
+type urlSource struct {
+	url    string
+	client *http.Client
+}
+
+func (s *urlSource) Load(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: unable to download BLOB: %v", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: unable to download BLOB: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("metadata: unable to download BLOB: unexpected status %s", resp.Status)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBLOBSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("metadata: unable to download BLOB: %v", err)
+	}
+	if len(body) > maxBLOBSize {
+		return nil, fmt.Errorf("metadata: unable to download BLOB: BLOB is too large")
+	}
+	return body, nil
+}
+
+type fileSource string
+
+func (s fileSource) Load(ctx context.Context) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("metadata: unable to read BLOB: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(string(s))
+	if err != nil {
+		return nil, fmt.Errorf("metadata: unable to read BLOB: %v", err)
+	}
+	return b, nil
+}
+
+type readerSource struct {
+	mu  sync.Mutex
+	r   io.Reader
+	b   []byte
+	err error
+}
+
+func (s *readerSource) Load(ctx context.Context) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("metadata: unable to read BLOB: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.r != nil {
+		s.b, s.err = ioutil.ReadAll(s.r)
+		if s.err != nil {
+			s.err = fmt.Errorf("metadata: unable to read BLOB: %v", s.err)
+		}
+		s.r = nil
+	}
+	return s.b, s.err
+}
@@ -0,0 +1,36 @@
+package protocol
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// AAGUIDString returns the AAGUID of the authenticator in the canonical UUID form, as used by the FIDO metadata, for
+// instance "cb69481e-8ff7-4039-93ec-0a2729a154a8". It returns an empty string if the AAGUID is not 16 bytes long.
+func (d AttestedCredentialData) AAGUIDString() string {
+	return FormatAAGUID(d.AAGUID)
+}
+
+// FormatAAGUID formats an AAGUID in the canonical UUID form, i.e. lower case hexadecimal digits separated by dashes. It
+// returns an empty string if the AAGUID is not 16 bytes long.
+func FormatAAGUID(aaguid []byte) string {
+	if len(aaguid) != 16 {
+		return ""
+	}
+	s := hex.EncodeToString(aaguid)
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
+
+// ParseAAGUID parses an AAGUID in the UUID form of FormatAAGUID. Upper case digits are accepted as well.
+func ParseAAGUID(s string) ([]byte, error) {
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return nil, fmt.Errorf("invalid AAGUID %q", s)
+	}
+
+	aaguid, err := hex.DecodeString(strings.Replace(s, "-", "", -1))
+	if err != nil || len(aaguid) != 16 {
+		return nil, fmt.Errorf("invalid AAGUID %q", s)
+	}
+	return aaguid, nil
+}
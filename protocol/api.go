@@ -49,6 +49,14 @@ type PublicKeyCredentialCreationOptions struct {
 	// This member is intended for use by Relying Parties that wish to express their preference for attestation
 	// conveyance. The default is none.
 	Attestation AttestationConveyancePreference `json:"attestation,omitempty"`
+	// This member is intended for use by Relying Parties that wish to express their preference for the attestation
+	// statement format used by the authenticator, such as "packed". The sequence is ordered from most preferred to
+	// least preferred. This is treated as a hint, and authenticators MAY provide an attestation statement in another
+	// format.
+	AttestationFormats []string `json:"attestationFormats,omitempty"`
+	// This OPTIONAL member contains hints, in descending order of preference, as to which authenticators the client
+	// should guide the user to, for instance a security key. Clients ignore hints that they do not support.
+	Hints []PublicKeyCredentialHint `json:"hints,omitempty"`
 	// This member contains additional parameters requesting additional processing by the client and authenticator. For
 	// example, the caller may request that only authenticators with certain capabilities be used to create the
 	// credential, or that particular information be returned in the attestation object. Some extensions are defined in
@@ -77,6 +85,9 @@ type PublicKeyCredentialRequestOptions struct {
 	// This member describes the Relying Party's requirements regarding user verification for the get() operation.
 	// Eligible authenticators are filtered to only those capable of satisfying this requirement.
 	UserVerification UserVerificationRequirement `json:"userVerification,omitempty"`
+	// This OPTIONAL member contains hints, in descending order of preference, as to which authenticators the client
+	// should guide the user to, for instance a security key. Clients ignore hints that they do not support.
+	Hints []PublicKeyCredentialHint `json:"hints,omitempty"`
 	// This OPTIONAL member contains additional parameters requesting additional processing by the client and
 	// authenticator. For example, if transaction confirmation is sought from the user, then the prompt string might
 	// be included as an extension.
@@ -109,7 +120,7 @@ type PublicKeyCredentialUserEntity struct {
 	// The user handle of the user account entity. To ensure secure operation, authentication and authorization
 	// decisions MUST be made on the basis of this id member, not the displayName nor name members. See
 	// Section 6.1 of [RFC8266].
-	ID []byte `json:"id"`
+	ID URLEncodedBase64 `json:"id"`
 	// A human-palatable name for the user account, intended only for display. For example, "Alex P. Müller" or
 	// "田中 倫". The Relying Party SHOULD let the user choose this, and SHOULD NOT restrict the choice more than
 	// necessary.
@@ -137,8 +148,33 @@ type COSEAlgorithmIdentifier int
 const (
 	// ES256 is the COSE Algorithm Identifier of ECDSA 256
 	ES256 COSEAlgorithmIdentifier = -7
+	// ES384 is the COSE Algorithm Identifier of ECDSA 384
+	ES384 COSEAlgorithmIdentifier = -35
+	// ES512 is the COSE Algorithm Identifier of ECDSA 521
+	ES512 COSEAlgorithmIdentifier = -36
 	// RS256 is the COSE Algorithm Identifier of RSA 256
 	RS256 COSEAlgorithmIdentifier = -257
+	// RS384 is the COSE Algorithm Identifier of RSASSA-PKCS1-v1_5 with SHA-384
+	RS384 COSEAlgorithmIdentifier = -258
+	// RS512 is the COSE Algorithm Identifier of RSASSA-PKCS1-v1_5 with SHA-512
+	RS512 COSEAlgorithmIdentifier = -259
+	// PS256 is the COSE Algorithm Identifier of RSASSA-PSS with SHA-256
+	PS256 COSEAlgorithmIdentifier = -37
+	// PS384 is the COSE Algorithm Identifier of RSASSA-PSS with SHA-384
+	PS384 COSEAlgorithmIdentifier = -38
+	// PS512 is the COSE Algorithm Identifier of RSASSA-PSS with SHA-512
+	PS512 COSEAlgorithmIdentifier = -39
+	// EdDSA is the COSE Algorithm Identifier of EdDSA, of which Ed25519 is supported, and Ed448, which is always
+	// accepted by this package, but only by webauthn.RelyingParty if non-standard algorithms are enabled using
+	// webauthn.WithNonStandardAlgorithms
+	EdDSA COSEAlgorithmIdentifier = -8
+	// ES256K is the COSE Algorithm Identifier of ECDSA on the secp256k1 curve with SHA-256, which is always accepted by
+	// this package, but only by webauthn.RelyingParty if non-standard algorithms are enabled using
+	// webauthn.WithNonStandardAlgorithms
+	ES256K COSEAlgorithmIdentifier = -47
+	// RS1 is the COSE Algorithm Identifier of RSA with SHA-1, which is used by some TPMs for attestation signatures,
+	// but is not accepted for credential public keys
+	RS1 COSEAlgorithmIdentifier = -65535
 )
 
 // AuthenticatorTransport represents the transport used by an authenticator. Authenticators may implement various
@@ -147,6 +183,7 @@ const (
 // credential. Note that these hints represent the WebAuthn Relying Party's best belief as to how an authenticator may
 // be reached. A Relying Party may obtain a list of transports hints from some attestation statement formats or via
 // some out-of-band mechanism; it is outside the scope of this specification to define that mechanism.
+// Clients may report transports that are not defined here, which should be retained as they are.
 // https://www.w3.org/TR/webauthn/#enumdef-authenticatortransport
 type AuthenticatorTransport string
 
@@ -154,14 +191,56 @@ const (
 	// AuthenticatorTransportUSB indicates the respective authenticator can be contacted over removable USB.
 	AuthenticatorTransportUSB AuthenticatorTransport = "usb"
 	// AuthenticatorTransportNFC indicates the respective authenticator can be contacted over Near Field Communication (NFC).
-	AuthenticatorTransportNFC = "nfc"
+	AuthenticatorTransportNFC AuthenticatorTransport = "nfc"
 	// AuthenticatorTransportBLE indicates the respective authenticator can be contacted over Bluetooth Smart (Bluetooth Low Energy / BLE).
-	AuthenticatorTransportBLE = "ble"
+	AuthenticatorTransportBLE AuthenticatorTransport = "ble"
 	// AuthenticatorTransportInternal indicates the respective authenticator is contacted using a client device-specific transport. These
 	// authenticators are not removable from the client device.
-	AuthenticatorTransportInternal = "internal"
+	AuthenticatorTransportInternal AuthenticatorTransport = "internal"
+	// AuthenticatorTransportHybrid indicates the respective authenticator can be contacted using a combination of
+	// (often separate) data-transport and proximity mechanisms, such as a phone that is used by a desktop computer.
+	AuthenticatorTransportHybrid AuthenticatorTransport = "hybrid"
+	// AuthenticatorTransportSmartCard indicates the respective authenticator can be contacted over ISO/IEC 7816 smart
+	// card with contacts.
+	AuthenticatorTransportSmartCard AuthenticatorTransport = "smart-card"
 )
 
+// PublicKeyCredentialHint is a hint to the client as to which authenticators the user should be guided to during a
+// ceremony. https://www.w3.org/TR/webauthn-3/#enum-hints
+type PublicKeyCredentialHint string
+
+const (
+	// PublicKeyCredentialHintSecurityKey indicates that the Relying Party believes that the user will use a physical
+	// security key.
+	PublicKeyCredentialHintSecurityKey PublicKeyCredentialHint = "security-key"
+	// PublicKeyCredentialHintClientDevice indicates that the Relying Party believes that the user will use a platform
+	// authenticator that is built into the client device, for instance to create a passkey.
+	PublicKeyCredentialHintClientDevice PublicKeyCredentialHint = "client-device"
+	// PublicKeyCredentialHintHybrid indicates that the Relying Party believes that the user will use a general-purpose
+	// authenticator, such as a phone, that is connected to the client using the hybrid transport.
+	PublicKeyCredentialHintHybrid PublicKeyCredentialHint = "hybrid"
+)
+
+// IsKnown returns whether the hint is one of the hints that are defined by WebAuthn Level 3.
+func (h PublicKeyCredentialHint) IsKnown() bool {
+	switch h {
+	case PublicKeyCredentialHintSecurityKey, PublicKeyCredentialHintClientDevice, PublicKeyCredentialHintHybrid:
+		return true
+	default:
+		return false
+	}
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface. Older versions of Chrome report the hybrid
+// transport as "cable", its name before it was standardized, which is decoded as AuthenticatorTransportHybrid.
+func (t *AuthenticatorTransport) UnmarshalText(text []byte) error {
+	*t = AuthenticatorTransport(text)
+	if *t == "cable" {
+		*t = AuthenticatorTransportHybrid
+	}
+	return nil
+}
+
 // PublicKeyCredentialParameters is used to supply additional parameters when creating a new credential.
 // https://www.w3.org/TR/webauthn/#dictdef-publickeycredentialparameters
 type PublicKeyCredentialParameters struct {
@@ -180,7 +259,7 @@ type PublicKeyCredentialDescriptor struct {
 	// This member contains the type of the public key credential the caller is referring to.
 	Type PublicKeyCredentialType `json:"type"`
 	// This member contains the credential ID of the public key credential the caller is referring to.
-	ID []byte `json:"id"`
+	ID URLEncodedBase64 `json:"id"`
 	// This OPTIONAL member contains a hint as to how the client might communicate with the managing authenticator of
 	// the public key credential the caller is referring to.
 	Transport []AuthenticatorTransport `json:"transports,omitempty"`
@@ -228,10 +307,10 @@ const (
 	UserVerificationRequired UserVerificationRequirement = "required"
 	// UserVerificationPreferred indicates that the Relying Party prefers user verification for the operation if possible, but
 	// will not fail the operation if the response does not have the UV flag set.
-	UserVerificationPreferred = "preferred"
+	UserVerificationPreferred UserVerificationRequirement = "preferred"
 	// UserVerificationDiscouraged indicates that the Relying Party does not want user verification employed during the operation
 	// (e.g., in the interest of minimizing disruption to the user interaction flow).
-	UserVerificationDiscouraged = "discouraged"
+	UserVerificationDiscouraged UserVerificationRequirement = "discouraged"
 )
 
 // AttestationConveyancePreference may be used by WebAuthn Relying Parties to specify their preference regarding attestation
@@ -253,9 +332,437 @@ const (
 	// AttestationConveyancePreferenceDirect indicates that the Relying Party wants to receive the attestation statement as generated by the
 	// authenticator.
 	AttestationConveyancePreferenceDirect = "direct"
+	// AttestationConveyancePreferenceEnterprise indicates that the Relying Party wants to receive an attestation statement that
+	// may include uniquely identifying information, such as the serial number of the authenticator. This is intended
+	// for controlled deployments within an enterprise, where authenticators are bound to specific devices or users.
+	AttestationConveyancePreferenceEnterprise = "enterprise"
+)
+
+// AttestationType is the type of an attestation, which determines the trust that can be placed in it.
+// https://www.w3.org/TR/webauthn/#sctn-attestation-types
+type AttestationType string
+
+const (
+	// AttestationTypeBasic indicates that the authenticator's attestation key pair is specific to an authenticator
+	// model, such that the attestation certificate identifies the model.
+	AttestationTypeBasic AttestationType = "basic"
+	// AttestationTypeSelf indicates that the credential private key is used to create the attestation signature, such
+	// that there is no proof of the authenticator model.
+	AttestationTypeSelf AttestationType = "self"
+	// AttestationTypeAttCA indicates that the attestation is signed by an attestation key of which the certificate is
+	// issued by an Attestation CA, as is the case with TPMs.
+	AttestationTypeAttCA AttestationType = "attca"
+	// AttestationTypeAnonCA indicates that the attestation is signed by an attestation key of which the certificate is
+	// issued per credential by an Anonymization CA, as is the case with Apple devices.
+	AttestationTypeAnonCA AttestationType = "anonca"
+	// AttestationTypeNone indicates that no attestation statement is available.
+	AttestationTypeNone AttestationType = "none"
 )
 
 // AuthenticationExtensionsClientInputs contains the client extension input values for zero or more WebAuthn extensions, as defined
 // in §9 WebAuthn Extensions.
 // https://www.w3.org/TR/webauthn/#dictdef-authenticationextensionsclientinputs
 type AuthenticationExtensionsClientInputs map[string]interface{}
+
+// AuthenticationExtensionsClientOutputs contains the client extension output values for zero or more WebAuthn
+// extensions, as defined in §9 WebAuthn Extensions.
+// https://www.w3.org/TR/webauthn/#dictdef-authenticationextensionsclientoutputs
+type AuthenticationExtensionsClientOutputs map[string]interface{}
+
+// AppID returns the output of the appid extension, which indicates whether the AppID was used instead of the RP ID,
+// such that the RP ID hash in the authenticator data is the SHA-256 hash of the AppID.
+// https://www.w3.org/TR/webauthn/#sctn-appid-extension
+func (o AuthenticationExtensionsClientOutputs) AppID() (bool, error) {
+	rawAppID, ok := o["appid"]
+	if !ok {
+		return false, nil
+	}
+	appID, ok := rawAppID.(bool)
+	if !ok {
+		return false, ErrInvalidRequest.WithDebugf("invalid appid extension output, is of invalid type %T", rawAppID)
+	}
+	return appID, nil
+}
+
+// CredentialPropertiesOutput contains the output of the credProps extension, which reports the properties of a newly
+// created credential.
+// https://www.w3.org/TR/webauthn-2/#sctn-authenticator-credential-properties-extension
+type CredentialPropertiesOutput struct {
+	// ResidentKey indicates whether the created credential is a client-side discoverable credential. It is nil if the
+	// client does not know.
+	ResidentKey *bool `json:"rk,omitempty"`
+}
+
+// CredentialProperties returns the output of the credProps extension. If the client did not return the output, which
+// is allowed even if it was requested, nil is returned.
+func (o AuthenticationExtensionsClientOutputs) CredentialProperties() (*CredentialPropertiesOutput, error) {
+	rawProps, ok := o["credProps"]
+	if !ok {
+		return nil, nil
+	}
+	props, ok := rawProps.(map[string]interface{})
+	if !ok {
+		return nil, ErrInvalidRequest.WithDebugf("invalid credProps extension output, is of invalid type %T", rawProps)
+	}
+
+	output := &CredentialPropertiesOutput{}
+	if rawRK, ok := props["rk"]; ok {
+		rk, ok := rawRK.(bool)
+		if !ok {
+			return nil, ErrInvalidRequest.WithDebugf("invalid rk in credProps extension output, is of invalid type %T", rawRK)
+		}
+		output.ResidentKey = &rk
+	}
+
+	return output, nil
+}
+
+// LargeBlobSupport describes the Relying Party's requirement regarding largeBlob support of the authenticator during
+// registration.
+// https://www.w3.org/TR/webauthn-2/#enumdef-largeblobsupport
+type LargeBlobSupport string
+
+const (
+	// LargeBlobSupportRequired indicates that the credential must be created with an authenticator that supports
+	// storing large blobs.
+	LargeBlobSupportRequired LargeBlobSupport = "required"
+	// LargeBlobSupportPreferred indicates that the credential should be created with an authenticator that supports
+	// storing large blobs, if possible.
+	LargeBlobSupportPreferred LargeBlobSupport = "preferred"
+)
+
+// LargeBlobInputs contains the input of the largeBlob extension. During registration, only Support may be set. During
+// login, either Read or Write may be set.
+// https://www.w3.org/TR/webauthn-2/#dictdef-authenticationextensionslargeblobinputs
+type LargeBlobInputs struct {
+	// Support is the requirement regarding largeBlob support during registration.
+	Support LargeBlobSupport `json:"support,omitempty"`
+	// Read indicates that the blob associated with the credential should be read during login.
+	Read bool `json:"read,omitempty"`
+	// Write is the blob that should be associated with the credential during login.
+	Write URLEncodedBase64 `json:"write,omitempty"`
+}
+
+// LargeBlobOutput contains the output of the largeBlob extension.
+// https://www.w3.org/TR/webauthn-2/#dictdef-authenticationextensionslargebloboutputs
+type LargeBlobOutput struct {
+	// Supported indicates whether the created credential supports storing large blobs. It is only set during
+	// registration.
+	Supported *bool `json:"supported,omitempty"`
+	// Blob is the blob associated with the credential, if it was read during login.
+	Blob URLEncodedBase64 `json:"blob,omitempty"`
+	// Written indicates whether the blob was written during login.
+	Written *bool `json:"written,omitempty"`
+}
+
+// LargeBlob returns the output of the largeBlob extension. If the client did not return the output, nil is returned.
+// The blob is expected to be encoded as base64url, like other binary values.
+func (o AuthenticationExtensionsClientOutputs) LargeBlob() (*LargeBlobOutput, error) {
+	rawLargeBlob, ok := o["largeBlob"]
+	if !ok {
+		return nil, nil
+	}
+	largeBlob, ok := rawLargeBlob.(map[string]interface{})
+	if !ok {
+		return nil, ErrInvalidRequest.WithDebugf("invalid largeBlob extension output, is of invalid type %T", rawLargeBlob)
+	}
+
+	output := &LargeBlobOutput{}
+	for _, field := range []struct {
+		name  string
+		value **bool
+	}{
+		{"supported", &output.Supported},
+		{"written", &output.Written},
+	} {
+		rawValue, ok := largeBlob[field.name]
+		if !ok {
+			continue
+		}
+		value, ok := rawValue.(bool)
+		if !ok {
+			return nil, ErrInvalidRequest.WithDebugf("invalid %s in largeBlob extension output, is of invalid type %T", field.name, rawValue)
+		}
+		*field.value = &value
+	}
+
+	if rawBlob, ok := largeBlob["blob"]; ok {
+		encodedBlob, ok := rawBlob.(string)
+		if !ok {
+			return nil, ErrInvalidRequest.WithDebugf("invalid blob in largeBlob extension output, is of invalid type %T", rawBlob)
+		}
+		blob, err := decodeBase64(encodedBlob)
+		if err != nil {
+			return nil, ErrInvalidRequest.WithDebugf("invalid blob in largeBlob extension output: %v", err)
+		}
+		output.Blob = blob
+	}
+
+	return output, nil
+}
+
+// AuthenticationExtensionsAuthenticatorOutputs contains the authenticator extension output values for zero or more
+// WebAuthn extensions, which are CBOR encoded in the extensions of the authenticator data.
+// https://www.w3.org/TR/webauthn/#authenticator-extension-output
+type AuthenticationExtensionsAuthenticatorOutputs map[string]interface{}
+
+// HMACSecretCreated returns the output of the hmac-secret extension during registration, which indicates whether the
+// authenticator has created an HMAC secret for the credential.
+// https://fidoalliance.org/specs/fido-v2.0-ps-20190130/fido-client-to-authenticator-protocol-v2.0-ps-20190130.html#sctn-hmac-secret-extension
+func (o AuthenticationExtensionsAuthenticatorOutputs) HMACSecretCreated() (bool, error) {
+	rawCreated, ok := o["hmac-secret"]
+	if !ok {
+		return false, nil
+	}
+	created, ok := rawCreated.(bool)
+	if !ok {
+		return false, ErrInvalidRequest.WithDebugf("invalid hmac-secret extension output, is of invalid type %T", rawCreated)
+	}
+	return created, nil
+}
+
+// HMACSecretOutput returns the output of the hmac-secret extension during login, which contains the encrypted output
+// of the HMAC of the requested salts. It is nil if the authenticator did not return the output.
+// https://fidoalliance.org/specs/fido-v2.0-ps-20190130/fido-client-to-authenticator-protocol-v2.0-ps-20190130.html#sctn-hmac-secret-extension
+func (o AuthenticationExtensionsAuthenticatorOutputs) HMACSecretOutput() ([]byte, error) {
+	rawOutput, ok := o["hmac-secret"]
+	if !ok {
+		return nil, nil
+	}
+	output, ok := rawOutput.([]byte)
+	if !ok {
+		return nil, ErrInvalidRequest.WithDebugf("invalid hmac-secret extension output, is of invalid type %T", rawOutput)
+	}
+	return output, nil
+}
+
+// CredentialProtectionPolicy is the protection level of a credential, as requested and returned by the credProtect
+// extension, which determines whether user verification is required to use the credential.
+// https://fidoalliance.org/specs/fido-v2.1-ps-20210615/fido-client-to-authenticator-protocol-v2.1-ps-20210615.html#sctn-credProtect-extension
+type CredentialProtectionPolicy string
+
+const (
+	// CredentialProtectionUserVerificationOptional indicates that user verification is optional, which is the default
+	// of authenticators.
+	CredentialProtectionUserVerificationOptional CredentialProtectionPolicy = "userVerificationOptional"
+	// CredentialProtectionUserVerificationOptionalWithCredentialIDList indicates that user verification is optional if
+	// the credential is in the allowed credentials, such that a discoverable credential is not disclosed to anyone
+	// without user verification.
+	CredentialProtectionUserVerificationOptionalWithCredentialIDList CredentialProtectionPolicy = "userVerificationOptionalWithCredentialIDList"
+	// CredentialProtectionUserVerificationRequired indicates that user verification is required to use the credential.
+	CredentialProtectionUserVerificationRequired CredentialProtectionPolicy = "userVerificationRequired"
+)
+
+// credentialProtectionPolicies are the policies of the values of the credProtect extension output.
+var credentialProtectionPolicies = map[uint64]CredentialProtectionPolicy{
+	1: CredentialProtectionUserVerificationOptional,
+	2: CredentialProtectionUserVerificationOptionalWithCredentialIDList,
+	3: CredentialProtectionUserVerificationRequired,
+}
+
+// CredProtect returns the output of the credProtect extension during registration, which is the protection policy of
+// the created credential. If the authenticator did not return the output, an empty policy is returned.
+// https://fidoalliance.org/specs/fido-v2.1-ps-20210615/fido-client-to-authenticator-protocol-v2.1-ps-20210615.html#sctn-credProtect-extension
+func (o AuthenticationExtensionsAuthenticatorOutputs) CredProtect() (CredentialProtectionPolicy, error) {
+	rawPolicy, ok := o["credProtect"]
+	if !ok {
+		return "", nil
+	}
+	value, ok := rawPolicy.(uint64)
+	if !ok {
+		return "", ErrInvalidRequest.WithDebugf("invalid credProtect extension output, is of invalid type %T", rawPolicy)
+	}
+	policy, ok := credentialProtectionPolicies[value]
+	if !ok {
+		return "", ErrInvalidRequest.WithDebugf("invalid credProtect extension output %d", value)
+	}
+	return policy, nil
+}
+
+// maxMinPINLength is the largest minimum PIN length, since PINs are at most 63 bytes long.
+const maxMinPINLength = 63
+
+// MinPINLength returns the output of the minPinLength extension during registration, which is the minimum PIN length
+// of the authenticator. Authenticators only return it if the RP ID is in the list of RP IDs that may read it, which is
+// configured on the authenticator, for instance by an enterprise. If the authenticator did not return the output, 0 is
+// returned.
+// https://fidoalliance.org/specs/fido-v2.1-ps-20210615/fido-client-to-authenticator-protocol-v2.1-ps-20210615.html#sctn-minpinlength-extension
+func (o AuthenticationExtensionsAuthenticatorOutputs) MinPINLength() (int, error) {
+	rawLength, ok := o["minPinLength"]
+	if !ok {
+		return 0, nil
+	}
+	length, ok := rawLength.(uint64)
+	if !ok {
+		return 0, ErrInvalidRequest.WithDebugf("invalid minPinLength extension output, is of invalid type %T", rawLength)
+	}
+	if length == 0 || length > maxMinPINLength {
+		return 0, ErrInvalidRequest.WithDebugf("invalid minPinLength extension output %d", length)
+	}
+	return int(length), nil
+}
+
+// UserVerificationMethod is a user verification method as defined in the FIDO Registry of Predefined Values.
+// https://fidoalliance.org/specs/common-specs/fido-registry-v2.1-ps-20191217.html#user-verification-methods
+type UserVerificationMethod uint32
+
+const (
+	// UserVerificationMethodPresenceInternal indicates a test of user presence, such as touching a button.
+	UserVerificationMethodPresenceInternal UserVerificationMethod = 0x00000001
+	// UserVerificationMethodFingerprintInternal indicates a fingerprint that is verified by the authenticator.
+	UserVerificationMethodFingerprintInternal UserVerificationMethod = 0x00000002
+	// UserVerificationMethodPasscodeInternal indicates a PIN or password that is verified by the authenticator.
+	UserVerificationMethodPasscodeInternal UserVerificationMethod = 0x00000004
+	// UserVerificationMethodVoiceprintInternal indicates voice recognition by the authenticator.
+	UserVerificationMethodVoiceprintInternal UserVerificationMethod = 0x00000008
+	// UserVerificationMethodFaceprintInternal indicates face recognition by the authenticator.
+	UserVerificationMethodFaceprintInternal UserVerificationMethod = 0x00000010
+	// UserVerificationMethodLocationInternal indicates verification of the location by the authenticator.
+	UserVerificationMethodLocationInternal UserVerificationMethod = 0x00000020
+	// UserVerificationMethodEyeprintInternal indicates eye recognition by the authenticator.
+	UserVerificationMethodEyeprintInternal UserVerificationMethod = 0x00000040
+	// UserVerificationMethodPatternInternal indicates a drawn pattern that is verified by the authenticator.
+	UserVerificationMethodPatternInternal UserVerificationMethod = 0x00000080
+	// UserVerificationMethodHandprintInternal indicates hand geometry recognition by the authenticator.
+	UserVerificationMethodHandprintInternal UserVerificationMethod = 0x00000100
+	// UserVerificationMethodNone indicates that no user verification or user presence test was performed.
+	UserVerificationMethodNone UserVerificationMethod = 0x00000200
+	// UserVerificationMethodAll indicates that all of the other methods were required.
+	UserVerificationMethodAll UserVerificationMethod = 0x00000400
+	// UserVerificationMethodPasscodeExternal indicates a PIN or password that is entered on the client.
+	UserVerificationMethodPasscodeExternal UserVerificationMethod = 0x00000800
+	// UserVerificationMethodPatternExternal indicates a drawn pattern that is entered on the client.
+	UserVerificationMethodPatternExternal UserVerificationMethod = 0x00001000
+)
+
+// UVMEntry is an entry of the output of the uvm extension, which describes a factor that was used to verify the user.
+// The key and matcher protection types are defined in the FIDO Registry of Predefined Values.
+// https://www.w3.org/TR/webauthn/#sctn-uvm-extension
+type UVMEntry struct {
+	// UserVerificationMethod is the method that was used to verify the user.
+	UserVerificationMethod UserVerificationMethod
+	// KeyProtectionType is the way the credential private key is protected.
+	KeyProtectionType uint16
+	// MatcherProtectionType is the way the matcher that verified the user is protected.
+	MatcherProtectionType uint16
+}
+
+// maxUVMEntries is the maximum number of entries in the output of the uvm extension.
+const maxUVMEntries = 3
+
+// UVM returns the output of the uvm extension, which contains the factors that were used to verify the user. If the
+// authenticator did not return the output, nil is returned.
+func (o AuthenticationExtensionsAuthenticatorOutputs) UVM() ([]UVMEntry, error) {
+	rawUVM, ok := o["uvm"]
+	if !ok {
+		return nil, nil
+	}
+	uvm, ok := rawUVM.([]interface{})
+	if !ok {
+		return nil, ErrInvalidRequest.WithDebugf("invalid uvm extension output, is of invalid type %T", rawUVM)
+	}
+	if len(uvm) == 0 || len(uvm) > maxUVMEntries {
+		return nil, ErrInvalidRequest.WithDebugf("invalid uvm extension output, contains %d entries", len(uvm))
+	}
+
+	entries := make([]UVMEntry, len(uvm))
+	for i, rawEntry := range uvm {
+		entry, ok := rawEntry.([]interface{})
+		if !ok || len(entry) != 3 {
+			return nil, ErrInvalidRequest.WithDebugf("invalid entry %d in uvm extension output", i)
+		}
+
+		var values [3]uint64
+		for j, rawValue := range entry {
+			value, ok := rawValue.(uint64)
+			if !ok || (j == 0 && value > 0xffffffff) || (j > 0 && value > 0xffff) {
+				return nil, ErrInvalidRequest.WithDebugf("invalid entry %d in uvm extension output", i)
+			}
+			values[j] = value
+		}
+
+		entries[i] = UVMEntry{
+			UserVerificationMethod: UserVerificationMethod(values[0]),
+			KeyProtectionType:      uint16(values[1]),
+			MatcherProtectionType:  uint16(values[2]),
+		}
+	}
+
+	return entries, nil
+}
+
+// DevicePublicKeyOutput contains the authenticator output of the devicePubKey extension, which contains a device-bound
+// key pair of the authenticator, such that the specific device behind a synced credential can be distinguished.
+// https://w3c.github.io/webauthn/#sctn-device-publickey-extension
+type DevicePublicKeyOutput struct {
+	// AAGUID is the AAGUID of the device.
+	AAGUID []byte
+	// DPK is the device public key, encoded in COSE_Key format.
+	DPK []byte
+	// Scope is the scope of the device public key. If it is 0, the key is shared across all RP IDs, if it is 1, the
+	// key is specific to the RP ID.
+	Scope uint64
+	// Nonce is a nonce that may be used to distinguish attestations of the device public key.
+	Nonce []byte
+	// Fmt is the attestation statement format of the attestation of the device public key.
+	Fmt string
+	// AttStmt is the attestation statement of the device public key.
+	AttStmt map[string]interface{}
+}
+
+// DevicePubKey returns the output of the devicePubKey extension. If the authenticator did not return the output, nil
+// is returned.
+func (o AuthenticationExtensionsAuthenticatorOutputs) DevicePubKey() (*DevicePublicKeyOutput, error) {
+	rawOutput, ok := o["devicePubKey"]
+	if !ok {
+		return nil, nil
+	}
+	output, ok := stringMap(rawOutput)
+	if !ok {
+		return nil, ErrInvalidRequest.WithDebugf("invalid devicePubKey extension output, is of invalid type %T", rawOutput)
+	}
+
+	devicePubKey := &DevicePublicKeyOutput{}
+	var valid bool
+	if devicePubKey.AAGUID, valid = output["aaguid"].([]byte); !valid || len(devicePubKey.AAGUID) != 16 {
+		return nil, ErrInvalidRequest.WithDebug("invalid aaguid in devicePubKey extension output")
+	}
+	if devicePubKey.DPK, valid = output["dpk"].([]byte); !valid {
+		return nil, ErrInvalidRequest.WithDebug("invalid dpk in devicePubKey extension output")
+	}
+	if devicePubKey.Scope, valid = output["scope"].(uint64); !valid {
+		return nil, ErrInvalidRequest.WithDebug("invalid scope in devicePubKey extension output")
+	}
+	if devicePubKey.Nonce, valid = output["nonce"].([]byte); !valid {
+		return nil, ErrInvalidRequest.WithDebug("invalid nonce in devicePubKey extension output")
+	}
+	if devicePubKey.Fmt, valid = output["fmt"].(string); !valid {
+		return nil, ErrInvalidRequest.WithDebug("invalid fmt in devicePubKey extension output")
+	}
+	if devicePubKey.AttStmt, valid = stringMap(output["attStmt"]); !valid {
+		return nil, ErrInvalidRequest.WithDebug("invalid attStmt in devicePubKey extension output")
+	}
+
+	return devicePubKey, nil
+}
+
+// stringMap converts a decoded CBOR map with string keys to a map[string]interface{}. Nested maps are decoded as
+// map[interface{}]interface{}, since CBOR allows keys of any type.
+func stringMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(m))
+		for rawKey, value := range m {
+			key, ok := rawKey.(string)
+			if !ok {
+				return nil, false
+			}
+			converted[key] = value
+		}
+		return converted, true
+	default:
+		return nil, false
+	}
+}
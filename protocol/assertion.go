@@ -1,9 +1,15 @@
 package protocol
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/json"
+	"io"
+
+	"github.com/keycloud/webauthn/cose"
 )
 
 // AssertionResponse contains the attributes that are returned to the caller when a new assertion is requested.
@@ -32,13 +38,13 @@ type ParsedAssertionResponse struct {
 type AuthenticatorAssertionResponse struct {
 	AuthenticatorResponse
 	// This attribute contains the authenticator data returned by the authenticator. See §6.1 Authenticator data.
-	AuthenticatorData []byte `json:"authenticatorData"`
+	AuthenticatorData URLEncodedBase64 `json:"authenticatorData"`
 	// This attribute contains the raw signature returned from the authenticator. See §6.3.3 The
 	// authenticatorGetAssertion operation.
-	Signature []byte `json:"signature"`
+	Signature URLEncodedBase64 `json:"signature"`
 	// This attribute contains the user handle returned from the authenticator, or null if the authenticator did not
 	// return a user handle. See §6.3.3 The authenticatorGetAssertion operation.
-	UserHandle []byte `json:"userHandle,omitempty"`
+	UserHandle URLEncodedBase64 `json:"userHandle,omitempty"`
 }
 
 // ParsedAuthenticatorAssertionResponse is a parsed version of AuthenticatorAssertionResponse.
@@ -60,8 +66,8 @@ type ParsedAuthenticatorAssertionResponse struct {
 // Error.
 func ParseAssertionResponse(p AssertionResponse) (ParsedAssertionResponse, error) {
 	r := ParsedAssertionResponse{}
-	r.ID, r.RawID, r.Type = p.ID, p.RawID, p.Type
-	r.Response.Signature = p.Response.Signature
+	r.ID, r.RawID, r.Type, r.ClientExtensionResults = p.ID, p.RawID, p.Type, p.ClientExtensionResults
+	r.Response.Signature, r.Response.UserHandle = p.Response.Signature, p.Response.UserHandle
 	r.RawResponse = p
 
 	// 6. Let C, the client data claimed as used for the signature, be the result of running an implementation-specific
@@ -71,24 +77,43 @@ func ParseAssertionResponse(p AssertionResponse) (ParsedAssertionResponse, error
 	}
 
 	if err := r.Response.AuthData.UnmarshalBinary(p.Response.AuthenticatorData); err != nil {
-		return ParsedAssertionResponse{}, ErrInvalidRequest.WithDebug(err.Error()).WithHint("Unable to parse auth data")
+		return ParsedAssertionResponse{}, ToWebAuthnError(err).WithHint("Unable to parse auth data")
 	}
 
 	return r, nil
 }
 
+// ParseCredentialAssertionResponse reads the JSON encoded response of navigator.credentials.get() from r, as sent by the
+// browser, and parses it to a ParsedAssertionResponse. Binary values may be encoded using base64url or standard
+// base64. If the data is invalid, an error is returned, usually of the type Error.
+func ParseCredentialAssertionResponse(r io.Reader) (ParsedAssertionResponse, error) {
+	var p AssertionResponse
+	if err := json.NewDecoder(r).Decode(&p); err != nil {
+		return ParsedAssertionResponse{}, ErrInvalidRequest.WithDebug(err.Error()).WithHint("Unable to parse response")
+	}
+	return ParseAssertionResponse(p)
+}
+
 // IsValidAssertion may be used to check whether an assertion is valid. If originalChallenge is nil, the challenge value
 // will not be checked (INSECURE). If relyingPartyID is empty, the relying party hash will not be checked (INSECURE). If
 // relyingPartyOrigin is empty, the relying party origin will not be checked (INSEUCRE).
-// If cert is nil, the hash will not be checked (INSECURE). Before calling this method, clients should execute the
+// If cert is nil, the hash will not be checked (INSECURE). The signature is verified using the signature algorithm of
+// cert if it is set, which is required for algorithms such as RSASSA-PSS that can not be derived from the public key,
+// or the default algorithm of its public key otherwise. Before calling this method, clients should execute the
 // following steps: If the allowCredentials option was given when this authentication ceremony was initiated, verify that
 // credential.id identifies one of the public key credentials that were listed in allowCredentials; If
 // credential.response.userHandle is present, verify that the user identified by this value is the owner of the public
 // key credential identified by credential.id. If the data is invalid, an error is returned, usually of the type
 // Error.
 func IsValidAssertion(p ParsedAssertionResponse, originalChallenge []byte, relyingPartyID, relyingPartyOrigin string, cert *x509.Certificate) (bool, error) {
+	return isValidAssertion(p, ClientDataTypeGet, originalChallenge, relyingPartyID, relyingPartyOrigin, cert)
+}
+
+// isValidAssertion checks whether an assertion is valid like IsValidAssertion, of which the client data must be of the
+// given type.
+func isValidAssertion(p ParsedAssertionResponse, clientDataType string, originalChallenge []byte, relyingPartyID, relyingPartyOrigin string, cert *x509.Certificate) (bool, error) {
 	// Check the client data, i.e. steps 7-10
-	if err := p.Response.ClientData.IsValid("webauthn.get", originalChallenge, relyingPartyOrigin); err != nil {
+	if err := p.Response.ClientData.IsValid(clientDataType, originalChallenge, relyingPartyOrigin); err != nil {
 		return false, err
 	}
 
@@ -103,8 +128,27 @@ func IsValidAssertion(p ParsedAssertionResponse, originalChallenge []byte, relyi
 
 		// 16. Using the credential public key looked up in step 3, verify that sig is a valid signature over the binary
 		// concatenation of authData and hash.
-		verificationData := append(p.RawResponse.Response.AuthenticatorData, clientDataHash[:]...)
-		if err := cert.CheckSignature(x509.ECDSAWithSHA256, verificationData, p.Response.Signature); err != nil {
+		authData := p.RawResponse.Response.AuthenticatorData
+		verificationData := make([]byte, 0, len(authData)+len(clientDataHash))
+		verificationData = append(verificationData, authData...)
+		verificationData = append(verificationData, clientDataHash[:]...)
+		if key, ok := cert.PublicKey.(cose.Ed448PublicKey); ok {
+			// Ed448 is not supported by x509
+			if !key.Verify(verificationData, p.Response.Signature) {
+				return false, ErrInvalidSignature.WithDebug("invalid Ed448 signature")
+			}
+			return true, nil
+		}
+		algorithm := cert.SignatureAlgorithm
+		if algorithm == x509.UnknownSignatureAlgorithm {
+			algorithm = assertionSignatureAlgorithm(cert.PublicKey)
+		}
+		if _, ok := cert.PublicKey.(*ecdsa.PublicKey); ok {
+			if _, _, err := ParseECDSASignature(p.Response.Signature); err != nil {
+				return false, ErrInvalidSignature.WithDebug(err.Error()).WithCause(err)
+			}
+		}
+		if err := cert.CheckSignature(algorithm, verificationData, p.Response.Signature); err != nil {
 			return false, ErrInvalidSignature.WithDebug(err.Error())
 		}
 	}
@@ -114,3 +158,156 @@ func IsValidAssertion(p ParsedAssertionResponse, originalChallenge []byte, relyi
 
 	return true, nil
 }
+
+// AssertionVerificationOptions are the options of VerifyAssertion.
+type AssertionVerificationOptions struct {
+	// UserVerification is the user verification requirement of the ceremony. If it is UserVerificationRequired, the
+	// User Verified flag must be set.
+	UserVerification UserVerificationRequirement
+	// AppID is the FIDO AppID that was requested using the appid extension, if any. If the client used it, the RP ID
+	// hash is checked against the AppID instead of the RP ID.
+	AppID string
+	// TokenBindingID is the Token Binding ID of the TLS connection over which the assertion was obtained, if token
+	// binding was used. See CollectedClientData.VerifyTokenBinding.
+	TokenBindingID []byte
+	// Payment is the payment that the user is expected to have confirmed, if the assertion is a Secure Payment
+	// Confirmation assertion. If it is set, the type of the client data must be ClientDataTypePaymentGet and its
+	// payment data must match, see CollectedClientData.VerifyPayment. Otherwise, Secure Payment Confirmation
+	// assertions are rejected.
+	Payment *CollectedClientPaymentData
+}
+
+// VerifyAssertion verifies an assertion response of an authentication ceremony, without the session handling of a
+// Relying Party. The challenge, origin and RP ID are the expected values of the ceremony, which must be set, and
+// publicKey is the COSE encoded public key of the credential. The caller is responsible for looking up the credential
+// and checking that it is allowed and owned by the user, as described by IsValidAssertion. If the assertion is valid,
+// the sign count of the authenticator is returned, which the caller should store with the credential. Public keys of
+// the non-standard algorithms ES256K and Ed448 are always accepted, since whether they are allowed is decided on
+// registration. If the data is invalid, an error is returned, usually of the type Error.
+func VerifyAssertion(resp AssertionResponse, expectedChallenge []byte, expectedOrigin, expectedRPID string, publicKey []byte, opts AssertionVerificationOptions) (uint32, error) {
+	p, err := VerifyAssertionResponse(resp, expectedChallenge, expectedOrigin, expectedRPID, publicKey, opts)
+	if err != nil {
+		return 0, err
+	}
+	return p.Response.AuthData.SignCount, nil
+}
+
+// VerifyAssertionResponse verifies an assertion response like VerifyAssertion, but returns the parsed response, such
+// that the flags and the extension outputs of the authenticator data can be examined once the assertion is valid.
+func VerifyAssertionResponse(resp AssertionResponse, expectedChallenge []byte, expectedOrigin, expectedRPID string, publicKey []byte, opts AssertionVerificationOptions) (*ParsedAssertionResponse, error) {
+	if len(expectedChallenge) == 0 || expectedOrigin == "" || expectedRPID == "" {
+		return nil, ErrInvalidRequest.WithDebug("missing expected challenge, origin or RP ID")
+	}
+
+	p, err := ParseAssertionResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.Response.ClientData.VerifyTokenBinding(opts.TokenBindingID); err != nil {
+		return nil, err
+	}
+
+	clientDataType := ClientDataTypeGet
+	if opts.Payment != nil {
+		clientDataType = ClientDataTypePaymentGet
+	}
+
+	key, err := cose.ParseCOSE(publicKey)
+	if err != nil {
+		return nil, ErrInvalidRequest.WithDebugf("invalid stored public key: %v", err).WithCause(err)
+	}
+
+	// The algorithm of the credential determines the signature algorithm, for instance RSASSA-PSS for RSA keys
+	alg, err := cose.Algorithm(publicKey)
+	if err != nil {
+		return nil, ErrInvalidRequest.WithDebugf("invalid stored public key: %v", err).WithCause(err)
+	}
+
+	// If the client used the AppID, the RP ID hash is the hash of the AppID instead of the RP ID
+	rpID := expectedRPID
+	if opts.AppID != "" {
+		appID, err := p.ClientExtensionResults.AppID()
+		if err != nil {
+			return nil, err
+		}
+		if appID {
+			rpID = opts.AppID
+		}
+	}
+
+	valid, err := isValidAssertion(p, clientDataType, expectedChallenge, rpID, expectedOrigin, &x509.Certificate{
+		PublicKey:          key,
+		SignatureAlgorithm: COSEAlgorithmIdentifier(alg).SignatureAlgorithm(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, ErrInvalidRequest.WithDebug("invalid login")
+	}
+
+	if opts.Payment != nil {
+		if err := p.Response.ClientData.VerifyPayment(*opts.Payment); err != nil {
+			return nil, err
+		}
+	}
+
+	// Verify that the User Verified bit of the flags in authData is set if user verification is required
+	if opts.UserVerification == UserVerificationRequired && !p.Response.AuthData.Flags.UserVerified() {
+		return nil, ErrUserNotVerified
+	}
+
+	return &p, nil
+}
+
+// SignatureAlgorithm returns the X.509 signature algorithm that corresponds to the COSE algorithm, or
+// x509.UnknownSignatureAlgorithm if it is not supported.
+func (alg COSEAlgorithmIdentifier) SignatureAlgorithm() x509.SignatureAlgorithm {
+	switch alg {
+	case ES256, ES256K:
+		return x509.ECDSAWithSHA256
+	case ES384:
+		return x509.ECDSAWithSHA384
+	case ES512:
+		return x509.ECDSAWithSHA512
+	case EdDSA:
+		return x509.PureEd25519
+	case RS256:
+		return x509.SHA256WithRSA
+	case RS384:
+		return x509.SHA384WithRSA
+	case RS512:
+		return x509.SHA512WithRSA
+	case PS256:
+		return x509.SHA256WithRSAPSS
+	case PS384:
+		return x509.SHA384WithRSAPSS
+	case PS512:
+		return x509.SHA512WithRSAPSS
+	default:
+		return x509.UnknownSignatureAlgorithm
+	}
+}
+
+// assertionSignatureAlgorithm returns the signature algorithm that is used for assertions by the given public key.
+func assertionSignatureAlgorithm(publicKey interface{}) x509.SignatureAlgorithm {
+	switch publicKey := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		switch publicKey.Curve.Params().BitSize {
+		case 384:
+			return x509.ECDSAWithSHA384
+		case 521:
+			return x509.ECDSAWithSHA512
+		default:
+			return x509.ECDSAWithSHA256
+		}
+	case *rsa.PublicKey:
+		return x509.SHA256WithRSA
+	case ed25519.PublicKey:
+		// EdDSA signatures are the raw 64-byte signature instead of being ASN.1 encoded
+		return x509.PureEd25519
+	default:
+		return x509.ECDSAWithSHA256
+	}
+}
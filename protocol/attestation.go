@@ -3,7 +3,12 @@ package protocol
 import (
 	"bytes"
 	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
 	"encoding/json"
+	"io"
+	"sort"
+	"time"
 
 	"github.com/ugorji/go/codec"
 )
@@ -40,7 +45,10 @@ type AuthenticatorAttestationResponse struct {
 	// contains any additional information that the Relying Party's server requires to validate the attestation
 	// statement, as well as to decode and validate the authenticator data along with the JSON-serialized client data.
 	// For more details, see §6.4 Attestation, §6.4.4 Generating an Attestation Object, and Figure 5.
-	AttestationObject []byte `json:"attestationObject"`
+	AttestationObject URLEncodedBase64 `json:"attestationObject"`
+	// Transports contains the transports that the authenticator is believed to support, as returned by
+	// getTransports(). It may be empty if the client does not report them.
+	Transports []AuthenticatorTransport `json:"transports,omitempty"`
 }
 
 // ParsedAuthenticatorAttestationResponse is a parsed version of AuthenticatorAttestationResponse
@@ -74,7 +82,7 @@ type Attestation struct {
 // Error.
 func ParseAttestationResponse(p AttestationResponse) (ParsedAttestationResponse, error) {
 	r := ParsedAttestationResponse{}
-	r.ID, r.RawID, r.Type = p.ID, p.RawID, p.Type
+	r.ID, r.RawID, r.Type, r.ClientExtensionResults = p.ID, p.RawID, p.Type, p.ClientExtensionResults
 	r.RawResponse = p
 
 	// 2. Let C, the client data claimed as collected during the credential creation, be the result of running an
@@ -83,16 +91,62 @@ func ParseAttestationResponse(p AttestationResponse) (ParsedAttestationResponse,
 		return ParsedAttestationResponse{}, ErrInvalidRequest.WithDebug(err.Error()).WithHint("Unable to parse client data")
 	}
 
+	attestation, err := ParseAttestationObject(p.Response.AttestationObject)
+	if err != nil {
+		return ParsedAttestationResponse{}, err
+	}
+	r.Response.Attestation = attestation
+
+	return r, nil
+}
+
+// ParseAttestationObject parses a CBOR encoded attestation object, which contains the attestation statement format fmt,
+// the authenticator data authData, and the attestation statement attStmt. The authenticator data must contain the
+// attested credential data. Since the attestation object is supplied by the client, it must be within the
+// AttestationObjectLimits. If the data is invalid, an error of the type Error is returned.
+func ParseAttestationObject(data []byte) (Attestation, error) {
+	// The attestation object is checked before decoding it, since it is supplied by the client
+	if err := AttestationObjectLimits.check(data); err != nil {
+		return Attestation{}, ToWebAuthnError(err).WithHint("Unable to parse attestation")
+	}
+
 	cbor := codec.CborHandle{}
 
 	// 8. Perform CBOR decoding on the attestationObject field of the AuthenticatorAttestationResponse structure to
 	// obtain the attestation statement format fmt, the authenticator data authData, and the attestation statement
 	// attStmt.
-	if err := codec.NewDecoder(bytes.NewReader(p.Response.AttestationObject), &cbor).Decode(&r.Response.Attestation); err != nil {
-		return ParsedAttestationResponse{}, ErrInvalidRequest.WithDebug(err.Error()).WithHint("Unable to parse attestation")
+	var rawAttestation struct {
+		Fmt      string                 `codec:"fmt"`
+		AuthData []byte                 `codec:"authData"`
+		AttStmt  map[string]interface{} `codec:"attStmt"`
+	}
+	if err := codec.NewDecoder(bytes.NewReader(data), &cbor).Decode(&rawAttestation); err != nil {
+		return Attestation{}, ErrInvalidRequest.WithDebugf("invalid attestation object: %v", err).WithHint("Unable to parse attestation")
 	}
 
-	return r, nil
+	// The authenticator data is parsed separately so that its errors are not hidden by the CBOR decoder
+	a := Attestation{Fmt: rawAttestation.Fmt, AttStmt: rawAttestation.AttStmt}
+	if err := a.AuthData.UnmarshalBinary(rawAttestation.AuthData); err != nil {
+		return Attestation{}, ToWebAuthnError(err).WithHint("Unable to parse auth data")
+	}
+
+	// The authenticator data of an attestation must contain the attested credential data of the new credential
+	if !a.AuthData.Flags.HasAttestedCredentialData() {
+		return Attestation{}, ErrMissingAttestedCredentialData.WithDebug("AT flag is not set").WithHint("Unable to parse auth data")
+	}
+
+	return a, nil
+}
+
+// ParseCredentialCreationResponse reads the JSON encoded response of navigator.credentials.create() from r, as sent by
+// the browser, and parses it to a ParsedAttestationResponse. Binary values may be encoded using base64url or standard
+// base64. If the data is invalid, an error is returned, usually of the type Error.
+func ParseCredentialCreationResponse(r io.Reader) (ParsedAttestationResponse, error) {
+	var p AttestationResponse
+	if err := json.NewDecoder(r).Decode(&p); err != nil {
+		return ParsedAttestationResponse{}, ErrInvalidRequest.WithDebug(err.Error()).WithHint("Unable to parse response")
+	}
+	return ParseAttestationResponse(p)
 }
 
 // IsValidAttestation may be used to check whether an attestation is valid. If originalChallenge is nil, the challenge value
@@ -100,43 +154,129 @@ func ParseAttestationResponse(p AttestationResponse) (ParsedAttestationResponse,
 // relyingPartyOrigin is empty, the relying party origin will not be checked (INSEUCRE).
 // If the data is invalid, an error is returned, usually of the type Error.
 func IsValidAttestation(p ParsedAttestationResponse, originalChallenge []byte, relyingPartyID, relyingPartyOrigin string) (bool, error) {
-	// Check the client data, i.e. steps 3-6
-	if err := p.Response.ClientData.IsValid("webauthn.create", originalChallenge, relyingPartyOrigin); err != nil {
+	if _, _, err := VerifyAttestation(p, originalChallenge, relyingPartyID, relyingPartyOrigin); err != nil {
 		return false, err
 	}
+	return true, nil
+}
+
+// VerifyAttestation checks whether an attestation is valid like IsValidAttestation, and returns the attestation type
+// and the attestation trust path, of which the first certificate is the attestation certificate. The trust path is
+// empty for self and none attestation. Credential public keys of the non-standard algorithms ES256K and Ed448 are
+// always accepted, so callers that do not accept them should reject them using cose.IsNonStandardKey, as
+// webauthn.RelyingParty does unless webauthn.WithNonStandardAlgorithms is set.
+func VerifyAttestation(p ParsedAttestationResponse, originalChallenge []byte, relyingPartyID, relyingPartyOrigin string) (AttestationType, []*x509.Certificate, error) {
+	return VerifyAttestationWithOptions(p, originalChallenge, relyingPartyID, relyingPartyOrigin, AttestationVerificationOptions{})
+}
+
+// AttestationVerificationOptions are the options of VerifyAttestationWithOptions, which are passed to the verification
+// procedure of the attestation format. The zero value verifies attestations as strictly as VerifyAttestation.
+type AttestationVerificationOptions struct {
+	// LenientSignatureAlgorithm determines whether packed basic attestation signatures that are invalid for the
+	// algorithm specified in alg are verified again with the signature algorithm of the attestation certificate and
+	// with ES256. This accommodates authenticators, such as some of Yubico's keys, that specify the wrong algorithm,
+	// but also hides attestations of which alg does not match the signature.
+	LenientSignatureAlgorithm bool
+	// AllowBasicIntegrity determines whether android-safetynet responses of which the ctsProfileMatch attribute is
+	// false are accepted if their basicIntegrity attribute is true. This also accepts devices that have not been
+	// tampered with but fail the Android compatibility tests, such as devices with an unlocked bootloader or an
+	// uncertified custom ROM.
+	AllowBasicIntegrity bool
+	// SafetyNetMaxResponseAge is the maximum difference between the timestamp of an android-safetynet response and the
+	// time at which it is verified, such that stale responses are not accepted. If it is zero, it is 60 seconds.
+	SafetyNetMaxResponseAge time.Duration
+	// CurrentTime is the time of the verification, against which the validity periods of attestation certificates and
+	// the freshness of android-safetynet responses are checked. If it is zero, the current time is used.
+	CurrentTime time.Time
+}
+
+// Now returns CurrentTime, or the current time if it is zero.
+func (o AttestationVerificationOptions) Now() time.Time {
+	if o.CurrentTime.IsZero() {
+		return time.Now()
+	}
+	return o.CurrentTime
+}
+
+// VerifyAttestationWithOptions checks whether an attestation is valid like VerifyAttestation, and passes opts to the
+// verification procedure of the attestation format.
+func VerifyAttestationWithOptions(p ParsedAttestationResponse, originalChallenge []byte, relyingPartyID, relyingPartyOrigin string, opts AttestationVerificationOptions) (AttestationType, []*x509.Certificate, error) {
+	// Check the client data, i.e. steps 3-6
+	if err := p.Response.ClientData.IsValid(ClientDataTypeCreate, originalChallenge, relyingPartyOrigin); err != nil {
+		return "", nil, err
+	}
 
 	// 7. Compute the hash of response.clientDataJSON using SHA-256
 	clientDataHash := sha256.Sum256(p.RawResponse.Response.ClientDataJSON)
 
 	// Check the attestation, i.e. steps 9-14
-	if err := p.Response.Attestation.IsValid(relyingPartyID, clientDataHash[:]); err != nil {
-		return false, err
+	return p.Response.Attestation.VerifyWithOptions(relyingPartyID, clientDataHash[:], opts)
+}
+
+// VerifyStatementFields verifies that the attestation statement only contains the keys that are registered for its
+// format using RegisterFormatFields, as is required by some Relying Parties for conformance. An unexpected key results
+// in ErrUnexpectedAttStmtField. Attestation statements of formats without registered keys are not checked.
+func (a Attestation) VerifyStatementFields() error {
+	fields, ok := lookupFormatFields(a.Fmt)
+	if !ok {
+		return nil
 	}
 
-	return true, nil
+	allowed := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		allowed[field] = true
+	}
+
+	// Sort the keys, such that the same key is reported for the same attestation statement
+	keys := make([]string, 0, len(a.AttStmt))
+	for key := range a.AttStmt {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !allowed[key] {
+			return ErrUnexpectedAttStmtField.WithDebugf("unexpected field %q in attestation statement for %s", key, a.Fmt)
+		}
+	}
+
+	return nil
 }
 
 // IsValid checks whether the Attestation is valid. If relyingPartyID is empty, the relying party ID hash will not be
 // checked (INSEUCRE). To register a new attestation type, use RegisterFormat. If the data is invalid, an error is
 // returned, usually of the type Error.
 func (a Attestation) IsValid(relyingPartyID string, clientDataHash []byte) error {
+	_, _, err := a.Verify(relyingPartyID, clientDataHash)
+	return err
+}
+
+// Verify checks whether the Attestation is valid like IsValid, and returns the attestation type and the attestation
+// trust path, as returned by the verification procedure of the attestation format.
+func (a Attestation) Verify(relyingPartyID string, clientDataHash []byte) (AttestationType, []*x509.Certificate, error) {
+	return a.VerifyWithOptions(relyingPartyID, clientDataHash, AttestationVerificationOptions{})
+}
+
+// VerifyWithOptions checks whether the Attestation is valid like Verify, and passes opts to the verification procedure
+// of the attestation format.
+func (a Attestation) VerifyWithOptions(relyingPartyID string, clientDataHash []byte, opts AttestationVerificationOptions) (AttestationType, []*x509.Certificate, error) {
 	// Check the auth data, i.e. steps 9-11
 	if err := a.AuthData.IsValid(relyingPartyID); err != nil {
-		return err
+		return "", nil, err
 	}
 
 	// 13. Determine the attestation statement format by performing a USASCII case-sensitive match on fmt against the set
 	// of supported WebAuthn Attestation Statement Format Identifier values.
-	format, ok := attestationFormats[a.Fmt]
+	format, ok := lookupFormat(a.Fmt)
 	if !ok {
-		return ErrUnsupportedAttestationFormat.WithDebugf("The attestation format %q is unknown", a.Fmt)
+		return "", nil, ErrUnsupportedAttestationFormat.WithDebugf("The attestation format %q is unknown", a.Fmt)
 	}
 
 	// 14. Verify that attStmt is a correct attestation statement, conveying a valid attestation signature, by using the
 	// attestation statement format fmt’s verification procedure given attStmt, authData and the hash of the serialized
 	// client data computed in step 7.
-	if err := format(a, clientDataHash); err != nil {
-		return err
+	attestationType, trustPath, err := format(a, clientDataHash, opts)
+	if err != nil {
+		return "", nil, err
 	}
 
 	// NOTE: However, if permitted by policy, the Relying Party MAY register the credential ID and credential public
@@ -144,5 +284,95 @@ func (a Attestation) IsValid(relyingPartyID string, clientDataHash []byte) error
 	// Relying Party is asserting there is no cryptographic proof that the public key credential has been generated
 	// by a particular authenticator model. See [FIDOSecRef] and [UAFProtocol] for a more detailed discussion.
 
-	return nil
+	return attestationType, trustPath, nil
+}
+
+var extensionIDSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+
+// Certificates returns the parsed certificates of x5c in the attestation statement, of which the first is the
+// attestation certificate. If the attestation statement does not contain x5c, nil is returned.
+func (a Attestation) Certificates() ([]*x509.Certificate, error) {
+	rawX5c, ok := a.AttStmt["x5c"]
+	if !ok {
+		return nil, nil
+	}
+	x5c, ok := rawX5c.([]interface{})
+	if !ok {
+		return nil, ErrInvalidAttestation.WithDebug("invalid x5c").WithStep(AttestationStepStatement)
+	}
+
+	certs := make([]*x509.Certificate, len(x5c))
+	for i, rawCert := range x5c {
+		der, ok := rawCert.([]byte)
+		if !ok {
+			return nil, ErrInvalidAttestation.WithDebug("invalid x5c").WithStep(AttestationStepStatement)
+		}
+
+		cert, err := ParseCertificate(der)
+		if err != nil {
+			return nil, ErrInvalidAttestation.WithDebugf("invalid x5c: %v", err).WithCause(err).WithStep(AttestationStepCertificate)
+		}
+		certs[i] = cert
+	}
+
+	return certs, nil
+}
+
+// VerifyChain verifies that the attestation certificate, which is the first element of x5c in the attestation
+// statement, chains up to one of the given roots and returns the verified chains. The remaining elements of x5c are
+// used as intermediates. Attestations without x5c, such as self attestation, none attestation and android-safetynet,
+// can not be verified and will result in an error; use VerifyCertificateChain with the trust path that is returned by
+// Verify instead. This should be called after IsValid, since it does not verify the attestation statement itself.
+func (a Attestation) VerifyChain(roots *x509.CertPool, currentTime time.Time) ([][]*x509.Certificate, error) {
+	certs, err := a.Certificates()
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, ErrCertChainInvalid.WithDebugf("attestation format %q does not contain a certificate chain", a.Fmt)
+	}
+
+	return VerifyCertificateChain(certs, roots, currentTime)
+}
+
+// VerifyCertificateChain verifies that an attestation trust path, such as the one that is returned by Verify, chains up
+// to one of the given roots and returns the verified chains. The first certificate of the trust path is the attestation
+// certificate and the remaining certificates are used as intermediates. The validity periods of the certificates are
+// checked against currentTime, or the current time if it is zero, which should be the CurrentTime of the
+// AttestationVerificationOptions that the attestation statement is verified with. An empty trust path, such as that of
+// self and none attestation, results in an error.
+func VerifyCertificateChain(certs []*x509.Certificate, roots *x509.CertPool, currentTime time.Time) ([][]*x509.Certificate, error) {
+	if len(certs) == 0 {
+		return nil, ErrCertChainInvalid.WithDebug("attestation does not contain a certificate chain")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	// The subject alternative name of TPM attestation certificates only contains a directoryName, which is not
+	// supported by crypto/x509, so it is reported as unhandled. It is verified by the tpm format instead. The leaf is
+	// copied, since parsed certificates are shared by ParseCertificate.
+	leaf := *certs[0]
+	var unhandled []asn1.ObjectIdentifier
+	for _, ext := range leaf.UnhandledCriticalExtensions {
+		if !ext.Equal(extensionIDSubjectAltName) {
+			unhandled = append(unhandled, ext)
+		}
+	}
+	leaf.UnhandledCriticalExtensions = unhandled
+
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   currentTime,
+		// Attestation certificates use a variety of extended key usages, such as tcg-kp-AIKCertificate for TPMs
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, ErrCertChainInvalid.WithDebugf("invalid certificate chain: %v", err).WithCause(err)
+	}
+
+	return chains, nil
 }
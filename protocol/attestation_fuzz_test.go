@@ -0,0 +1,94 @@
+package protocol_test
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	_ "github.com/keycloud/webauthn/attestation"
+	"github.com/keycloud/webauthn/protocol"
+)
+
+// TestAttestationStatementFuzz verifies attestation statements with random fields of random types using all
+// attestation formats, which must return an error instead of panicking.
+func TestAttestationStatementFuzz(t *testing.T) {
+	b := protocol.AttestationResponse{}
+	if err := json.Unmarshal([]byte(attestationResponses[0]), &b); err != nil {
+		t.Fatal(err)
+	}
+	p, err := protocol.ParseAttestationResponse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientDataHash := sha256.Sum256(b.Response.ClientDataJSON)
+
+	certificate := newTestCertificate(t, 1)
+	keys := []string{"alg", "sig", "x5c", "ecdaaKeyId", "ver", "response", "certInfo", "pubArea"}
+
+	r := rand.New(rand.NewSource(1))
+	randomBytes := func() []byte {
+		b := make([]byte, r.Intn(64))
+		r.Read(b)
+		return b
+	}
+	randomValue := func() interface{} {
+		switch r.Intn(12) {
+		case 0:
+			return nil
+		case 1:
+			return int64(-7 - r.Intn(300))
+		case 2:
+			return uint64(r.Intn(300))
+		case 3:
+			return "value"
+		case 4:
+			return randomBytes()
+		case 5:
+			return []interface{}{}
+		case 6:
+			return []interface{}{randomBytes()}
+		case 7:
+			return []interface{}{"value", randomBytes()}
+		case 8:
+			return []interface{}{certificate, nil}
+		case 9:
+			return []interface{}{certificate}
+		case 10:
+			return map[interface{}]interface{}{"value": randomBytes()}
+		default:
+			return true
+		}
+	}
+
+	for _, format := range []string{"packed", "fido-u2f", "android-key", "android-safetynet", "tpm", "none"} {
+		t.Run(format, func(t *testing.T) {
+			for i := 0; i < 2000; i++ {
+				a := p.Response.Attestation
+				a.Fmt = format
+				a.AttStmt = make(map[string]interface{})
+				for _, key := range keys {
+					if r.Intn(3) > 0 {
+						a.AttStmt[key] = randomValue()
+					}
+				}
+				// Use valid types for the common fields, such that the remaining fields are verified as well
+				if r.Intn(2) == 0 {
+					a.AttStmt["alg"], a.AttStmt["sig"] = int64(protocol.ES256), randomBytes()
+				}
+				if format == "tpm" && r.Intn(2) == 0 {
+					a.AttStmt["ver"] = "2.0"
+				}
+
+				func() {
+					defer func() {
+						if v := recover(); v != nil {
+							t.Fatalf("panic for attestation statement %#v: %v", a.AttStmt, v)
+						}
+					}()
+					_ = a.IsValid("", clientDataHash[:])
+				}()
+			}
+		})
+	}
+}
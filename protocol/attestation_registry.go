@@ -1,12 +1,92 @@
 package protocol
 
-// AttestationFormatFunction will be called when checking whether an Attestation is valid.
-type AttestationFormatFunction func(Attestation, []byte) error
+import (
+	"crypto/x509"
+	"sync"
+)
 
-var attestationFormats = make(map[string]AttestationFormatFunction)
+// AttestationFormatFunction will be called when checking whether an Attestation is valid. If it is, the attestation
+// type and the attestation trust path, of which the first certificate is the attestation certificate, are returned.
+type AttestationFormatFunction func(Attestation, []byte) (AttestationType, []*x509.Certificate, error)
+
+// AttestationFormatFunctionWithOptions is an AttestationFormatFunction that is also passed the options of the
+// verification, such that its behavior can be configured per Relying Party. Use RegisterFormatWithOptions to register
+// it.
+type AttestationFormatFunctionWithOptions func(Attestation, []byte, AttestationVerificationOptions) (AttestationType, []*x509.Certificate, error)
+
+// LegacyAttestationFormatFunction is the former signature of AttestationFormatFunction, which only returns whether
+// the Attestation is valid. Use LegacyFormat to register it.
+type LegacyAttestationFormatFunction func(Attestation, []byte) error
+
+var (
+	attestationFormatsMu    sync.RWMutex
+	attestationFormats      = make(map[string]AttestationFormatFunctionWithOptions)
+	attestationFormatFields = make(map[string][]string)
+)
 
 // RegisterFormat will register an attestation format. If the name already exists, it will be overwritten without
-// warning.
+// warning. Formats are commonly registered from init, but it is safe to register them while attestations are verified.
 func RegisterFormat(name string, f AttestationFormatFunction) {
+	RegisterFormatWithOptions(name, func(a Attestation, clientDataHash []byte, _ AttestationVerificationOptions) (AttestationType, []*x509.Certificate, error) {
+		return f(a, clientDataHash)
+	})
+}
+
+// RegisterFormatWithOptions will register an attestation format like RegisterFormat, of which the verification
+// procedure is passed the options of the verification.
+func RegisterFormatWithOptions(name string, f AttestationFormatFunctionWithOptions) {
+	attestationFormatsMu.Lock()
+	defer attestationFormatsMu.Unlock()
 	attestationFormats[name] = f
 }
+
+// RegisterFormatFields registers the keys that attestation statements of the format may contain, which are checked
+// by Attestation.VerifyStatementFields. If no fields are given, the attestation statement must be empty.
+func RegisterFormatFields(name string, fields ...string) {
+	attestationFormatsMu.Lock()
+	defer attestationFormatsMu.Unlock()
+	attestationFormatFields[name] = fields
+}
+
+// lookupFormatFields returns the keys that attestation statements of the format may contain, if they are registered.
+func lookupFormatFields(name string) ([]string, bool) {
+	attestationFormatsMu.RLock()
+	defer attestationFormatsMu.RUnlock()
+	fields, ok := attestationFormatFields[name]
+	return fields, ok
+}
+
+// lookupFormat returns the attestation format with the given name, if it is registered.
+func lookupFormat(name string) (AttestationFormatFunctionWithOptions, bool) {
+	attestationFormatsMu.RLock()
+	defer attestationFormatsMu.RUnlock()
+	f, ok := attestationFormats[name]
+	return f, ok
+}
+
+// IsFormatRegistered returns whether the attestation format with the given name is registered, i.e. whether
+// attestations of the format can be verified.
+func IsFormatRegistered(name string) bool {
+	_, ok := lookupFormat(name)
+	return ok
+}
+
+// LegacyFormat adapts a LegacyAttestationFormatFunction to an AttestationFormatFunction. Since the attestation type is
+// not known, it is basic attestation with x5c as the attestation trust path if the attestation statement contains x5c,
+// and self attestation otherwise.
+func LegacyFormat(f LegacyAttestationFormatFunction) AttestationFormatFunction {
+	return func(a Attestation, clientDataHash []byte) (AttestationType, []*x509.Certificate, error) {
+		if err := f(a, clientDataHash); err != nil {
+			return "", nil, err
+		}
+
+		trustPath, err := a.Certificates()
+		if err != nil {
+			return "", nil, err
+		}
+		if len(trustPath) == 0 {
+			return AttestationTypeSelf, nil, nil
+		}
+		return AttestationTypeBasic, trustPath, nil
+	}
+}
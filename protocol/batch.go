@@ -0,0 +1,83 @@
+package protocol
+
+import (
+	"context"
+	"crypto/x509"
+	"runtime"
+	"sync"
+)
+
+// BatchVerificationOptions are the options of VerifyBatch.
+type BatchVerificationOptions struct {
+	// RelyingPartyID is the RP ID that the attestations must be scoped to. If it is empty, the RP ID hash is not
+	// checked (INSECURE).
+	RelyingPartyID string
+	// RelyingPartyOrigin is the origin that the client data must match. If it is empty, the origin is not checked
+	// (INSECURE).
+	RelyingPartyOrigin string
+	// Attestation are the options that every attestation is verified with, for instance a CurrentTime in the past to
+	// re-validate attestations at the time at which they were registered.
+	Attestation AttestationVerificationOptions
+	// Workers is the number of attestations that are verified concurrently. If it is zero or negative, GOMAXPROCS
+	// attestations are verified concurrently.
+	Workers int
+}
+
+// BatchVerificationResult is the result of the verification of a single attestation by VerifyBatch.
+type BatchVerificationResult struct {
+	// AttestationType is the type of the attestation, if it is valid.
+	AttestationType AttestationType
+	// TrustPath is the attestation trust path, if the attestation is valid, of which the first certificate is the
+	// attestation certificate.
+	TrustPath []*x509.Certificate
+	// Err is the error of the verification, which is nil if the attestation is valid.
+	Err error
+}
+
+// VerifyBatch verifies many attestations concurrently like VerifyAttestationWithOptions, for instance to re-validate stored
+// attestations during a migration. Since the challenges of past ceremonies are not known, they are not checked. The
+// results are in the order of the responses. If ctx is cancelled, the attestations that have not been verified yet
+// are not verified, and their result contains the error of ctx.
+func VerifyBatch(ctx context.Context, responses []ParsedAttestationResponse, opts BatchVerificationOptions) []BatchVerificationResult {
+	results := make([]BatchVerificationResult, len(responses))
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(responses) {
+		workers = len(responses)
+	}
+
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				r := &results[i]
+				r.AttestationType, r.TrustPath, r.Err = VerifyAttestationWithOptions(responses[i], nil, opts.RelyingPartyID, opts.RelyingPartyOrigin, opts.Attestation)
+			}
+		}()
+	}
+
+	for i := range responses {
+		if err := ctx.Err(); err != nil {
+			for j := i; j < len(responses); j++ {
+				results[j].Err = err
+			}
+			break
+		}
+
+		select {
+		case indices <- i:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+		}
+	}
+	close(indices)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,77 @@
+package protocol_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	_ "github.com/keycloud/webauthn/attestation/packed"
+	"github.com/keycloud/webauthn/protocol"
+	"github.com/keycloud/webauthn/testutil"
+)
+
+func TestVerifyBatch(t *testing.T) {
+	authenticator, err := testutil.NewVirtualAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responses := make([]protocol.ParsedAttestationResponse, 10)
+	for i := range responses {
+		options := &protocol.CredentialCreationOptions{}
+		options.PublicKey.Challenge = []byte("challenge")
+		options.PublicKey.User.ID = []byte("user")
+		resp, err := authenticator.Register("https://localhost", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if responses[i], err = protocol.ParseAttestationResponse(*resp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Invalidate the signature of one of the attestations
+	invalid := responses[3].Response.Attestation.AttStmt["sig"].([]byte)
+	invalid = append([]byte{}, invalid...)
+	invalid[len(invalid)-1] ^= 0xff
+	responses[3].Response.Attestation.AttStmt = map[string]interface{}{"alg": responses[3].Response.Attestation.AttStmt["alg"], "sig": invalid}
+
+	for _, workers := range []int{0, 1, 3, 20} {
+		results := protocol.VerifyBatch(context.Background(), responses, protocol.BatchVerificationOptions{
+			RelyingPartyID:     "localhost",
+			RelyingPartyOrigin: "https://localhost",
+			Workers:            workers,
+		})
+		if len(results) != len(responses) {
+			t.Fatalf("expected %d results, got %d", len(responses), len(results))
+		}
+		for i, result := range results {
+			if i == 3 {
+				if result.Err == nil {
+					t.Fatalf("expected error for invalid attestation with %d workers", workers)
+				}
+				continue
+			}
+			if result.Err != nil || result.AttestationType != protocol.AttestationTypeSelf {
+				t.Fatalf("unexpected result %d with %d workers: %v, %v", i, workers, result.AttestationType, result.Err)
+			}
+		}
+	}
+
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		for _, result := range protocol.VerifyBatch(ctx, responses, protocol.BatchVerificationOptions{}) {
+			if !errors.Is(result.Err, context.Canceled) {
+				t.Fatalf("expected context.Canceled, got %v", result.Err)
+			}
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		if results := protocol.VerifyBatch(context.Background(), nil, protocol.BatchVerificationOptions{}); len(results) != 0 {
+			t.Fatalf("expected no results, got %d", len(results))
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package protocol
+
+// CBORLimits limits the CBOR data that is decoded, to prevent a malicious client from exhausting resources with a
+// huge or deeply nested attestation object. A limit of zero or less disables the limit.
+type CBORLimits struct {
+	// MaxSize is the maximum size in bytes of the encoded data.
+	MaxSize int
+	// MaxDepth is the maximum nesting depth of arrays, maps and tags.
+	MaxDepth int
+	// MaxLength is the maximum number of elements of an array or pairs of a map.
+	MaxLength int
+}
+
+// AttestationObjectLimits are the limits of attestation objects that are parsed by ParseAttestationResponse. If an
+// attestation object exceeds them, ErrAttestationTooLarge is returned.
+var AttestationObjectLimits = CBORLimits{
+	MaxSize:   64 * 1024,
+	MaxDepth:  16,
+	MaxLength: 256,
+}
+
+// check checks that the first CBOR data item of data is well-formed and within the limits.
+func (l CBORLimits) check(data []byte) error {
+	if l.MaxSize > 0 && len(data) > l.MaxSize {
+		return ErrAttestationTooLarge.WithDebugf("size of %d bytes exceeds the maximum of %d bytes", len(data), l.MaxSize)
+	}
+	_, err := l.checkItem(data, 0)
+	return err
+}
+
+// checkItem checks the CBOR data item at the start of data, which is nested at the given depth, and returns its
+// encoded length.
+func (l CBORLimits) checkItem(data []byte, depth int) (int, error) {
+	if len(data) == 0 {
+		return 0, ErrInvalidRequest.WithDebug("truncated CBOR data")
+	}
+
+	major, info := data[0]>>5, data[0]&0x1f
+	n := 1
+
+	var arg uint64
+	indefinite := false
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(data) < n+size {
+			return 0, ErrInvalidRequest.WithDebug("truncated CBOR data")
+		}
+		for _, b := range data[n : n+size] {
+			arg = arg<<8 | uint64(b)
+		}
+		n += size
+	case info == 31 && major >= 2 && major <= 5:
+		indefinite = true
+	default:
+		return 0, ErrInvalidRequest.WithDebugf("malformed CBOR data item 0x%02x", data[0])
+	}
+
+	switch major {
+	case 0, 1, 7:
+		// Integers, simple values and floats
+		return n, nil
+	case 2, 3:
+		// Byte and text strings, of which indefinite length strings consist of definite length chunks
+		if indefinite {
+			for {
+				if len(data) <= n {
+					return 0, ErrInvalidRequest.WithDebug("truncated CBOR data")
+				}
+				if data[n] == 0xff {
+					return n + 1, nil
+				}
+				if data[n]>>5 != major || data[n]&0x1f == 31 {
+					return 0, ErrInvalidRequest.WithDebug("malformed CBOR string chunk")
+				}
+				m, err := l.checkItem(data[n:], depth)
+				if err != nil {
+					return 0, err
+				}
+				n += m
+			}
+		}
+		if arg > uint64(len(data)-n) {
+			return 0, ErrInvalidRequest.WithDebug("truncated CBOR data")
+		}
+		return n + int(arg), nil
+	case 6:
+		// Tags
+		if l.MaxDepth > 0 && depth >= l.MaxDepth {
+			return 0, ErrAttestationTooLarge.WithDebugf("nesting exceeds the maximum depth of %d", l.MaxDepth)
+		}
+		m, err := l.checkItem(data[n:], depth+1)
+		if err != nil {
+			return 0, err
+		}
+		return n + m, nil
+	}
+
+	// Arrays and maps, of which every element is at least one byte
+	if l.MaxDepth > 0 && depth >= l.MaxDepth {
+		return 0, ErrAttestationTooLarge.WithDebugf("nesting exceeds the maximum depth of %d", l.MaxDepth)
+	}
+	if !indefinite {
+		if l.MaxLength > 0 && arg > uint64(l.MaxLength) {
+			return 0, ErrAttestationTooLarge.WithDebugf("length of %d exceeds the maximum of %d", arg, l.MaxLength)
+		}
+		if arg > uint64(len(data)-n) {
+			return 0, ErrInvalidRequest.WithDebug("truncated CBOR data")
+		}
+	}
+
+	elements := 1
+	if major == 5 {
+		elements = 2
+	}
+	for i := uint64(0); indefinite || i < arg; i++ {
+		if indefinite {
+			if len(data) <= n {
+				return 0, ErrInvalidRequest.WithDebug("truncated CBOR data")
+			}
+			if data[n] == 0xff {
+				return n + 1, nil
+			}
+			if l.MaxLength > 0 && i >= uint64(l.MaxLength) {
+				return 0, ErrAttestationTooLarge.WithDebugf("length exceeds the maximum of %d", l.MaxLength)
+			}
+		}
+		for j := 0; j < elements; j++ {
+			m, err := l.checkItem(data[n:], depth+1)
+			if err != nil {
+				return 0, err
+			}
+			n += m
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,62 @@
+package protocol_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+func TestAttestationObjectLimits(t *testing.T) {
+	defer func(limits protocol.CBORLimits) {
+		protocol.AttestationObjectLimits = limits
+	}(protocol.AttestationObjectLimits)
+	protocol.AttestationObjectLimits = protocol.CBORLimits{MaxSize: 1024, MaxDepth: 4, MaxLength: 8}
+
+	// {"fmt": "none", "attStmt": x}
+	attestationObject := func(attStmt ...byte) []byte {
+		return append([]byte{0xa2, 0x63, 'f', 'm', 't', 0x64, 'n', 'o', 'n', 'e', 0x67, 'a', 't', 't', 'S', 't', 'm', 't'}, attStmt...)
+	}
+
+	for _, test := range []struct {
+		name              string
+		attestationObject []byte
+		err               error
+	}{
+		{"Too large", attestationObject(append([]byte{0x59, 0x04, 0x00}, make([]byte, 1024)...)...), protocol.ErrAttestationTooLarge},
+		{"Too deep", attestationObject(0x81, 0x81, 0x81, 0x81, 0x00), protocol.ErrAttestationTooLarge},
+		{"Too deep tags", attestationObject(0xc1, 0xc1, 0xc1, 0xc1, 0x00), protocol.ErrAttestationTooLarge},
+		{"Too long array", attestationObject(0x89, 0, 0, 0, 0, 0, 0, 0, 0, 0), protocol.ErrAttestationTooLarge},
+		{"Too long map", attestationObject(0xbb, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff), protocol.ErrAttestationTooLarge},
+		{"Too long indefinite array", attestationObject(0x9f, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff), protocol.ErrAttestationTooLarge},
+		{"Truncated array", attestationObject(0x9a, 0x00, 0x00, 0x00, 0x08), protocol.ErrInvalidRequest},
+		{"Truncated string", attestationObject(0x5a, 0xff, 0xff, 0xff, 0xff), protocol.ErrInvalidRequest},
+		{"Malformed", attestationObject(0x1c), protocol.ErrInvalidRequest},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			p := protocol.AttestationResponse{}
+			p.Response.ClientDataJSON = []byte(`{}`)
+			p.Response.AttestationObject = test.attestationObject
+
+			_, err := protocol.ParseAttestationResponse(p)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+			if test.err != protocol.ErrAttestationTooLarge && errors.Is(err, protocol.ErrAttestationTooLarge) {
+				t.Fatalf("expected no %v", protocol.ErrAttestationTooLarge)
+			}
+		})
+	}
+
+	// Nested data within the limits is passed to the decoder
+	_, err := protocol.ParseAttestationResponse(protocol.AttestationResponse{
+		Response: protocol.AuthenticatorAttestationResponse{
+			AuthenticatorResponse: protocol.AuthenticatorResponse{ClientDataJSON: []byte(`{}`)},
+			AttestationObject:     attestationObject(append(bytes.Repeat([]byte{0xa1, 0x61, 'x'}, 3), 0x00)...),
+		},
+	})
+	if errors.Is(err, protocol.ErrAttestationTooLarge) {
+		t.Fatalf("expected attestation object within the limits, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package protocol
+
+import (
+	"container/list"
+	"crypto/x509"
+	"encoding/asn1"
+	"sync"
+)
+
+// DefaultCertificateCacheSize is the default number of parsed certificates that are cached by ParseCertificate.
+const DefaultCertificateCacheSize = 1024
+
+var certificates = newCertificateCache(DefaultCertificateCacheSize)
+
+// ParseCertificate parses a single DER encoded certificate, like x509.ParseCertificate. Since authenticators of the
+// same model commonly share their attestation certificate, recently parsed certificates are cached by their raw bytes,
+// so the same certificate is parsed only once. The returned certificate may be shared and must not be modified.
+func ParseCertificate(der []byte) (*x509.Certificate, error) {
+	return certificates.parse(der)
+}
+
+// SetCertificateCacheSize sets the maximum number of parsed certificates that are cached by ParseCertificate. If size
+// is zero or negative, certificates are not cached. The default is DefaultCertificateCacheSize.
+func SetCertificateCacheSize(size int) {
+	certificates.resize(size)
+}
+
+// extensionIDFIDOU2FTransports is the OID of the id-fido-u2f-ce-transports extension, which contains the transports
+// that the authenticator supports.
+var extensionIDFIDOU2FTransports = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 2, 1, 1}
+
+// fidoU2FTransports are the transports of the bits of the id-fido-u2f-ce-transports extension, which are
+// bluetoothRadio, bluetoothLowEnergy, uSB, nFC and uSBInternal.
+var fidoU2FTransports = []AuthenticatorTransport{
+	AuthenticatorTransportBLE,
+	AuthenticatorTransportBLE,
+	AuthenticatorTransportUSB,
+	AuthenticatorTransportNFC,
+	AuthenticatorTransportInternal,
+}
+
+// CertificateTransports returns the transports that are contained in the id-fido-u2f-ce-transports extension of an
+// attestation certificate, or nil if it does not contain the extension. Unknown transports are ignored.
+func CertificateTransports(cert *x509.Certificate) ([]AuthenticatorTransport, error) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(extensionIDFIDOU2FTransports) {
+			continue
+		}
+
+		var bits asn1.BitString
+		if rest, err := asn1.Unmarshal(ext.Value, &bits); err != nil {
+			return nil, ErrInvalidAttestation.WithDebugf("invalid transports extension: %v", err)
+		} else if len(rest) > 0 {
+			return nil, ErrInvalidAttestation.WithDebug("invalid transports extension: trailing data")
+		}
+
+		var transports []AuthenticatorTransport
+		for i, transport := range fidoU2FTransports {
+			if bits.At(i) == 0 {
+				continue
+			}
+			// Both Bluetooth bits map to the same transport
+			if len(transports) > 0 && transports[len(transports)-1] == transport {
+				continue
+			}
+			transports = append(transports, transport)
+		}
+		return transports, nil
+	}
+
+	return nil, nil
+}
+
+// certificateCache is a least recently used cache of parsed certificates, keyed by their raw bytes.
+type certificateCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func newCertificateCache(size int) *certificateCache {
+	return &certificateCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *certificateCache) parse(der []byte) (*x509.Certificate, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[string(der)]; ok {
+		c.order.MoveToFront(e)
+		c.mu.Unlock()
+		return e.Value.(*x509.Certificate), nil
+	}
+	c.mu.Unlock()
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.size <= 0 {
+		return cert, nil
+	}
+	if e, ok := c.entries[string(der)]; ok {
+		// The certificate has been parsed concurrently
+		c.order.MoveToFront(e)
+		return e.Value.(*x509.Certificate), nil
+	}
+	c.entries[string(der)] = c.order.PushFront(cert)
+	c.evict()
+
+	return cert, nil
+}
+
+func (c *certificateCache) resize(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.size = size
+	c.evict()
+}
+
+// evict removes the least recently used certificates until the cache is within its size. The caller must hold mu.
+func (c *certificateCache) evict() {
+	for c.order.Len() > 0 && c.order.Len() > c.size {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.entries, string(e.Value.(*x509.Certificate).Raw))
+	}
+}
@@ -0,0 +1,138 @@
+package protocol_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+func newTestCertificate(t testing.TB, serial int64, extensions ...pkix.Extension) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "Test Attestation"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+
+		ExtraExtensions: extensions,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return der
+}
+
+func TestParseCertificate(t *testing.T) {
+	defer protocol.SetCertificateCacheSize(protocol.DefaultCertificateCacheSize)
+	protocol.SetCertificateCacheSize(1)
+
+	first, second := newTestCertificate(t, 1), newTestCertificate(t, 2)
+
+	cert, err := protocol.ParseCertificate(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := protocol.ParseCertificate(first); cached != cert {
+		t.Fatal("expected certificate to be cached")
+	}
+
+	if _, err := protocol.ParseCertificate(second); err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := protocol.ParseCertificate(first); cached == cert {
+		t.Fatal("expected certificate to be evicted")
+	}
+
+	protocol.SetCertificateCacheSize(0)
+	if cert, _ = protocol.ParseCertificate(first); cert == nil {
+		t.Fatal("expected certificate to be parsed")
+	}
+	if cached, _ := protocol.ParseCertificate(first); cached == cert {
+		t.Fatal("expected certificate not to be cached")
+	}
+
+	if _, err := protocol.ParseCertificate([]byte("invalid")); err == nil {
+		t.Fatal("expected error for invalid certificate")
+	}
+}
+
+func TestCertificateTransports(t *testing.T) {
+	transportsExtension := func(bits asn1.BitString) pkix.Extension {
+		value, err := asn1.Marshal(bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pkix.Extension{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 2, 1, 1}, Value: value}
+	}
+
+	for _, test := range []struct {
+		name       string
+		extensions []pkix.Extension
+		expected   []protocol.AuthenticatorTransport
+		err        bool
+	}{
+		{"Missing", nil, nil, false},
+		{"USB and NFC", []pkix.Extension{transportsExtension(asn1.BitString{Bytes: []byte{0x30}, BitLength: 4})}, []protocol.AuthenticatorTransport{protocol.AuthenticatorTransportUSB, protocol.AuthenticatorTransportNFC}, false},
+		{"Bluetooth", []pkix.Extension{transportsExtension(asn1.BitString{Bytes: []byte{0xc0}, BitLength: 2})}, []protocol.AuthenticatorTransport{protocol.AuthenticatorTransportBLE}, false},
+		{"Unknown", []pkix.Extension{transportsExtension(asn1.BitString{Bytes: []byte{0x01}, BitLength: 8})}, nil, false},
+		{"Invalid", []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 2, 1, 1}, Value: []byte{0x04, 0x00}}}, nil, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cert, err := protocol.ParseCertificate(newTestCertificate(t, 1, test.extensions...))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			transports, err := protocol.CertificateTransports(cert)
+			if test.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(transports, test.expected) {
+				t.Fatalf("expected %v, got %v", test.expected, transports)
+			}
+		})
+	}
+}
+
+func BenchmarkParseCertificate(b *testing.B) {
+	der := newTestCertificate(b, 1)
+
+	for _, size := range []int{0, protocol.DefaultCertificateCacheSize} {
+		protocol.SetCertificateCacheSize(size)
+
+		name := "Cached"
+		if size == 0 {
+			name = "Uncached"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := protocol.ParseCertificate(der); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
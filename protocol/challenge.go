@@ -18,3 +18,14 @@ func NewChallenge() (Challenge, error) {
 	}
 	return b, nil
 }
+
+// MarshalJSON implements the json.Marshaler interface. The challenge is encoded as a base64url string, as expected by
+// the browser.
+func (c Challenge) MarshalJSON() ([]byte, error) {
+	return URLEncodedBase64(c).MarshalJSON()
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (c *Challenge) UnmarshalJSON(data []byte) error {
+	return (*URLEncodedBase64)(c).UnmarshalJSON(data)
+}
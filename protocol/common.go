@@ -1,14 +1,16 @@
 package protocol
 
 import (
-	"bytes"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding"
 	"encoding/base64"
 	"encoding/binary"
 	"fmt"
+	"strings"
 
 	"github.com/keycloud/webauthn/cose"
+	"github.com/ugorji/go/codec"
 )
 
 // The PublicKeyCredential interface inherits from Credential [CREDENTIAL-MANAGEMENT-1], and contains the attributes
@@ -20,9 +22,11 @@ type PublicKeyCredential struct {
 	// returning the base64url encoding of the data contained in the object’s [[identifier]] internal slot.
 	ID string `json:"id"`
 	// This attribute returns the ArrayBuffer contained in the [[identifier]] internal slot.
-	RawID []byte `json:"rawId"`
+	RawID URLEncodedBase64 `json:"rawId"`
 	// The PublicKeyCredential interface object's [[type]] internal slot's value is the string "public-key".
 	Type string `json:"type"`
+	// This contains the results of getClientExtensionResults(), i.e. the client extension output values.
+	ClientExtensionResults AuthenticationExtensionsClientOutputs `json:"clientExtensionResults,omitempty"`
 }
 
 // ParsedPublicKeyCredential is a parsed version of PublicKeyCredential
@@ -35,21 +39,24 @@ type ParsedPublicKeyCredential struct {
 	RawID []byte
 	// The PublicKeyCredential interface object's [[type]] internal slot's value is the string "public-key".
 	Type string
+	// This contains the results of getClientExtensionResults(), i.e. the client extension output values.
+	ClientExtensionResults AuthenticationExtensionsClientOutputs
 }
 
 // AuthenticatorResponse is used by authenticators to respond to Relying Party requests.
 // https://www.w3.org/TR/webauthn/#authenticatorresponse
 type AuthenticatorResponse struct {
 	// This attribute contains a JSON serialization of the client data passed to the authenticator by the client in
-	// its call to either create() or get().
-	ClientDataJSON []byte `json:"clientDataJSON"`
+	// its call to either create() or get(). The client data hash is always computed over these exact bytes, since
+	// the client data is not guaranteed to be serialized the same way again.
+	ClientDataJSON URLEncodedBase64 `json:"clientDataJSON"`
 }
 
 // ParsedAuthenticatorResponse is a parsed version of AuthenticatorResponse.
 // https://www.w3.org/TR/webauthn/#authenticatorresponse
 type ParsedAuthenticatorResponse struct {
 	// This attribute contains the parsed client data passed to the authenticator by the client in its call to either
-	// create() or get().
+	// create() or get(). It is only used to check its members; unknown members are ignored.
 	ClientData CollectedClientData
 }
 
@@ -71,8 +78,19 @@ type CollectedClientData struct {
 	// This OPTIONAL member contains information about the state of the Token Binding protocol used when communicating
 	// with the Relying Party. Its absence indicates that the client doesn’t support token binding.
 	TokenBinding *TokenBinding `json:"tokenBinding,omitempty"`
+	// This member contains the payment that the user confirmed, if the client data is of a Secure Payment
+	// Confirmation assertion. See VerifyPayment.
+	Payment *CollectedClientPaymentData `json:"payment,omitempty"`
 }
 
+// The values of the type member of CollectedClientData.
+const (
+	// ClientDataTypeCreate is the type of the client data when creating new credentials.
+	ClientDataTypeCreate = "webauthn.create"
+	// ClientDataTypeGet is the type of the client data when getting an assertion from an existing credential.
+	ClientDataTypeGet = "webauthn.get"
+)
+
 // TokenBinding represents the token binding.
 // https://www.w3.org/TR/webauthn/#dictdef-tokenbinding
 type TokenBinding struct {
@@ -88,21 +106,49 @@ type TokenBinding struct {
 type TokenBindingStatus string
 
 const (
-	// TokenBindingStatusPresent indicates the client supports token binding, but it was not negotiated when
-	// communicating with the Relying Party.
-	TokenBindingStatusPresent TokenBindingStatus = "present"
-	// TokenBindingStatusSupported indicates token binding was used when communicating with the Relying Party. In this
+	// TokenBindingStatusPresent indicates token binding was used when communicating with the Relying Party. In this
 	// case, the id member MUST be present.
-	TokenBindingStatusSupported = "supported"
+	TokenBindingStatusPresent TokenBindingStatus = "present"
+	// TokenBindingStatusSupported indicates the client supports token binding, but it was not negotiated when
+	// communicating with the Relying Party.
+	TokenBindingStatusSupported TokenBindingStatus = "supported"
+	// TokenBindingStatusNotSupported indicates the client does not support token binding. It is sent by older clients
+	// instead of omitting the token binding.
+	TokenBindingStatusNotSupported TokenBindingStatus = "not-supported"
 )
 
-// IsValid checks whether the CollectedClientData is valid. If originalChallenge is nil, the challenge value
+// VerifyTokenBinding verifies the token binding of the client data against the Token Binding ID of the TLS connection
+// over which the response was obtained. If tokenBindingID is nil, token binding was not used on the connection and the
+// token binding is ignored. Otherwise, the status must be present and the id must match tokenBindingID, as otherwise
+// ErrTokenBindingMismatch is returned.
+func (c CollectedClientData) VerifyTokenBinding(tokenBindingID []byte) error {
+	if tokenBindingID == nil {
+		return nil
+	}
+
+	if c.TokenBinding == nil || c.TokenBinding.Status != TokenBindingStatusPresent {
+		return ErrTokenBindingMismatch.WithDebug("token binding is not present")
+	}
+	id, err := base64.RawURLEncoding.DecodeString(c.TokenBinding.ID)
+	if err != nil {
+		return ErrTokenBindingMismatch.WithDebugf("invalid token binding ID: %v", err)
+	}
+	if subtle.ConstantTimeCompare(id, tokenBindingID) != 1 {
+		return ErrTokenBindingMismatch
+	}
+
+	return nil
+}
+
+// IsValid checks whether the CollectedClientData is valid. The type must match requiredType, which is either
+// ClientDataTypeCreate or ClientDataTypeGet, as otherwise ErrInvalidClientDataType is returned. This prevents client
+// data of one ceremony from being replayed in another. If originalChallenge is nil, the challenge value
 // will not be checked (INSECURE). If relyingPartyOrigin is empty, the relying party will not be checked (INSEUCRE).
 // If the data is invalid, an error is returned, usually of the type Error.
 func (c CollectedClientData) IsValid(requiredType string, originalChallenge []byte, relyingPartyOrigin string) error {
 	// Verify that the value of C.type is requiredType
 	if c.Type != requiredType {
-		return ErrInvalidType.WithDebugf("%q did not match required %q", c.Type, requiredType)
+		return ErrInvalidClientDataType.WithDebugf("%q did not match required %q", c.Type, requiredType)
 	}
 
 	if originalChallenge != nil {
@@ -112,7 +158,8 @@ func (c CollectedClientData) IsValid(requiredType string, originalChallenge []by
 		if err != nil {
 			return ErrInvalidChallenge.WithDebug(err.Error())
 		}
-		if !bytes.Equal(challenge, originalChallenge) {
+		// Compare in constant time to not leak the challenge through timing
+		if subtle.ConstantTimeCompare(challenge, originalChallenge) != 1 {
 			return ErrInvalidChallenge
 		}
 	}
@@ -122,9 +169,8 @@ func (c CollectedClientData) IsValid(requiredType string, originalChallenge []by
 		return ErrInvalidOrigin.WithDebugf("%q did not match required %q", relyingPartyOrigin, c.Origin)
 	}
 
-	// TODO: Verify that the value of C.tokenBinding.status matches the state of Token Binding for the TLS connection
-	// over which the assertion was obtained. If Token Binding was used on that TLS connection, also verify that
-	// C.tokenBinding.id matches the base64url encoding of the Token Binding ID for the connection.
+	// The token binding is verified by VerifyTokenBinding, since the Token Binding ID of the connection is only known
+	// to the caller
 
 	return nil
 }
@@ -146,6 +192,9 @@ type AuthenticatorData struct {
 	// attested credential data (if present). See §6.4.1 Attested credential data for details. Its length depends on the
 	// length of the credential ID and credential public key being attested.
 	AttestedCredentialData AttestedCredentialData
+	// Extension-defined authenticator data (if present). This is a CBOR map with extension identifiers as keys, and
+	// authenticator extension outputs as values. See §9 WebAuthn Extensions for details.
+	Extensions AuthenticationExtensionsAuthenticatorOutputs
 	// Raw contains the raw bytes of this AuthenticatorData.
 	Raw []byte
 }
@@ -155,13 +204,18 @@ type AuthenticatorData struct {
 func (a AuthenticatorData) IsValid(relyingPartyID string) error {
 	// Verify that the RP ID hash in authData is indeed the SHA-256 hash of the RP ID expected by the RP
 	rpHash := sha256.Sum256([]byte(relyingPartyID))
-	if relyingPartyID != "" && !bytes.Equal(rpHash[:], a.RPIDHash) {
-		return ErrInvalidOrigin.WithDebugf("hash %X did not match required %X", a.RPIDHash, rpHash[:])
+	if relyingPartyID != "" && subtle.ConstantTimeCompare(rpHash[:], a.RPIDHash) != 1 {
+		return ErrRPIDHashMismatch.WithDebugf("hash %X did not match required %X", a.RPIDHash, rpHash[:])
 	}
 
 	// Verify that the User Present bit of the flags in authData is set
 	if !a.Flags.UserPresent() {
-		return ErrNoUserPresent
+		return ErrNoUserPresent.WithDebugf("flags are %s", a.Flags)
+	}
+
+	// If the BE bit of the flags in authData is not set, verify that the BS bit is not set
+	if a.Flags.BackupState() && !a.Flags.BackupEligible() {
+		return ErrInvalidRequest.WithDebugf("backup state is set, but the credential is not backup eligible: flags are %s", a.Flags)
 	}
 
 	return nil
@@ -170,34 +224,107 @@ func (a AuthenticatorData) IsValid(relyingPartyID string) error {
 var _ encoding.BinaryUnmarshaler = (*AuthenticatorData)(nil)
 var _ encoding.BinaryMarshaler = (*AuthenticatorData)(nil)
 
+// maxCredentialIDLength is the maximum length of a credential ID.
+const maxCredentialIDLength = 1023
+
 // UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
 func (a *AuthenticatorData) UnmarshalBinary(authData []byte) error {
-	if len(authData) < 37 {
+	r := &authDataReader{data: authData}
+
+	header, ok := r.next(37)
+	if !ok {
 		return ErrInvalidRequest.WithDebug("invalid authenticator data")
 	}
+	a.RPIDHash = header[0:32]
+	a.Flags = AuthenticatorDataFlags(header[32])
+	a.SignCount = binary.BigEndian.Uint32(header[33:37])
+
+	// The attested credential data is only present if the AT flag is set
+	if a.Flags.HasAttestedCredentialData() {
+		if a.AttestedCredentialData.AAGUID, ok = r.next(16); !ok {
+			return ErrMissingAttestedCredentialData.WithDebug("truncated attested credential data")
+		}
+		rawLength, ok := r.next(2)
+		if !ok {
+			return ErrMissingAttestedCredentialData.WithDebug("truncated attested credential data")
+		}
+		credentialIDLength := int(binary.BigEndian.Uint16(rawLength))
+		if credentialIDLength > maxCredentialIDLength {
+			return ErrInvalidRequest.WithDebugf("credential ID of %d bytes exceeds the maximum of %d bytes", credentialIDLength, maxCredentialIDLength)
+		}
+		if a.AttestedCredentialData.CredentialID, ok = r.next(credentialIDLength); !ok {
+			return ErrMissingAttestedCredentialData.WithDebug("truncated credential ID")
+		}
 
-	a.RPIDHash = authData[0:32]
-	a.Flags = AuthenticatorDataFlags(authData[32])
-	a.SignCount = binary.BigEndian.Uint32(authData[33:37])
-
-	if a.Flags.HasAttestedCredentialData() && len(authData) > 37 {
-		a.AttestedCredentialData.AAGUID = authData[37:53]
-		credentialIDLength := binary.BigEndian.Uint16(authData[53:55])
-
-		a.AttestedCredentialData.CredentialID = authData[55 : 55+credentialIDLength]
+		// The credential public key may be followed by extensions, so determine its length by decoding it
+		var key interface{}
+		rawKey, err := r.nextCBOR(&key)
+		if err != nil {
+			return ErrInvalidRequest.WithDebugf("unable to parse COSE key: %v", err.Error())
+		}
+		a.AttestedCredentialData.CredentialPublicKey = rawKey
 
-		var err error
-		a.AttestedCredentialData.COSEKey, err = cose.ParseCOSE(authData[55+credentialIDLength:])
+		a.AttestedCredentialData.COSEKey, err = cose.ParseCOSE(a.AttestedCredentialData.CredentialPublicKey)
 		if err != nil {
 			return ErrInvalidRequest.WithDebugf("unable to parse COSE key: %v", err.Error())
 		}
 	}
 
+	// The extensions are only present if the ED flag is set, and are a single CBOR map that ends the authenticator data
+	if a.Flags.HasExtensions() {
+		if r.remaining() == 0 {
+			return ErrInvalidRequest.WithDebug("missing extensions")
+		}
+		if _, err := r.nextCBOR(&a.Extensions); err != nil {
+			return ErrInvalidRequest.WithDebugf("unable to parse extensions: %v", err.Error())
+		}
+		if n := r.remaining(); n > 0 {
+			return ErrInvalidRequest.WithDebugf("%d bytes of trailing data after extensions", n)
+		}
+	} else if n := r.remaining(); n > 0 {
+		return ErrInvalidRequest.WithDebugf("%d bytes of trailing data without extensions", n)
+	}
+
 	a.Raw = authData
 
 	return nil
 }
 
+// authDataReader reads authenticator data sequentially, keeping track of the offset of the next unread byte, such that
+// truncated fields and trailing bytes can be detected.
+type authDataReader struct {
+	data   []byte
+	offset int
+}
+
+// next returns the next n bytes and advances the offset past them. If fewer than n bytes remain, false is returned and
+// the offset is not advanced. The capacity of the returned slice is limited to its length, so appending to it does not
+// overwrite the bytes that follow.
+func (r *authDataReader) next(n int) ([]byte, bool) {
+	if n < 0 || n > r.remaining() {
+		return nil, false
+	}
+	b := r.data[r.offset : r.offset+n : r.offset+n]
+	r.offset += n
+	return b, true
+}
+
+// nextCBOR decodes the next CBOR data item into v and advances the offset past it. The raw bytes of the item are
+// returned. If the item is invalid or truncated, the offset is not advanced.
+func (r *authDataReader) nextCBOR(v interface{}) ([]byte, error) {
+	dec := codec.NewDecoderBytes(r.data[r.offset:], &codec.CborHandle{})
+	if err := dec.Decode(v); err != nil {
+		return nil, err
+	}
+	b, _ := r.next(dec.NumBytesRead())
+	return b, nil
+}
+
+// remaining returns the number of bytes that have not been read.
+func (r *authDataReader) remaining() int {
+	return len(r.data) - r.offset
+}
+
 // MarshalBinary implements the encoding.BinaryMarshaler interface.
 func (a *AuthenticatorData) MarshalBinary() ([]byte, error) {
 	return nil, fmt.Errorf("unsupported operation")
@@ -211,6 +338,10 @@ const (
 	AuthenticatorDataFlagUserPresent = 0x001 // 0000 0001
 	// AuthenticatorDataFlagUserVerified indicates the UV flag.
 	AuthenticatorDataFlagUserVerified = 0x004 // 0000 0100
+	// AuthenticatorDataFlagBackupEligible indicates the BE flag.
+	AuthenticatorDataFlagBackupEligible = 0x008 // 0000 1000
+	// AuthenticatorDataFlagBackupState indicates the BS flag.
+	AuthenticatorDataFlagBackupState = 0x010 // 0001 0000
 	// AuthenticatorDataFlagHasCredentialData indicates the AT flag.
 	AuthenticatorDataFlagHasCredentialData = 0x040 // 0100 0000
 	// AuthenticatorDataFlagHasExtension indicates the ED flag.
@@ -227,6 +358,17 @@ func (f AuthenticatorDataFlags) UserVerified() bool {
 	return (f & AuthenticatorDataFlagUserVerified) == AuthenticatorDataFlagUserVerified
 }
 
+// BackupEligible returns whether the BE flag is set, i.e. whether the credential can be backed up, for instance
+// because it is synced across devices.
+func (f AuthenticatorDataFlags) BackupEligible() bool {
+	return (f & AuthenticatorDataFlagBackupEligible) == AuthenticatorDataFlagBackupEligible
+}
+
+// BackupState returns whether the BS flag is set, i.e. whether the credential is currently backed up.
+func (f AuthenticatorDataFlags) BackupState() bool {
+	return (f & AuthenticatorDataFlagBackupState) == AuthenticatorDataFlagBackupState
+}
+
 // HasAttestedCredentialData returns whether the AT flag is set.
 func (f AuthenticatorDataFlags) HasAttestedCredentialData() bool {
 	return (f & AuthenticatorDataFlagHasCredentialData) == AuthenticatorDataFlagHasCredentialData
@@ -237,6 +379,25 @@ func (f AuthenticatorDataFlags) HasExtensions() bool {
 	return (f & AuthenticatorDataFlagHasExtension) == AuthenticatorDataFlagHasExtension
 }
 
+// authenticatorDataFlagNames are the names of the flags in the order of their bits, where RFU1 and RFU2 are reserved
+// for future use.
+var authenticatorDataFlagNames = [8]string{"UP", "RFU1", "UV", "BE", "BS", "RFU2", "AT", "ED"}
+
+// String returns the names of the flags that are set, followed by the raw byte, for instance "UP|UV|AT (0x45)". It is
+// meant for logging, such that it can be seen exactly which flags an authenticator set.
+func (f AuthenticatorDataFlags) String() string {
+	var names []string
+	for i, name := range authenticatorDataFlagNames {
+		if f&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		names = []string{"none"}
+	}
+	return fmt.Sprintf("%s (0x%02x)", strings.Join(names, "|"), byte(f))
+}
+
 // AttestedCredentialData represents the AttestedCredentialData type in the WebAuthn specification.
 // https://www.w3.org/TR/webauthn/#attested-credential-data
 type AttestedCredentialData struct {
@@ -245,6 +406,8 @@ type AttestedCredentialData struct {
 	// A probabilistically-unique byte sequence identifying a public key credential source and its authentication
 	// assertions.
 	CredentialID []byte
+	// The credential public key, encoded in COSE_Key format.
+	CredentialPublicKey []byte
 	// The decoded credential public key.
 	COSEKey interface{}
 }
@@ -0,0 +1,49 @@
+package protocol
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// URLEncodedBase64 represents a byte slice that is encoded in JSON as a base64url string without padding, which is
+// the encoding used by browsers for binary values. When decoding, standard base64 and padding are accepted as well.
+type URLEncodedBase64 []byte
+
+// MarshalJSON implements the json.Marshaler interface.
+func (b URLEncodedBase64) MarshalJSON() ([]byte, error) {
+	if b == nil {
+		return []byte("null"), nil
+	}
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (b *URLEncodedBase64) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*b = nil
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	decoded, err := decodeBase64(s)
+	if err != nil {
+		return fmt.Errorf("invalid base64url data: %v", err)
+	}
+	*b = decoded
+	return nil
+}
+
+// decodeBase64 decodes s, which may be encoded using either base64url or standard base64, with or without padding.
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	if strings.ContainsAny(s, "+/") {
+		return base64.RawStdEncoding.DecodeString(s)
+	}
+	return base64.RawURLEncoding.DecodeString(s)
+}
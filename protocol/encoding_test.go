@@ -0,0 +1,109 @@
+package protocol_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+func TestCredentialCreationOptionsJSON(t *testing.T) {
+	options := protocol.CredentialCreationOptions{
+		PublicKey: protocol.PublicKeyCredentialCreationOptions{
+			RP: protocol.PublicKeyCredentialRpEntity{
+				PublicKeyCredentialEntity: protocol.PublicKeyCredentialEntity{Name: "webauthn-test"},
+				ID:                        "localhost",
+			},
+			User: protocol.PublicKeyCredentialUserEntity{
+				PublicKeyCredentialEntity: protocol.PublicKeyCredentialEntity{Name: "test"},
+				ID:                        []byte{0xfb, 0xff},
+				DisplayName:               "Test",
+			},
+			Challenge: protocol.Challenge{0xfb, 0xef, 0xbe},
+			ExcludeCredentials: []protocol.PublicKeyCredentialDescriptor{
+				{Type: protocol.PublicKeyCredentialTypePublicKey, ID: []byte{0xff}},
+			},
+		},
+	}
+
+	b, err := json.Marshal(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{`"challenge":"----"`, `"id":"-_8"`, `"id":"_w"`} {
+		if !bytes.Contains(b, []byte(expected)) {
+			t.Fatalf("expected %s in %s", expected, b)
+		}
+	}
+
+	var decoded protocol.CredentialCreationOptions
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(decoded.PublicKey.Challenge, options.PublicKey.Challenge) {
+		t.Fatalf("invalid challenge %x", decoded.PublicKey.Challenge)
+	}
+	if !bytes.Equal(decoded.PublicKey.User.ID, options.PublicKey.User.ID) {
+		t.Fatalf("invalid user id %x", decoded.PublicKey.User.ID)
+	}
+	if !bytes.Equal(decoded.PublicKey.ExcludeCredentials[0].ID, options.PublicKey.ExcludeCredentials[0].ID) {
+		t.Fatalf("invalid credential id %x", decoded.PublicKey.ExcludeCredentials[0].ID)
+	}
+}
+
+func TestParseURLEncodedAttestationResponse(t *testing.T) {
+	var urlEncoded protocol.AttestationResponse
+	if err := json.Unmarshal([]byte(urlEncodedAttestationResponse), &urlEncoded); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdEncoded protocol.AttestationResponse
+	if err := json.Unmarshal([]byte(attestationResponses[0]), &stdEncoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(urlEncoded.RawID, stdEncoded.RawID) {
+		t.Fatal("raw id does not match")
+	}
+	if !bytes.Equal(urlEncoded.Response.AttestationObject, stdEncoded.Response.AttestationObject) {
+		t.Fatal("attestation object does not match")
+	}
+
+	p, err := protocol.ParseAttestationResponse(urlEncoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Response.ClientData.Challenge != "-1jQysnwaIjNU-GrwRp4PWNBMlX0i9_caRkcKd7LPj8" {
+		t.Fatalf("invalid challenge %q", p.Response.ClientData.Challenge)
+	}
+}
+
+// The first attestation response of attestationResponses, encoded as a browser would using base64url.
+var urlEncodedAttestationResponse = `{"id":"LOXI3xfiLvIP04MD_S2ZmJYwn3cvMX1FUXxiQO7xlfUvrfcj99UVO2aMrMAwsGvsujY7NHWiM6G3B6ryKJDBBdab-cl4tVZeOwOMhgvHLXk","rawId":"LOXI3xfiLvIP04MD_S2ZmJYwn3cvMX1FUXxiQO7xlfUvrfcj99UVO2aMrMAwsGvsujY7NHWiM6G3B6ryKJDBBdab-cl4tVZeOwOMhgvHLXk","response":{"attestationObject":"o2dhdHRTdG10omNzaWdYRjBEAiAJ8Q7i8DQzKlb00g4Wby4PoEjlI-s3bS-kVKI3PKoyXQIgDzcP2c5vpplZdmftN-zUDNfXtG1TniWbJv2-6kGZ8bljeDVjgVkBKzCCAScwgc6gAwIBAgIBADAKBggqhkjOPQQDAjAWMRQwEgYDVQQDDAtLcnlwdG9uIEtleTAeFw0xODA5MTcxODQ3NDJaFw0yODA5MTcxODQ3NDJaMBYxFDASBgNVBAMMC0tyeXB0b24gS2V5MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEwzIpvM5A6mZQXYxRIhfp0sb_21yTcr_sp5Y5DU0IWODQf5ldS2rlDCl62yEaQDM9Akxbsay_vA_S5ut4VSsvoKMNMAswCQYDVR0TBAIwADAKBggqhkjOPQQDAgNIADBFAiA4Yx-5MtKVnjme6V3qXKQ2qcgaHfO6DMgXM9kwOCZcNAIhAJdNk5PPSA04ITfrX9HQy5azo8sH9yhkW7c6gLdb_Kz-aGF1dGhEYXRhWNRJlg3liA6MaHQ0Fw9kdmBbj-SuuaKGMseZXPO6gx2XY0EAAAAALOXI3xfiLvIP04MD_S2ZmABQLOXI3xfiLvIP04MD_S2ZmJYwn3cvMX1FUXxiQO7xlfUvrfcj99UVO2aMrMAwsGvsujY7NHWiM6G3B6ryKJDBBdab-cl4tVZeOwOMhgvHLXmlAQIDJiABIVggwzIpvM5A6mZQXYxRIhfp0sb_21yTcr_sp5Y5DU0IWOAiWCDQf5ldS2rlDCl62yEaQDM9Akxbsay_vA_S5ut4VSsvoGNmbXRoZmlkby11MmY","clientDataJSON":"eyJjaGFsbGVuZ2UiOiItMWpReXNud2FJak5VLUdyd1JwNFBXTkJNbFgwaTlfY2FSa2NLZDdMUGo4IiwiY2xpZW50RXh0ZW5zaW9ucyI6e30sImhhc2hBbGdvcml0aG0iOiJTSEEtMjU2Iiwib3JpZ2luIjoiaHR0cDovL2xvY2FsaG9zdDo1Mzg3OSIsInRva2VuQmluZGluZyI6eyJzdGF0dXMiOiJub3Qtc3VwcG9ydGVkIn0sInR5cGUiOiJ3ZWJhdXRobi5jcmVhdGUifQ"},"type":"public-key"}`
+
+func TestAuthenticatorTransportJSON(t *testing.T) {
+	var transports []protocol.AuthenticatorTransport
+	if err := json.Unmarshal([]byte(`["usb","cable","hybrid","internal","unknown"]`), &transports); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []protocol.AuthenticatorTransport{
+		protocol.AuthenticatorTransportUSB,
+		protocol.AuthenticatorTransportHybrid,
+		protocol.AuthenticatorTransportHybrid,
+		protocol.AuthenticatorTransportInternal,
+		"unknown",
+	}
+	if len(transports) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, transports)
+	}
+	for i := range expected {
+		if transports[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, transports)
+		}
+	}
+}
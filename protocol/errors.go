@@ -54,6 +54,93 @@ var (
 		Description: "No user was presented during authentication",
 		Code:        http.StatusBadRequest,
 	}
+	ErrUserNotVerified = &Error{
+		Name:        "user_not_verified",
+		Description: "The user was not verified, while user verification is required",
+		Code:        http.StatusBadRequest,
+	}
+)
+
+// ErrInvalidClientDataType is returned if the type of the client data does not match the ceremony. It wraps
+// ErrInvalidType.
+var ErrInvalidClientDataType = ErrInvalidType.wrap("invalid_client_data_type", "The client data type does not match the ceremony")
+
+// ErrRPIDHashMismatch is returned if the RP ID hash in the authenticator data does not match the RP ID, meaning that
+// the credential is scoped to another Relying Party. It wraps ErrInvalidOrigin.
+var ErrRPIDHashMismatch = ErrInvalidOrigin.wrap("rp_id_hash_mismatch", "The RP ID hash does not match the RP ID")
+
+// ErrMissingAttestedCredentialData is returned if the attested credential data is missing from the authenticator data
+// of an attestation, or if the AT flag is set but the attested credential data is truncated. It wraps
+// ErrInvalidRequest.
+var ErrMissingAttestedCredentialData = ErrInvalidRequest.wrap("missing_attested_credential_data", "The attested credential data is missing")
+
+// ErrAttestationTooLarge is returned if the attestation object exceeds the AttestationObjectLimits. It wraps
+// ErrInvalidRequest.
+var ErrAttestationTooLarge = ErrInvalidRequest.wrap("attestation_too_large", "The attestation object exceeds the size limits")
+
+// ErrTokenBindingMismatch is returned if the token binding of the client data does not match the Token Binding ID of
+// the connection. It wraps ErrInvalidRequest.
+var ErrTokenBindingMismatch = ErrInvalidRequest.wrap("token_binding_mismatch", "The token binding does not match the connection")
+
+// ErrPaymentMismatch is returned if the payment data of a Secure Payment Confirmation assertion does not match the
+// payment that the Relying Party expects. It wraps ErrInvalidRequest.
+var ErrPaymentMismatch = ErrInvalidRequest.wrap("payment_mismatch", "The confirmed payment does not match the payment request")
+
+// Attestation errors, which all wrap ErrInvalidAttestation. Use errors.Is to determine the cause of an error.
+var (
+	ErrSignatureInvalid       = ErrInvalidAttestation.wrap("signature_invalid", "The attestation signature is invalid").WithStep(AttestationStepSignature)
+	ErrCertChainInvalid       = ErrInvalidAttestation.wrap("cert_chain_invalid", "The attestation certificate chain is invalid").WithStep(AttestationStepCertificateChain)
+	ErrAAGUIDMismatch         = ErrInvalidAttestation.wrap("aaguid_mismatch", "The attestation certificate AAGUID does not match the authenticator data").WithStep(AttestationStepAAGUID)
+	ErrUnsupportedAlgorithm   = ErrInvalidAttestation.wrap("unsupported_algorithm", "The attestation algorithm is unsupported").WithStep(AttestationStepAlgorithm)
+	ErrAlgMismatch            = ErrInvalidAttestation.wrap("alg_mismatch", "The attestation algorithm does not match the credential public key").WithStep(AttestationStepAlgorithm)
+	ErrUnexpectedAttStmtField = ErrInvalidAttestation.wrap("unexpected_att_stmt_field", "The attestation statement contains an unexpected field").WithStep(AttestationStepStatement)
+	ErrCertExpired            = ErrInvalidAttestation.wrap("cert_expired", "The attestation certificate is not valid at the time of the registration").WithStep(AttestationStepCertificateValidity)
+)
+
+// AttestationStep identifies the step of the verification of an attestation statement that failed, which is set on
+// errors of attestation formats such that failures can be aggregated by their cause.
+type AttestationStep string
+
+const (
+	// AttestationStepStatement indicates that the attestation statement is malformed, for instance because alg, sig
+	// or x5c is missing or of the wrong type.
+	AttestationStepStatement AttestationStep = "statement"
+	// AttestationStepAlgorithm indicates that the algorithm is unsupported or does not match the credential public key.
+	AttestationStepAlgorithm AttestationStep = "algorithm"
+	// AttestationStepCredentialPublicKey indicates that the credential public key is invalid for the attestation format.
+	AttestationStepCredentialPublicKey AttestationStep = "credential_public_key"
+	// AttestationStepSignature indicates that the attestation signature is invalid.
+	AttestationStepSignature AttestationStep = "signature"
+	// AttestationStepCertificate indicates that the attestation certificate can not be parsed or has an invalid public
+	// key.
+	AttestationStepCertificate AttestationStep = "certificate"
+	// AttestationStepCertificateVersion indicates that the version of the attestation certificate is not 3.
+	AttestationStepCertificateVersion AttestationStep = "certificate_version"
+	// AttestationStepCertificateValidity indicates that the attestation certificate is expired or not yet valid.
+	AttestationStepCertificateValidity AttestationStep = "certificate_validity"
+	// AttestationStepCertificateCA indicates that the CA component of the Basic Constraints extension of the
+	// attestation certificate is set.
+	AttestationStepCertificateCA AttestationStep = "certificate_ca"
+	// AttestationStepCertificateSubject indicates that the subject of the attestation certificate does not meet the
+	// requirements of the attestation format.
+	AttestationStepCertificateSubject AttestationStep = "certificate_subject"
+	// AttestationStepAAGUID indicates that the AAGUID extension of the attestation certificate is invalid or does not
+	// match the AAGUID of the authenticator data.
+	AttestationStepAAGUID AttestationStep = "aaguid"
+	// AttestationStepNonce indicates that the nonce of the attestation, such as the nonce extension of the attestation
+	// certificate, the attestation challenge of android-key or the extraData of tpm, is invalid or does not match the
+	// authenticator data and client data.
+	AttestationStepNonce AttestationStep = "nonce"
+	// AttestationStepKeyDescription indicates that the key description extension of an android-key attestation
+	// certificate is invalid or does not meet the requirements of the format.
+	AttestationStepKeyDescription AttestationStep = "key_description"
+	// AttestationStepTimestamp indicates that the timestamp of an android-safetynet response is not recent.
+	AttestationStepTimestamp AttestationStep = "timestamp"
+	// AttestationStepDeviceIntegrity indicates that an android-safetynet response does not attest the integrity of the
+	// device.
+	AttestationStepDeviceIntegrity AttestationStep = "device_integrity"
+	// AttestationStepCertificateChain indicates that the attestation certificate chain is invalid.
+	AttestationStepCertificateChain AttestationStep = "certificate_chain"
 )
 
 // Error is a representation of errors returned from this package.
@@ -70,6 +157,11 @@ type Error struct {
 	Debug string `json:"debug,omitempty"`
 	// Cause contains the error that caused this error, if available
 	Cause error `json:"-"`
+	// Step contains the step of the attestation verification that failed, if available.
+	Step AttestationStep `json:"step,omitempty"`
+
+	// base is the error that is wrapped by this error, if any.
+	base *Error
 }
 
 // ToWebAuthnError converts any error into the *Error type. If that is not possible, it will return an *Error
@@ -88,6 +180,15 @@ func ToWebAuthnError(err error) *Error {
 	}
 }
 
+// wrap returns a new error with the given name and description that wraps e.
+func (e *Error) wrap(name, description string) *Error {
+	err := *e
+	err.Name = name
+	err.Description = description
+	err.base = e
+	return &err
+}
+
 // Error implements the error interface.
 func (e *Error) Error() string {
 	return e.Name
@@ -117,8 +218,36 @@ func (e *Error) WithDebug(debug string) *Error {
 	return &err
 }
 
+// WithCause will add/replace the cause of the error.
 func (e *Error) WithCause(cause error) *Error {
 	err := *e
 	err.Cause = cause
 	return &err
 }
+
+// WithStep will add/replace the attestation verification step of the error.
+func (e *Error) WithStep(step AttestationStep) *Error {
+	err := *e
+	err.Step = step
+	return &err
+}
+
+// Is reports whether target is an *Error with the same name as e or one of the errors wrapped by e, which allows
+// comparing errors returned by WithDebug and similar methods with errors.Is.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	for err := e; err != nil; err = err.base {
+		if err.Name == t.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap returns the cause of the error, if any.
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
@@ -0,0 +1,37 @@
+//go:build go1.18
+// +build go1.18
+
+package protocol_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+// FuzzParseAttestationObject parses and verifies random attestation objects, which must return an error of the type
+// protocol.Error instead of panicking. The seed corpus consists of the packed, fido-u2f and android-safetynet
+// attestation objects of attestationResponses.
+func FuzzParseAttestationObject(f *testing.F) {
+	for _, response := range attestationResponses {
+		b := protocol.AttestationResponse{}
+		if err := json.Unmarshal([]byte(response), &b); err != nil {
+			f.Fatal(err)
+		}
+		f.Add([]byte(b.Response.AttestationObject))
+	}
+
+	clientDataHash := make([]byte, 32)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		a, err := protocol.ParseAttestationObject(data)
+		if err != nil {
+			if _, ok := err.(*protocol.Error); !ok {
+				t.Fatalf("expected error of type *protocol.Error, got %T", err)
+			}
+			return
+		}
+
+		_ = a.IsValid("", clientDataHash)
+	})
+}
@@ -0,0 +1,77 @@
+package protocol
+
+// ClientDataTypePaymentGet is the type of the client data of a Secure Payment Confirmation assertion, which is an
+// assertion that is created by the payment handler of the browser to confirm a payment.
+// https://www.w3.org/TR/secure-payment-confirmation/#sctn-verifying-assertion
+const ClientDataTypePaymentGet = "payment.get"
+
+// CollectedClientPaymentData contains the additional client data of a Secure Payment Confirmation assertion, which
+// describes the payment that the user confirmed.
+// https://www.w3.org/TR/secure-payment-confirmation/#sctn-collectedclientpaymentdata-dictionary
+type CollectedClientPaymentData struct {
+	// RPID is the RP ID of the credential that was used to confirm the payment.
+	RPID string `json:"rpId"`
+	// TopOrigin is the origin of the top-level frame of the page on which the payment was requested.
+	TopOrigin string `json:"topOrigin"`
+	// PayeeName is the name of the payee that was shown to the user, if any.
+	PayeeName string `json:"payeeName,omitempty"`
+	// PayeeOrigin is the origin of the payee that was shown to the user, if any.
+	PayeeOrigin string `json:"payeeOrigin,omitempty"`
+	// Total is the amount of the payment that was shown to the user.
+	Total PaymentCurrencyAmount `json:"total"`
+	// Instrument is the payment instrument that was shown to the user.
+	Instrument PaymentCredentialInstrument `json:"instrument"`
+}
+
+// PaymentCurrencyAmount is a monetary amount, for instance {"currency": "EUR", "value": "10.00"}.
+// https://www.w3.org/TR/payment-request/#dom-paymentcurrencyamount
+type PaymentCurrencyAmount struct {
+	// Currency is the ISO 4217 currency code of the amount.
+	Currency string `json:"currency"`
+	// Value is the decimal amount, which is compared as a string.
+	Value string `json:"value"`
+}
+
+// PaymentCredentialInstrument describes the payment instrument, such as a card, that was shown to the user.
+// https://www.w3.org/TR/secure-payment-confirmation/#dictdef-paymentcredentialinstrument
+type PaymentCredentialInstrument struct {
+	// DisplayName is the name of the instrument.
+	DisplayName string `json:"displayName"`
+	// Icon is the URL of the icon of the instrument.
+	Icon string `json:"icon"`
+}
+
+// VerifyPayment verifies that the payment data of a Secure Payment Confirmation assertion is the payment that the
+// Relying Party expects the user to have confirmed. The payee name and origin are only compared if they are set in
+// expected, since either of them may be shown to the user. If the payment data does not match, ErrPaymentMismatch is
+// returned.
+func (c CollectedClientData) VerifyPayment(expected CollectedClientPaymentData) error {
+	p := c.Payment
+	if p == nil {
+		return ErrPaymentMismatch.WithDebug("missing payment data")
+	}
+
+	if p.RPID != expected.RPID {
+		return ErrPaymentMismatch.WithDebugf("RP ID %q did not match required %q", p.RPID, expected.RPID)
+	}
+	if p.TopOrigin != expected.TopOrigin {
+		return ErrPaymentMismatch.WithDebugf("top origin %q did not match required %q", p.TopOrigin, expected.TopOrigin)
+	}
+	if expected.PayeeName != "" && p.PayeeName != expected.PayeeName {
+		return ErrPaymentMismatch.WithDebugf("payee name %q did not match required %q", p.PayeeName, expected.PayeeName)
+	}
+	if expected.PayeeOrigin != "" && p.PayeeOrigin != expected.PayeeOrigin {
+		return ErrPaymentMismatch.WithDebugf("payee origin %q did not match required %q", p.PayeeOrigin, expected.PayeeOrigin)
+	}
+	if p.PayeeName == "" && p.PayeeOrigin == "" {
+		return ErrPaymentMismatch.WithDebug("missing payee name and origin")
+	}
+	if p.Total != expected.Total {
+		return ErrPaymentMismatch.WithDebugf("total %s %s did not match required %s %s", p.Total.Value, p.Total.Currency, expected.Total.Value, expected.Total.Currency)
+	}
+	if p.Instrument != expected.Instrument {
+		return ErrPaymentMismatch.WithDebugf("instrument %q did not match required %q", p.Instrument.DisplayName, expected.Instrument.DisplayName)
+	}
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package protocol
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+)
+
+// PublicKeyEqual reports whether the public keys a and b, such as the public key of an attestation certificate and the
+// credential public key, are of the same type and are equal. Only ECDSA, RSA and Ed25519 keys are supported, so keys
+// of other types are never equal.
+func PublicKeyEqual(a, b interface{}) bool {
+	switch a := a.(type) {
+	case *ecdsa.PublicKey:
+		b, ok := b.(*ecdsa.PublicKey)
+		return ok && a.Curve == b.Curve && a.X.Cmp(b.X) == 0 && a.Y.Cmp(b.Y) == 0
+	case *rsa.PublicKey:
+		b, ok := b.(*rsa.PublicKey)
+		return ok && a.E == b.E && a.N.Cmp(b.N) == 0
+	case ed25519.PublicKey:
+		b, ok := b.(ed25519.PublicKey)
+		return ok && bytes.Equal(a, b)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,53 @@
+package protocol_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"math/big"
+	"testing"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+func TestPublicKeyEqual(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherECDSAKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed25519Key, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name  string
+		a, b  interface{}
+		equal bool
+	}{
+		{"ECDSA", &ecdsaKey.PublicKey, &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).Set(ecdsaKey.X), Y: new(big.Int).Set(ecdsaKey.Y)}, true},
+		{"Different ECDSA", &ecdsaKey.PublicKey, &otherECDSAKey.PublicKey, false},
+		{"Different curve", &ecdsaKey.PublicKey, &ecdsa.PublicKey{Curve: elliptic.P384(), X: ecdsaKey.X, Y: ecdsaKey.Y}, false},
+		{"RSA", &rsaKey.PublicKey, &rsa.PublicKey{N: new(big.Int).Set(rsaKey.N), E: rsaKey.E}, true},
+		{"Different exponent", &rsaKey.PublicKey, &rsa.PublicKey{N: rsaKey.N, E: 3}, false},
+		{"Ed25519", ed25519Key, append(ed25519.PublicKey{}, ed25519Key...), true},
+		{"Different types", &ecdsaKey.PublicKey, &rsaKey.PublicKey, false},
+		{"Unsupported type", []byte("key"), []byte("key"), false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if equal := protocol.PublicKeyEqual(test.a, test.b); equal != test.equal {
+				t.Fatalf("expected %v, got %v", test.equal, equal)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// DeriveRPID returns the RP ID that clients use by default for an origin, which is the effective domain of the
+// origin, for instance "login.example.com" for "https://login.example.com:8443". An error is returned if the origin is
+// not a secure context, i.e. it is not https or http://localhost, or if its host is an IP address, which can not be
+// used as RP ID.
+func DeriveRPID(origin string) (string, error) {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return "", fmt.Errorf("invalid origin %q: %v", origin, err)
+	}
+	if u.Host == "" || u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid origin %q", origin)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if u.Scheme != "https" && !(u.Scheme == "http" && host == "localhost") {
+		return "", fmt.Errorf("origin %q is not a secure context", origin)
+	}
+	if net.ParseIP(host) != nil {
+		return "", fmt.Errorf("origin %q has an IP address instead of a domain", origin)
+	}
+
+	return host, nil
+}
+
+// ValidateRPID checks whether rpID may be used as RP ID for origin, which is the case if it is the effective domain of
+// the origin or a registrable domain suffix thereof, for instance "example.com" for "https://login.example.com". Use
+// it to detect a misconfigured RP ID when the Relying Party is set up, since clients reject ceremonies with an RP ID
+// that is not valid for the origin. Whether the RP ID is a public suffix, such as "co.uk", is not checked.
+func ValidateRPID(rpID, origin string) error {
+	host, err := DeriveRPID(origin)
+	if err != nil {
+		return err
+	}
+
+	if rpID == "" || rpID != strings.ToLower(rpID) || strings.ContainsAny(rpID, ":/") || strings.HasPrefix(rpID, ".") || strings.HasSuffix(rpID, ".") {
+		return fmt.Errorf("invalid RP ID %q, which must be a lowercase domain", rpID)
+	}
+	if host != rpID && !strings.HasSuffix(host, "."+rpID) {
+		return fmt.Errorf("RP ID %q is not a registrable domain suffix of origin %q", rpID, origin)
+	}
+
+	return nil
+}
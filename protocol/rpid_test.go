@@ -0,0 +1,75 @@
+package protocol_test
+
+import (
+	"testing"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+func TestDeriveRPID(t *testing.T) {
+	for _, test := range []struct {
+		origin string
+		rpID   string
+	}{
+		{"https://login.example.com", "login.example.com"},
+		{"https://Login.Example.com:8443", "login.example.com"},
+		{"https://example.com/", "example.com"},
+		{"http://localhost:9000", "localhost"},
+		{"https://localhost", "localhost"},
+	} {
+		rpID, err := protocol.DeriveRPID(test.origin)
+		if err != nil {
+			t.Fatalf("%s: %v", test.origin, err)
+		}
+		if rpID != test.rpID {
+			t.Fatalf("expected RP ID %q for %s, got %q", test.rpID, test.origin, rpID)
+		}
+	}
+
+	for _, origin := range []string{
+		"",
+		"example.com",
+		"http://example.com",
+		"ftp://example.com",
+		"https://127.0.0.1",
+		"https://[::1]:8443",
+		"https://user@example.com",
+		"https://example.com/path",
+		"https://example.com?query",
+		"https://example.com#fragment",
+	} {
+		if _, err := protocol.DeriveRPID(origin); err == nil {
+			t.Fatalf("expected error for %q", origin)
+		}
+	}
+}
+
+func TestValidateRPID(t *testing.T) {
+	for _, test := range []struct {
+		rpID   string
+		origin string
+		valid  bool
+	}{
+		{"login.example.com", "https://login.example.com", true},
+		{"example.com", "https://login.example.com", true},
+		{"example.com", "https://a.b.example.com:8443", true},
+		{"localhost", "http://localhost:9000", true},
+		{"example.com", "https://evil.com", false},
+		{"example.com", "https://notexample.com", false},
+		{"login.example.com", "https://example.com", false},
+		{"Example.com", "https://example.com", false},
+		{"example.com.", "https://example.com", false},
+		{".example.com", "https://login.example.com", false},
+		{"https://example.com", "https://example.com", false},
+		{"example.com:443", "https://example.com", false},
+		{"", "https://example.com", false},
+		{"example.com", "http://example.com", false},
+	} {
+		err := protocol.ValidateRPID(test.rpID, test.origin)
+		if test.valid && err != nil {
+			t.Fatalf("%q for %s: %v", test.rpID, test.origin, err)
+		} else if !test.valid && err == nil {
+			t.Fatalf("expected error for %q and %s", test.rpID, test.origin)
+		}
+	}
+}
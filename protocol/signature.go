@@ -0,0 +1,25 @@
+package protocol
+
+import (
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+)
+
+// ParseECDSASignature parses an ECDSA signature, which is the DER encoding of the ASN.1 Ecdsa-Sig-Value sequence of r
+// and s, as used by assertions and by attestations with the ES256, ES384, ES512 and ES256K algorithms. The signature is
+// rejected if it contains trailing data or if r or s is not positive.
+func ParseECDSASignature(sig []byte) (r, s *big.Int, err error) {
+	var signature struct {
+		R, S *big.Int
+	}
+	if rest, err := asn1.Unmarshal(sig, &signature); err != nil {
+		return nil, nil, fmt.Errorf("invalid ECDSA signature: %v", err)
+	} else if len(rest) != 0 {
+		return nil, nil, fmt.Errorf("invalid ECDSA signature: trailing data")
+	}
+	if signature.R.Sign() <= 0 || signature.S.Sign() <= 0 {
+		return nil, nil, fmt.Errorf("invalid ECDSA signature: r and s must be positive")
+	}
+	return signature.R, signature.S, nil
+}
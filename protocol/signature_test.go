@@ -0,0 +1,62 @@
+package protocol_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+func TestParseECDSASignature(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256([]byte("message"))
+	r, s, err := ecdsa.Sign(rand.Reader, key, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	marshal := func(r, s *big.Int) []byte {
+		sig, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+	valid := marshal(r, s)
+
+	parsedR, parsedS, err := protocol.ParseECDSASignature(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsedR.Cmp(r) != 0 || parsedS.Cmp(s) != 0 {
+		t.Fatal("expected r and s of the signature")
+	}
+
+	for _, test := range []struct {
+		name string
+		sig  []byte
+	}{
+		{"Empty", nil},
+		{"Trailing data", append(append([]byte{}, valid...), 0x00)},
+		{"Truncated", valid[:len(valid)-1]},
+		{"Not a sequence", marshal(r, s)[2:]},
+		{"Single integer", func() []byte { b, _ := asn1.Marshal([]*big.Int{r}); return b }()},
+		{"Negative r", marshal(new(big.Int).Neg(r), s)},
+		{"Zero s", marshal(r, new(big.Int))},
+		{"Non-minimal integer", []byte{0x30, 0x07, 0x02, 0x02, 0x00, 0x01, 0x02, 0x01, 0x01}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, err := protocol.ParseECDSASignature(test.sig); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -1,12 +1,24 @@
 package protocol_test
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/keycloud/webauthn/protocol"
+	"github.com/keycloud/webauthn/testutil"
 )
 
 func TestIsValidAssertion(t *testing.T) {
@@ -55,6 +67,685 @@ func TestIsValidAssertion(t *testing.T) {
 	}
 }
 
+func TestAuthenticatorDataFlagsString(t *testing.T) {
+	for _, test := range []struct {
+		flags    protocol.AuthenticatorDataFlags
+		expected string
+	}{
+		{0x00, "none (0x00)"},
+		{0x01, "UP (0x01)"},
+		{0x45, "UP|UV|AT (0x45)"},
+		{0x1d, "UP|UV|BE|BS (0x1d)"},
+		{0xc1, "UP|AT|ED (0xc1)"},
+		{0x22, "RFU1|RFU2 (0x22)"},
+		{0xff, "UP|RFU1|UV|BE|BS|RFU2|AT|ED (0xff)"},
+	} {
+		if s := test.flags.String(); s != test.expected {
+			t.Errorf("expected %q for 0x%02x, got %q", test.expected, byte(test.flags), s)
+		}
+	}
+}
+
+func TestAuthenticatorDataBackupFlags(t *testing.T) {
+	for _, test := range []struct {
+		name               string
+		flags              byte
+		eligible, backedUp bool
+		valid              bool
+	}{
+		{"Not eligible", 0x01, false, false, true},
+		{"Eligible", 0x09, true, false, true},
+		{"Backed up", 0x19, true, true, true},
+		{"Backed up without eligibility", 0x11, false, true, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			raw := make([]byte, 37)
+			raw[32] = test.flags
+
+			var authData protocol.AuthenticatorData
+			if err := authData.UnmarshalBinary(raw); err != nil {
+				t.Fatal(err)
+			}
+
+			if authData.Flags.BackupEligible() != test.eligible {
+				t.Fatalf("expected backup eligible to be %v", test.eligible)
+			}
+			if authData.Flags.BackupState() != test.backedUp {
+				t.Fatalf("expected backup state to be %v", test.backedUp)
+			}
+
+			err := authData.IsValid("")
+			if test.valid && err != nil {
+				t.Fatal(err)
+			} else if !test.valid && err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestAuthenticatorDataLayout(t *testing.T) {
+	authenticator, err := testutil.NewVirtualAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := authenticator.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := func(flags byte) []byte {
+		raw := make([]byte, 37)
+		raw[32] = protocol.AuthenticatorDataFlagUserPresent | flags
+		return raw
+	}
+	attested := func(flags byte, credentialIDLength int, rest ...[]byte) []byte {
+		raw := append(header(protocol.AuthenticatorDataFlagHasCredentialData|flags), make([]byte, 16)...)
+		raw = append(raw, byte(credentialIDLength>>8), byte(credentialIDLength))
+		raw = append(raw, bytes.Repeat([]byte{0x01}, credentialIDLength)...)
+		for _, b := range rest {
+			raw = append(raw, b...)
+		}
+		return raw
+	}
+	extensions := []byte("\xa1\x6bhmac-secret\xf5")
+
+	for _, test := range []struct {
+		name  string
+		raw   []byte
+		valid bool
+	}{
+		{"Header", header(0), true},
+		{"Truncated header", header(0)[:36], false},
+		{"Trailing bytes after header", append(header(0), 0x00), false},
+		{"Attested credential data", attested(0, 16, key), true},
+		{"Attested credential data with extensions", attested(protocol.AuthenticatorDataFlagHasExtension, 16, key, extensions), true},
+		{"Truncated AAGUID", append(header(protocol.AuthenticatorDataFlagHasCredentialData), make([]byte, 8)...), false},
+		{"Truncated credential ID length", attested(0, 0)[:37+17], false},
+		{"Truncated credential ID", attested(0, 16)[:37+18+8], false},
+		{"Missing credential public key", attested(0, 16), false},
+		{"Truncated credential public key", attested(0, 16, key[:len(key)-1]), false},
+		{"Trailing bytes after credential public key", attested(0, 16, key, []byte{0x00}), false},
+		{"Extensions without ED flag", attested(0, 16, key, extensions), false},
+		{"ED flag without extensions", attested(protocol.AuthenticatorDataFlagHasExtension, 16, key), false},
+		{"Trailing bytes after extensions", append(header(protocol.AuthenticatorDataFlagHasExtension), append(extensions, 0x00)...), false},
+		{"Truncated extensions", append(header(protocol.AuthenticatorDataFlagHasExtension), extensions[:len(extensions)-1]...), false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var authData protocol.AuthenticatorData
+			err := authData.UnmarshalBinary(test.raw)
+			if !test.valid {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if authData.Flags.HasAttestedCredentialData() {
+				if !bytes.Equal(authData.AttestedCredentialData.CredentialPublicKey, key) {
+					t.Fatalf("expected credential public key %x, got %x", key, authData.AttestedCredentialData.CredentialPublicKey)
+				}
+				if credentialID := authData.AttestedCredentialData.CredentialID; len(credentialID) != 16 || cap(credentialID) != 16 {
+					t.Fatalf("expected credential ID of 16 bytes with capacity 16, got %d bytes with capacity %d", len(credentialID), cap(credentialID))
+				}
+			}
+			if authData.Flags.HasExtensions() && authData.Extensions["hmac-secret"] != true {
+				t.Fatalf("expected hmac-secret extension output, got %v", authData.Extensions)
+			}
+		})
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	err := protocol.ErrSignatureInvalid.WithDebug("invalid signature").WithCause(fmt.Errorf("cause"))
+
+	if !errors.Is(err, protocol.ErrSignatureInvalid) {
+		t.Fatal("expected error to be ErrSignatureInvalid")
+	}
+	if !errors.Is(err, protocol.ErrInvalidAttestation) {
+		t.Fatal("expected error to wrap ErrInvalidAttestation")
+	}
+	if errors.Is(err, protocol.ErrCertChainInvalid) {
+		t.Fatal("expected error not to be ErrCertChainInvalid")
+	}
+	if errors.Is(protocol.ErrInvalidAttestation, protocol.ErrSignatureInvalid) {
+		t.Fatal("expected ErrInvalidAttestation not to be ErrSignatureInvalid")
+	}
+}
+
+func TestErrorStep(t *testing.T) {
+	err := protocol.ErrSignatureInvalid.WithDebug("invalid signature")
+	if err.Step != protocol.AttestationStepSignature {
+		t.Fatalf("expected step %q, got %q", protocol.AttestationStepSignature, err.Step)
+	}
+
+	err = protocol.ErrInvalidAttestation.WithDebug("missing x5c").WithStep(protocol.AttestationStepStatement)
+	if !errors.Is(err, protocol.ErrInvalidAttestation) {
+		t.Fatal("expected error to be ErrInvalidAttestation")
+	}
+	if protocol.ErrInvalidAttestation.Step != "" {
+		t.Fatal("expected ErrInvalidAttestation not to be modified")
+	}
+
+	b, e := json.Marshal(err)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !strings.Contains(string(b), `"step":"statement"`) {
+		t.Fatalf("expected step in %s", b)
+	}
+
+	// The step is set by all attestation formats
+	rawAttestation := protocol.AttestationResponse{}
+	if err := json.Unmarshal([]byte(attestationResponses[0]), &rawAttestation); err != nil {
+		t.Fatal(err)
+	}
+	p, e := protocol.ParseAttestationResponse(rawAttestation)
+	if e != nil {
+		t.Fatal(e)
+	}
+	clientDataHash := sha256.Sum256(rawAttestation.Response.ClientDataJSON)
+	for _, test := range []struct {
+		format  string
+		attStmt map[string]interface{}
+	}{
+		{"tpm", map[string]interface{}{"ver": "2.0"}},
+		{"android-key", map[string]interface{}{"alg": int64(protocol.ES256)}},
+		{"android-safetynet", map[string]interface{}{"ver": "14366019", "response": []byte("response")}},
+	} {
+		t.Run(test.format, func(t *testing.T) {
+			a := p.Response.Attestation
+			a.Fmt, a.AttStmt = test.format, test.attStmt
+
+			err := a.IsValid("", clientDataHash[:])
+			if step := protocol.ToWebAuthnError(err).Step; step != protocol.AttestationStepStatement {
+				t.Fatalf("expected step %q, got %q for %v", protocol.AttestationStepStatement, step, err)
+			}
+		})
+	}
+}
+
+func TestLegacyFormat(t *testing.T) {
+	verified := false
+	protocol.RegisterFormat("legacy-test", protocol.LegacyFormat(func(protocol.Attestation, []byte) error {
+		verified = true
+		return nil
+	}))
+
+	der := newTestCertificate(t, 1)
+
+	for _, test := range []struct {
+		name            string
+		attStmt         map[string]interface{}
+		attestationType protocol.AttestationType
+		trustPath       int
+	}{
+		{"Basic", map[string]interface{}{"x5c": []interface{}{der}}, protocol.AttestationTypeBasic, 1},
+		{"Self", map[string]interface{}{}, protocol.AttestationTypeSelf, 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			verified = false
+			a := protocol.Attestation{
+				Fmt:      "legacy-test",
+				AuthData: protocol.AuthenticatorData{Flags: protocol.AuthenticatorDataFlagUserPresent},
+				AttStmt:  test.attStmt,
+			}
+
+			attestationType, trustPath, err := a.Verify("", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !verified {
+				t.Fatal("legacy format function was not called")
+			}
+			if attestationType != test.attestationType {
+				t.Fatalf("expected attestation type %q, got %q", test.attestationType, attestationType)
+			}
+			if len(trustPath) != test.trustPath {
+				t.Fatalf("expected trust path of length %d, got %d", test.trustPath, len(trustPath))
+			}
+		})
+	}
+}
+
+func TestVerifyStatementFields(t *testing.T) {
+	protocol.RegisterFormatFields("fields-test", "alg", "sig")
+	protocol.RegisterFormatFields("empty-test")
+
+	for _, test := range []struct {
+		name    string
+		fmt     string
+		attStmt map[string]interface{}
+		err     error
+	}{
+		{"Expected", "fields-test", map[string]interface{}{"alg": int64(-7), "sig": []byte{}}, nil},
+		{"Optional", "fields-test", map[string]interface{}{"sig": []byte{}}, nil},
+		{"Unexpected", "fields-test", map[string]interface{}{"alg": int64(-7), "sig": []byte{}, "vendor": true}, protocol.ErrUnexpectedAttStmtField},
+		{"Empty", "empty-test", map[string]interface{}{}, nil},
+		{"Not empty", "empty-test", map[string]interface{}{"sig": []byte{}}, protocol.ErrUnexpectedAttStmtField},
+		{"Unregistered", "unregistered-test", map[string]interface{}{"vendor": true}, nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			a := protocol.Attestation{Fmt: test.fmt, AttStmt: test.attStmt}
+			if err := a.VerifyStatementFields(); !errors.Is(err, test.err) || (err == nil) != (test.err == nil) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestRegisterFormatConcurrent(t *testing.T) {
+	format := func(protocol.Attestation, []byte) (protocol.AttestationType, []*x509.Certificate, error) {
+		return protocol.AttestationTypeNone, nil, nil
+	}
+	a := protocol.Attestation{
+		Fmt:      "concurrent-test",
+		AuthData: protocol.AuthenticatorData{Flags: protocol.AuthenticatorDataFlagUserPresent},
+	}
+
+	// Run with -race to detect unsynchronized access to the registry
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			protocol.RegisterFormat(fmt.Sprintf("concurrent-test-%d", i), format)
+			protocol.RegisterFormat("concurrent-test", format)
+		}(i)
+		go func() {
+			defer wg.Done()
+			// The format may not be registered yet
+			_, _, _ = a.Verify("", nil)
+		}()
+	}
+	wg.Wait()
+
+	if _, _, err := a.Verify("", nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseCredentialCreationResponse(t *testing.T) {
+	p, err := protocol.ParseCredentialCreationResponse(strings.NewReader(urlEncodedAttestationResponse))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Response.Attestation.Fmt != "fido-u2f" {
+		t.Fatalf("invalid fmt %q", p.Response.Attestation.Fmt)
+	}
+	if len(p.Response.Attestation.AuthData.AttestedCredentialData.CredentialID) == 0 {
+		t.Fatal("missing credential ID")
+	}
+
+	// An attestation object with a none attestation statement and authenticator data with the AT flag set, which is
+	// truncated after the AAGUID.
+	truncatedAuthData := append([]byte("\xa3\x63fmt\x64none\x67attStmt\xa0\x68authData\x58\x3c"), make([]byte, 60)...)
+	truncatedAuthData[len(truncatedAuthData)-60+32] = 0x41
+
+	for _, test := range []struct {
+		name              string
+		rawID             string
+		attestationObject []byte
+	}{
+		{"Malformed base64", "not base64!", nil},
+		{"Truncated CBOR", "", []byte("\xa3\x63fmt\x64none")},
+		{"Truncated auth data", "", truncatedAuthData},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var resp protocol.AttestationResponse
+			if err := json.Unmarshal([]byte(urlEncodedAttestationResponse), &resp); err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := json.Marshal(resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.rawID != "" {
+				b = bytes.Replace(b, []byte(`"rawId":"`+base64.RawURLEncoding.EncodeToString(resp.RawID)), []byte(`"rawId":"`+test.rawID), 1)
+			}
+			if test.attestationObject != nil {
+				b = bytes.Replace(b, []byte(base64.RawURLEncoding.EncodeToString(resp.Response.AttestationObject)), []byte(base64.RawURLEncoding.EncodeToString(test.attestationObject)), 1)
+			}
+
+			_, err = protocol.ParseCredentialCreationResponse(bytes.NewReader(b))
+			if err == nil {
+				t.Fatal("expected an error")
+			} else if e := protocol.ToWebAuthnError(err); e.Name != protocol.ErrInvalidRequest.Name || e.Debug == "" {
+				t.Fatalf("expected invalid request with debug information, got %v", err)
+			}
+		})
+	}
+}
+
+func TestParseCredentialAssertionResponse(t *testing.T) {
+	p, err := protocol.ParseCredentialAssertionResponse(strings.NewReader(assertionResponses[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Response.AuthData.SignCount != 1 {
+		t.Fatalf("invalid sign count %d", p.Response.AuthData.SignCount)
+	}
+
+	for _, test := range []struct {
+		name     string
+		from, to string
+	}{
+		{"Malformed base64", `"signature":"`, `"signature":"!`},
+		{"Truncated auth data", `"authenticatorData":"SZYN5YgOjGh0NBcPZHZgW4/krrmihjLHmVzzuoMdl2MBAAAAAQ=="`, `"authenticatorData":"SZYN5YgOjGh0NBcPZHZgW4/krrmihjLHmVzzuoMdl2MB"`},
+		{"Malformed JSON", `}`, ``},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			resp := strings.Replace(assertionResponses[0], test.from, test.to, 1)
+			_, err := protocol.ParseCredentialAssertionResponse(strings.NewReader(resp))
+			if err == nil {
+				t.Fatal("expected an error")
+			} else if e := protocol.ToWebAuthnError(err); e.Name != protocol.ErrInvalidRequest.Name || e.Debug == "" {
+				t.Fatalf("expected invalid request with debug information, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCollectedClientDataType(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		typ          string
+		requiredType string
+		valid        bool
+	}{
+		{"Create", protocol.ClientDataTypeCreate, protocol.ClientDataTypeCreate, true},
+		{"Get", protocol.ClientDataTypeGet, protocol.ClientDataTypeGet, true},
+		{"Get during registration", protocol.ClientDataTypeGet, protocol.ClientDataTypeCreate, false},
+		{"Create during login", protocol.ClientDataTypeCreate, protocol.ClientDataTypeGet, false},
+		{"Missing", "", protocol.ClientDataTypeGet, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := protocol.CollectedClientData{Type: test.typ}.IsValid(test.requiredType, nil, "")
+			if test.valid && err != nil {
+				t.Fatal(err)
+			} else if !test.valid && !errors.Is(err, protocol.ErrInvalidClientDataType) {
+				t.Fatalf("expected ErrInvalidClientDataType, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCollectedClientDataChallenge(t *testing.T) {
+	challenge := []byte{0x01, 0x02, 0x03, 0x04}
+
+	for _, test := range []struct {
+		name      string
+		challenge []byte
+		valid     bool
+	}{
+		{"Equal", []byte{0x01, 0x02, 0x03, 0x04}, true},
+		{"Equal length", []byte{0x01, 0x02, 0x03, 0x05}, false},
+		{"Shorter", []byte{0x01, 0x02, 0x03}, false},
+		{"Longer", []byte{0x01, 0x02, 0x03, 0x04, 0x05}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c := protocol.CollectedClientData{
+				Type:      protocol.ClientDataTypeGet,
+				Challenge: base64.RawURLEncoding.EncodeToString(test.challenge),
+			}
+
+			err := c.IsValid(protocol.ClientDataTypeGet, challenge, "")
+			if test.valid && err != nil {
+				t.Fatal(err)
+			} else if !test.valid && !errors.Is(err, protocol.ErrInvalidChallenge) {
+				t.Fatalf("expected ErrInvalidChallenge, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCollectedClientDataTokenBinding(t *testing.T) {
+	id := []byte{0x01, 0x02, 0x03, 0x04}
+
+	for _, test := range []struct {
+		name       string
+		clientData string
+		expectedID []byte
+		valid      bool
+	}{
+		{"Ignored", `{"type":"webauthn.get","tokenBinding":{"status":"present","id":"BAMCAQ"}}`, nil, true},
+		{"Not supported", `{"type":"webauthn.get","tokenBinding":{"status":"not-supported"}}`, nil, true},
+		{"Present", `{"type":"webauthn.get","tokenBinding":{"status":"present","id":"AQIDBA"}}`, id, true},
+		{"Different ID", `{"type":"webauthn.get","tokenBinding":{"status":"present","id":"BAMCAQ"}}`, id, false},
+		{"Invalid ID", `{"type":"webauthn.get","tokenBinding":{"status":"present","id":"!"}}`, id, false},
+		{"Supported", `{"type":"webauthn.get","tokenBinding":{"status":"supported"}}`, id, false},
+		{"Missing", `{"type":"webauthn.get"}`, id, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var c protocol.CollectedClientData
+			if err := json.Unmarshal([]byte(test.clientData), &c); err != nil {
+				t.Fatal(err)
+			}
+
+			err := c.VerifyTokenBinding(test.expectedID)
+			if test.valid && err != nil {
+				t.Fatal(err)
+			} else if !test.valid && !errors.Is(err, protocol.ErrTokenBindingMismatch) {
+				t.Fatalf("expected ErrTokenBindingMismatch, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorDataExtensions(t *testing.T) {
+	output := bytes.Repeat([]byte{0x42}, 32)
+
+	for _, test := range []struct {
+		name       string
+		extensions []byte
+		created    bool
+		output     []byte
+	}{
+		{"Registration", []byte("\xa1\x6bhmac-secret\xf5"), true, nil},
+		{"Login", append([]byte("\xa1\x6bhmac-secret\x58\x20"), output...), false, output},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			raw := make([]byte, 37)
+			raw[32] = protocol.AuthenticatorDataFlagUserPresent | protocol.AuthenticatorDataFlagHasExtension
+			raw = append(raw, test.extensions...)
+
+			var authData protocol.AuthenticatorData
+			if err := authData.UnmarshalBinary(raw); err != nil {
+				t.Fatal(err)
+			}
+
+			if test.output == nil {
+				created, err := authData.Extensions.HMACSecretCreated()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if created != test.created {
+					t.Fatalf("expected hmac-secret created to be %v", test.created)
+				}
+			} else {
+				output, err := authData.Extensions.HMACSecretOutput()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(output, test.output) {
+					t.Fatalf("invalid hmac-secret output %x", output)
+				}
+			}
+		})
+	}
+
+	t.Run("Device public key", func(t *testing.T) {
+		aaguid := bytes.Repeat([]byte{0x01}, 16)
+		raw := make([]byte, 37)
+		raw[32] = protocol.AuthenticatorDataFlagUserPresent | protocol.AuthenticatorDataFlagHasExtension
+		raw = append(raw, "\xa1\x6cdevicePubKey\xa6\x66aaguid\x50"...)
+		raw = append(raw, aaguid...)
+		raw = append(raw, "\x63dpk\x44\x01\x02\x03\x04\x65scope\x00\x65nonce\x40\x63fmt\x64none\x67attStmt\xa0"...)
+
+		var authData protocol.AuthenticatorData
+		if err := authData.UnmarshalBinary(raw); err != nil {
+			t.Fatal(err)
+		}
+
+		devicePubKey, err := authData.Extensions.DevicePubKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(devicePubKey.AAGUID, aaguid) {
+			t.Fatalf("invalid aaguid %x", devicePubKey.AAGUID)
+		}
+		if !bytes.Equal(devicePubKey.DPK, []byte{0x01, 0x02, 0x03, 0x04}) {
+			t.Fatalf("invalid dpk %x", devicePubKey.DPK)
+		}
+		if devicePubKey.Scope != 0 || len(devicePubKey.Nonce) != 0 || devicePubKey.Fmt != "none" || len(devicePubKey.AttStmt) != 0 {
+			t.Fatalf("invalid devicePubKey output %+v", devicePubKey)
+		}
+	})
+
+	t.Run("User verification methods", func(t *testing.T) {
+		for _, test := range []struct {
+			name    string
+			uvm     string
+			entries []protocol.UVMEntry
+		}{
+			{"Fingerprint", "\x81\x83\x02\x02\x02", []protocol.UVMEntry{
+				{protocol.UserVerificationMethodFingerprintInternal, 2, 2},
+			}},
+			{"Passcode and presence", "\x82\x83\x04\x0a\x04\x83\x01\x0a\x04", []protocol.UVMEntry{
+				{protocol.UserVerificationMethodPasscodeInternal, 10, 4},
+				{protocol.UserVerificationMethodPresenceInternal, 10, 4},
+			}},
+			{"Too many entries", "\x84\x83\x01\x01\x01\x83\x01\x01\x01\x83\x01\x01\x01\x83\x01\x01\x01", nil},
+			{"Invalid entry", "\x81\x82\x02\x02", nil},
+			{"Invalid protection type", "\x81\x83\x02\x1a\x00\x01\x00\x00\x02", nil},
+		} {
+			t.Run(test.name, func(t *testing.T) {
+				raw := make([]byte, 37)
+				raw[32] = protocol.AuthenticatorDataFlagUserPresent | protocol.AuthenticatorDataFlagHasExtension
+				raw = append(raw, "\xa1\x63uvm"+test.uvm...)
+
+				var authData protocol.AuthenticatorData
+				if err := authData.UnmarshalBinary(raw); err != nil {
+					t.Fatal(err)
+				}
+
+				entries, err := authData.Extensions.UVM()
+				if test.entries == nil {
+					if err == nil {
+						t.Fatal("expected error for invalid uvm")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if fmt.Sprint(entries) != fmt.Sprint(test.entries) {
+					t.Fatalf("expected uvm entries %v, got %v", test.entries, entries)
+				}
+			})
+		}
+	})
+
+	t.Run("Credential protection", func(t *testing.T) {
+		for _, test := range []struct {
+			name   string
+			value  string
+			policy protocol.CredentialProtectionPolicy
+			valid  bool
+		}{
+			{"Optional", "\x01", protocol.CredentialProtectionUserVerificationOptional, true},
+			{"Optional with credential ID list", "\x02", protocol.CredentialProtectionUserVerificationOptionalWithCredentialIDList, true},
+			{"Required", "\x03", protocol.CredentialProtectionUserVerificationRequired, true},
+			{"Unknown", "\x04", "", false},
+			{"Invalid type", "\x64test", "", false},
+		} {
+			t.Run(test.name, func(t *testing.T) {
+				raw := make([]byte, 37)
+				raw[32] = protocol.AuthenticatorDataFlagUserPresent | protocol.AuthenticatorDataFlagHasExtension
+				raw = append(raw, "\xa1\x6bcredProtect"+test.value...)
+
+				var authData protocol.AuthenticatorData
+				if err := authData.UnmarshalBinary(raw); err != nil {
+					t.Fatal(err)
+				}
+
+				policy, err := authData.Extensions.CredProtect()
+				if !test.valid {
+					if err == nil {
+						t.Fatal("expected error for invalid credProtect")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if policy != test.policy {
+					t.Fatalf("expected policy %q, got %q", test.policy, policy)
+				}
+			})
+		}
+	})
+
+	t.Run("Minimum PIN length", func(t *testing.T) {
+		for _, test := range []struct {
+			name   string
+			value  string
+			length int
+			valid  bool
+		}{
+			{"Length", "\x08", 8, true},
+			{"Maximum length", "\x18\x3f", 63, true},
+			{"Zero", "\x00", 0, false},
+			{"Too long", "\x18\x40", 0, false},
+			{"Invalid type", "\x64test", 0, false},
+		} {
+			t.Run(test.name, func(t *testing.T) {
+				raw := make([]byte, 37)
+				raw[32] = protocol.AuthenticatorDataFlagUserPresent | protocol.AuthenticatorDataFlagHasExtension
+				raw = append(raw, "\xa1\x6cminPinLength"+test.value...)
+
+				var authData protocol.AuthenticatorData
+				if err := authData.UnmarshalBinary(raw); err != nil {
+					t.Fatal(err)
+				}
+
+				length, err := authData.Extensions.MinPINLength()
+				if !test.valid {
+					if err == nil {
+						t.Fatal("expected error for invalid minPinLength")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if length != test.length {
+					t.Fatalf("expected minimum PIN length %d, got %d", test.length, length)
+				}
+			})
+		}
+
+		if length, err := (protocol.AuthenticationExtensionsAuthenticatorOutputs{}).MinPINLength(); length != 0 || err != nil {
+			t.Fatalf("expected no minimum PIN length, got %d, %v", length, err)
+		}
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		raw := make([]byte, 37)
+		raw[32] = protocol.AuthenticatorDataFlagUserPresent | protocol.AuthenticatorDataFlagHasExtension
+		raw = append(raw, "\xa1\x6bhmac"...)
+
+		var authData protocol.AuthenticatorData
+		if err := authData.UnmarshalBinary(raw); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
 var attestationResponses = []string{
 	`{"id":"LOXI3xfiLvIP04MD_S2ZmJYwn3cvMX1FUXxiQO7xlfUvrfcj99UVO2aMrMAwsGvsujY7NHWiM6G3B6ryKJDBBdab-cl4tVZeOwOMhgvHLXk","rawId":"LOXI3xfiLvIP04MD/S2ZmJYwn3cvMX1FUXxiQO7xlfUvrfcj99UVO2aMrMAwsGvsujY7NHWiM6G3B6ryKJDBBdab+cl4tVZeOwOMhgvHLXk=","response":{"attestationObject":"o2dhdHRTdG10omNzaWdYRjBEAiAJ8Q7i8DQzKlb00g4Wby4PoEjlI+s3bS+kVKI3PKoyXQIgDzcP2c5vpplZdmftN+zUDNfXtG1TniWbJv2+6kGZ8bljeDVjgVkBKzCCAScwgc6gAwIBAgIBADAKBggqhkjOPQQDAjAWMRQwEgYDVQQDDAtLcnlwdG9uIEtleTAeFw0xODA5MTcxODQ3NDJaFw0yODA5MTcxODQ3NDJaMBYxFDASBgNVBAMMC0tyeXB0b24gS2V5MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEwzIpvM5A6mZQXYxRIhfp0sb/21yTcr/sp5Y5DU0IWODQf5ldS2rlDCl62yEaQDM9Akxbsay/vA/S5ut4VSsvoKMNMAswCQYDVR0TBAIwADAKBggqhkjOPQQDAgNIADBFAiA4Yx+5MtKVnjme6V3qXKQ2qcgaHfO6DMgXM9kwOCZcNAIhAJdNk5PPSA04ITfrX9HQy5azo8sH9yhkW7c6gLdb/Kz+aGF1dGhEYXRhWNRJlg3liA6MaHQ0Fw9kdmBbj+SuuaKGMseZXPO6gx2XY0EAAAAALOXI3xfiLvIP04MD/S2ZmABQLOXI3xfiLvIP04MD/S2ZmJYwn3cvMX1FUXxiQO7xlfUvrfcj99UVO2aMrMAwsGvsujY7NHWiM6G3B6ryKJDBBdab+cl4tVZeOwOMhgvHLXmlAQIDJiABIVggwzIpvM5A6mZQXYxRIhfp0sb/21yTcr/sp5Y5DU0IWOAiWCDQf5ldS2rlDCl62yEaQDM9Akxbsay/vA/S5ut4VSsvoGNmbXRoZmlkby11MmY=","clientDataJSON":"eyJjaGFsbGVuZ2UiOiItMWpReXNud2FJak5VLUdyd1JwNFBXTkJNbFgwaTlfY2FSa2NLZDdMUGo4IiwiY2xpZW50RXh0ZW5zaW9ucyI6e30sImhhc2hBbGdvcml0aG0iOiJTSEEtMjU2Iiwib3JpZ2luIjoiaHR0cDovL2xvY2FsaG9zdDo1Mzg3OSIsInRva2VuQmluZGluZyI6eyJzdGF0dXMiOiJub3Qtc3VwcG9ydGVkIn0sInR5cGUiOiJ3ZWJhdXRobi5jcmVhdGUifQ=="},"type":"public-key"}`,
 	`{"id":"SNBSJTt1DHEuG9XBd6lfc4XXqxkppWfFbt4P5sRVQEPIPANIHHCmPo1AwY5pkUGcpVL3W-uHyWEn4vbgzp34Qw","rawId":"SNBSJTt1DHEuG9XBd6lfc4XXqxkppWfFbt4P5sRVQEPIPANIHHCmPo1AwY5pkUGcpVL3W+uHyWEn4vbgzp34Qw==","response":{"attestationObject":"o2NmbXRmcGFja2VkZ2F0dFN0bXSjY2FsZyZjc2lnWEcwRQIgFls/elhmdZmqEBEKafdcyvQPDrTdBRMW92v6RKJj1bACIQCZ+46sXn65dMEpPuGxvMUruV5i7XN25ctFV/iAi3wSomN4NWOBWQLCMIICvjCCAaagAwIBAgIEdIb9wjANBgkqhkiG9w0BAQsFADAuMSwwKgYDVQQDEyNZdWJpY28gVTJGIFJvb3QgQ0EgU2VyaWFsIDQ1NzIwMDYzMTAgFw0xNDA4MDEwMDAwMDBaGA8yMDUwMDkwNDAwMDAwMFowbzELMAkGA1UEBhMCU0UxEjAQBgNVBAoMCVl1YmljbyBBQjEiMCAGA1UECwwZQXV0aGVudGljYXRvciBBdHRlc3RhdGlvbjEoMCYGA1UEAwwfWXViaWNvIFUyRiBFRSBTZXJpYWwgMTk1NTAwMzg0MjBZMBMGByqGSM49AgEGCCqGSM49AwEHA0IABJVd8633JH0xde/9nMTzGk6HjrrhgQlWYVD7OIsuX2Unv1dAmqWBpQ0KxS8YRFwKE1SKE1PIpOWacE5SO8BN6+2jbDBqMCIGCSsGAQQBgsQKAgQVMS4zLjYuMS40LjEuNDE0ODIuMS4xMBMGCysGAQQBguUcAgEBBAQDAgUgMCEGCysGAQQBguUcAQEEBBIEEPigEfOMCk0VgAYXER+e3H0wDAYDVR0TAQH/BAIwADANBgkqhkiG9w0BAQsFAAOCAQEAMVxIgOaaUn44Zom9af0KqG9J655OhUVBVW+q0As6AIod3AH5bHb2aDYakeIyyBCnnGMHTJtuekbrHbXYXERIn4aKdkPSKlyGLsA/A+WEi+OAfXrNVfjhrh7iE6xzq0sg4/vVJoywe4eAJx0fS+Dl3axzTTpYl71Nc7p/NX6iCMmdik0pAuYJegBcTckE3AoYEg4K99AM/JaaKIblsbFh8+3LxnemeNf7UwOczaGGvjS6UzGVI0Odf9lKcPIwYhuTxM5CaNMXTZQ7xq4/yTfC3kPWtE4hFT34UJJflZBiLrxG4OsYxkHw/n5vKgmpspB3GfYuYTWhkDKiE8CYtyg87mhhdXRoRGF0YVjESZYN5YgOjGh0NBcPZHZgW4/krrmihjLHmVzzuoMdl2NBAAAAA/igEfOMCk0VgAYXER+e3H0AQEjQUiU7dQxxLhvVwXepX3OF16sZKaVnxW7eD+bEVUBDyDwDSBxwpj6NQMGOaZFBnKVS91vrh8lhJ+L24M6d+EOlAQIDJiABIVggLxxTguKmjCV4N5OMqd2Sl9AIxSltaPevmQxSqnyNlAciWCDEHOaQDaZ6pC2gC+Z0KS4Ln/XQiJp0X1BmTd+K+FdqSg==","clientDataJSON":"eyJjaGFsbGVuZ2UiOiJKVXRsWWNncGtTaUZOenNUaERZdU9ydFNWWTFWZUxvZk0tbVdUUkNDWHFVIiwibmV3X2tleXNfbWF5X2JlX2FkZGVkX2hlcmUiOiJkbyBub3QgY29tcGFyZSBjbGllbnREYXRhSlNPTiBhZ2FpbnN0IGEgdGVtcGxhdGUuIFNlZSBodHRwczovL2dvby5nbC95YWJQZXgiLCJvcmlnaW4iOiJodHRwOi8vbG9jYWxob3N0OjkwMDAiLCJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIn0="},"type":"public-key"}`,
@@ -78,3 +769,282 @@ var assertionResponses = []string{
 	// Android SafetyNet
 	`{"id":"ARKBRFD84uLN6qG_rHsV0K2Bh9Lj3_HaJsXdC_DpPslKO6ZWmD38-hz90Lf_MzELErMa9AqR21Sr9brNzE2un1U","rawId":"ARKBRFD84uLN6qG/rHsV0K2Bh9Lj3/HaJsXdC/DpPslKO6ZWmD38+hz90Lf/MzELErMa9AqR21Sr9brNzE2un1U=","response":{"clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uZ2V0IiwiY2hhbGxlbmdlIjoiSEMzM2hWN2pGWXg2bTRoVWt2TkYwR0xWbjJXaWhUaWxhRXRuaWhhLVF2dyIsIm9yaWdpbiI6Imh0dHBzOlwvXC9iMzk5ZmEwMC5uZ3Jvay5pbyIsImFuZHJvaWRQYWNrYWdlTmFtZSI6ImNvbS5hbmRyb2lkLmNocm9tZSJ9","authenticatorData":"KkPvsfM9p8duZQuRebCXabD0/+VBGQvI2BYU7WpAiNUFAAAAAQ==","signature":"MEQCIBapcKD8L5Kp92QBr4XpHNwiRPjo/MGTEIEwCsklxfvAAiABn02rbcatTqHFtHwbnHwdNOLa5apxCBRuFPPwABBm3w==","userHandle":""},"type":"public-key"}`,
 }
+
+func TestAuthenticatorDataExtensionFlag(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, y := make([]byte, 32), make([]byte, 32)
+	copy(x[32-len(key.X.Bytes()):], key.X.Bytes())
+	copy(y[32-len(key.Y.Bytes()):], key.Y.Bytes())
+	// {1: 2, 3: -7, -1: 1, -2: x, -3: y}
+	coseKey := append([]byte("\xa5\x01\x02\x03\x26\x20\x01\x21\x58\x20"), x...)
+	coseKey = append(append(coseKey, "\x22\x58\x20"...), y...)
+
+	attestedCredentialData := append(make([]byte, 16), 0x00, 0x04, 0x01, 0x02, 0x03, 0x04)
+	attestedCredentialData = append(attestedCredentialData, coseKey...)
+	extensions := []byte("\xa1\x6bhmac-secret\xf5")
+
+	for _, test := range []struct {
+		name       string
+		flags      byte
+		data       []byte
+		extensions bool
+		valid      bool
+	}{
+		{"Without extensions", 0, nil, false, true},
+		{"With extensions", protocol.AuthenticatorDataFlagHasExtension, extensions, true, true},
+		{"Credential without extensions", protocol.AuthenticatorDataFlagHasCredentialData, attestedCredentialData, false, true},
+		{"Credential with extensions", protocol.AuthenticatorDataFlagHasCredentialData | protocol.AuthenticatorDataFlagHasExtension, append(append([]byte{}, attestedCredentialData...), extensions...), true, true},
+		{"Extensions without flag", 0, extensions, false, false},
+		{"Credential and extensions without flag", protocol.AuthenticatorDataFlagHasCredentialData, append(append([]byte{}, attestedCredentialData...), extensions...), false, false},
+		{"Missing extensions", protocol.AuthenticatorDataFlagHasExtension, nil, false, false},
+		{"Trailing data after extensions", protocol.AuthenticatorDataFlagHasExtension, append(append([]byte{}, extensions...), 0x00), false, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			raw := make([]byte, 37)
+			raw[32] = protocol.AuthenticatorDataFlagUserPresent | test.flags
+			raw = append(raw, test.data...)
+
+			var authData protocol.AuthenticatorData
+			err := authData.UnmarshalBinary(raw)
+			if !test.valid {
+				if !errors.Is(err, protocol.ErrInvalidRequest) {
+					t.Fatalf("expected %v, got %v", protocol.ErrInvalidRequest, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.flags&protocol.AuthenticatorDataFlagHasCredentialData != 0 {
+				if !bytes.Equal(authData.AttestedCredentialData.CredentialID, []byte{0x01, 0x02, 0x03, 0x04}) {
+					t.Fatalf("invalid credential ID %x", authData.AttestedCredentialData.CredentialID)
+				}
+				if !bytes.Equal(authData.AttestedCredentialData.CredentialPublicKey, coseKey) {
+					t.Fatalf("invalid credential public key %x", authData.AttestedCredentialData.CredentialPublicKey)
+				}
+			}
+			if created, _ := authData.Extensions.HMACSecretCreated(); created != test.extensions {
+				t.Fatalf("expected hmac-secret created to be %v", test.extensions)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorDataAttestedCredentialData(t *testing.T) {
+	// authData returns authenticator data with the AT flag set, followed by the given attested credential data
+	authData := func(attestedCredentialData ...byte) []byte {
+		raw := make([]byte, 37)
+		raw[32] = protocol.AuthenticatorDataFlagUserPresent | protocol.AuthenticatorDataFlagHasCredentialData
+		return append(raw, attestedCredentialData...)
+	}
+
+	for _, test := range []struct {
+		name     string
+		authData []byte
+		err      error
+	}{
+		{"Missing", authData(), protocol.ErrMissingAttestedCredentialData},
+		{"Truncated AAGUID", authData(make([]byte, 8)...), protocol.ErrMissingAttestedCredentialData},
+		{"Truncated credential ID", authData(append(make([]byte, 16), 0x00, 0x10, 0x01, 0x02)...), protocol.ErrMissingAttestedCredentialData},
+		{"Credential ID too long", authData(append(make([]byte, 16), append([]byte{0x04, 0x00}, make([]byte, 1024)...)...)...), protocol.ErrInvalidRequest},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var a protocol.AuthenticatorData
+			err := a.UnmarshalBinary(test.authData)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+
+	t.Run("AT flag not set", func(t *testing.T) {
+		// {"fmt": "none", "attStmt": {}, "authData": h'00...'}
+		attestationObject := append([]byte("\xa3\x63fmt\x64none\x67attStmt\xa0\x68authData\x58\x25"), make([]byte, 37)...)
+		attestationObject[len(attestationObject)-5] = protocol.AuthenticatorDataFlagUserPresent
+
+		p := protocol.AttestationResponse{}
+		p.Response.ClientDataJSON = []byte(`{}`)
+		p.Response.AttestationObject = attestationObject
+
+		_, err := protocol.ParseAttestationResponse(p)
+		if !errors.Is(err, protocol.ErrMissingAttestedCredentialData) {
+			t.Fatalf("expected %v, got %v", protocol.ErrMissingAttestedCredentialData, err)
+		}
+	})
+}
+
+func TestClientDataJSONRawBytes(t *testing.T) {
+	t.Run("Attestation", func(t *testing.T) {
+		raw := protocol.AttestationResponse{}
+		if err := json.Unmarshal([]byte(attestationResponses[0]), &raw); err != nil {
+			t.Fatal(err)
+		}
+
+		// The client data contains the unknown members clientExtensions and hashAlgorithm, which are not
+		// serialized in the same order by encoding/json
+		p, err := protocol.ParseAttestationResponse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := protocol.VerifyAttestation(p, nil, "", ""); err != nil {
+			t.Fatal(err)
+		}
+
+		// Re-serializing the client data changes its hash, which invalidates the attestation signature
+		reserialized, err := json.Marshal(p.Response.ClientData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw.Response.ClientDataJSON = reserialized
+		if p, err = protocol.ParseAttestationResponse(raw); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := protocol.VerifyAttestation(p, nil, "", ""); err == nil {
+			t.Fatal("expected error for re-serialized client data")
+		}
+	})
+
+	t.Run("Assertion", func(t *testing.T) {
+		authenticator, err := testutil.NewVirtualAuthenticator()
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicKey, err := authenticator.PublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		challenge := []byte("challenge")
+		resp, err := authenticator.Login("https://localhost", &protocol.CredentialRequestOptions{
+			PublicKey: protocol.PublicKeyCredentialRequestOptions{Challenge: challenge},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Members in a different order, unknown members and insignificant whitespace must be tolerated, and the
+		// signature must be verified over the exact bytes
+		resp.Response.ClientDataJSON = []byte(`{ "origin": "https://localhost", "crossOrigin": false, "type": "webauthn.get",` +
+			` "challenge": "` + base64.RawURLEncoding.EncodeToString(challenge) + `", "other_keys_can_be_added_here": {"nested": [1, 2]} }`)
+		clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+		hash := sha256.Sum256(append(append([]byte{}, resp.Response.AuthenticatorData...), clientDataHash[:]...))
+		if resp.Response.Signature, err = authenticator.PrivateKey.Sign(rand.Reader, hash[:], crypto.SHA256); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := protocol.VerifyAssertion(*resp, challenge, "https://localhost", "localhost", publicKey, protocol.AssertionVerificationOptions{}); err != nil {
+			t.Fatal(err)
+		}
+
+		resp.Response.ClientDataJSON = append(resp.Response.ClientDataJSON, ' ')
+		if _, err := protocol.VerifyAssertion(*resp, challenge, "https://localhost", "localhost", publicKey, protocol.AssertionVerificationOptions{}); !errors.Is(err, protocol.ErrInvalidSignature) {
+			t.Fatalf("expected %v, got %v", protocol.ErrInvalidSignature, err)
+		}
+	})
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	for i := range assertionRequests {
+		t.Run(fmt.Sprintf("Run %d", i), func(t *testing.T) {
+			rawAttestation := protocol.AttestationResponse{}
+			if err := json.Unmarshal([]byte(attestationResponses[i]), &rawAttestation); err != nil {
+				t.Fatal(err)
+			}
+
+			attestation, err := protocol.ParseAttestationResponse(rawAttestation)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			publicKey := attestation.Response.Attestation.AuthData.AttestedCredentialData.CredentialPublicKey
+
+			r := protocol.CredentialCreationOptions{}
+			if err := json.Unmarshal([]byte(assertionRequests[i]), &r); err != nil {
+				t.Fatal(err)
+			}
+
+			b := protocol.AssertionResponse{}
+			if err := json.Unmarshal([]byte(assertionResponses[i]), &b); err != nil {
+				t.Fatal(err)
+			}
+
+			p, err := protocol.ParseAssertionResponse(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			origin := p.Response.ClientData.Origin
+			u, err := url.Parse(origin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rpID := u.Hostname()
+
+			signCount, err := protocol.VerifyAssertion(b, r.PublicKey.Challenge, origin, rpID, publicKey, protocol.AssertionVerificationOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if signCount != p.Response.AuthData.SignCount {
+				t.Fatalf("expected sign count %d, got %d", p.Response.AuthData.SignCount, signCount)
+			}
+
+			for _, test := range []struct {
+				name      string
+				challenge []byte
+				origin    string
+				rpID      string
+				err       error
+			}{
+				{"Missing challenge", nil, origin, rpID, protocol.ErrInvalidRequest},
+				{"Invalid challenge", []byte("invalid"), origin, rpID, protocol.ErrInvalidChallenge},
+				{"Invalid origin", r.PublicKey.Challenge, "https://invalid.example", rpID, protocol.ErrInvalidOrigin},
+				{"Invalid RP ID", r.PublicKey.Challenge, origin, "invalid.example", protocol.ErrRPIDHashMismatch},
+			} {
+				t.Run(test.name, func(t *testing.T) {
+					_, err := protocol.VerifyAssertion(b, test.challenge, test.origin, test.rpID, publicKey, protocol.AssertionVerificationOptions{})
+					if !errors.Is(err, test.err) {
+						t.Fatalf("expected %v, got %v", test.err, err)
+					}
+				})
+			}
+
+			if _, err := protocol.VerifyAssertion(b, r.PublicKey.Challenge, origin, rpID, []byte("invalid"), protocol.AssertionVerificationOptions{}); !errors.Is(err, protocol.ErrInvalidRequest) {
+				t.Fatalf("expected %v for invalid stored public key, got %v", protocol.ErrInvalidRequest, err)
+			}
+
+			if !p.Response.AuthData.Flags.UserVerified() {
+				_, err := protocol.VerifyAssertion(b, r.PublicKey.Challenge, origin, rpID, publicKey, protocol.AssertionVerificationOptions{
+					UserVerification: protocol.UserVerificationRequired,
+				})
+				if !errors.Is(err, protocol.ErrUserNotVerified) {
+					t.Fatalf("expected %v, got %v", protocol.ErrUserNotVerified, err)
+				}
+			}
+		})
+	}
+}
+
+func TestAAGUIDString(t *testing.T) {
+	aaguid := []byte{0xcb, 0x69, 0x48, 0x1e, 0x8f, 0xf7, 0x40, 0x39, 0x93, 0xec, 0x0a, 0x27, 0x29, 0xa1, 0x54, 0xa8}
+	d := protocol.AttestedCredentialData{AAGUID: aaguid}
+	if s := d.AAGUIDString(); s != "cb69481e-8ff7-4039-93ec-0a2729a154a8" {
+		t.Fatalf("unexpected AAGUID %q", s)
+	}
+	if s := protocol.FormatAAGUID(aaguid[:8]); s != "" {
+		t.Fatalf("expected empty string for invalid AAGUID, got %q", s)
+	}
+
+	for _, s := range []string{"cb69481e-8ff7-4039-93ec-0a2729a154a8", "CB69481E-8FF7-4039-93EC-0A2729A154A8"} {
+		if parsed, err := protocol.ParseAAGUID(s); err != nil || !bytes.Equal(parsed, aaguid) {
+			t.Fatalf("unable to parse %q: %v, %v", s, parsed, err)
+		}
+	}
+	for _, s := range []string{"", "cb69481e8ff7403993ec0a2729a154a8", "cb69481e-8ff7-4039-93ec-0a2729a154a", "cb69481e-8ff7-4039-93ec-0a2729a154ax", "cb69481e-8ff7-4039-93ec-0a2729a15-a8"} {
+		if _, err := protocol.ParseAAGUID(s); err == nil {
+			t.Fatalf("expected error for %q", s)
+		}
+	}
+}
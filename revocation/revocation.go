@@ -0,0 +1,271 @@
+// revocation implements revocation checking of attestation certificates using OCSP and CRLs.
+package revocation // import "github.com/keycloud/webauthn/revocation"
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// Mode determines how the result of a revocation check is handled.
+type Mode int
+
+const (
+	// ModeNone disables revocation checking.
+	ModeNone Mode = iota
+	// ModeSoftFail rejects revoked certificates, but accepts certificates of which the revocation status could not be
+	// determined, for instance because the OCSP responder is unavailable.
+	ModeSoftFail
+	// ModeHardFail rejects revoked certificates and certificates of which the revocation status could not be
+	// determined.
+	ModeHardFail
+)
+
+const (
+	// maxOCSPResponseSize is the maximum size of an OCSP response.
+	maxOCSPResponseSize = 1 << 20
+	// maxCRLSize is the maximum size of a CRL.
+	maxCRLSize = 16 << 20
+)
+
+// Errors
+var (
+	ErrRevoked = errors.New("revocation: certificate is revoked")
+	ErrUnknown = errors.New("revocation: unable to determine revocation status")
+)
+
+// Cache caches the responses of OCSP responders and CRL distribution points.
+type Cache interface {
+	// Get returns the cached response of the given key, if any.
+	Get(key string) ([]byte, bool)
+	// Set caches the response of the given key until it expires.
+	Set(key string, response []byte, expires time.Time)
+}
+
+// Checker checks whether certificates are revoked. The leaf certificate is checked using its OCSP responders and, if
+// none of them returns a status, its CRL distribution points. The zero value uses http.DefaultClient and an in-memory
+// cache. A Checker is safe for concurrent use.
+type Checker struct {
+	// Client is the HTTP client that is used to contact OCSP responders and CRL distribution points. If it is nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+	// Cache is used to cache responses. If it is nil, an in-memory cache is used.
+	Cache Cache
+
+	once     sync.Once
+	memCache *memoryCache
+}
+
+// Check checks whether the first certificate of chain, which must be issued by the second certificate of the chain,
+// is revoked. If it is ErrRevoked is returned. If the status could not be determined, an error that wraps ErrUnknown
+// is returned, which can be detected using errors.Is.
+func (c *Checker) Check(chain []*x509.Certificate) error {
+	return c.CheckContext(context.Background(), chain)
+}
+
+// CheckContext is like Check, but requests to OCSP responders and CRL distribution points are aborted once ctx is
+// done.
+func (c *Checker) CheckContext(ctx context.Context, chain []*x509.Certificate) error {
+	if len(chain) < 2 {
+		return fmt.Errorf("%w: missing issuer", ErrUnknown)
+	}
+	leaf, issuer := chain[0], chain[1]
+
+	var errs []error
+	for _, server := range leaf.OCSPServer {
+		err := c.checkOCSP(ctx, server, leaf, issuer)
+		if err == nil || err == ErrRevoked {
+			return err
+		}
+		errs = append(errs, err)
+	}
+
+	for _, distributionPoint := range leaf.CRLDistributionPoints {
+		err := c.checkCRL(ctx, distributionPoint, leaf, issuer)
+		if err == nil || err == ErrRevoked {
+			return err
+		}
+		errs = append(errs, err)
+	}
+
+	if len(errs) == 0 {
+		return fmt.Errorf("%w: no OCSP responders or CRL distribution points", ErrUnknown)
+	}
+
+	return fmt.Errorf("%w: %v", ErrUnknown, errs)
+}
+
+func (c *Checker) checkOCSP(ctx context.Context, server string, leaf, issuer *x509.Certificate) error {
+	key := "ocsp " + server + " " + leaf.SerialNumber.String()
+
+	raw, ok := c.cache().Get(key)
+	if !ok {
+		req, err := ocsp.CreateRequest(leaf, issuer, nil)
+		if err != nil {
+			return err
+		}
+
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, server, bytes.NewReader(req))
+		if err != nil {
+			return err
+		}
+		httpReq.Header.Set("Content-Type", "application/ocsp-request")
+
+		resp, err := c.client().Do(httpReq)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status %s from OCSP responder %s", resp.Status, server)
+		}
+
+		if raw, err = ioutil.ReadAll(io.LimitReader(resp.Body, maxOCSPResponseSize+1)); err != nil {
+			return err
+		}
+		if len(raw) > maxOCSPResponseSize {
+			return fmt.Errorf("OCSP response from %s is too large", server)
+		}
+	}
+
+	ocspResp, err := ocsp.ParseResponseForCert(raw, leaf, issuer)
+	if err != nil {
+		return err
+	}
+
+	// A response is only valid between its thisUpdate and nextUpdate, such that stale responses can not be replayed
+	now := time.Now()
+	if ocspResp.ThisUpdate.After(now) {
+		return fmt.Errorf("OCSP response from %s is not valid before %s", server, ocspResp.ThisUpdate.Format(time.RFC3339))
+	}
+	if !ocspResp.NextUpdate.IsZero() && ocspResp.NextUpdate.Before(now) {
+		return fmt.Errorf("OCSP response from %s has expired", server)
+	}
+
+	if !ok && !ocspResp.NextUpdate.IsZero() {
+		c.cache().Set(key, raw, ocspResp.NextUpdate)
+	}
+
+	switch ocspResp.Status {
+	case ocsp.Good:
+		return nil
+	case ocsp.Revoked:
+		return ErrRevoked
+	default:
+		return fmt.Errorf("unknown status from OCSP responder %s", server)
+	}
+}
+
+func (c *Checker) checkCRL(ctx context.Context, distributionPoint string, leaf, issuer *x509.Certificate) error {
+	key := "crl " + distributionPoint
+
+	raw, ok := c.cache().Get(key)
+	if !ok {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, distributionPoint, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := c.client().Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected status %s from CRL distribution point %s", resp.Status, distributionPoint)
+		}
+
+		if raw, err = ioutil.ReadAll(io.LimitReader(resp.Body, maxCRLSize+1)); err != nil {
+			return err
+		}
+		if len(raw) > maxCRLSize {
+			return fmt.Errorf("CRL from distribution point %s is too large", distributionPoint)
+		}
+	}
+
+	crl, err := x509.ParseCRL(raw)
+	if err != nil {
+		return err
+	}
+
+	if err := issuer.CheckCRLSignature(crl); err != nil {
+		return err
+	}
+
+	if crl.HasExpired(time.Now()) {
+		return fmt.Errorf("CRL from distribution point %s has expired", distributionPoint)
+	}
+
+	if !ok {
+		c.cache().Set(key, raw, crl.TBSCertList.NextUpdate)
+	}
+
+	for _, revoked := range crl.TBSCertList.RevokedCertificates {
+		if revoked.SerialNumber.Cmp(leaf.SerialNumber) == 0 {
+			return ErrRevoked
+		}
+	}
+
+	return nil
+}
+
+func (c *Checker) client() *http.Client {
+	if c.Client != nil {
+		return c.Client
+	}
+	return http.DefaultClient
+}
+
+func (c *Checker) cache() Cache {
+	if c.Cache != nil {
+		return c.Cache
+	}
+	c.once.Do(func() {
+		c.memCache = &memoryCache{entries: make(map[string]memoryCacheEntry)}
+	})
+	return c.memCache
+}
+
+type memoryCacheEntry struct {
+	response []byte
+	expires  time.Time
+}
+
+// memoryCache is the default in-memory Cache.
+type memoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+}
+
+func (c *memoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !time.Now().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.response, true
+}
+
+func (c *memoryCache) Set(key string, response []byte, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[key] = memoryCacheEntry{response, expires}
+}
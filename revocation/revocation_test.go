@@ -0,0 +1,247 @@
+package revocation_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/keycloud/webauthn/revocation"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCA{cert, key}
+}
+
+func (ca *testCA) issue(t *testing.T, serial int64, ocspServer, crlDistributionPoint string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "Test Attestation"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if ocspServer != "" {
+		template.OCSPServer = []string{ocspServer}
+	}
+	if crlDistributionPoint != "" {
+		template.CRLDistributionPoints = []string{crlDistributionPoint}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+// newTestServer returns a server that acts as OCSP responder on /ocsp and CRL distribution point on /crl, of which
+// the certificate with serial number 2 is revoked. The OCSP responses for serial number 3 have expired, and those for
+// serial number 4 are not valid yet.
+func newTestServer(t *testing.T, ca *testCA, requests *int) *httptest.Server {
+	crl, err := ca.cert.CreateCRL(rand.Reader, ca.key, []pkix.RevokedCertificate{
+		{SerialNumber: big.NewInt(2), RevocationTime: time.Now()},
+	}, time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ocsp", func(rw http.ResponseWriter, r *http.Request) {
+		*requests++
+
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := ocsp.ParseRequest(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		template := ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now(),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}
+		switch req.SerialNumber.Int64() {
+		case 2:
+			template.Status = ocsp.Revoked
+			template.RevokedAt = time.Now()
+		case 3:
+			template.ThisUpdate, template.NextUpdate = time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour)
+		case 4:
+			template.ThisUpdate, template.NextUpdate = time.Now().Add(time.Hour), time.Now().Add(2*time.Hour)
+		}
+
+		resp, err := ocsp.CreateResponse(ca.cert, ca.cert, template, ca.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rw.Write(resp)
+	})
+	mux.HandleFunc("/crl", func(rw http.ResponseWriter, r *http.Request) {
+		*requests++
+		rw.Write(crl)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestCheck(t *testing.T) {
+	ca := newTestCA(t)
+
+	var requests int
+	server := newTestServer(t, ca, &requests)
+	defer server.Close()
+
+	for _, test := range []struct {
+		name       string
+		serial     int64
+		ocsp, crl  bool
+		revoked    bool
+		determined bool
+	}{
+		{"OCSP good", 1, true, false, false, true},
+		{"OCSP revoked", 2, true, false, true, true},
+		{"OCSP expired", 3, true, false, false, false},
+		{"OCSP not yet valid", 4, true, false, false, false},
+		{"CRL good", 1, false, true, false, true},
+		{"CRL revoked", 2, false, true, true, true},
+		{"No endpoints", 1, false, false, false, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var ocspServer, crlDistributionPoint string
+			if test.ocsp {
+				ocspServer = server.URL + "/ocsp"
+			}
+			if test.crl {
+				crlDistributionPoint = server.URL + "/crl"
+			}
+
+			leaf := ca.issue(t, test.serial, ocspServer, crlDistributionPoint)
+
+			err := (&revocation.Checker{}).Check([]*x509.Certificate{leaf, ca.cert})
+			if test.revoked && err != revocation.ErrRevoked {
+				t.Fatalf("expected ErrRevoked, got %v", err)
+			} else if !test.revoked && test.determined && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			} else if !test.determined && !errors.Is(err, revocation.ErrUnknown) {
+				t.Fatalf("expected ErrUnknown, got %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckCache(t *testing.T) {
+	ca := newTestCA(t)
+
+	var requests int
+	server := newTestServer(t, ca, &requests)
+	defer server.Close()
+
+	leaf := ca.issue(t, 1, server.URL+"/ocsp", "")
+
+	c := &revocation.Checker{}
+	for i := 0; i < 2; i++ {
+		if err := c.Check([]*x509.Certificate{leaf, ca.cert}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if requests != 1 {
+		t.Fatalf("expected response to be cached, got %d requests", requests)
+	}
+}
+
+func TestCheckContext(t *testing.T) {
+	ca := newTestCA(t)
+
+	var requests int
+	server := newTestServer(t, ca, &requests)
+	defer server.Close()
+
+	leaf := ca.issue(t, 2, server.URL+"/ocsp", server.URL+"/crl")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := (&revocation.Checker{}).CheckContext(ctx, []*x509.Certificate{leaf, ca.cert})
+	if err == nil || err == revocation.ErrRevoked {
+		t.Fatalf("expected undetermined status, got %v", err)
+	}
+	if requests != 0 {
+		t.Fatalf("expected no requests, got %d", requests)
+	}
+}
+
+func TestCheckResponseSize(t *testing.T) {
+	ca := newTestCA(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write(make([]byte, 2<<20))
+	}))
+	defer server.Close()
+
+	leaf := ca.issue(t, 1, server.URL, "")
+
+	err := (&revocation.Checker{}).Check([]*x509.Certificate{leaf, ca.cert})
+	if err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Fatalf("expected error for too large response, got %v", err)
+	}
+}
@@ -0,0 +1,358 @@
+// testutil provides a virtual authenticator, which can be used to test the registration and login ceremonies of a
+// relying party without real hardware.
+package testutil // import "github.com/keycloud/webauthn/testutil"
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	_ "crypto/sha512" // for crypto.SHA384 and crypto.SHA512
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/keycloud/webauthn/cose"
+	"github.com/keycloud/webauthn/protocol"
+	"github.com/ugorji/go/codec"
+)
+
+// VirtualAuthenticator is a software authenticator that holds a single credential. It creates packed self or basic
+// attestations when registering and signed assertions when logging in. Its fields may be changed between ceremonies,
+// for instance to test how a relying party handles a regressed signature counter or a missing flag.
+type VirtualAuthenticator struct {
+	// PrivateKey is the credential private key. Keys on the curves P-256, P-384, P-521 and cose.Secp256k1 are
+	// supported.
+	PrivateKey *ecdsa.PrivateKey
+	// CredentialID is the ID of the credential.
+	CredentialID []byte
+	// AAGUID is the AAGUID of the authenticator, which is all zeros by default.
+	AAGUID []byte
+	// UserHandle is the user handle that is returned in assertions. It is set to the user ID by Register.
+	UserHandle []byte
+	// RPID is the RP ID that the credential is scoped to. It is set by Register, and assertions are only created for
+	// the same RP ID.
+	RPID string
+	// SignCount is the signature counter of the next response. It is incremented after every response, unless it is
+	// zero, which means that the authenticator does not implement a signature counter.
+	SignCount uint32
+	// Flags are the flags of the authenticator data. The AT and ED flags are ignored, since they are set depending
+	// on the contents of the authenticator data.
+	Flags protocol.AuthenticatorDataFlags
+	// Extensions are the authenticator extension outputs that are added to the authenticator data of every response,
+	// for instance {"uvm": [][]uint64{{2, 2, 2}}}. If it is empty, the authenticator data contains no extensions.
+	Extensions map[string]interface{}
+	// AttestationKey is the P-256 private key of the attestation certificate, which is the first element of
+	// AttestationCertificates. If both are set, Register creates packed basic attestations with the ES256 algorithm and
+	// AttestationCertificates as x5c instead of self attestations.
+	AttestationKey *ecdsa.PrivateKey
+	// AttestationCertificates are the DER encoded attestation certificate and the certificates of its chain, without
+	// the root certificate.
+	AttestationCertificates [][]byte
+}
+
+// NewVirtualAuthenticator creates a VirtualAuthenticator with a new P-256 key and a random credential ID. It
+// performs user verification and implements a signature counter, which starts at one.
+func NewVirtualAuthenticator() (*VirtualAuthenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	credentialID := make([]byte, 16)
+	if _, err := rand.Read(credentialID); err != nil {
+		return nil, err
+	}
+
+	return &VirtualAuthenticator{
+		PrivateKey:   key,
+		CredentialID: credentialID,
+		AAGUID:       make([]byte, 16),
+		SignCount:    1,
+		Flags:        protocol.AuthenticatorDataFlagUserPresent | protocol.AuthenticatorDataFlagUserVerified,
+	}, nil
+}
+
+// Register creates the credential for the given options, as navigator.credentials.create() would on the given origin.
+// If the options do not contain an RP ID, the host of the origin is used.
+func (a *VirtualAuthenticator) Register(origin string, options *protocol.CredentialCreationOptions) (*protocol.AttestationResponse, error) {
+	for _, c := range options.PublicKey.ExcludeCredentials {
+		if bytes.Equal(c.ID, a.CredentialID) {
+			return nil, fmt.Errorf("credential is excluded")
+		}
+	}
+
+	rpID, err := relyingPartyID(options.PublicKey.RP.ID, origin)
+	if err != nil {
+		return nil, err
+	}
+
+	alg, _, err := a.algorithm()
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := a.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	clientDataJSON, err := clientData(protocol.ClientDataTypeCreate, options.PublicKey.Challenge, origin)
+	if err != nil {
+		return nil, err
+	}
+
+	aaguid := a.AAGUID
+	if aaguid == nil {
+		aaguid = make([]byte, 16)
+	}
+	if len(aaguid) != 16 {
+		return nil, fmt.Errorf("invalid AAGUID length %d", len(aaguid))
+	}
+
+	attestedCredentialData := append([]byte{}, aaguid...)
+	attestedCredentialData = append(attestedCredentialData, byte(len(a.CredentialID)>>8), byte(len(a.CredentialID)))
+	attestedCredentialData = append(attestedCredentialData, a.CredentialID...)
+	attestedCredentialData = append(attestedCredentialData, publicKey...)
+
+	authData, err := a.authenticatorData(rpID, protocol.AuthenticatorDataFlagHasCredentialData, attestedCredentialData)
+	if err != nil {
+		return nil, err
+	}
+	attStmt, err := a.attestationStatement(alg, authData, clientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	attestationObject, err := encodeCBOR(map[string]interface{}{
+		"fmt":      "packed",
+		"attStmt":  attStmt,
+		"authData": authData,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a.RPID, a.UserHandle = rpID, options.PublicKey.User.ID
+	a.incrementSignCount()
+
+	return &protocol.AttestationResponse{
+		PublicKeyCredential: a.publicKeyCredential(),
+		Response: protocol.AuthenticatorAttestationResponse{
+			AuthenticatorResponse: protocol.AuthenticatorResponse{ClientDataJSON: clientDataJSON},
+			AttestationObject:     attestationObject,
+			Transports:            []protocol.AuthenticatorTransport{protocol.AuthenticatorTransportInternal},
+		},
+	}, nil
+}
+
+// Login creates an assertion for the given options, as navigator.credentials.get() would on the given origin. If the
+// options do not contain an RP ID, the host of the origin is used. An error is returned if the credential is not
+// allowed by the options or is scoped to a different RP ID.
+func (a *VirtualAuthenticator) Login(origin string, options *protocol.CredentialRequestOptions) (*protocol.AssertionResponse, error) {
+	if len(options.PublicKey.AllowCredentials) > 0 {
+		allowed := false
+		for _, c := range options.PublicKey.AllowCredentials {
+			allowed = allowed || bytes.Equal(c.ID, a.CredentialID)
+		}
+		if !allowed {
+			return nil, fmt.Errorf("credential is not allowed")
+		}
+	}
+
+	rpID, err := relyingPartyID(options.PublicKey.RPID, origin)
+	if err != nil {
+		return nil, err
+	}
+	if a.RPID != "" && rpID != a.RPID {
+		return nil, fmt.Errorf("credential is scoped to RP ID %q, not %q", a.RPID, rpID)
+	}
+
+	clientDataJSON, err := clientData(protocol.ClientDataTypeGet, options.PublicKey.Challenge, origin)
+	if err != nil {
+		return nil, err
+	}
+
+	authData, err := a.authenticatorData(rpID, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := a.sign(authData, clientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	a.incrementSignCount()
+
+	return &protocol.AssertionResponse{
+		PublicKeyCredential: a.publicKeyCredential(),
+		Response: protocol.AuthenticatorAssertionResponse{
+			AuthenticatorResponse: protocol.AuthenticatorResponse{ClientDataJSON: clientDataJSON},
+			AuthenticatorData:     authData,
+			Signature:             sig,
+			UserHandle:            a.UserHandle,
+		},
+	}, nil
+}
+
+// PublicKey returns the credential public key, encoded in COSE_Key format.
+func (a *VirtualAuthenticator) PublicKey() ([]byte, error) {
+	alg, crv, err := a.algorithm()
+	if err != nil {
+		return nil, err
+	}
+
+	// The coordinates must be padded to the size of the curve
+	size := (a.PrivateKey.Curve.Params().BitSize + 7) / 8
+	x, y := make([]byte, size), make([]byte, size)
+	xBytes, yBytes := a.PrivateKey.X.Bytes(), a.PrivateKey.Y.Bytes()
+	copy(x[size-len(xBytes):], xBytes)
+	copy(y[size-len(yBytes):], yBytes)
+
+	return encodeCBOR(map[int64]interface{}{
+		1:  int64(2), // kty: EC2
+		3:  int64(alg),
+		-1: crv,
+		-2: x,
+		-3: y,
+	})
+}
+
+// algorithm returns the COSE algorithm and curve of the private key.
+func (a *VirtualAuthenticator) algorithm() (protocol.COSEAlgorithmIdentifier, int64, error) {
+	if a.PrivateKey == nil {
+		return 0, 0, fmt.Errorf("missing private key")
+	}
+
+	switch a.PrivateKey.Curve {
+	case elliptic.P256():
+		return protocol.ES256, 1, nil
+	case elliptic.P384():
+		return protocol.ES384, 2, nil
+	case elliptic.P521():
+		return protocol.ES512, 3, nil
+	case cose.Secp256k1():
+		return protocol.ES256K, 8, nil
+	}
+	return 0, 0, fmt.Errorf("unsupported curve %s", a.PrivateKey.Curve.Params().Name)
+}
+
+// sign signs the concatenation of authData and the hash of clientDataJSON with the private key.
+func (a *VirtualAuthenticator) sign(authData, clientDataJSON []byte) ([]byte, error) {
+	alg, _, err := a.algorithm()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := crypto.SHA256
+	switch alg {
+	case protocol.ES384:
+		hash = crypto.SHA384
+	case protocol.ES512:
+		hash = crypto.SHA512
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	h := hash.New()
+	h.Write(authData)
+	h.Write(clientDataHash[:])
+
+	return a.PrivateKey.Sign(rand.Reader, h.Sum(nil), hash)
+}
+
+// attestationStatement returns the packed attestation statement over authData and the hash of clientDataJSON, which is
+// a basic attestation if AttestationKey and AttestationCertificates are set, and a self attestation otherwise.
+func (a *VirtualAuthenticator) attestationStatement(alg protocol.COSEAlgorithmIdentifier, authData, clientDataJSON []byte) (map[string]interface{}, error) {
+	if a.AttestationKey == nil || len(a.AttestationCertificates) == 0 {
+		sig, err := a.sign(authData, clientDataJSON)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"alg": int64(alg), "sig": sig}, nil
+	}
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	sig, err := a.AttestationKey.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	x5c := make([]interface{}, len(a.AttestationCertificates))
+	for i, der := range a.AttestationCertificates {
+		x5c[i] = der
+	}
+	return map[string]interface{}{"alg": int64(protocol.ES256), "sig": sig, "x5c": x5c}, nil
+}
+
+// authenticatorData returns the authenticator data for the given RP ID with the given flags set in addition to Flags,
+// followed by the extensions, if any.
+func (a *VirtualAuthenticator) authenticatorData(rpID string, flags protocol.AuthenticatorDataFlags, attestedCredentialData []byte) ([]byte, error) {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	flags |= a.Flags &^ (protocol.AuthenticatorDataFlagHasCredentialData | protocol.AuthenticatorDataFlagHasExtension)
+
+	var extensions []byte
+	if len(a.Extensions) > 0 {
+		var err error
+		if extensions, err = encodeCBOR(a.Extensions); err != nil {
+			return nil, err
+		}
+		flags |= protocol.AuthenticatorDataFlagHasExtension
+	}
+
+	authData := append(rpIDHash[:], byte(flags), 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(authData[33:], a.SignCount)
+	authData = append(authData, attestedCredentialData...)
+	return append(authData, extensions...), nil
+}
+
+func (a *VirtualAuthenticator) incrementSignCount() {
+	if a.SignCount > 0 {
+		a.SignCount++
+	}
+}
+
+func (a *VirtualAuthenticator) publicKeyCredential() protocol.PublicKeyCredential {
+	return protocol.PublicKeyCredential{
+		ID:    base64.RawURLEncoding.EncodeToString(a.CredentialID),
+		RawID: a.CredentialID,
+		Type:  string(protocol.PublicKeyCredentialTypePublicKey),
+	}
+}
+
+// relyingPartyID returns rpID, or the host of origin if it is empty.
+func relyingPartyID(rpID, origin string) (string, error) {
+	if rpID != "" {
+		return rpID, nil
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return "", fmt.Errorf("invalid origin: %v", err)
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("invalid origin %q", origin)
+	}
+	return u.Hostname(), nil
+}
+
+func clientData(typ string, challenge []byte, origin string) ([]byte, error) {
+	return json.Marshal(protocol.CollectedClientData{
+		Type:      typ,
+		Challenge: base64.RawURLEncoding.EncodeToString(challenge),
+		Origin:    origin,
+	})
+}
+
+func encodeCBOR(v interface{}) ([]byte, error) {
+	var b []byte
+	handle := &codec.CborHandle{}
+	handle.Canonical = true
+	if err := codec.NewEncoderBytes(&b, handle).Encode(v); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,116 @@
+package testutil_test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	_ "github.com/keycloud/webauthn/attestation"
+	"github.com/keycloud/webauthn/cose"
+	"github.com/keycloud/webauthn/protocol"
+	"github.com/keycloud/webauthn/testutil"
+	"github.com/keycloud/webauthn/webauthn"
+)
+
+type testUser struct{}
+
+func (testUser) WebAuthID() []byte          { return []byte("user") }
+func (testUser) WebAuthName() string        { return "test" }
+func (testUser) WebAuthDisplayName() string { return "Test" }
+
+func TestVirtualAuthenticator(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithNonStandardAlgorithms())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, curve := range []elliptic.Curve{elliptic.P256(), elliptic.P384(), elliptic.P521(), cose.Secp256k1()} {
+		t.Run(curve.Params().Name, func(t *testing.T) {
+			authenticator, err := testutil.NewVirtualAuthenticator()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if authenticator.PrivateKey, err = ecdsa.GenerateKey(curve, rand.Reader); err != nil {
+				t.Fatal(err)
+			}
+
+			creationOptions, session, err := rp.BeginRegistration(testUser{},
+				webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelectionCriteria{UserVerification: protocol.UserVerificationRequired}),
+				webauthn.WithCredentialAlgorithms([]protocol.COSEAlgorithmIdentifier{protocol.ES256, protocol.ES384, protocol.ES512, protocol.ES256K}),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			attestation, err := authenticator.Register("https://localhost", creationOptions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			credential, err := rp.FinishRegistration(session, attestation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(credential.ID, authenticator.CredentialID) || !bytes.Equal(credential.UserID, []byte("user")) {
+				t.Fatalf("unexpected credential %+v", credential)
+			}
+
+			for i := 0; i < 2; i++ {
+				requestOptions, session, err := rp.BeginLogin(webauthn.WithUserCredentials(credential))
+				if err != nil {
+					t.Fatal(err)
+				}
+				assertion, err := authenticator.Login("https://localhost", requestOptions)
+				if err != nil {
+					t.Fatal(err)
+				}
+				signCount, err := rp.FinishLogin(session, credential, assertion)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := credential.UpdateCounter(signCount); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if credential.SignCount != 3 {
+				t.Fatalf("expected sign count 3, got %d", credential.SignCount)
+			}
+		})
+	}
+
+	t.Run("User not present", func(t *testing.T) {
+		authenticator, err := testutil.NewVirtualAuthenticator()
+		if err != nil {
+			t.Fatal(err)
+		}
+		authenticator.Flags = 0
+
+		options, session, err := rp.BeginRegistration(testUser{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		attestation, err := authenticator.Register("https://localhost", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rp.FinishRegistration(session, attestation); !errors.Is(err, protocol.ErrNoUserPresent) {
+			t.Fatalf("expected ErrNoUserPresent, got %v", err)
+		}
+	})
+
+	t.Run("Credential not allowed", func(t *testing.T) {
+		authenticator, err := testutil.NewVirtualAuthenticator()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		options, _, err := rp.BeginLogin(webauthn.WithAllowCredentialIDs([]byte("other")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := authenticator.Login("https://localhost", options); err == nil {
+			t.Fatal("expected error for credential that is not allowed")
+		}
+	})
+}
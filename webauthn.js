@@ -1,12 +1,23 @@
 class WebAuthn {
-	// Decode a base64 string into a Uint8Array.
+	// Decode a base64url string into a Uint8Array.
 	static _decodeBuffer(value) {
-		return Uint8Array.from(atob(value), c => c.charCodeAt(0));
+		return Uint8Array.from(atob(value.replace(/-/g, '+').replace(/_/g, '/')), c => c.charCodeAt(0));
 	}
 
-	// Encode an ArrayBuffer into a base64 string.
+	// Encode an ArrayBuffer into a base64url string.
 	static _encodeBuffer(value) {
-		return btoa(new Uint8Array(value).reduce((s, byte) => s + String.fromCharCode(byte), ''));
+		return btoa(new Uint8Array(value).reduce((s, byte) => s + String.fromCharCode(byte), ''))
+			.replace(/\+/g, '-')
+			.replace(/\//g, '_')
+			.replace(/=/g, '');
+	}
+
+	// Encode the binary values of the client extension results, such that they can be serialized as JSON.
+	static _encodeExtensionResults(results) {
+		if (results.largeBlob && results.largeBlob.blob) {
+			results.largeBlob.blob = WebAuthn._encodeBuffer(results.largeBlob.blob);
+		}
+		return results;
 	}
 
 	// Checks whether the status returned matches the status given.
@@ -48,9 +59,11 @@ class WebAuthn {
 						rawId: WebAuthn._encodeBuffer(credential.rawId),
 						response: {
 							attestationObject: WebAuthn._encodeBuffer(credential.response.attestationObject),
-							clientDataJSON: WebAuthn._encodeBuffer(credential.response.clientDataJSON)
+							clientDataJSON: WebAuthn._encodeBuffer(credential.response.clientDataJSON),
+							transports: credential.response.getTransports ? credential.response.getTransports() : []
 						},
-						type: credential.type
+						type: credential.type,
+						clientExtensionResults: WebAuthn._encodeExtensionResults(credential.getClientExtensionResults())
 					}),
 				})
 			})
@@ -70,6 +83,9 @@ class WebAuthn {
 						res.publicKey.allowCredentials[i].id = WebAuthn._decodeBuffer(res.publicKey.allowCredentials[i].id);
 					}
 				}
+				if (res.publicKey.extensions && res.publicKey.extensions.largeBlob && res.publicKey.extensions.largeBlob.write) {
+					res.publicKey.extensions.largeBlob.write = WebAuthn._decodeBuffer(res.publicKey.extensions.largeBlob.write);
+				}
 				return res;
 			})
 			.then(res => navigator.credentials.get(res))
@@ -89,7 +105,8 @@ class WebAuthn {
 							signature: WebAuthn._encodeBuffer(credential.response.signature),
 							userHandle: WebAuthn._encodeBuffer(credential.response.userHandle),
 						},
-						type: credential.type
+						type: credential.type,
+						clientExtensionResults: WebAuthn._encodeExtensionResults(credential.getClientExtensionResults())
 					}),
 				})
 			})
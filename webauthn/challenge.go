@@ -0,0 +1,118 @@
+package webauthn
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+// minChallengeSize is the minimum size of a challenge in bytes, as required by the specification.
+const minChallengeSize = 16
+
+// ChallengeGenerator generates the challenges of registration and login ceremonies. Challenges must be at least 16
+// bytes long and should be randomly generated by a trusted source, as they prevent replay attacks.
+type ChallengeGenerator interface {
+	// Generate returns a new challenge.
+	Generate() ([]byte, error)
+}
+
+// ErrChallengeConsumed is returned by RelyingParty.FinishRegistration and RelyingParty.FinishLogin if the challenge of
+// the session has already been used to finish a ceremony, as reported by the ChallengeConsumer.
+var ErrChallengeConsumed = &protocol.Error{
+	Name:        "challenge_consumed",
+	Description: "The challenge has already been used",
+	Hint:        "Start the ceremony again",
+	Code:        http.StatusBadRequest,
+}
+
+// ChallengeConsumer makes challenges single-use, which prevents a response from being replayed with the same
+// SessionData. FinishRegistration and FinishLogin consume the challenge of the session before verifying the response,
+// regardless of whether the verification succeeds. By default, a RelyingParty remembers the consumed challenges in
+// memory, which can be replaced by WithChallengeConsumer or disabled by WithoutChallengeConsumer.
+type ChallengeConsumer interface {
+	// ConsumeChallenge marks the challenge as used. It must return ErrChallengeConsumed if the challenge has already
+	// been consumed or is unknown, and must be safe for concurrent use, such that a challenge is only consumed once.
+	ConsumeChallenge(challenge []byte) error
+}
+
+// randomChallengeGenerator is the default ChallengeGenerator, which generates challenges of protocol.ChallengeSize
+// bytes using crypto/rand.
+type randomChallengeGenerator struct{}
+
+func (randomChallengeGenerator) Generate() ([]byte, error) {
+	return protocol.NewChallenge()
+}
+
+// newChallenge generates a new challenge using the ChallengeGenerator of the RelyingParty.
+func (rp *RelyingParty) newChallenge() (protocol.Challenge, error) {
+	chal, err := rp.challengeGenerator.Generate()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(chal) < minChallengeSize {
+		return nil, fmt.Errorf("challenge of %d bytes is shorter than %d bytes", len(chal), minChallengeSize)
+	}
+
+	return chal, nil
+}
+
+// consumeChallenge consumes the challenge of the session using the ChallengeConsumer of the RelyingParty, if any.
+func (rp *RelyingParty) consumeChallenge(session *SessionData) error {
+	if rp.challengeConsumer == nil {
+		return nil
+	}
+
+	if err := rp.challengeConsumer.ConsumeChallenge(session.Challenge); err != nil {
+		var perr *protocol.Error
+		if errors.As(err, &perr) {
+			return perr
+		}
+		return ErrChallengeConsumed.WithDebug(err.Error()).WithCause(err)
+	}
+	return nil
+}
+
+// memoryChallengeConsumer is the default ChallengeConsumer, which remembers consumed challenges in memory for ttl, the
+// timeout of the ceremonies of the RelyingParty, after which their sessions have expired. If ttl is zero, sessions do
+// not expire and challenges are remembered indefinitely. Since the challenges are only known to a single process,
+// deployments with several servers should use WithChallengeConsumer with a shared store instead.
+type memoryChallengeConsumer struct {
+	clock func() time.Time
+	ttl   time.Duration
+
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+func newMemoryChallengeConsumer(clock func() time.Time, ttl time.Duration) *memoryChallengeConsumer {
+	return &memoryChallengeConsumer{
+		clock: clock,
+		ttl:   ttl,
+		used:  make(map[string]time.Time),
+	}
+}
+
+func (c *memoryChallengeConsumer) ConsumeChallenge(challenge []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock()
+	if c.ttl > 0 {
+		for k, forget := range c.used {
+			if now.After(forget) {
+				delete(c.used, k)
+			}
+		}
+	}
+
+	if _, ok := c.used[string(challenge)]; ok {
+		return ErrChallengeConsumed
+	}
+	c.used[string(challenge)] = now.Add(c.ttl)
+	return nil
+}
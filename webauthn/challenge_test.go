@@ -0,0 +1,61 @@
+package webauthn_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/keycloud/webauthn/webauthn"
+)
+
+type testChallengeGenerator struct {
+	challenge []byte
+	err       error
+}
+
+func (g testChallengeGenerator) Generate() ([]byte, error) {
+	return g.challenge, g.err
+}
+
+func TestChallengeGenerator(t *testing.T) {
+	challenge := bytes.Repeat([]byte{0x42}, 16)
+
+	for _, test := range []struct {
+		name      string
+		generator testChallengeGenerator
+		valid     bool
+	}{
+		{"Valid", testChallengeGenerator{challenge: challenge}, true},
+		{"Too short", testChallengeGenerator{challenge: challenge[:15]}, false},
+		{"Error", testChallengeGenerator{err: fmt.Errorf("unavailable")}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithChallengeGenerator(test.generator))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			registrationOptions, registrationSession, err := rp.BeginRegistration(&testUser{id: []byte("test")})
+			if !test.valid {
+				if err == nil {
+					t.Fatal("expected registration error")
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if !bytes.Equal(registrationOptions.PublicKey.Challenge, challenge) || !bytes.Equal(registrationSession.Challenge, challenge) {
+				t.Fatal("generated challenge is not used for registration")
+			}
+
+			loginOptions, loginSession, err := rp.BeginLogin()
+			if !test.valid {
+				if err == nil {
+					t.Fatal("expected login error")
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if !bytes.Equal(loginOptions.PublicKey.Challenge, challenge) || !bytes.Equal(loginSession.Challenge, challenge) {
+				t.Fatal("generated challenge is not used for login")
+			}
+		})
+	}
+}
@@ -0,0 +1,415 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/keycloud/webauthn/cose"
+	"github.com/keycloud/webauthn/metadata"
+	"github.com/keycloud/webauthn/protocol"
+)
+
+// ErrCounterRollback is returned by Credential.UpdateCounter if the signature counter did not increase, which is a
+// signal that the authenticator may have been cloned.
+var ErrCounterRollback = &protocol.Error{
+	Name:        "counter_rollback",
+	Description: "The signature counter did not increase",
+	Hint:        "The authenticator may have been cloned",
+	Code:        http.StatusUnauthorized,
+}
+
+// ErrCredentialExcluded is returned by RelyingParty.FinishRegistration if the registered credential is one of the
+// excluded credentials, meaning that the authenticator has already been registered. This is the equivalent of the
+// InvalidStateError that is raised by the client if an authenticator contains an excluded credential.
+var ErrCredentialExcluded = &protocol.Error{
+	Name:        "credential_excluded",
+	Description: "The credential has already been registered",
+	Hint:        "Use another authenticator",
+	Code:        http.StatusConflict,
+}
+
+// ErrAlgorithmNotAllowed is returned by RelyingParty.FinishRegistration if the algorithm of the credential public key
+// is not one of the algorithms that were requested, as configured by WithCredentialAlgorithms, or is not supported by
+// the authenticator according to the metadata, as configured by WithMetadataAlgorithms.
+var ErrAlgorithmNotAllowed = &protocol.Error{
+	Name:        "algorithm_not_allowed",
+	Description: "The algorithm of the credential is not allowed",
+	Hint:        "Use an authenticator that supports one of the requested algorithms",
+	Code:        http.StatusBadRequest,
+}
+
+// ErrCredentialNotAllowed is returned by RelyingParty.FinishLogin if the credential is not one of the allowed
+// credentials, as configured by WithAllowCredentials.
+var ErrCredentialNotAllowed = &protocol.Error{
+	Name:        "credential_not_allowed",
+	Description: "The credential is not allowed",
+	Hint:        "Use a credential of the user",
+	Code:        http.StatusUnauthorized,
+}
+
+// ErrUserHandleMismatch is returned by RelyingParty.FinishLogin if the user handle that is returned by the
+// authenticator does not match the user of the credential, or if a discoverable credential without a user ID is used.
+var ErrUserHandleMismatch = &protocol.Error{
+	Name:        "user_handle_mismatch",
+	Description: "The user handle does not match the user of the credential",
+	Code:        http.StatusUnauthorized,
+}
+
+// ErrUntrustedAttestation is returned by RelyingParty.FinishRegistration if the attestation is not trusted, as
+// configured by WithAttestationPolicy.
+var ErrUntrustedAttestation = &protocol.Error{
+	Name:        "untrusted_attestation",
+	Description: "The attestation is not trusted",
+	Hint:        "Use an authenticator that provides a trusted attestation",
+	Code:        http.StatusForbidden,
+}
+
+// ErrAAGUIDNotAllowed is returned by RelyingParty.FinishRegistration if the authenticator model is not allowed to
+// register, as configured by WithAllowedAAGUIDs, WithBlockedAAGUIDs and WithRequireAAGUID.
+var ErrAAGUIDNotAllowed = &protocol.Error{
+	Name:        "aaguid_not_allowed",
+	Description: "The authenticator is not allowed",
+	Hint:        "Use an authenticator of another model",
+	Code:        http.StatusForbidden,
+}
+
+// ErrAuthenticatorStatusNotAllowed is returned by RelyingParty.FinishRegistration if the status of the authenticator in
+// the metadata is not allowed, as configured by WithBlockedStatuses.
+var ErrAuthenticatorStatusNotAllowed = &protocol.Error{
+	Name:        "authenticator_status_not_allowed",
+	Description: "The authenticator is not allowed because of its status",
+	Hint:        "Use an authenticator of another model",
+	Code:        http.StatusForbidden,
+}
+
+// Credential represents a public key credential that has been registered by a RelyingParty. It should be stored by the
+// caller after registration, such that the user can log in with it.
+type Credential struct {
+	// ID is the credential ID, which is used to identify the credential during login.
+	ID []byte `json:"id"`
+	// RPID is the RP ID that the credential is scoped to, which is one of the RP IDs that are set by
+	// WithRelyingPartyIDs, or the RP ID that is passed to NewRelyingParty. On login, the credential is only accepted
+	// for this RP ID. It is empty for credentials that have been stored without it, which are accepted for any of the
+	// RP IDs.
+	RPID string `json:"rpId,omitempty"`
+	// RawPublicKey is the credential public key, encoded in COSE_Key format.
+	RawPublicKey []byte `json:"publicKey"`
+	// UserID is the ID of the user that registered the credential, which is returned as the user handle on login.
+	UserID []byte `json:"userId,omitempty"`
+	// AAGUID is the AAGUID of the authenticator that created the credential.
+	AAGUID []byte `json:"aaguid"`
+	// SignCount is the last known signature counter of the credential.
+	SignCount uint32 `json:"signCount"`
+	// Transports are the transports that the authenticator supports, as reported by the client at registration. They
+	// may be passed as hints in the allowed credentials on login.
+	Transports []protocol.AuthenticatorTransport `json:"transports,omitempty"`
+	// ResidentKey indicates whether the credential is a client-side discoverable credential, as reported by the
+	// credProps extension. It is false if the client did not report it, so it is only reliable if it is true.
+	ResidentKey bool `json:"residentKey,omitempty"`
+	// LargeBlob indicates whether the credential supports storing large blobs, as reported by the largeBlob extension.
+	LargeBlob bool `json:"largeBlob,omitempty"`
+	// CredentialProtection is the protection policy of the credential, as reported by the credProtect extension. It is
+	// empty if the authenticator did not report it, in which case authenticators commonly do not require user
+	// verification.
+	CredentialProtection protocol.CredentialProtectionPolicy `json:"credentialProtection,omitempty"`
+	// MinPINLength is the minimum PIN length of the authenticator, as reported by the minPinLength extension. It is 0
+	// if the authenticator did not report it.
+	MinPINLength int `json:"minPinLength,omitempty"`
+	// BackupEligible indicates whether the credential can be backed up, for instance because it is a passkey that is
+	// synced across devices. It is false for credentials that are bound to a single device, such as security keys.
+	BackupEligible bool `json:"backupEligible,omitempty"`
+	// BackupState indicates whether the credential was backed up at registration.
+	BackupState bool `json:"backupState,omitempty"`
+	// AttestationType is the type of the attestation that was provided at registration.
+	AttestationType protocol.AttestationType `json:"attestationType,omitempty"`
+	// AttestationTrustPath is the attestation trust path that was provided at registration, of which the first
+	// certificate is the attestation certificate. If attestation roots are configured, it is the verified chain up to
+	// one of the roots. It is not stored when the credential is JSON encoded.
+	AttestationTrustPath []*x509.Certificate `json:"-"`
+	// SerialNumber is the serial number of the authenticator, as contained in the subject of the attestation
+	// certificate. It is only set if enterprise attestation was requested and the authenticator provided it.
+	SerialNumber string `json:"serialNumber,omitempty"`
+	// AuthenticatorStatus is the status of the authenticator in the metadata at registration, for instance for
+	// logging. It is empty if no metadata service is configured or the authenticator is not listed. It is not stored.
+	AuthenticatorStatus metadata.AuthenticatorStatus `json:"-"`
+	// Authenticator describes the authenticator model, as listed in the metadata at registration, such that it can be
+	// shown to the user. It is nil if no metadata service is configured or the authenticator is not listed. It is not
+	// stored.
+	Authenticator *AuthenticatorModel `json:"-"`
+}
+
+// AuthenticatorModel describes an authenticator model, as listed in the metadata.
+type AuthenticatorModel struct {
+	// Name is the description of the authenticator in English, for instance "YubiKey 5 Series with NFC". It is empty
+	// if the metadata does not contain a metadata statement.
+	Name string `json:"name,omitempty"`
+	// Icon is the icon of the authenticator, encoded as a data: URL. It may be empty.
+	Icon string `json:"icon,omitempty"`
+	// AuthenticationAlgorithms contains the authentication algorithms supported by the authenticator, for instance
+	// "secp256r1_ecdsa_sha256_raw".
+	AuthenticationAlgorithms []string `json:"authenticationAlgorithms,omitempty"`
+	// CertificationLevel is the current certification status of the authenticator, for instance
+	// metadata.StatusFIDOCertifiedL1. It is empty if the authenticator has never been certified.
+	CertificationLevel metadata.AuthenticatorStatus `json:"certificationLevel,omitempty"`
+}
+
+// newAuthenticatorModel returns the description of the authenticator model of a metadata entry.
+func newAuthenticatorModel(entry *metadata.Entry) *AuthenticatorModel {
+	authenticator := &AuthenticatorModel{CertificationLevel: entry.CertificationLevel()}
+	if statement := entry.MetadataStatement; statement != nil {
+		authenticator.Name = statement.Description
+		authenticator.Icon = statement.Icon
+		authenticator.AuthenticationAlgorithms = statement.AuthenticationAlgorithms
+	}
+	return authenticator
+}
+
+// UpdateCounter updates the signature counter with the counter of a new assertion, as returned by
+// RelyingParty.FinishLogin. If the new counter is not greater than the stored counter, the counter is not updated and
+// ErrCounterRollback is returned, in which case the Relying Party may decide whether to reject the login. Authenticators
+// that do not implement a signature counter always report 0, which is not regarded as a rollback. This includes most
+// platform authenticators and synced passkeys, of which clones can not be detected by the counter, so Relying Parties
+// should rely on BackupEligible and BackupState instead to judge whether the credential is bound to a single device.
+func (c *Credential) UpdateCounter(newCount uint32) error {
+	// 17. If the signature counter value authData.signCount is nonzero or the value stored in conjunction with
+	// credential’s id attribute is nonzero, then the signature counter value authData.signCount must be greater than
+	// the signature counter value stored in conjunction with credential’s id attribute.
+	if newCount == 0 && c.SignCount == 0 {
+		return nil
+	}
+	if newCount <= c.SignCount {
+		return ErrCounterRollback
+	}
+
+	c.SignCount = newCount
+	return nil
+}
+
+// PublicKey returns the credential public key, which is an *ecdsa.PublicKey, an *rsa.PublicKey, an ed25519.PublicKey
+// or a cose.Ed448PublicKey, such that it can be used with the crypto packages. An error is returned if the stored key is
+// invalid.
+func (c *Credential) PublicKey() (crypto.PublicKey, error) {
+	return cose.ParseCOSE(c.RawPublicKey)
+}
+
+// JWK returns the credential public key as a JSON Web Key, for instance to verify signatures of the credential with a
+// JOSE library.
+func (c *Credential) JWK() (*cose.JSONWebKey, error) {
+	return cose.JWK(c.RawPublicKey)
+}
+
+// Descriptor returns the descriptor of the credential, including its transports as hints for the client. It may be
+// passed to WithAllowCredentials and WithExcludeCredentials.
+func (c *Credential) Descriptor() protocol.PublicKeyCredentialDescriptor {
+	return protocol.PublicKeyCredentialDescriptor{
+		Type:      protocol.PublicKeyCredentialTypePublicKey,
+		ID:        c.ID,
+		Transport: c.Transports,
+	}
+}
+
+// credentialFormatVersion is the version of the binary format of Credential. Version 2 added the credential
+// protection policy, version 3 the RP ID and version 4 the minimum PIN length.
+const credentialFormatVersion = 4
+
+// Flags of the binary format of Credential.
+const (
+	credentialFlagResidentKey = 1 << iota
+	credentialFlagLargeBlob
+	credentialFlagBackupEligible
+	credentialFlagBackupState
+)
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface, such that the credential can be stored. The
+// attestation trust path is not stored.
+func (c *Credential) MarshalBinary() ([]byte, error) {
+	b := []byte{credentialFormatVersion}
+	for _, field := range [][]byte{c.ID, c.RawPublicKey, c.UserID, c.AAGUID} {
+		b = appendBytes(b, field)
+	}
+	var signCount [4]byte
+	binary.BigEndian.PutUint32(signCount[:], c.SignCount)
+	b = append(b, signCount[:]...)
+
+	var flags byte
+	if c.ResidentKey {
+		flags |= credentialFlagResidentKey
+	}
+	if c.LargeBlob {
+		flags |= credentialFlagLargeBlob
+	}
+	if c.BackupEligible {
+		flags |= credentialFlagBackupEligible
+	}
+	if c.BackupState {
+		flags |= credentialFlagBackupState
+	}
+	b = append(b, flags)
+
+	b = appendBytes(b, []byte(c.AttestationType))
+	b = appendBytes(b, []byte(c.SerialNumber))
+	b = appendUvarint(b, uint64(len(c.Transports)))
+	for _, transport := range c.Transports {
+		b = appendBytes(b, []byte(transport))
+	}
+	b = appendBytes(b, []byte(c.CredentialProtection))
+	b = appendBytes(b, []byte(c.RPID))
+	b = appendUvarint(b, uint64(c.MinPINLength))
+
+	return b, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface, such that a credential that is stored using
+// MarshalBinary can be loaded. An error is returned if the data is invalid, including the public key.
+func (c *Credential) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] == 0 || data[0] > credentialFormatVersion {
+		return fmt.Errorf("unsupported credential format")
+	}
+	version := data[0]
+	r := &credentialReader{data: data[1:]}
+
+	credential := Credential{
+		ID:           r.bytes(),
+		RawPublicKey: r.bytes(),
+		UserID:       r.bytes(),
+		AAGUID:       r.bytes(),
+		SignCount:    r.uint32(),
+	}
+
+	flags := r.byte()
+	credential.ResidentKey = flags&credentialFlagResidentKey != 0
+	credential.LargeBlob = flags&credentialFlagLargeBlob != 0
+	credential.BackupEligible = flags&credentialFlagBackupEligible != 0
+	credential.BackupState = flags&credentialFlagBackupState != 0
+
+	credential.AttestationType = protocol.AttestationType(r.bytes())
+	credential.SerialNumber = string(r.bytes())
+	for n := r.uvarint(); n > 0 && r.err == nil; n-- {
+		credential.Transports = append(credential.Transports, protocol.AuthenticatorTransport(r.bytes()))
+	}
+	if version >= 2 {
+		credential.CredentialProtection = protocol.CredentialProtectionPolicy(r.bytes())
+	}
+	if version >= 3 {
+		credential.RPID = string(r.bytes())
+	}
+	if version >= 4 {
+		credential.MinPINLength = int(r.uvarint())
+	}
+
+	if r.err != nil {
+		return fmt.Errorf("invalid credential: %v", r.err)
+	}
+	if len(r.data) > 0 {
+		return fmt.Errorf("invalid credential: trailing data")
+	}
+	if _, err := cose.ParseCOSE(credential.RawPublicKey); err != nil {
+		return fmt.Errorf("invalid credential public key: %v", err)
+	}
+
+	*c = credential
+	return nil
+}
+
+// jsonCredential is Credential without its methods, to encode it using its struct tags.
+type jsonCredential Credential
+
+// MarshalJSON implements the json.Marshaler interface. The binary values are encoded as base64, like other byte
+// slices. The attestation trust path is not stored.
+func (c *Credential) MarshalJSON() ([]byte, error) {
+	return json.Marshal((*jsonCredential)(c))
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface, such that a credential that is stored using MarshalJSON can
+// be loaded. An error is returned if the public key is invalid.
+func (c *Credential) UnmarshalJSON(data []byte) error {
+	var credential jsonCredential
+	if err := json.Unmarshal(data, &credential); err != nil {
+		return err
+	}
+	if _, err := cose.ParseCOSE(credential.RawPublicKey); err != nil {
+		return fmt.Errorf("invalid credential public key: %v", err)
+	}
+
+	*c = Credential(credential)
+	return nil
+}
+
+// appendUvarint appends the varint encoding of v to data.
+func appendUvarint(data []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	return append(data, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+// appendBytes appends b to data, prefixed with its length.
+func appendBytes(data, b []byte) []byte {
+	return append(appendUvarint(data, uint64(len(b))), b...)
+}
+
+// credentialReader reads the fields of the binary format of Credential. Once an error occurs, it is stored in err and
+// the remaining fields are read as zero values.
+type credentialReader struct {
+	data []byte
+	err  error
+}
+
+func (r *credentialReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.err = fmt.Errorf("invalid length")
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *credentialReader) bytes() []byte {
+	n := r.uvarint()
+	if r.err != nil {
+		return nil
+	}
+	if n > uint64(len(r.data)) {
+		r.err = fmt.Errorf("truncated data")
+		return nil
+	}
+	if n == 0 {
+		return nil
+	}
+	b := make([]byte, n)
+	copy(b, r.data)
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *credentialReader) uint32() uint32 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.data) < 4 {
+		r.err = fmt.Errorf("truncated data")
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return v
+}
+
+func (r *credentialReader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.data) < 1 {
+		r.err = fmt.Errorf("truncated data")
+		return 0
+	}
+	v := r.data[0]
+	r.data = r.data[1:]
+	return v
+}
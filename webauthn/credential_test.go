@@ -0,0 +1,209 @@
+package webauthn_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/keycloud/webauthn/protocol"
+	"github.com/keycloud/webauthn/testutil"
+	"github.com/keycloud/webauthn/webauthn"
+)
+
+func TestCredentialUpdateCounter(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		stored   uint32
+		newCount uint32
+		err      error
+		expected uint32
+	}{
+		{"Increased", 1, 2, nil, 2},
+		{"Increased from zero", 0, 1, nil, 1},
+		{"Equal", 2, 2, webauthn.ErrCounterRollback, 2},
+		{"Decreased", 2, 1, webauthn.ErrCounterRollback, 2},
+		{"Reset to zero", 2, 0, webauthn.ErrCounterRollback, 2},
+		{"No counter", 0, 0, nil, 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c := &webauthn.Credential{SignCount: test.stored}
+			if err := c.UpdateCounter(test.newCount); err != test.err {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if c.SignCount != test.expected {
+				t.Fatalf("expected sign count %d, got %d", test.expected, c.SignCount)
+			}
+		})
+	}
+}
+
+func TestCredentialMarshal(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := strings.Replace(loginRegistrationResponse, `"response":{`, `"response":{"transports":["usb","nfc"],`, 1)
+	credential := register(t, rp, loginRegistrationRequest, response)
+	if !reflect.DeepEqual(credential.Transports, []protocol.AuthenticatorTransport{protocol.AuthenticatorTransportUSB, protocol.AuthenticatorTransportNFC}) {
+		t.Fatalf("unexpected transports %v", credential.Transports)
+	}
+
+	credential.UserID, credential.SignCount, credential.ResidentKey, credential.BackupState = []byte("test"), 42, true, true
+	credential.CredentialProtection = protocol.CredentialProtectionUserVerificationRequired
+	credential.MinPINLength = 8
+	credential.AttestationTrustPath = nil
+
+	t.Run("Binary", func(t *testing.T) {
+		b, err := credential.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var c webauthn.Credential
+		if err := c.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(&c, credential) {
+			t.Fatalf("expected %+v, got %+v", credential, &c)
+		}
+
+		for _, data := range [][]byte{nil, {0x04}, {0x05}, b[:len(b)-1], append(b, 0x00)} {
+			if err := c.UnmarshalBinary(data); err == nil {
+				t.Fatalf("expected error for %x", data)
+			}
+		}
+
+		// Version 3 does not contain the minimum PIN length, version 2 neither the RP ID and version 1 neither the
+		// credential protection policy
+		v3 := append([]byte{0x03}, b[1:len(b)-1]...)
+		if err := c.UnmarshalBinary(v3); err != nil {
+			t.Fatal(err)
+		}
+		if c.MinPINLength != 0 || c.RPID != credential.RPID {
+			t.Fatalf("unexpected version 3 credential %+v", &c)
+		}
+
+		v2 := append([]byte{0x02}, v3[1:len(v3)-len(credential.RPID)-1]...)
+		if err := c.UnmarshalBinary(v2); err != nil {
+			t.Fatal(err)
+		}
+		if c.RPID != "" || c.CredentialProtection != credential.CredentialProtection {
+			t.Fatalf("unexpected version 2 credential %+v", &c)
+		}
+
+		v1 := append([]byte{0x01}, v2[1:len(v2)-len(credential.CredentialProtection)-1]...)
+		if err := c.UnmarshalBinary(v1); err != nil {
+			t.Fatal(err)
+		}
+		if c.CredentialProtection != "" || c.SignCount != credential.SignCount {
+			t.Fatalf("unexpected version 1 credential %+v", &c)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		b, err := json.Marshal(credential)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var c webauthn.Credential
+		if err := json.Unmarshal(b, &c); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(&c, credential) {
+			t.Fatalf("expected %+v, got %+v", credential, &c)
+		}
+	})
+
+	t.Run("Invalid public key", func(t *testing.T) {
+		invalid := *credential
+		invalid.RawPublicKey = []byte{0xa0}
+
+		b, err := invalid.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := new(webauthn.Credential).UnmarshalBinary(b); err == nil {
+			t.Fatal("expected error for invalid public key")
+		}
+
+		if b, err = json.Marshal(&invalid); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(b, new(webauthn.Credential)); err == nil {
+			t.Fatal("expected error for invalid public key")
+		}
+	})
+}
+
+func TestCredentialDescriptor(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unknown transports are retained, since they may be supported by future clients
+	response := strings.Replace(loginRegistrationResponse, `"response":{`, `"response":{"transports":["hybrid","internal","future"],`, 1)
+	credential := register(t, rp, loginRegistrationRequest, response)
+
+	options, _, err := rp.BeginLogin(webauthn.WithAllowCredentials(credential.Descriptor()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(options.PublicKey.AllowCredentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"type":"public-key","id":"lLrr-NW18YOnjyhyByT6d3o9YkCdFCewrdzyJLGXJb0","transports":["hybrid","internal","future"]}]`
+	if string(b) != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+}
+
+func TestCredentialPublicKey(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator, err := testutil.NewVirtualAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestation, err := authenticator.Register("https://localhost", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := rp.FinishRegistration(session, attestation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := credential.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(key, &authenticator.PrivateKey.PublicKey) {
+		t.Fatalf("expected public key %v, got %v", &authenticator.PrivateKey.PublicKey, key)
+	}
+
+	jwk, err := credential.JWK()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jwk.Kty != "EC" || jwk.Crv != "P-256" || jwk.Alg != "ES256" {
+		t.Fatalf("unexpected key %+v", jwk)
+	}
+
+	credential.RawPublicKey = []byte{0xa0}
+	if _, err := credential.PublicKey(); err == nil {
+		t.Fatal("expected error for invalid public key")
+	}
+}
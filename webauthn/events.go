@@ -0,0 +1,79 @@
+package webauthn
+
+import (
+	"github.com/keycloud/webauthn/protocol"
+)
+
+// EventType is the type of an Event.
+type EventType string
+
+const (
+	// EventRegistrationStarted is emitted by BeginRegistration.
+	EventRegistrationStarted EventType = "registration_started"
+	// EventRegistrationSucceeded is emitted by FinishRegistration if the credential is registered.
+	EventRegistrationSucceeded EventType = "registration_succeeded"
+	// EventRegistrationFailed is emitted by FinishRegistration if the registration is rejected.
+	EventRegistrationFailed EventType = "registration_failed"
+	// EventLoginStarted is emitted by BeginLogin.
+	EventLoginStarted EventType = "login_started"
+	// EventLoginSucceeded is emitted by FinishLogin, FinishDiscoverableLogin and FinishPaymentConfirmation if the
+	// assertion is valid.
+	EventLoginSucceeded EventType = "login_succeeded"
+	// EventLoginFailed is emitted by FinishLogin, FinishDiscoverableLogin and FinishPaymentConfirmation if the
+	// assertion is rejected.
+	EventLoginFailed EventType = "login_failed"
+)
+
+// Event describes a step of a ceremony, as passed to the EventHandler that is set by WithEventHandler. The fields are
+// only set if they are known at the step, for instance the AAGUID is not known if the attestation could not be parsed.
+type Event struct {
+	// Type is the type of the event.
+	Type EventType
+	// UserID is the ID of the user that is being registered or logged in, if known.
+	UserID []byte
+	// CredentialID is the ID of the credential that is being registered or used to log in.
+	CredentialID []byte
+	// AAGUID is the AAGUID of the authenticator.
+	AAGUID []byte
+	// AttestationType is the type of the attestation of a registration.
+	AttestationType protocol.AttestationType
+	// SignCount is the signature counter of the assertion of a successful login.
+	SignCount uint32
+	// Reason is the name of the error of a failed ceremony, for instance "signature_invalid", such that failures can
+	// be aggregated by their cause.
+	Reason string
+	// Step is the step of the attestation verification that failed, if the registration failed because the
+	// attestation is invalid.
+	Step protocol.AttestationStep
+	// Err is the error of a failed ceremony.
+	Err error
+}
+
+// EventHandler receives the events of the ceremonies of a RelyingParty, for instance to log them or to record metrics.
+// It is called synchronously, so it should not block, and must be safe for concurrent use.
+type EventHandler interface {
+	// HandleEvent handles an event. The event must not be modified or retained after HandleEvent returns.
+	HandleEvent(e *Event)
+}
+
+// EventHandlerFunc is an EventHandler that calls the function.
+type EventHandlerFunc func(e *Event)
+
+// HandleEvent calls f(e).
+func (f EventHandlerFunc) HandleEvent(e *Event) {
+	f(e)
+}
+
+// emit passes the event to the EventHandler of the RelyingParty, if any. If err is set, the reason and step of the
+// event are set from it.
+func (rp *RelyingParty) emit(e *Event, err error) {
+	if rp.eventHandler == nil {
+		return
+	}
+
+	if err != nil {
+		pe := protocol.ToWebAuthnError(err)
+		e.Reason, e.Step, e.Err = pe.Name, pe.Step, err
+	}
+	rp.eventHandler.HandleEvent(e)
+}
@@ -0,0 +1,86 @@
+package webauthn_test
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/keycloud/webauthn/protocol"
+	"github.com/keycloud/webauthn/testutil"
+	"github.com/keycloud/webauthn/webauthn"
+)
+
+func TestEventHandler(t *testing.T) {
+	var mu sync.Mutex
+	var events []webauthn.Event
+	handler := webauthn.EventHandlerFunc(func(e *webauthn.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, *e)
+	})
+
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithEventHandler(handler), webauthn.WithoutChallengeConsumer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator, err := testutil.NewVirtualAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestation, err := authenticator.Register("https://localhost", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := rp.FinishRegistration(session, attestation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The challenge does not match the session
+	if _, err := rp.FinishRegistration(&webauthn.SessionData{Challenge: []byte("invalid")}, attestation); err == nil {
+		t.Fatal("expected error for invalid challenge")
+	}
+
+	loginOptions, loginSession, err := rp.BeginLogin(webauthn.WithUserCredentials(credential))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertion, err := authenticator.Login("https://localhost", loginOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.FinishLogin(loginSession, credential, assertion); err != nil {
+		t.Fatal(err)
+	}
+	signCount := authenticator.SignCount - 1
+
+	// The signature does not match the assertion
+	assertion.Response.Signature[len(assertion.Response.Signature)-1] ^= 0xff
+	if _, err := rp.FinishLogin(&webauthn.SessionData{Challenge: loginSession.Challenge}, credential, assertion); err == nil {
+		t.Fatal("expected error for invalid signature")
+	}
+
+	expected := []webauthn.Event{
+		{Type: webauthn.EventRegistrationStarted, UserID: []byte("test")},
+		{Type: webauthn.EventRegistrationSucceeded, UserID: []byte("test"), CredentialID: credential.ID, AAGUID: credential.AAGUID, AttestationType: protocol.AttestationTypeSelf},
+		{Type: webauthn.EventRegistrationFailed, CredentialID: credential.ID, AAGUID: credential.AAGUID, Reason: protocol.ErrInvalidChallenge.Name},
+		{Type: webauthn.EventLoginStarted},
+		{Type: webauthn.EventLoginSucceeded, UserID: []byte("test"), CredentialID: credential.ID, AAGUID: credential.AAGUID, SignCount: signCount},
+		{Type: webauthn.EventLoginFailed, UserID: []byte("test"), CredentialID: credential.ID, AAGUID: credential.AAGUID, Reason: protocol.ErrInvalidSignature.Name},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d: %+v", len(expected), len(events), events)
+	}
+	for i, e := range events {
+		x := expected[i]
+		if e.Type != x.Type || !bytes.Equal(e.UserID, x.UserID) || !bytes.Equal(e.CredentialID, x.CredentialID) || !bytes.Equal(e.AAGUID, x.AAGUID) ||
+			e.AttestationType != x.AttestationType || e.SignCount != x.SignCount || e.Reason != x.Reason || (e.Err == nil) != (x.Reason == "") {
+			t.Fatalf("expected event %d to be %+v, got %+v", i, x, e)
+		}
+	}
+}
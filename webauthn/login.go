@@ -2,12 +2,15 @@ package webauthn
 
 import (
 	"bytes"
+	"context"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"net/http"
 
+	"github.com/keycloud/webauthn/facets"
 	"github.com/keycloud/webauthn/protocol"
 )
 
@@ -185,3 +188,351 @@ func (w *WebAuthn) FinishLogin(r *http.Request, rw http.ResponseWriter, user Use
 
 	return authr
 }
+
+// LoginOption configures the options that are passed to navigator.credentials.get() by RelyingParty.BeginLogin.
+type LoginOption func(*protocol.PublicKeyCredentialRequestOptions)
+
+// WithUserVerification sets the user verification requirement for login. If it is required, FinishLogin rejects
+// assertions of which the UV flag is not set with protocol.ErrUserNotVerified. If it is preferred or discouraged,
+// assertions are accepted regardless of the UV flag.
+func WithUserVerification(requirement protocol.UserVerificationRequirement) LoginOption {
+	return func(o *protocol.PublicKeyCredentialRequestOptions) {
+		o.UserVerification = requirement
+	}
+}
+
+// WithAllowCredentials sets the credentials that may be used for login, for instance the credentials of a user that
+// has been identified by its username. FinishLogin rejects assertions of other credentials with
+// ErrCredentialNotAllowed. If no credentials are set, any discoverable credential may be used, which allows
+// usernameless login.
+func WithAllowCredentials(credentials ...protocol.PublicKeyCredentialDescriptor) LoginOption {
+	return func(o *protocol.PublicKeyCredentialRequestOptions) {
+		o.AllowCredentials = append(o.AllowCredentials, credentials...)
+	}
+}
+
+// WithAllowCredentialIDs sets the IDs of the credentials that may be used for login, like WithAllowCredentials.
+func WithAllowCredentialIDs(ids ...[]byte) LoginOption {
+	return func(o *protocol.PublicKeyCredentialRequestOptions) {
+		for _, id := range ids {
+			o.AllowCredentials = append(o.AllowCredentials, protocol.PublicKeyCredentialDescriptor{
+				Type: protocol.PublicKeyCredentialTypePublicKey,
+				ID:   id,
+			})
+		}
+	}
+}
+
+// WithUserCredentials sets the stored credentials of the user as the credentials that may be used for login, like
+// WithAllowCredentials. The transports of each credential are passed as hints, such that the client can directly use
+// the right transport, for instance the platform authenticator. Credentials without transports are passed without
+// hints.
+func WithUserCredentials(credentials ...*Credential) LoginOption {
+	return func(o *protocol.PublicKeyCredentialRequestOptions) {
+		for _, credential := range credentials {
+			o.AllowCredentials = append(o.AllowCredentials, credential.Descriptor())
+		}
+	}
+}
+
+// WithLoginHints sets the hints as to which authenticators the client should guide the user to during login, like
+// WithHints does for registration.
+func WithLoginHints(hints []protocol.PublicKeyCredentialHint) LoginOption {
+	return func(o *protocol.PublicKeyCredentialRequestOptions) {
+		o.Hints = knownHints(hints)
+	}
+}
+
+// WithLargeBlobRead requests the largeBlob extension to read the blob associated with the credential. The blob is
+// returned by the LargeBlob method of the client extension results of the assertion response.
+func WithLargeBlobRead() LoginOption {
+	return func(o *protocol.PublicKeyCredentialRequestOptions) {
+		if o.Extensions == nil {
+			o.Extensions = make(protocol.AuthenticationExtensionsClientInputs)
+		}
+		o.Extensions["largeBlob"] = &protocol.LargeBlobInputs{Read: true}
+	}
+}
+
+// WithLargeBlobWrite requests the largeBlob extension to associate the given blob with the credential. Whether the
+// blob was written is returned by the LargeBlob method of the client extension results of the assertion response.
+func WithLargeBlobWrite(blob []byte) LoginOption {
+	return func(o *protocol.PublicKeyCredentialRequestOptions) {
+		if o.Extensions == nil {
+			o.Extensions = make(protocol.AuthenticationExtensionsClientInputs)
+		}
+		o.Extensions["largeBlob"] = &protocol.LargeBlobInputs{Write: blob}
+	}
+}
+
+// BeginLogin starts the login ceremony. The returned options should be passed to navigator.credentials.get(), for
+// instance via JSON over HTTP. The returned SessionData should be stored server-side and passed to FinishLogin once
+// the client responds.
+func (rp *RelyingParty) BeginLogin(opts ...LoginOption) (*protocol.CredentialRequestOptions, *SessionData, error) {
+	chal, err := rp.newChallenge()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	options := &protocol.CredentialRequestOptions{
+		PublicKey: protocol.PublicKeyCredentialRequestOptions{
+			Challenge: chal,
+			Timeout:   rp.timeout,
+			RPID:      rp.id,
+		},
+	}
+
+	if rp.appID != "" {
+		options.PublicKey.Extensions = protocol.AuthenticationExtensionsClientInputs{
+			"appid": rp.appID,
+		}
+	}
+
+	for _, opt := range opts {
+		opt(&options.PublicKey)
+	}
+
+	session := &SessionData{
+		Challenge:        chal,
+		UserVerification: options.PublicKey.UserVerification,
+		Expires:          rp.expires(options.PublicKey.Timeout),
+	}
+	for _, credential := range options.PublicKey.AllowCredentials {
+		session.AllowCredentials = append(session.AllowCredentials, credential.ID)
+	}
+
+	rp.emit(&Event{Type: EventLoginStarted}, nil)
+
+	return options, session, nil
+}
+
+// FinishLogin finishes the login ceremony that has been started by BeginLogin. The session must be the SessionData
+// that was returned by BeginLogin, credential the stored credential identified by the raw ID of resp and resp the
+// response of navigator.credentials.get(). If the response is valid, the new signature counter is returned, which
+// should be stored with the credential using Credential.UpdateCounter.
+//
+// Like FinishRegistration, the challenge of the session is consumed before the response is verified, so a session can
+// only be finished once and a replay results in ErrChallengeConsumed.
+func (rp *RelyingParty) FinishLogin(session *SessionData, credential *Credential, resp *protocol.AssertionResponse) (uint32, error) {
+	return rp.FinishLoginContext(context.Background(), session, credential, resp)
+}
+
+// FinishLoginContext is like FinishLogin, but the resolution of the trusted facets of the AppID is aborted once ctx is
+// done, in which case the error of ctx is returned.
+func (rp *RelyingParty) FinishLoginContext(ctx context.Context, session *SessionData, credential *Credential, resp *protocol.AssertionResponse) (uint32, error) {
+	result, err := rp.finishLogin(ctx, session, credential, resp, nil)
+	if err != nil {
+		return 0, err
+	}
+	return result.SignCount, nil
+}
+
+// FinishLoginResult is like FinishLogin, but returns a LoginResult, which contains the flags and the extension outputs
+// of the assertion besides the new signature counter. Invalid outputs of the uvm and devicePubKey extensions result in
+// protocol.ErrInvalidRequest.
+func (rp *RelyingParty) FinishLoginResult(session *SessionData, credential *Credential, resp *protocol.AssertionResponse) (*LoginResult, error) {
+	return rp.FinishLoginResultContext(context.Background(), session, credential, resp)
+}
+
+// FinishLoginResultContext is like FinishLoginResult, but aborts like FinishLoginContext once ctx is done.
+func (rp *RelyingParty) FinishLoginResultContext(ctx context.Context, session *SessionData, credential *Credential, resp *protocol.AssertionResponse) (*LoginResult, error) {
+	return rp.finishLogin(ctx, session, credential, resp, nil)
+}
+
+// finishLogin finishes the login ceremony like FinishLoginResult. If payment is set, the assertion must be a Secure
+// Payment Confirmation assertion of the payment.
+func (rp *RelyingParty) finishLogin(ctx context.Context, session *SessionData, credential *Credential, resp *protocol.AssertionResponse, payment *protocol.CollectedClientPaymentData) (*LoginResult, error) {
+	e := &Event{Type: EventLoginSucceeded}
+	if credential != nil {
+		e.UserID, e.CredentialID, e.AAGUID = credential.UserID, credential.ID, credential.AAGUID
+	} else if resp != nil {
+		e.CredentialID = resp.RawID
+	}
+
+	var result *LoginResult
+	p, err := rp.verifyLogin(ctx, session, credential, resp, payment)
+	if err == nil {
+		e.SignCount = p.Response.AuthData.SignCount
+		result, err = newLoginResult(p)
+	}
+	if err == nil && rp.credentialStore != nil {
+		err = rp.saveCounter(credential, result.SignCount)
+		if errors.Is(err, ErrCounterRollback) && rp.allowCounterRollback {
+			result.CounterRollback, err = true, nil
+		}
+	}
+	if err != nil {
+		e.Type = EventLoginFailed
+		result = nil
+	}
+	rp.emit(e, err)
+
+	return result, err
+}
+
+// verifyLogin verifies the assertion of a login ceremony for finishLogin.
+func (rp *RelyingParty) verifyLogin(ctx context.Context, session *SessionData, credential *Credential, resp *protocol.AssertionResponse, payment *protocol.CollectedClientPaymentData) (*protocol.ParsedAssertionResponse, error) {
+	if session == nil || len(session.Challenge) == 0 {
+		return nil, protocol.ErrInvalidRequest.WithDebug("missing session data")
+	}
+	if session.expired(rp.clock()) {
+		return nil, ErrCeremonyExpired
+	}
+	if err := rp.consumeChallenge(session); err != nil {
+		return nil, err
+	}
+	if credential == nil {
+		return nil, protocol.ErrInvalidRequest.WithDebug("missing credential")
+	}
+	if resp == nil {
+		return nil, protocol.ErrInvalidRequest.WithDebug("missing assertion response")
+	}
+
+	if !bytes.Equal(resp.RawID, credential.ID) {
+		return nil, protocol.ErrInvalidRequest.WithDebug("assertion is not for the given credential")
+	}
+
+	// If the allowed credentials were set, verify that the credential is one of them
+	if len(session.AllowCredentials) > 0 && !containsCredentialID(session.AllowCredentials, resp.RawID) {
+		return nil, ErrCredentialNotAllowed
+	}
+
+	// If the user was not identified before the ceremony, a discoverable credential is used, which must return the
+	// user handle of the user of the credential. Otherwise, verify that the user handle is the user of the credential
+	// if both are known.
+	userHandle := resp.Response.UserHandle
+	if len(session.AllowCredentials) == 0 {
+		if len(userHandle) == 0 {
+			return nil, protocol.ErrInvalidRequest.WithDebug("missing user handle for discoverable credential")
+		}
+		if len(credential.UserID) == 0 {
+			return nil, ErrUserHandleMismatch.WithDebug("missing user ID of discoverable credential")
+		}
+		if !bytes.Equal(userHandle, credential.UserID) {
+			return nil, ErrUserHandleMismatch
+		}
+	} else if len(userHandle) > 0 && len(credential.UserID) > 0 && !bytes.Equal(userHandle, credential.UserID) {
+		return nil, ErrUserHandleMismatch
+	}
+
+	// The origin is only known after parsing the client data, which is parsed again by VerifyAssertion
+	var clientData protocol.CollectedClientData
+	if err := json.Unmarshal(resp.Response.ClientDataJSON, &clientData); err != nil {
+		return nil, protocol.ErrInvalidRequest.WithDebug(err.Error()).WithHint("Unable to parse client data")
+	}
+
+	// Secure Payment Confirmation assertions may be requested by the top-level origin of the payment
+	expectedOrigin := rp.expectedOrigin(clientData.Origin)
+	if payment != nil && clientData.Origin == payment.TopOrigin {
+		expectedOrigin = payment.TopOrigin
+	}
+
+	// If the client used the AppID and its trusted facets are resolved, the origin must be one of them
+	if rp.appID != "" && rp.appIDFacets != nil {
+		usedAppID, err := resp.ClientExtensionResults.AppID()
+		if err != nil {
+			return nil, err
+		}
+		if usedAppID {
+			err := rp.appIDFacets.Check(ctx, rp.appID, clientData.Origin)
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			if errors.Is(err, facets.ErrNotTrusted) {
+				return nil, protocol.ErrInvalidOrigin.WithDebugf("origin %q is not a trusted facet of AppID %q", clientData.Origin, rp.appID)
+			} else if err != nil {
+				return nil, protocol.ErrInvalidOrigin.WithDebugf("unable to resolve the trusted facets of AppID %q: %v", rp.appID, err).WithCause(err)
+			}
+			expectedOrigin = clientData.Origin
+		}
+	}
+
+	// A credential is only accepted for the RP ID that it is scoped to, of which the hash is verified by
+	// VerifyAssertion. Credentials that have been stored without their RP ID are accepted for any of the RP IDs.
+	rpID := credential.RPID
+	if rpID == "" {
+		rpID = rp.id
+		if authData := resp.Response.AuthenticatorData; len(authData) >= 32 {
+			rpID = rp.expectedRPID(authData[:32])
+		}
+	} else if !rp.acceptsRPID(rpID) {
+		return nil, protocol.ErrRPIDHashMismatch.WithDebugf("RP ID %q of the credential is not accepted", rpID)
+	}
+
+	return protocol.VerifyAssertionResponse(*resp, session.Challenge, expectedOrigin, rpID, credential.RawPublicKey, protocol.AssertionVerificationOptions{
+		UserVerification: session.UserVerification,
+		AppID:            rp.appID,
+		TokenBindingID:   session.TokenBindingID,
+		Payment:          payment,
+	})
+}
+
+// saveCounter updates the signature counter of the credential and saves it in the CredentialStore. If the counter
+// did not increase, ErrCounterRollback is returned and the credential is not saved.
+func (rp *RelyingParty) saveCounter(credential *Credential, signCount uint32) error {
+	if err := credential.UpdateCounter(signCount); err != nil {
+		return err
+	}
+	return rp.credentialStore.Save(credential)
+}
+
+// CredentialLookup looks up the stored credential with the given ID for FinishDiscoverableLogin. The user handle that is
+// returned by the authenticator is passed as well, which identifies the user of the credential.
+type CredentialLookup func(credentialID, userHandle []byte) (*Credential, error)
+
+// FinishDiscoverableLogin finishes the login ceremony like FinishLogin, for usernameless login with a discoverable
+// credential. Since the user is not known before the ceremony, the credential is looked up using lookup. The returned
+// credential identifies the user by its UserID, which is verified to match the user handle that is returned by the
+// authenticator. If lookup is nil, the credential is looked up in the CredentialStore that is set by
+// WithCredentialStore.
+func (rp *RelyingParty) FinishDiscoverableLogin(session *SessionData, lookup CredentialLookup, resp *protocol.AssertionResponse) (*Credential, uint32, error) {
+	return rp.FinishDiscoverableLoginContext(context.Background(), session, lookup, resp)
+}
+
+// FinishDiscoverableLoginContext is like FinishDiscoverableLogin, but aborts like FinishLoginContext once ctx is done.
+func (rp *RelyingParty) FinishDiscoverableLoginContext(ctx context.Context, session *SessionData, lookup CredentialLookup, resp *protocol.AssertionResponse) (*Credential, uint32, error) {
+	if resp == nil {
+		err := protocol.ErrInvalidRequest.WithDebug("missing assertion response")
+		rp.emit(&Event{Type: EventLoginFailed}, err)
+		return nil, 0, err
+	}
+	if len(resp.Response.UserHandle) == 0 {
+		err := protocol.ErrInvalidRequest.WithDebug("missing user handle for discoverable credential")
+		rp.emit(&Event{Type: EventLoginFailed, CredentialID: resp.RawID}, err)
+		return nil, 0, err
+	}
+
+	if lookup == nil && rp.credentialStore != nil {
+		lookup = func(credentialID, userHandle []byte) (*Credential, error) {
+			return rp.credentialStore.GetByCredentialID(credentialID)
+		}
+	}
+	if lookup == nil {
+		err := protocol.ErrInvalidRequest.WithDebug("missing credential lookup")
+		rp.emit(&Event{Type: EventLoginFailed, UserID: resp.Response.UserHandle, CredentialID: resp.RawID}, err)
+		return nil, 0, err
+	}
+
+	credential, err := lookup(resp.RawID, resp.Response.UserHandle)
+	if err != nil {
+		rp.emit(&Event{Type: EventLoginFailed, UserID: resp.Response.UserHandle, CredentialID: resp.RawID}, err)
+		return nil, 0, err
+	}
+
+	signCount, err := rp.FinishLoginContext(ctx, session, credential, resp)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return credential, signCount, nil
+}
+
+// containsCredentialID returns whether ids contains id.
+func containsCredentialID(ids [][]byte, id []byte) bool {
+	for _, i := range ids {
+		if bytes.Equal(i, id) {
+			return true
+		}
+	}
+	return false
+}
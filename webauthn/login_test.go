@@ -0,0 +1,754 @@
+package webauthn_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/keycloud/webauthn/facets"
+	"github.com/keycloud/webauthn/protocol"
+	"github.com/keycloud/webauthn/testutil"
+	"github.com/keycloud/webauthn/webauthn"
+)
+
+func TestBeginLogin(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options, session, err := rp.BeginLogin(webauthn.WithUserVerification(protocol.UserVerificationRequired))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(options.PublicKey.Challenge, session.Challenge) {
+		t.Fatal("session challenge does not match challenge in options")
+	}
+	if options.PublicKey.RPID != "localhost" {
+		t.Fatalf("invalid RP ID %q", options.PublicKey.RPID)
+	}
+	if session.UserVerification != protocol.UserVerificationRequired {
+		t.Fatal("user verification requirement is not stored in session")
+	}
+}
+
+func TestBeginLoginHints(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options, _, err := rp.BeginLogin(webauthn.WithLoginHints([]protocol.PublicKeyCredentialHint{"future", protocol.PublicKeyCredentialHintSecurityKey, protocol.PublicKeyCredentialHintHybrid}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(options.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte(`"hints":["security-key","hybrid"]`)) {
+		t.Fatalf("invalid hints in %s", b)
+	}
+}
+
+func TestBeginLoginUserCredentials(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options, session, err := rp.BeginLogin(webauthn.WithUserCredentials(
+		&webauthn.Credential{ID: []byte{1}, Transports: []protocol.AuthenticatorTransport{protocol.AuthenticatorTransportInternal, protocol.AuthenticatorTransportHybrid}},
+		&webauthn.Credential{ID: []byte{2}},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(session.AllowCredentials) != 2 {
+		t.Fatalf("expected 2 allowed credentials in session, got %d", len(session.AllowCredentials))
+	}
+
+	b, err := json.Marshal(options.PublicKey.AllowCredentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `[{"type":"public-key","id":"AQ","transports":["internal","hybrid"]},{"type":"public-key","id":"Ag"}]`; string(b) != expected {
+		t.Fatalf("expected allowed credentials %s, got %s", expected, b)
+	}
+}
+
+func TestBeginLoginLargeBlob(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name     string
+		opt      webauthn.LoginOption
+		expected string
+	}{
+		{"Read", webauthn.WithLargeBlobRead(), `{"read":true}`},
+		{"Write", webauthn.WithLargeBlobWrite([]byte{0xde, 0xad, 0xbe, 0xef}), `{"write":"3q2-7w"}`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			options, _, err := rp.BeginLogin(test.opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			input, err := json.Marshal(options.PublicKey.Extensions["largeBlob"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(input) != test.expected {
+				t.Fatalf("expected largeBlob input %s, got %s", test.expected, input)
+			}
+		})
+	}
+}
+
+func TestLargeBlobOutput(t *testing.T) {
+	var results protocol.AuthenticationExtensionsClientOutputs
+	if err := json.Unmarshal([]byte(`{"largeBlob":{"blob":"3q2-7w","written":false}}`), &results); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := results.LargeBlob()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(output.Blob, []byte{0xde, 0xad, 0xbe, 0xef}) {
+		t.Fatalf("invalid blob %x", output.Blob)
+	}
+	if output.Written == nil || *output.Written {
+		t.Fatal("expected written to be false")
+	}
+	if output.Supported != nil {
+		t.Fatal("expected supported to be absent")
+	}
+}
+
+func TestLoginTimeout(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithTimeout(2*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options, session, err := rp.BeginLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.PublicKey.Timeout != 120000 {
+		t.Fatalf("expected timeout of 120000 ms, got %d", options.PublicKey.Timeout)
+	}
+	if d := time.Until(session.Expires); d <= time.Minute || d > 2*time.Minute {
+		t.Fatalf("invalid session expiry %v", session.Expires)
+	}
+
+	credential := register(t, rp, loginRegistrationRequest, loginRegistrationResponse)
+
+	r := protocol.CredentialRequestOptions{}
+	if err := json.Unmarshal([]byte(loginRequest), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := &protocol.AssertionResponse{}
+	if err := json.Unmarshal([]byte(loginResponse), resp); err != nil {
+		t.Fatal(err)
+	}
+
+	session = &webauthn.SessionData{Challenge: r.PublicKey.Challenge, Expires: time.Now().Add(-time.Second)}
+	if _, err := rp.FinishLogin(session, credential, resp); err != webauthn.ErrCeremonyExpired {
+		t.Fatalf("expected ErrCeremonyExpired, got %v", err)
+	}
+
+	session.Expires = time.Now().Add(time.Minute)
+	if _, err := rp.FinishLogin(session, credential, resp); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestFinishLogin(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithoutChallengeConsumer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential := register(t, rp, loginRegistrationRequest, loginRegistrationResponse)
+
+	r := protocol.CredentialRequestOptions{}
+	if err := json.Unmarshal([]byte(loginRequest), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := &protocol.AssertionResponse{}
+	if err := json.Unmarshal([]byte(loginResponse), resp); err != nil {
+		t.Fatal(err)
+	}
+
+	signCount, err := rp.FinishLogin(&webauthn.SessionData{Challenge: r.PublicKey.Challenge}, credential, resp)
+	if err != nil {
+		e := protocol.ToWebAuthnError(err)
+		t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+	}
+
+	if signCount != 1 {
+		t.Fatalf("invalid sign count %d", signCount)
+	}
+
+	t.Run("Invalid challenge", func(t *testing.T) {
+		_, err := rp.FinishLogin(&webauthn.SessionData{Challenge: []byte("invalid")}, credential, resp)
+		if protocol.ToWebAuthnError(err).Name != protocol.ErrInvalidChallenge.Name {
+			t.Fatalf("expected invalid challenge, got %v", err)
+		}
+	})
+
+	t.Run("Invalid signature", func(t *testing.T) {
+		invalid := *resp
+		invalid.Response.Signature = append([]byte{}, resp.Response.Signature...)
+		invalid.Response.Signature[len(invalid.Response.Signature)-1] ^= 0xff
+
+		_, err := rp.FinishLogin(&webauthn.SessionData{Challenge: r.PublicKey.Challenge}, credential, &invalid)
+		if protocol.ToWebAuthnError(err).Name != protocol.ErrInvalidSignature.Name {
+			t.Fatalf("expected invalid signature, got %v", err)
+		}
+	})
+
+	t.Run("Token binding", func(t *testing.T) {
+		session := &webauthn.SessionData{Challenge: r.PublicKey.Challenge, TokenBindingID: []byte("token binding")}
+
+		_, err := rp.FinishLogin(session, credential, resp)
+		if !errors.Is(err, protocol.ErrTokenBindingMismatch) {
+			t.Fatalf("expected ErrTokenBindingMismatch, got %v", err)
+		}
+	})
+
+	for _, test := range []struct {
+		requirement protocol.UserVerificationRequirement
+		valid       bool
+	}{
+		{protocol.UserVerificationRequired, false},
+		{protocol.UserVerificationPreferred, true},
+		{protocol.UserVerificationDiscouraged, true},
+	} {
+		t.Run("User verification "+string(test.requirement), func(t *testing.T) {
+			session := &webauthn.SessionData{
+				Challenge:        r.PublicKey.Challenge,
+				UserVerification: test.requirement,
+			}
+
+			_, err := rp.FinishLogin(session, credential, resp)
+			if test.valid && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			} else if !test.valid && err != protocol.ErrUserNotVerified {
+				t.Fatalf("expected ErrUserNotVerified, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFinishLoginAllowCredentials(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithoutChallengeConsumer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential := register(t, rp, loginRegistrationRequest, loginRegistrationResponse)
+
+	r := protocol.CredentialRequestOptions{}
+	if err := json.Unmarshal([]byte(loginRequest), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := &protocol.AssertionResponse{}
+	if err := json.Unmarshal([]byte(loginResponse), resp); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name    string
+		ids     [][]byte
+		allowed bool
+	}{
+		{"Allowed", [][]byte{[]byte("other"), credential.ID}, true},
+		{"Not allowed", [][]byte{[]byte("other")}, false},
+		{"Discoverable", nil, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			options, session, err := rp.BeginLogin(webauthn.WithAllowCredentialIDs(test.ids...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(options.PublicKey.AllowCredentials) != len(test.ids) {
+				t.Fatalf("expected %d allowed credentials, got %d", len(test.ids), len(options.PublicKey.AllowCredentials))
+			}
+			session.Challenge = r.PublicKey.Challenge
+
+			_, err = rp.FinishLogin(session, credential, resp)
+			if test.allowed && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			} else if !test.allowed && err != webauthn.ErrCredentialNotAllowed {
+				t.Fatalf("expected ErrCredentialNotAllowed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFinishDiscoverableLogin(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithoutChallengeConsumer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential := register(t, rp, loginRegistrationRequest, loginRegistrationResponse)
+
+	r := protocol.CredentialRequestOptions{}
+	if err := json.Unmarshal([]byte(loginRequest), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := &protocol.AssertionResponse{}
+	if err := json.Unmarshal([]byte(loginResponse), resp); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name       string
+		userID     []byte
+		userHandle []byte
+		err        *protocol.Error
+	}{
+		{"Valid", []byte("test"), []byte("test"), nil},
+		{"User handle mismatch", []byte("other"), []byte("test"), webauthn.ErrUserHandleMismatch},
+		{"Missing user handle", []byte("test"), nil, protocol.ErrInvalidRequest},
+		{"Missing user ID", nil, []byte("test"), webauthn.ErrUserHandleMismatch},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c := *credential
+			c.UserID = test.userID
+
+			resp := *resp
+			resp.Response.UserHandle = test.userHandle
+
+			lookup := func(credentialID, userHandle []byte) (*webauthn.Credential, error) {
+				if !bytes.Equal(credentialID, c.ID) {
+					t.Fatalf("unexpected credential ID %x", credentialID)
+				}
+				return &c, nil
+			}
+
+			found, _, err := rp.FinishDiscoverableLogin(&webauthn.SessionData{Challenge: r.PublicKey.Challenge}, lookup, &resp)
+			if test.err == nil {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				if !bytes.Equal(found.UserID, test.userHandle) {
+					t.Fatalf("expected user %q, got %q", test.userHandle, found.UserID)
+				}
+			} else if err == nil || protocol.ToWebAuthnError(err).Name != test.err.Name {
+				t.Fatalf("expected %s, got %v", test.err.Name, err)
+			}
+		})
+	}
+}
+
+func TestFinishLoginRSAPSS(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential := register(t, rp, pssRegistrationRequest, pssRegistrationResponse)
+
+	r := protocol.CredentialRequestOptions{}
+	if err := json.Unmarshal([]byte(pssLoginRequest), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := &protocol.AssertionResponse{}
+	if err := json.Unmarshal([]byte(pssLoginResponse), resp); err != nil {
+		t.Fatal(err)
+	}
+
+	signCount, err := rp.FinishLogin(&webauthn.SessionData{Challenge: r.PublicKey.Challenge}, credential, resp)
+	if err != nil {
+		e := protocol.ToWebAuthnError(err)
+		t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+	}
+	if signCount != 1 {
+		t.Fatalf("expected sign count 1, got %d", signCount)
+	}
+}
+
+func TestFinishLoginAppID(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithAppID("https://localhost/appid.json"), webauthn.WithoutChallengeConsumer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options, _, err := rp.BeginLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.PublicKey.Extensions["appid"] != "https://localhost/appid.json" {
+		t.Fatal("appid extension is not requested")
+	}
+
+	credential := register(t, rp, appIDRegistrationRequest, appIDRegistrationResponse)
+
+	r := protocol.CredentialRequestOptions{}
+	if err := json.Unmarshal([]byte(appIDLoginRequest), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name    string
+		rp      *webauthn.RelyingParty
+		results string
+		valid   bool
+	}{
+		{"AppID used", rp, `{"appid":true}`, true},
+		{"AppID not used", rp, `{"appid":false}`, false},
+		{"AppID absent", rp, `{}`, false},
+		{"AppID not configured", nil, `{"appid":true}`, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rp := test.rp
+			if rp == nil {
+				var err error
+				if rp, err = webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			resp := &protocol.AssertionResponse{}
+			if err := json.Unmarshal([]byte(appIDLoginResponse), resp); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(test.results), &resp.ClientExtensionResults); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := rp.FinishLogin(&webauthn.SessionData{Challenge: r.PublicKey.Challenge}, credential, resp)
+			if test.valid && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			} else if !test.valid && !errors.Is(err, protocol.ErrRPIDHashMismatch) {
+				t.Fatalf("expected RP ID hash mismatch, got %v", err)
+			}
+		})
+	}
+
+	for _, test := range []struct {
+		name     string
+		document string
+		err      error
+	}{
+		{"Trusted facet", `{"trustedFacets":[{"version":{"major":1,"minor":0},"ids":["https://localhost"]}]}`, nil},
+		{"Untrusted facet", `{"trustedFacets":[{"version":{"major":1,"minor":0},"ids":["https://other.example"]}]}`, protocol.ErrInvalidOrigin},
+		{"Invalid document", `{`, protocol.ErrInvalidOrigin},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			// The trusted facets are served at the AppID URL https://localhost/appid.json
+			client := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				rec := httptest.NewRecorder()
+				rec.Header().Set("Content-Type", facets.ContentType)
+				if req.URL.String() != "https://localhost/appid.json" {
+					rec.WriteHeader(http.StatusNotFound)
+				}
+				rec.WriteString(test.document)
+				return rec.Result(), nil
+			})}
+			rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithAppID("https://localhost/appid.json"), webauthn.WithAppIDFacets(&facets.Resolver{Client: client}), webauthn.WithoutChallengeConsumer())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp := &protocol.AssertionResponse{}
+			if err := json.Unmarshal([]byte(appIDLoginResponse), resp); err != nil {
+				t.Fatal(err)
+			}
+			resp.ClientExtensionResults = protocol.AuthenticationExtensionsClientOutputs{"appid": true}
+
+			_, err = rp.FinishLogin(&webauthn.SessionData{Challenge: r.PublicKey.Challenge}, credential, resp)
+			if test.err == nil && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			} else if test.err != nil && !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+
+	t.Run("Context canceled", func(t *testing.T) {
+		client := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, req.Context().Err()
+		})}
+		rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithAppID("https://localhost/appid.json"), webauthn.WithAppIDFacets(&facets.Resolver{Client: client}), webauthn.WithoutChallengeConsumer())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp := &protocol.AssertionResponse{}
+		if err := json.Unmarshal([]byte(appIDLoginResponse), resp); err != nil {
+			t.Fatal(err)
+		}
+		resp.ClientExtensionResults = protocol.AuthenticationExtensionsClientOutputs{"appid": true}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err = rp.FinishLoginContext(ctx, &webauthn.SessionData{Challenge: r.PublicKey.Challenge}, credential, resp)
+		if err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+
+		lookup := func(credentialID, userHandle []byte) (*webauthn.Credential, error) {
+			return credential, nil
+		}
+		_, _, err = rp.FinishDiscoverableLoginContext(ctx, &webauthn.SessionData{Challenge: r.PublicKey.Challenge}, lookup, resp)
+		if err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	})
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+var loginRegistrationRequest = `{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"l62dmf3mYyQt5byAptH6nl6SjInoZakwJ9W0Aqyegrk=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`
+
+var loginRegistrationResponse = `{"id":"lLrr-NW18YOnjyhyByT6d3o9YkCdFCewrdzyJLGXJb0","rawId":"lLrr+NW18YOnjyhyByT6d3o9YkCdFCewrdzyJLGXJb0=","response":{"attestationObject":"o2dhdHRTdG10omNhbGcmY3NpZ1hHMEUCIQDGQ7cysqb4xkGCGzgT+ebVIkWqOpR8+LWuzDIBb+Cb+AIgaH1PS2hA4+q7JjKoTHcYhvh1RqCp41vMPjq9xo45TqNoYXV0aERhdGFYpEmWDeWIDoxodDQXD2R2YFuP5K65ooYyx5lc87qDHZdjRQAAAAAAAAAAAAAAAAAAAAAAAAAAACCUuuv41bXxg6ePKHIHJPp3ej1iQJ0UJ7Ct3PIksZclvaUiWCDfdoppVpOMfq/54XjtfKPsA+Q5uow3oXN4b+fBxEQ/JCFYIBoDnN1JtC3BtzrHwyrdzYTGInVqh9ci9KoAsIDr/EQYIAEBAgMmY2ZtdGZwYWNrZWQ=","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoibDYyZG1mM21ZeVF0NWJ5QXB0SDZubDZTaklub1pha3dKOVcwQXF5ZWdyayIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`
+
+var loginRequest = `{"publicKey":{"challenge":"dsvlypE63UDPVaUugIeZ/dO5kg7/4UCosyNoPEX8ENw=","timeout":30000,"rpId":"localhost"}}`
+
+var loginResponse = `{"id":"lLrr-NW18YOnjyhyByT6d3o9YkCdFCewrdzyJLGXJb0","rawId":"lLrr+NW18YOnjyhyByT6d3o9YkCdFCewrdzyJLGXJb0=","response":{"authenticatorData":"SZYN5YgOjGh0NBcPZHZgW4/krrmihjLHmVzzuoMdl2MBAAAAAQ==","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uZ2V0IiwiY2hhbGxlbmdlIjoiZHN2bHlwRTYzVURQVmFVdWdJZVpfZE81a2c3XzRVQ29zeU5vUEVYOEVOdyIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0=","signature":"MEUCIQDI8TDy+Tr7g1r9+6dz2sF7uQvDULqo9UUt2xNH3obLSgIgdMBAaD4ivVH8Fsxe11bOuUexCOn9BJBpfVC75OMyx4w=","userHandle":"dGVzdA=="},"type":"public-key"}`
+
+var appIDRegistrationRequest = `{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"GjjEkQGgsdZIymDr7iKOYP22iGbhyKCTd/X6njs/KNQ=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`
+
+var appIDRegistrationResponse = `{"id":"B9l6m4ANQcg4Yz-28c1DQpav-Xd1Z2UjulpF5zkokRw","rawId":"B9l6m4ANQcg4Yz+28c1DQpav+Xd1Z2UjulpF5zkokRw=","response":{"attestationObject":"o2dhdHRTdG10omNhbGcmY3NpZ1hHMEUCIQCY+mO3QA0OpSissHM1pgDTi9ahDmx7DfhjAbkocpbqagIgVSg6D2vj0FCX18OtZ/U32QgLI2AiUka3tKTeH9TKa0ZoYXV0aERhdGFYpEmWDeWIDoxodDQXD2R2YFuP5K65ooYyx5lc87qDHZdjRQAAAAAAAAAAAAAAAAAAAAAAAAAAACAH2XqbgA1ByDhjP7bxzUNClq/5d3VnZSO6WkXnOSiRHKUiWCBV9LxRS7zvH9wen+63+qtiWLlNdZAym3GT+noKA1669SFYIJBOKukPhLaIkyB+IRBjTOhQyWgHCPvAF9RGckR4nT/oIAEBAgMmY2ZtdGZwYWNrZWQ=","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoiR2pqRWtRR2dzZFpJeW1EcjdpS09ZUDIyaUdiaHlLQ1RkX1g2bmpzX0tOUSIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`
+
+// Assertion of which the RP ID hash is the hash of the AppID https://localhost/appid.json
+var appIDLoginRequest = `{"publicKey":{"challenge":"TRjmCaQL1JGB4IWZSyp/jJiI6L5DGsrNfA+gE0xRLG4=","timeout":30000,"rpId":"localhost"}}`
+
+var appIDLoginResponse = `{"id":"B9l6m4ANQcg4Yz-28c1DQpav-Xd1Z2UjulpF5zkokRw","rawId":"B9l6m4ANQcg4Yz+28c1DQpav+Xd1Z2UjulpF5zkokRw=","response":{"authenticatorData":"H1imZtcMkM3bl8LZh/IHs/mXCcafcDc+LD9Hlfz2/0cBAAAAAQ==","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uZ2V0IiwiY2hhbGxlbmdlIjoiVFJqbUNhUUwxSkdCNElXWlN5cF9qSmlJNkw1REdzck5mQS1nRTB4UkxHNCIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0=","signature":"MEYCIQD4al52zz6qVEEab22o6/zxzr9NmQ5ExijYrO/hkE+ZIAIhAIC6Oddx0cTGp6kkST/p01n738sO0vRH8iyIhQxJ6KuV","userHandle":"dGVzdA=="},"type":"public-key"}`
+
+// Self attestation and assertion of an RSASSA-PSS credential with SHA-256
+var pssRegistrationRequest = `{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"aOUEuNGKFOpYaIQT/rP7ei9p1IMJrU6CmffhF31wkoI=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`
+
+var pssRegistrationResponse = `{"id":"4PE9LBmkWfErcmZIAtjk3Vgu8JWTPsjHfVVaW5ZwB90","rawId":"4PE9LBmkWfErcmZIAtjk3Vgu8JWTPsjHfVVaW5ZwB90=","response":{"attestationObject":"o2dhdHRTdG10omNhbGc4JGNzaWdZAQA9hlDXg2UiPwYoDRbWUfE+AJ08alJdoQ7MugxuMLihDpdULrfQ3M0mtOvrPGyXuQlHcfhDGSJUJFbFYLExOvOmc1enVMoxjjHOrAKinhoB2j4KZkMLA+HyTbpeT6E2GNLVvqtdPMcCQyrEmR4XYksQHB30/eAF/eUGtejQP3HdUzf2Ng95T5XY8dJ/eJSYujDWIIOt3k0018TuhV/9cSjoJ9NZmAKwovqnaOuf/5ufcmDabUcK7UhiriC5c5u+3M0/4gG0PcewoB6Ww1jlLCJKewjZce6UWywtN4lrZBGayapAgLa4jWfC6MEdx0h+buzn7wNslu675ZCctvtk/JZiaGF1dGhEYXRhWQFmSZYN5YgOjGh0NBcPZHZgW4/krrmihjLHmVzzuoMdl2NFAAAAAAAAAAAAAAAAAAAAAAAAAAAAIODxPSwZpFnxK3JmSALY5N1YLvCVkz7Ix31VWluWcAfdpCFDAQABIFkBAO4iW60wVimJGBFpE2Pn57tpVceiDipUuSZbHV7ACKdMVTalfEhOodfSb9TBnOS4jlRUUXMLBk+kYEA6VnKUsXWydnLqTpl+qMmrgoVrF9ZYamiRAZYr304JP9lgyXTo7rMPdEtwAZW2qSx3L4Z9Y1lbl36ZsnYCO3x1kXZHbCD9iRWd4OlK3joEfHVFOJRS9hh7vSHrphoTgx6wim+PndEnFrBUqG5lSwkrq6x0PaK2CZARQz0SfEPM8GkQvSIxDf+V97TWlRpAS6H/05vGGxSIc+R2HQDt8pgeYSD1XU0XmjK6ZaoviW4xpaX/zAL8G135gCJk6fkzot/URI3JhLEBAwM4JGNmbXRmcGFja2Vk","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoiYU9VRXVOR0tGT3BZYUlRVF9yUDdlaTlwMUlNSnJVNkNtZmZoRjMxd2tvSSIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`
+
+var pssLoginRequest = `{"publicKey":{"challenge":"F39W3Nqyeky1/Dy9t5kqhJrN3YmNcCxx8t6tAvP0zxA=","timeout":30000,"rpId":"localhost"}}`
+
+var pssLoginResponse = `{"id":"4PE9LBmkWfErcmZIAtjk3Vgu8JWTPsjHfVVaW5ZwB90","rawId":"4PE9LBmkWfErcmZIAtjk3Vgu8JWTPsjHfVVaW5ZwB90=","response":{"authenticatorData":"SZYN5YgOjGh0NBcPZHZgW4/krrmihjLHmVzzuoMdl2MBAAAAAQ==","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uZ2V0IiwiY2hhbGxlbmdlIjoiRjM5VzNOcXlla3kxX0R5OXQ1a3FoSnJOM1ltTmNDeHg4dDZ0QXZQMHp4QSIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0=","signature":"W/1gZyEFdMMtMgtVc3K7dWUbknKZh5syrGXlwh4rfXnCRSWfUIgbj8mgd7KbPK8QGvqykOgQHIX5/b3DNji4XGy3eqbESocT+35Q/wPz2BzWgoEHqMthJ2O8pyR5aK69kVAKkdFZcWzye/8HI8OYSet5CnbAT8dAnDrtkcfQiQ0vkaconDtykuxwLuYljGjhzlXR1Ile+QydErvja9LPQzUBreR3LdRuwiSwhYshXEZPEEUfIyxQT4bhDoNz5+fiRklfwPJNg4+/iqkc43rrcSd2J4X5wjjYTRzdDG0wX48V8KOTuBvfon1z1ZBCtibpip+FRSb1JQ7YcBINlqsSBQ==","userHandle":"dGVzdA=="},"type":"public-key"}`
+
+func TestFinishLoginWithoutCounter(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Platform authenticators and synced passkeys do not implement a signature counter and always report 0
+	authenticator, err := testutil.NewVirtualAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator.SignCount = 0
+
+	options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestation, err := authenticator.Register("https://localhost", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := rp.FinishRegistration(session, attestation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		options, session, err := rp.BeginLogin(webauthn.WithUserCredentials(credential))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertion, err := authenticator.Login("https://localhost", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signCount, err := rp.FinishLogin(session, credential, assertion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if signCount != 0 {
+			t.Fatalf("expected sign count 0, got %d", signCount)
+		}
+		if err := credential.UpdateCounter(signCount); err != nil {
+			t.Fatalf("expected no rollback for assertion %d, got %v", i, err)
+		}
+	}
+	if credential.SignCount != 0 {
+		t.Fatalf("expected sign count 0, got %d", credential.SignCount)
+	}
+}
+
+func TestFinishLoginResult(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator, err := testutil.NewVirtualAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestation, err := authenticator.Register("https://localhost", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := rp.FinishRegistration(session, attestation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login := func() (*webauthn.LoginResult, error) {
+		options, session, err := rp.BeginLogin(webauthn.WithUserCredentials(credential))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertion, err := authenticator.Login("https://localhost", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rp.FinishLoginResult(session, credential, assertion)
+	}
+
+	result, err := login()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SignCount != authenticator.SignCount-1 || !result.Flags.UserVerified() || result.Extensions != nil || result.UVM != nil {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	// The extension outputs of the assertion are returned
+	authenticator.Extensions = map[string]interface{}{"uvm": [][]uint64{{uint64(protocol.UserVerificationMethodFingerprintInternal), 2, 2}}}
+	if result, err = login(); err != nil {
+		t.Fatal(err)
+	}
+	if !result.Flags.HasExtensions() || result.Extensions["uvm"] == nil {
+		t.Fatalf("expected uvm extension output, got %+v", result)
+	}
+	expected := []protocol.UVMEntry{{UserVerificationMethod: protocol.UserVerificationMethodFingerprintInternal, KeyProtectionType: 2, MatcherProtectionType: 2}}
+	if !reflect.DeepEqual(result.UVM, expected) {
+		t.Fatalf("expected UVM %+v, got %+v", expected, result.UVM)
+	}
+	if result.DevicePubKey != nil {
+		t.Fatalf("expected no devicePubKey extension output, got %+v", result.DevicePubKey)
+	}
+
+	authenticator.Extensions = map[string]interface{}{"uvm": "invalid"}
+	if _, err := login(); !errors.Is(err, protocol.ErrInvalidRequest) {
+		t.Fatalf("expected ErrInvalidRequest, got %v", err)
+	}
+}
+
+func TestRelyingPartyIDs(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "login.example.com", "https://login.example.com",
+		webauthn.WithRelyingPartyIDs([]string{"accounts.example.com"}),
+		webauthn.WithAllowedOrigins([]string{"https://accounts.example.com"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// register registers a new credential with the given RP ID
+	register := func(t *testing.T, rpID string) (*testutil.VirtualAuthenticator, *webauthn.Credential, error) {
+		authenticator, err := testutil.NewVirtualAuthenticator()
+		if err != nil {
+			t.Fatal(err)
+		}
+		options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		options.PublicKey.RP.ID = rpID
+		attestation, err := authenticator.Register("https://accounts.example.com", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		credential, err := rp.FinishRegistration(session, attestation)
+		return authenticator, credential, err
+	}
+
+	// login logs in with the credential with the given RP ID
+	login := func(t *testing.T, authenticator *testutil.VirtualAuthenticator, credential *webauthn.Credential, rpID string) error {
+		options, session, err := rp.BeginLogin(webauthn.WithUserCredentials(credential))
+		if err != nil {
+			t.Fatal(err)
+		}
+		options.PublicKey.RPID = rpID
+		assertion, err := authenticator.Login("https://accounts.example.com", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = rp.FinishLogin(session, credential, assertion)
+		return err
+	}
+
+	for _, rpID := range []string{"login.example.com", "accounts.example.com"} {
+		t.Run(rpID, func(t *testing.T) {
+			authenticator, credential, err := register(t, rpID)
+			if err != nil {
+				e := protocol.ToWebAuthnError(err)
+				t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+			}
+			if credential.RPID != rpID {
+				t.Fatalf("expected RP ID %q, got %q", rpID, credential.RPID)
+			}
+			if err := login(t, authenticator, credential, rpID); err != nil {
+				e := protocol.ToWebAuthnError(err)
+				t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+			}
+		})
+	}
+
+	t.Run("Other RP ID", func(t *testing.T) {
+		if _, _, err := register(t, "example.com"); !errors.Is(err, protocol.ErrRPIDHashMismatch) {
+			t.Fatalf("expected ErrRPIDHashMismatch, got %v", err)
+		}
+	})
+
+	t.Run("Other RP ID of credential", func(t *testing.T) {
+		authenticator, credential, err := register(t, "accounts.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The credential is only accepted for its own RP ID, even if the other RP ID is accepted
+		authenticator.RPID = ""
+		if err := login(t, authenticator, credential, "login.example.com"); !errors.Is(err, protocol.ErrRPIDHashMismatch) {
+			t.Fatalf("expected ErrRPIDHashMismatch, got %v", err)
+		}
+
+		// Credentials that have been stored without their RP ID are accepted for any of the RP IDs
+		credential.RPID = ""
+		if err := login(t, authenticator, credential, "login.example.com"); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package webauthn
+
+import (
+	"context"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+// WithPaymentCredential requests the payment extension with isPayment set, such that the credential may be used for
+// Secure Payment Confirmation, including on the origin of a merchant instead of the Relying Party.
+// https://www.w3.org/TR/secure-payment-confirmation/#sctn-payment-extension-registration
+func WithPaymentCredential() RegistrationOption {
+	return func(o *protocol.PublicKeyCredentialCreationOptions) {
+		if o.Extensions == nil {
+			o.Extensions = make(protocol.AuthenticationExtensionsClientInputs)
+		}
+		o.Extensions["payment"] = map[string]interface{}{"isPayment": true}
+	}
+}
+
+// FinishPaymentConfirmation finishes a Secure Payment Confirmation, which is a login ceremony of which the assertion is
+// created by the payment handler of the browser, like FinishLogin. The challenge of session must have been passed to
+// the merchant, and payment is the payment that the user is expected to have confirmed. If its RP ID is empty, the RP
+// ID of the Relying Party is used. Besides the origins of the Relying Party, the assertion may be requested by the
+// top-level origin of the payment. If Secure Payment Confirmation is not enabled using WithSecurePaymentConfirmation,
+// an error is returned.
+func (rp *RelyingParty) FinishPaymentConfirmation(session *SessionData, credential *Credential, resp *protocol.AssertionResponse, payment protocol.CollectedClientPaymentData) (uint32, error) {
+	return rp.FinishPaymentConfirmationContext(context.Background(), session, credential, resp, payment)
+}
+
+// FinishPaymentConfirmationContext is like FinishPaymentConfirmation, but aborts like FinishLoginContext once ctx is
+// done.
+func (rp *RelyingParty) FinishPaymentConfirmationContext(ctx context.Context, session *SessionData, credential *Credential, resp *protocol.AssertionResponse, payment protocol.CollectedClientPaymentData) (uint32, error) {
+	if !rp.securePaymentConfirmation {
+		return 0, protocol.ErrInvalidRequest.WithDebug("secure payment confirmation is not enabled")
+	}
+
+	if payment.RPID == "" {
+		payment.RPID = rp.id
+	}
+	result, err := rp.finishLogin(ctx, session, credential, resp, &payment)
+	if err != nil {
+		return 0, err
+	}
+	return result.SignCount, nil
+}
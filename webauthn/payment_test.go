@@ -0,0 +1,139 @@
+package webauthn_test
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/keycloud/webauthn/protocol"
+	"github.com/keycloud/webauthn/testutil"
+	"github.com/keycloud/webauthn/webauthn"
+)
+
+func TestFinishPaymentConfirmation(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithSecurePaymentConfirmation(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator, err := testutil.NewVirtualAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")}, webauthn.WithPaymentCredential())
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestation, err := authenticator.Register("https://localhost", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := rp.FinishRegistration(session, attestation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment := protocol.CollectedClientPaymentData{
+		TopOrigin:  "https://merchant.example",
+		PayeeName:  "Merchant",
+		Total:      protocol.PaymentCurrencyAmount{Currency: "EUR", Value: "10.00"},
+		Instrument: protocol.PaymentCredentialInstrument{DisplayName: "Card", Icon: "https://localhost/card.png"},
+	}
+	confirmed := payment
+	confirmed.RPID = "localhost"
+
+	// paymentAssertion creates an assertion like the payment handler of the browser would, of which the client data is
+	// replaced and signed again.
+	paymentAssertion := func(t *testing.T, typ, origin string, payment *protocol.CollectedClientPaymentData) (*webauthn.SessionData, *protocol.AssertionResponse) {
+		options, session, err := rp.BeginLogin(webauthn.WithUserCredentials(credential))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertion, err := authenticator.Login("https://localhost", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clientDataJSON, err := json.Marshal(protocol.CollectedClientData{
+			Type:      typ,
+			Challenge: base64.RawURLEncoding.EncodeToString(session.Challenge),
+			Origin:    origin,
+			Payment:   payment,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		clientDataHash := sha256.Sum256(clientDataJSON)
+		h := sha256.New()
+		h.Write(assertion.Response.AuthenticatorData)
+		h.Write(clientDataHash[:])
+		if assertion.Response.Signature, err = authenticator.PrivateKey.Sign(rand.Reader, h.Sum(nil), nil); err != nil {
+			t.Fatal(err)
+		}
+		assertion.Response.ClientDataJSON = clientDataJSON
+		return session, assertion
+	}
+
+	otherTotal := confirmed
+	otherTotal.Total.Value = "100.00"
+	otherInstrument := confirmed
+	otherInstrument.Instrument.DisplayName = "Other card"
+	otherRPID := confirmed
+	otherRPID.RPID = "other.example"
+	noPayee := confirmed
+	noPayee.PayeeName = ""
+
+	for _, test := range []struct {
+		name    string
+		typ     string
+		origin  string
+		payment *protocol.CollectedClientPaymentData
+		err     error
+	}{
+		{"Relying Party origin", protocol.ClientDataTypePaymentGet, "https://localhost", &confirmed, nil},
+		{"Merchant origin", protocol.ClientDataTypePaymentGet, "https://merchant.example", &confirmed, nil},
+		{"Other origin", protocol.ClientDataTypePaymentGet, "https://other.example", &confirmed, protocol.ErrInvalidOrigin},
+		{"Login assertion", protocol.ClientDataTypeGet, "https://localhost", nil, protocol.ErrInvalidClientDataType},
+		{"Missing payment", protocol.ClientDataTypePaymentGet, "https://localhost", nil, protocol.ErrPaymentMismatch},
+		{"Other total", protocol.ClientDataTypePaymentGet, "https://localhost", &otherTotal, protocol.ErrPaymentMismatch},
+		{"Other instrument", protocol.ClientDataTypePaymentGet, "https://localhost", &otherInstrument, protocol.ErrPaymentMismatch},
+		{"Other RP ID", protocol.ClientDataTypePaymentGet, "https://localhost", &otherRPID, protocol.ErrPaymentMismatch},
+		{"Missing payee", protocol.ClientDataTypePaymentGet, "https://localhost", &noPayee, protocol.ErrPaymentMismatch},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			session, assertion := paymentAssertion(t, test.typ, test.origin, test.payment)
+			_, err := rp.FinishPaymentConfirmation(session, credential, assertion, payment)
+			if test.err == nil {
+				if err != nil {
+					e := protocol.ToWebAuthnError(err)
+					t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+				}
+				return
+			}
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+
+	t.Run("Payment assertion on login", func(t *testing.T) {
+		session, assertion := paymentAssertion(t, protocol.ClientDataTypePaymentGet, "https://localhost", &confirmed)
+		if _, err := rp.FinishLogin(session, credential, assertion); !errors.Is(err, protocol.ErrInvalidClientDataType) {
+			t.Fatalf("expected ErrInvalidClientDataType, got %v", err)
+		}
+	})
+
+	t.Run("Not enabled", func(t *testing.T) {
+		rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+		if err != nil {
+			t.Fatal(err)
+		}
+		session, assertion := paymentAssertion(t, protocol.ClientDataTypePaymentGet, "https://localhost", &confirmed)
+		if _, err := rp.FinishPaymentConfirmation(session, credential, assertion, payment); !errors.Is(err, protocol.ErrInvalidRequest) {
+			t.Fatalf("expected ErrInvalidRequest, got %v", err)
+		}
+	})
+}
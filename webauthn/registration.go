@@ -2,10 +2,16 @@ package webauthn
 
 import (
 	"bytes"
+	"context"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/keycloud/webauthn/cose"
+	"github.com/keycloud/webauthn/metadata"
 	"github.com/keycloud/webauthn/protocol"
+	"github.com/keycloud/webauthn/revocation"
 	"net/http"
 )
 
@@ -174,4 +180,615 @@ func (w *WebAuthn) FinishRegistration(r *http.Request, rw http.ResponseWriter, u
 	rw.WriteHeader(http.StatusCreated)
 
 	return authr
-}
\ No newline at end of file
+}
+
+// RegistrationOption configures the options that are passed to navigator.credentials.create() by
+// RelyingParty.BeginRegistration.
+type RegistrationOption func(*protocol.PublicKeyCredentialCreationOptions)
+
+// WithExcludeCredentials sets the credentials that are already registered for the user, such that the user is not able
+// to register the same authenticator twice.
+func WithExcludeCredentials(credentials ...protocol.PublicKeyCredentialDescriptor) RegistrationOption {
+	return func(o *protocol.PublicKeyCredentialCreationOptions) {
+		o.ExcludeCredentials = append(o.ExcludeCredentials, credentials...)
+	}
+}
+
+// WithExcludeCredentialIDs sets the IDs of the credentials that are already registered for the user, like
+// WithExcludeCredentials. FinishRegistration rejects the registration of an excluded credential with
+// ErrCredentialExcluded, in case the authenticator did not refuse it.
+func WithExcludeCredentialIDs(ids ...[]byte) RegistrationOption {
+	return func(o *protocol.PublicKeyCredentialCreationOptions) {
+		for _, id := range ids {
+			o.ExcludeCredentials = append(o.ExcludeCredentials, protocol.PublicKeyCredentialDescriptor{
+				Type: protocol.PublicKeyCredentialTypePublicKey,
+				ID:   id,
+			})
+		}
+	}
+}
+
+// WithCredentialAlgorithms sets the algorithms of the credential public key that are requested, in order of
+// preference, for instance to exclude algorithms that the Relying Party does not support. At least one algorithm must
+// be given. The default is ES256, RS256 and EdDSA. FinishRegistration rejects credentials of other algorithms with
+// ErrAlgorithmNotAllowed.
+func WithCredentialAlgorithms(algorithms []protocol.COSEAlgorithmIdentifier) RegistrationOption {
+	return func(o *protocol.PublicKeyCredentialCreationOptions) {
+		o.PubKeyCredParams = make([]protocol.PublicKeyCredentialParameters, len(algorithms))
+		for i, alg := range algorithms {
+			o.PubKeyCredParams[i] = protocol.PublicKeyCredentialParameters{
+				Type:      protocol.PublicKeyCredentialTypePublicKey,
+				Algorithm: alg,
+			}
+		}
+	}
+}
+
+// WithAuthenticatorSelection sets the requirements that authenticators must fulfill to be used for registration.
+func WithAuthenticatorSelection(criteria protocol.AuthenticatorSelectionCriteria) RegistrationOption {
+	return func(o *protocol.PublicKeyCredentialCreationOptions) {
+		o.AuthenticatorSelection = criteria
+	}
+}
+
+// WithAttestationConveyance sets the preference regarding attestation conveyance. The default is direct attestation.
+func WithAttestationConveyance(preference protocol.AttestationConveyancePreference) RegistrationOption {
+	return func(o *protocol.PublicKeyCredentialCreationOptions) {
+		o.Attestation = preference
+	}
+}
+
+// WithAttestationFormats sets the attestation statement formats that are preferred, in order of preference, for
+// instance "packed" and "tpm". Clients may pass them to the authenticator as a hint, but attestations of other formats
+// are still accepted. BeginRegistration returns an error if one of the formats is not registered, since attestations
+// of the format could not be verified.
+func WithAttestationFormats(formats []string) RegistrationOption {
+	return func(o *protocol.PublicKeyCredentialCreationOptions) {
+		o.AttestationFormats = formats
+	}
+}
+
+// WithHints sets the hints as to which authenticators the client should guide the user to during registration, in
+// order of preference, for instance protocol.PublicKeyCredentialHintClientDevice to create a passkey on the client
+// device. Unknown hints are omitted, since clients ignore them as well.
+func WithHints(hints []protocol.PublicKeyCredentialHint) RegistrationOption {
+	return func(o *protocol.PublicKeyCredentialCreationOptions) {
+		o.Hints = knownHints(hints)
+	}
+}
+
+// knownHints returns the hints that are known, in the same order.
+func knownHints(hints []protocol.PublicKeyCredentialHint) []protocol.PublicKeyCredentialHint {
+	var known []protocol.PublicKeyCredentialHint
+	for _, hint := range hints {
+		if hint.IsKnown() {
+			known = append(known, hint)
+		}
+	}
+	return known
+}
+
+// WithCredentialProperties requests the credProps extension, such that the client reports whether a client-side
+// discoverable credential has been created, as reported by Credential.ResidentKey.
+func WithCredentialProperties() RegistrationOption {
+	return func(o *protocol.PublicKeyCredentialCreationOptions) {
+		if o.Extensions == nil {
+			o.Extensions = make(protocol.AuthenticationExtensionsClientInputs)
+		}
+		o.Extensions["credProps"] = true
+	}
+}
+
+// WithLargeBlob requests the largeBlob extension with the given support requirement, such that the client reports
+// whether the credential supports storing large blobs, as reported by Credential.LargeBlob.
+func WithLargeBlob(support protocol.LargeBlobSupport) RegistrationOption {
+	return func(o *protocol.PublicKeyCredentialCreationOptions) {
+		if o.Extensions == nil {
+			o.Extensions = make(protocol.AuthenticationExtensionsClientInputs)
+		}
+		o.Extensions["largeBlob"] = &protocol.LargeBlobInputs{Support: support}
+	}
+}
+
+// WithCredentialProtection requests the credProtect extension with the given policy, such that the authenticator
+// protects the credential accordingly, as reported by Credential.CredentialProtection. If enforce is set, the client
+// fails the registration if the authenticator does not support the policy, instead of creating a credential with a
+// lower protection level.
+func WithCredentialProtection(policy protocol.CredentialProtectionPolicy, enforce bool) RegistrationOption {
+	return func(o *protocol.PublicKeyCredentialCreationOptions) {
+		if o.Extensions == nil {
+			o.Extensions = make(protocol.AuthenticationExtensionsClientInputs)
+		}
+		o.Extensions["credentialProtectionPolicy"] = policy
+		if enforce {
+			o.Extensions["enforceCredentialProtectionPolicy"] = true
+		}
+	}
+}
+
+// WithMinPINLength requests the minPinLength extension, such that the authenticator reports its minimum PIN length, as
+// reported by Credential.MinPINLength. Authenticators only report it if the RP ID is in the list of RP IDs that may
+// read it, which is configured on the authenticator, for instance by an enterprise that manages its security keys.
+func WithMinPINLength() RegistrationOption {
+	return func(o *protocol.PublicKeyCredentialCreationOptions) {
+		if o.Extensions == nil {
+			o.Extensions = make(protocol.AuthenticationExtensionsClientInputs)
+		}
+		o.Extensions["minPinLength"] = true
+	}
+}
+
+// BeginRegistration starts the registration ceremony for the given user. The returned options should be passed to
+// navigator.credentials.create(), for instance via JSON over HTTP. The returned SessionData should be stored
+// server-side and passed to FinishRegistration once the client responds.
+func (rp *RelyingParty) BeginRegistration(user User, opts ...RegistrationOption) (*protocol.CredentialCreationOptions, *SessionData, error) {
+	chal, err := rp.newChallenge()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	credParams := make([]protocol.PublicKeyCredentialParameters, len(defaultCredentialParameters))
+	copy(credParams, defaultCredentialParameters)
+
+	options := &protocol.CredentialCreationOptions{
+		PublicKey: protocol.PublicKeyCredentialCreationOptions{
+			Challenge: chal,
+			RP: protocol.PublicKeyCredentialRpEntity{
+				ID: rp.id,
+				PublicKeyCredentialEntity: protocol.PublicKeyCredentialEntity{
+					Name: rp.name,
+				},
+			},
+			User: protocol.PublicKeyCredentialUserEntity{
+				ID: user.WebAuthID(),
+				PublicKeyCredentialEntity: protocol.PublicKeyCredentialEntity{
+					Name: user.WebAuthName(),
+				},
+				DisplayName: user.WebAuthDisplayName(),
+			},
+			PubKeyCredParams: credParams,
+			Timeout:          rp.timeout,
+			Attestation:      protocol.AttestationConveyancePreferenceDirect,
+		},
+	}
+
+	for _, opt := range opts {
+		opt(&options.PublicKey)
+	}
+
+	if len(options.PublicKey.PubKeyCredParams) == 0 {
+		return nil, nil, fmt.Errorf("no credential algorithms are requested")
+	}
+	for _, format := range options.PublicKey.AttestationFormats {
+		if !protocol.IsFormatRegistered(format) {
+			return nil, nil, fmt.Errorf("attestation format %q is requested, but not registered", format)
+		}
+	}
+
+	// Enterprise attestation must never be requested unless it has been enabled explicitly
+	enterprise := options.PublicKey.Attestation == protocol.AttestationConveyancePreferenceEnterprise
+	if enterprise && !rp.enterpriseAttestation {
+		return nil, nil, fmt.Errorf("enterprise attestation is requested, but not enabled")
+	}
+
+	session := &SessionData{
+		Challenge:             chal,
+		UserID:                user.WebAuthID(),
+		UserVerification:      options.PublicKey.AuthenticatorSelection.UserVerification,
+		Attestation:           options.PublicKey.Attestation,
+		EnterpriseAttestation: enterprise,
+		Expires:               rp.expires(options.PublicKey.Timeout),
+	}
+	for _, credential := range options.PublicKey.ExcludeCredentials {
+		session.ExcludeCredentials = append(session.ExcludeCredentials, credential.ID)
+	}
+	for _, params := range options.PublicKey.PubKeyCredParams {
+		session.CredentialAlgorithms = append(session.CredentialAlgorithms, params.Algorithm)
+	}
+
+	rp.emit(&Event{Type: EventRegistrationStarted, UserID: session.UserID}, nil)
+
+	return options, session, nil
+}
+
+// FinishRegistration finishes the registration ceremony that has been started by BeginRegistration. The session must be
+// the SessionData that was returned by BeginRegistration and resp the response of navigator.credentials.create(). If
+// the response is valid, the registered credential is returned, which should be stored by the caller. An invalid
+// challenge results in protocol.ErrInvalidChallenge, an invalid origin in protocol.ErrInvalidOrigin, an invalid RP ID
+// hash in protocol.ErrRPIDHashMismatch, which wraps protocol.ErrInvalidOrigin, and an invalid attestation in
+// protocol.ErrInvalidAttestation or protocol.ErrUnsupportedAttestationFormat. The cause of an invalid attestation can
+// be determined using errors.Is with, for instance, protocol.ErrSignatureInvalid or protocol.ErrCertChainInvalid. The
+// attestation formats must be registered by importing them, for instance all at once by importing
+// github.com/keycloud/webauthn/attestation.
+//
+// The challenge of the session is consumed by the ChallengeConsumer before the response is verified, so a session can
+// only be finished once, whether or not the response is valid, and a replay results in ErrChallengeConsumed. By
+// default, consumed challenges are only remembered by this RelyingParty; see WithChallengeConsumer and
+// WithoutChallengeConsumer.
+func (rp *RelyingParty) FinishRegistration(session *SessionData, resp *protocol.AttestationResponse) (*Credential, error) {
+	return rp.FinishRegistrationContext(context.Background(), session, resp)
+}
+
+// FinishRegistrationContext is like FinishRegistration, but the download of metadata and revocation checks are aborted
+// once ctx is done, in which case the error of ctx is returned.
+func (rp *RelyingParty) FinishRegistrationContext(ctx context.Context, session *SessionData, resp *protocol.AttestationResponse) (*Credential, error) {
+	result, err := rp.FinishRegistrationResultContext(ctx, session, resp)
+	if err != nil {
+		return nil, err
+	}
+	return result.Credential, nil
+}
+
+// FinishRegistrationResult is like FinishRegistration, but returns a RegistrationResult, which describes how the
+// attestation has been verified and contains warnings for weaknesses that have been accepted, such as a self
+// attestation that is accepted by the attestation policy.
+func (rp *RelyingParty) FinishRegistrationResult(session *SessionData, resp *protocol.AttestationResponse) (*RegistrationResult, error) {
+	return rp.FinishRegistrationResultContext(context.Background(), session, resp)
+}
+
+// FinishRegistrationResultContext is like FinishRegistrationResult, but aborts like FinishRegistrationContext once ctx
+// is done.
+func (rp *RelyingParty) FinishRegistrationResultContext(ctx context.Context, session *SessionData, resp *protocol.AttestationResponse) (*RegistrationResult, error) {
+	e := &Event{Type: EventRegistrationSucceeded}
+	if session != nil {
+		e.UserID = session.UserID
+	}
+
+	result, err := rp.finishRegistration(ctx, session, resp, e)
+	if err != nil {
+		e.Type = EventRegistrationFailed
+	}
+	rp.emit(e, err)
+
+	return result, err
+}
+
+// finishRegistration finishes the registration ceremony like FinishRegistrationResult. The credential ID, AAGUID and
+// attestation type are set on e once they are known.
+func (rp *RelyingParty) finishRegistration(ctx context.Context, session *SessionData, resp *protocol.AttestationResponse, e *Event) (*RegistrationResult, error) {
+	if session == nil || len(session.Challenge) == 0 {
+		return nil, protocol.ErrInvalidRequest.WithDebug("missing session data")
+	}
+	if session.expired(rp.clock()) {
+		return nil, ErrCeremonyExpired
+	}
+	if err := rp.consumeChallenge(session); err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return nil, protocol.ErrInvalidRequest.WithDebug("missing attestation response")
+	}
+
+	p, err := protocol.ParseAttestationResponse(*resp)
+	if err != nil {
+		return nil, err
+	}
+	e.CredentialID = p.Response.Attestation.AuthData.AttestedCredentialData.CredentialID
+	e.AAGUID = p.Response.Attestation.AuthData.AttestedCredentialData.AAGUID
+
+	rpID := rp.expectedRPID(p.Response.Attestation.AuthData.RPIDHash)
+	opts := rp.attestationOptions()
+	attestationType, trustPath, err := protocol.VerifyAttestationWithOptions(p, session.Challenge, rpID, rp.expectedOrigin(p.Response.ClientData.Origin), opts)
+	if err != nil {
+		return nil, err
+	}
+	// The trust path of an indirect attestation may have been issued by an Anonymization CA
+	indirect := session.Attestation == protocol.AttestationConveyancePreferenceIndirect
+	if indirect && rp.indirectAttestationPolicy == IndirectAttestationNone {
+		attestationType, trustPath = protocol.AttestationTypeNone, nil
+	}
+	e.AttestationType = attestationType
+	if rp.strictAttStmt {
+		if err := p.Response.Attestation.VerifyStatementFields(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.Response.ClientData.VerifyTokenBinding(session.TokenBindingID); err != nil {
+		return nil, err
+	}
+
+	authData := p.Response.Attestation.AuthData
+
+	// Verify that the User Verified bit of the flags in authData is set if user verification is required
+	if session.UserVerification == protocol.UserVerificationRequired && !authData.Flags.UserVerified() {
+		return nil, protocol.ErrUserNotVerified
+	}
+
+	if len(authData.AttestedCredentialData.CredentialID) == 0 {
+		return nil, protocol.ErrMissingAttestedCredentialData.WithDebug("missing credential ID")
+	}
+
+	// Authenticators should refuse to create a credential if one of the excluded credentials is bound to them, but
+	// the client can not be trusted to have passed them
+	if containsCredentialID(session.ExcludeCredentials, authData.AttestedCredentialData.CredentialID) {
+		return nil, ErrCredentialExcluded
+	}
+
+	// Verify that the credential ID is not yet registered for any user
+	if rp.credentialStore != nil {
+		if _, err := rp.credentialStore.GetByCredentialID(authData.AttestedCredentialData.CredentialID); err == nil {
+			return nil, ErrCredentialExcluded.WithDebug("credential is already stored")
+		} else if !errors.Is(err, ErrCredentialNotFound) {
+			return nil, err
+		}
+	}
+
+	// Verify that the algorithm of the credential public key is one of the requested algorithms. Sessions that do not
+	// contain the requested algorithms allow all algorithms.
+	if len(session.CredentialAlgorithms) > 0 {
+		alg, err := cose.Algorithm(authData.AttestedCredentialData.CredentialPublicKey)
+		if err != nil {
+			return nil, protocol.ErrInvalidRequest.WithDebugf("invalid credential public key: %v", err).WithCause(err)
+		}
+		if !containsAlgorithm(session.CredentialAlgorithms, protocol.COSEAlgorithmIdentifier(alg)) {
+			return nil, ErrAlgorithmNotAllowed.WithDebugf("algorithm %d is not requested", alg)
+		}
+	}
+
+	if !rp.nonStandardAlgorithms && cose.IsNonStandardKey(authData.AttestedCredentialData.COSEKey) {
+		return nil, ErrAlgorithmNotAllowed.WithDebug("non-standard algorithms are not enabled")
+	}
+
+	// If attestation roots are configured, the attestation must chain up to one of them. The roots of the metadata
+	// statement take precedence if the authenticator is listed in the metadata.
+	roots := rp.attestationRoots
+	var statement *metadata.MetadataStatement
+	var status metadata.AuthenticatorStatus
+	var authenticator *AuthenticatorModel
+	var warnings []Warning
+	if rp.metadata != nil {
+		entry, err := rp.metadata.LookupEntry(ctx, authData.AttestedCredentialData.AAGUID)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if err == nil {
+			statement, status = entry.MetadataStatement, entry.Status()
+			authenticator = newAuthenticatorModel(entry)
+			if statement != nil {
+				if roots, err = statement.RootCertificates(); err != nil {
+					return nil, err
+				}
+			}
+		} else if errors.Is(err, metadata.ErrNotFound) {
+			warnings = append(warnings, WarningAuthenticatorNotListed)
+		} else {
+			return nil, err
+		}
+	}
+
+	if rp.metadataAlgorithms && statement != nil && len(statement.COSEAlgorithms()) > 0 {
+		alg, err := cose.Algorithm(authData.AttestedCredentialData.CredentialPublicKey)
+		if err != nil {
+			return nil, protocol.ErrInvalidRequest.WithDebugf("invalid credential public key: %v", err).WithCause(err)
+		}
+		if !statement.SupportsAlgorithm(alg) {
+			return nil, ErrAlgorithmNotAllowed.WithDebugf("algorithm %d is not supported by the authenticator according to its metadata", alg)
+		}
+	}
+
+	verify := roots != nil
+	if indirect && rp.indirectAttestationPolicy != IndirectAttestationVerify {
+		verify = false
+	}
+	// Self attestations do not have a certificate chain to verify, so they are decided by the self attestation
+	// predicate instead, if it is set
+	if attestationType == protocol.AttestationTypeSelf && rp.selfAttestationPredicate != nil {
+		verify = false
+	}
+
+	var chain []*x509.Certificate
+	if verify {
+		chains, err := protocol.VerifyCertificateChain(trustPath, roots, opts.CurrentTime)
+		if err != nil {
+			return nil, err
+		}
+		chain = chains[0]
+	} else {
+		chain = trustPath
+	}
+
+	vctx := &VerificationContext{
+		Attestation:     p.Response.Attestation,
+		AttestationType: attestationType,
+		Chain:           chain,
+		Verified:        verify,
+		Indirect:        indirect,
+		AAGUID:          authData.AttestedCredentialData.AAGUID,
+		Transports:      p.RawResponse.Response.Transports,
+		Flags:           authData.Flags,
+		Metadata:        statement,
+		Status:          status,
+	}
+
+	if err := rp.checkAttestationPolicy(vctx); err != nil {
+		return nil, err
+	}
+	if attestationType == protocol.AttestationTypeNone || attestationType == protocol.AttestationTypeSelf {
+		warnings = append(warnings, WarningUntrustedAttestation)
+	} else if !verify {
+		warnings = append(warnings, WarningUnverifiedAttestation)
+	}
+
+	// Attestations without a certificate chain, such as self attestation, can not be revoked. The OCSP responders and
+	// CRL distribution points of an unverified chain are chosen by the client, so they are not contacted.
+	if rp.revocationMode != revocation.ModeNone && len(chain) > 0 && !verify {
+		if rp.revocationMode == revocation.ModeHardFail {
+			return nil, protocol.ErrInvalidAttestation.WithDebug("unable to check revocation of unverified attestation certificate")
+		}
+		warnings = append(warnings, WarningRevocationUnknown)
+	} else if rp.revocationMode != revocation.ModeNone && len(chain) > 0 {
+		err := rp.revocationChecker.CheckContext(ctx, chain)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if errors.Is(err, revocation.ErrRevoked) {
+			return nil, protocol.ErrInvalidAttestation.WithDebug("attestation certificate is revoked").WithCause(err)
+		} else if err != nil && rp.revocationMode == revocation.ModeHardFail {
+			return nil, protocol.ErrInvalidAttestation.WithDebugf("unable to check revocation of attestation certificate: %v", err).WithCause(err)
+		} else if err != nil {
+			warnings = append(warnings, WarningRevocationUnknown)
+		}
+	}
+
+	if err := rp.checkAAGUID(authData.AttestedCredentialData.AAGUID); err != nil {
+		return nil, err
+	}
+	if rp.requireBasicAttestationAAGUID && attestationType == protocol.AttestationTypeBasic && isZeroAAGUID(authData.AttestedCredentialData.AAGUID) {
+		return nil, protocol.ErrInvalidAttestation.WithDebug("basic attestation with the all-zero AAGUID").WithStep(protocol.AttestationStepAAGUID)
+	}
+
+	for _, blocked := range rp.blockedStatuses {
+		if status == blocked {
+			return nil, ErrAuthenticatorStatusNotAllowed.WithDebugf("authenticator status is %s", status)
+		}
+	}
+
+	if rp.attestationDecider != nil {
+		err := rp.attestationDecider(vctx)
+		var perr *protocol.Error
+		if errors.As(err, &perr) {
+			return nil, perr
+		} else if err != nil {
+			return nil, ErrUntrustedAttestation.WithDebug(err.Error()).WithCause(err)
+		}
+	}
+
+	credential := &Credential{
+		ID:           authData.AttestedCredentialData.CredentialID,
+		RPID:         rpID,
+		UserID:       session.UserID,
+		RawPublicKey: authData.AttestedCredentialData.CredentialPublicKey,
+		AAGUID:       authData.AttestedCredentialData.AAGUID,
+		SignCount:    authData.SignCount,
+		Transports:   p.RawResponse.Response.Transports,
+
+		BackupEligible: authData.Flags.BackupEligible(),
+		BackupState:    authData.Flags.BackupState(),
+
+		AttestationType:      attestationType,
+		AttestationTrustPath: chain,
+		AuthenticatorStatus:  status,
+		Authenticator:        authenticator,
+	}
+
+	// If the client did not report the transports, fall back to the transports in the attestation certificate. Since
+	// they are only hints, an invalid extension is ignored.
+	if len(credential.Transports) == 0 && len(chain) > 0 {
+		if transports, err := protocol.CertificateTransports(chain[0]); err == nil {
+			credential.Transports = transports
+		}
+	}
+
+	// The serial number in the attestation certificate identifies the authenticator for enterprise attestation
+	if session.EnterpriseAttestation && rp.enterpriseAttestation && len(chain) > 0 {
+		credential.SerialNumber = chain[0].Subject.SerialNumber
+	}
+
+	credProps, err := p.ClientExtensionResults.CredentialProperties()
+	if err != nil {
+		return nil, err
+	}
+	if credProps != nil && credProps.ResidentKey != nil {
+		credential.ResidentKey = *credProps.ResidentKey
+	}
+
+	if credential.CredentialProtection, err = authData.Extensions.CredProtect(); err != nil {
+		return nil, err
+	}
+	if credential.MinPINLength, err = authData.Extensions.MinPINLength(); err != nil {
+		return nil, err
+	}
+
+	largeBlob, err := p.ClientExtensionResults.LargeBlob()
+	if err != nil {
+		return nil, err
+	}
+	if largeBlob != nil && largeBlob.Supported != nil {
+		credential.LargeBlob = *largeBlob.Supported
+	}
+
+	if rp.credentialStore != nil {
+		if err := rp.credentialStore.Save(credential); err != nil {
+			return nil, err
+		}
+	}
+
+	return &RegistrationResult{
+		Credential:      credential,
+		AttestationType: attestationType,
+		TrustPath:       chain,
+		Verified:        verify,
+		AAGUID:          authData.AttestedCredentialData.AAGUID,
+		Flags:           authData.Flags,
+		Warnings:        warnings,
+	}, nil
+}
+
+// checkAttestationPolicy checks whether the attestation is accepted by the attestation policy. Self attestations are
+// decided by the self attestation predicate instead, if it is set.
+func (rp *RelyingParty) checkAttestationPolicy(ctx *VerificationContext) error {
+	attestationType, verified := ctx.AttestationType, ctx.Verified
+	if attestationType == protocol.AttestationTypeSelf && rp.selfAttestationPredicate != nil {
+		if !rp.selfAttestationPredicate(ctx) {
+			return ErrUntrustedAttestation.WithDebug("self attestation is not accepted for this authenticator")
+		}
+		return nil
+	}
+
+	untrusted := attestationType == protocol.AttestationTypeNone || attestationType == protocol.AttestationTypeSelf
+
+	switch rp.attestationPolicy {
+	case AttestationPolicyRejectUntrusted:
+		if untrusted {
+			return ErrUntrustedAttestation.WithDebugf("%s attestation is not accepted", attestationType)
+		}
+	case AttestationPolicyRequireAttestation:
+		if untrusted || !verified {
+			return ErrUntrustedAttestation.WithDebugf("%s attestation without a verified certificate chain is not accepted", attestationType)
+		}
+	}
+
+	return nil
+}
+
+// checkAAGUID checks whether the authenticator model with the given AAGUID is allowed to register.
+func (rp *RelyingParty) checkAAGUID(aaguid []byte) error {
+	zero := isZeroAAGUID(aaguid)
+	if containsAAGUID(rp.blockedAAGUIDs, aaguid) || (rp.requireAAGUID && zero) {
+		return ErrAAGUIDNotAllowed
+	}
+
+	if rp.allowedAAGUIDs == nil || containsAAGUID(rp.allowedAAGUIDs, aaguid) {
+		return nil
+	}
+
+	if rp.allowZeroAAGUID && zero {
+		return nil
+	}
+
+	return ErrAAGUIDNotAllowed
+}
+
+// isZeroAAGUID returns whether aaguid is the all-zero AAGUID, which authenticators report if they do not identify their
+// model.
+func isZeroAAGUID(aaguid []byte) bool {
+	return bytes.Equal(aaguid, make([]byte, len(aaguid)))
+}
+
+func containsAAGUID(aaguids [][]byte, aaguid []byte) bool {
+	for _, v := range aaguids {
+		if bytes.Equal(v, aaguid) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAlgorithm(algorithms []protocol.COSEAlgorithmIdentifier, alg protocol.COSEAlgorithmIdentifier) bool {
+	for _, a := range algorithms {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
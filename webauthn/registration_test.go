@@ -0,0 +1,1739 @@
+package webauthn_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ugorji/go/codec"
+	"gopkg.in/square/go-jose.v2"
+
+	_ "github.com/keycloud/webauthn/attestation"
+	"github.com/keycloud/webauthn/cose"
+	"github.com/keycloud/webauthn/metadata"
+	"github.com/keycloud/webauthn/protocol"
+	"github.com/keycloud/webauthn/revocation"
+	"github.com/keycloud/webauthn/testutil"
+	"github.com/keycloud/webauthn/webauthn"
+)
+
+type testUser struct {
+	id []byte
+}
+
+func (u *testUser) WebAuthID() []byte {
+	return u.id
+}
+
+func (u *testUser) WebAuthName() string {
+	return "test"
+}
+
+func (u *testUser) WebAuthDisplayName() string {
+	return "test"
+}
+
+func TestBeginRegistration(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	excluded := protocol.PublicKeyCredentialDescriptor{
+		Type: protocol.PublicKeyCredentialTypePublicKey,
+		ID:   []byte("excluded"),
+	}
+
+	options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")}, webauthn.WithExcludeCredentials(excluded))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(options.PublicKey.Challenge) != protocol.ChallengeSize {
+		t.Fatalf("invalid challenge size %d", len(options.PublicKey.Challenge))
+	}
+	if !bytes.Equal(options.PublicKey.Challenge, session.Challenge) {
+		t.Fatal("session challenge does not match challenge in options")
+	}
+	if !bytes.Equal(session.UserID, []byte("test")) {
+		t.Fatal("invalid session user ID")
+	}
+	if options.PublicKey.RP.ID != "localhost" {
+		t.Fatalf("invalid RP ID %q", options.PublicKey.RP.ID)
+	}
+	if options.PublicKey.Timeout != 60000 {
+		t.Fatalf("expected default timeout of 60000 ms, got %d", options.PublicKey.Timeout)
+	}
+	if session.Expires.Before(time.Now()) {
+		t.Fatalf("invalid session expiry %v", session.Expires)
+	}
+	var algorithms []protocol.COSEAlgorithmIdentifier
+	for _, params := range options.PublicKey.PubKeyCredParams {
+		algorithms = append(algorithms, params.Algorithm)
+	}
+	if expected := []protocol.COSEAlgorithmIdentifier{protocol.ES256, protocol.RS256, protocol.EdDSA}; !reflect.DeepEqual(algorithms, expected) {
+		t.Fatalf("expected default algorithms %v, got %v", expected, algorithms)
+	}
+	if len(options.PublicKey.ExcludeCredentials) != 1 || !bytes.Equal(options.PublicKey.ExcludeCredentials[0].ID, excluded.ID) {
+		t.Fatal("invalid excluded credentials")
+	}
+	if len(session.ExcludeCredentials) != 1 || !bytes.Equal(session.ExcludeCredentials[0], excluded.ID) {
+		t.Fatal("excluded credentials are not stored in session")
+	}
+}
+
+func TestBeginRegistrationAttestationFormats(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options, _, err := rp.BeginRegistration(&testUser{id: []byte("test")}, webauthn.WithAttestationFormats([]string{"tpm", "packed"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(options.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte(`"attestationFormats":["tpm","packed"]`)) {
+		t.Fatalf("missing attestation formats in %s", b)
+	}
+
+	// The formats are omitted if they are not set
+	if options, _, err = rp.BeginRegistration(&testUser{id: []byte("test")}); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = json.Marshal(options.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("attestationFormats")) {
+		t.Fatalf("unexpected attestation formats in %s", b)
+	}
+
+	if _, _, err := rp.BeginRegistration(&testUser{id: []byte("test")}, webauthn.WithAttestationFormats([]string{"packed", "unknown"})); err == nil {
+		t.Fatal("expected error for unregistered attestation format")
+	}
+}
+
+func TestBeginRegistrationHints(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name     string
+		hints    []protocol.PublicKeyCredentialHint
+		expected string
+	}{
+		{"Known", []protocol.PublicKeyCredentialHint{protocol.PublicKeyCredentialHintClientDevice, protocol.PublicKeyCredentialHintHybrid}, `"hints":["client-device","hybrid"]`},
+		{"Unknown", []protocol.PublicKeyCredentialHint{"future", protocol.PublicKeyCredentialHintSecurityKey}, `"hints":["security-key"]`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			options, _, err := rp.BeginRegistration(&testUser{id: []byte("test")}, webauthn.WithHints(test.hints))
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := json.Marshal(options.PublicKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Contains(b, []byte(test.expected)) {
+				t.Fatalf("expected %s in %s", test.expected, b)
+			}
+		})
+	}
+
+	// If none of the hints are known, they are omitted
+	options, _, err := rp.BeginRegistration(&testUser{id: []byte("test")}, webauthn.WithHints([]protocol.PublicKeyCredentialHint{"future"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options.PublicKey.Hints != nil {
+		t.Fatalf("unexpected hints %v", options.PublicKey.Hints)
+	}
+}
+
+func TestFinishRegistrationCredentialAlgorithms(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithoutChallengeConsumer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")}, webauthn.WithCredentialAlgorithms([]protocol.COSEAlgorithmIdentifier{protocol.RS256, protocol.ES256}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := options.PublicKey.PubKeyCredParams
+	if len(params) != 2 || params[0].Algorithm != protocol.RS256 || params[1].Algorithm != protocol.ES256 || params[0].Type != protocol.PublicKeyCredentialTypePublicKey {
+		t.Fatalf("invalid credential parameters %+v", params)
+	}
+	if len(session.CredentialAlgorithms) != 2 {
+		t.Fatalf("expected requested algorithms in session, got %v", session.CredentialAlgorithms)
+	}
+
+	if _, _, err := rp.BeginRegistration(&testUser{id: []byte("test")}, webauthn.WithCredentialAlgorithms(nil)); err == nil {
+		t.Fatal("expected error without algorithms")
+	}
+
+	r := protocol.CredentialCreationOptions{}
+	if err := json.Unmarshal([]byte(registrationRequest), &r); err != nil {
+		t.Fatal(err)
+	}
+	resp := &protocol.AttestationResponse{}
+	if err := json.Unmarshal([]byte(registrationResponse), resp); err != nil {
+		t.Fatal(err)
+	}
+
+	// The vector is an ES256 credential
+	for _, test := range []struct {
+		name       string
+		algorithms []protocol.COSEAlgorithmIdentifier
+		allowed    bool
+	}{
+		{"Requested", []protocol.COSEAlgorithmIdentifier{protocol.RS256, protocol.ES256}, true},
+		{"Not requested", []protocol.COSEAlgorithmIdentifier{protocol.RS256, protocol.EdDSA}, false},
+		{"Missing in session", nil, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			session := &webauthn.SessionData{Challenge: r.PublicKey.Challenge, CredentialAlgorithms: test.algorithms}
+			_, err := rp.FinishRegistration(session, resp)
+			if test.allowed && err != nil {
+				t.Fatal(err)
+			} else if !test.allowed && !errors.Is(err, webauthn.ErrAlgorithmNotAllowed) {
+				t.Fatalf("expected ErrAlgorithmNotAllowed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFinishRegistrationNonStandardAlgorithms(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		opts    []webauthn.Option
+		allowed bool
+	}{
+		{"Disabled", nil, false},
+		{"Enabled", []webauthn.Option{webauthn.WithNonStandardAlgorithms()}, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			authenticator, err := testutil.NewVirtualAuthenticator()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if authenticator.PrivateKey, err = ecdsa.GenerateKey(cose.Secp256k1(), rand.Reader); err != nil {
+				t.Fatal(err)
+			}
+
+			options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")}, webauthn.WithCredentialAlgorithms([]protocol.COSEAlgorithmIdentifier{protocol.ES256K}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			attestation, err := authenticator.Register("https://localhost", options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = rp.FinishRegistration(session, attestation)
+			if test.allowed && err != nil {
+				t.Fatal(err)
+			} else if !test.allowed && !errors.Is(err, webauthn.ErrAlgorithmNotAllowed) {
+				t.Fatalf("expected ErrAlgorithmNotAllowed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFinishRegistrationExcludeCredentials(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithoutChallengeConsumer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential := register(t, rp, registrationRequest, registrationResponse)
+
+	options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")}, webauthn.WithExcludeCredentialIDs(credential.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(options.PublicKey.ExcludeCredentials) != 1 || options.PublicKey.ExcludeCredentials[0].Type != protocol.PublicKeyCredentialTypePublicKey {
+		t.Fatal("invalid excluded credentials")
+	}
+
+	r := protocol.CredentialCreationOptions{}
+	if err := json.Unmarshal([]byte(registrationRequest), &r); err != nil {
+		t.Fatal(err)
+	}
+	session.Challenge = r.PublicKey.Challenge
+
+	resp := &protocol.AttestationResponse{}
+	if err := json.Unmarshal([]byte(registrationResponse), resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := rp.FinishRegistration(session, resp); err != webauthn.ErrCredentialExcluded {
+		t.Fatalf("expected ErrCredentialExcluded, got %v", err)
+	}
+}
+
+func TestFinishRegistration(t *testing.T) {
+	r := protocol.CredentialCreationOptions{}
+	if err := json.Unmarshal([]byte(registrationRequest), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := &protocol.AttestationResponse{}
+	if err := json.Unmarshal([]byte(registrationResponse), resp); err != nil {
+		t.Fatal(err)
+	}
+
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential, err := rp.FinishRegistration(&webauthn.SessionData{Challenge: r.PublicKey.Challenge}, resp)
+	if err != nil {
+		e := protocol.ToWebAuthnError(err)
+		t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+	}
+
+	if !bytes.Equal(credential.ID, resp.RawID) {
+		t.Fatal("credential ID does not match raw ID")
+	}
+	if len(credential.RawPublicKey) == 0 {
+		t.Fatal("missing credential public key")
+	}
+
+	t.Run("Invalid challenge", func(t *testing.T) {
+		_, err := rp.FinishRegistration(&webauthn.SessionData{Challenge: []byte("invalid")}, resp)
+		if protocol.ToWebAuthnError(err).Name != protocol.ErrInvalidChallenge.Name {
+			t.Fatalf("expected invalid challenge, got %v", err)
+		}
+	})
+
+	t.Run("Invalid origin", func(t *testing.T) {
+		rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = rp.FinishRegistration(&webauthn.SessionData{Challenge: r.PublicKey.Challenge}, resp)
+		if protocol.ToWebAuthnError(err).Name != protocol.ErrInvalidOrigin.Name {
+			t.Fatalf("expected invalid origin, got %v", err)
+		}
+	})
+
+	t.Run("Invalid RP ID", func(t *testing.T) {
+		rp, err := webauthn.NewRelyingParty("webauthn-test", "example.com", "https://localhost")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = rp.FinishRegistration(&webauthn.SessionData{Challenge: r.PublicKey.Challenge}, resp)
+		if !errors.Is(err, protocol.ErrRPIDHashMismatch) {
+			t.Fatalf("expected RP ID hash mismatch, got %v", err)
+		}
+		if !errors.Is(err, protocol.ErrInvalidOrigin) {
+			t.Fatalf("expected RP ID hash mismatch to wrap invalid origin, got %v", err)
+		}
+	})
+}
+
+func TestFinishRegistrationStrictAttStmt(t *testing.T) {
+	authenticator, err := testutil.NewVirtualAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// register registers a new credential, of which the attestation statement contains the given additional fields
+	register := func(t *testing.T, rp *webauthn.RelyingParty, fields map[string]interface{}) error {
+		options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		attestation, err := authenticator.Register("https://localhost", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		handle := &codec.CborHandle{}
+		var object map[string]interface{}
+		if err := codec.NewDecoderBytes(attestation.Response.AttestationObject, handle).Decode(&object); err != nil {
+			t.Fatal(err)
+		}
+		attStmt := make(map[string]interface{})
+		for key, value := range object["attStmt"].(map[interface{}]interface{}) {
+			attStmt[key.(string)] = value
+		}
+		for key, value := range fields {
+			attStmt[key] = value
+		}
+		object["attStmt"] = attStmt
+		var b []byte
+		if err := codec.NewEncoderBytes(&b, handle).Encode(object); err != nil {
+			t.Fatal(err)
+		}
+		attestation.Response.AttestationObject = b
+
+		_, err = rp.FinishRegistration(session, attestation)
+		return err
+	}
+
+	strict, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithStrictAttStmt())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := register(t, strict, nil); err != nil {
+		e := protocol.ToWebAuthnError(err)
+		t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+	}
+	if err := register(t, strict, map[string]interface{}{"vendor": true}); !errors.Is(err, protocol.ErrUnexpectedAttStmtField) {
+		t.Fatalf("expected ErrUnexpectedAttStmtField, got %v", err)
+	}
+
+	// Unexpected fields are ignored by default
+	lenient, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := register(t, lenient, map[string]interface{}{"vendor": true}); err != nil {
+		e := protocol.ToWebAuthnError(err)
+		t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+	}
+}
+
+func TestFinishRegistrationAttestationRoots(t *testing.T) {
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(attestationRoot)) {
+		t.Fatal("unable to parse attestation root")
+	}
+
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithAttestationRoots(roots))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	register(t, rp, chainRegistrationRequest, chainRegistrationResponse)
+
+	t.Run("Unknown root", func(t *testing.T) {
+		rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithAttestationRoots(x509.NewCertPool()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := finishRegistration(rp, chainRegistrationRequest, chainRegistrationResponse); !errors.Is(err, protocol.ErrCertChainInvalid) {
+			t.Fatalf("expected invalid certificate chain, got %v", err)
+		}
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		// The attestation certificate is valid until 2040, and the chain until 2045
+		rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost",
+			webauthn.WithAttestationRoots(roots),
+			webauthn.WithClock(func() time.Time { return time.Date(2041, 1, 1, 0, 0, 0, 0, time.UTC) }),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := finishRegistration(rp, chainRegistrationRequest, chainRegistrationResponse); !errors.Is(err, protocol.ErrCertExpired) {
+			t.Fatalf("expected ErrCertExpired, got %v", err)
+		}
+	})
+
+	t.Run("Self attestation", func(t *testing.T) {
+		if _, err := finishRegistration(rp, registrationRequest, registrationResponse); !errors.Is(err, protocol.ErrCertChainInvalid) {
+			t.Fatalf("expected invalid certificate chain, got %v", err)
+		}
+	})
+}
+
+func TestFinishRegistrationIndirectAttestation(t *testing.T) {
+	r := protocol.CredentialCreationOptions{}
+	if err := json.Unmarshal([]byte(chainRegistrationRequest), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name            string
+		attestation     protocol.AttestationConveyancePreference
+		policy          webauthn.IndirectAttestationPolicy
+		opts            []webauthn.Option
+		err             error
+		attestationType protocol.AttestationType
+	}{
+		{"Verify", protocol.AttestationConveyancePreferenceIndirect, webauthn.IndirectAttestationVerify, nil, protocol.ErrCertChainInvalid, ""},
+		{"Unverified", protocol.AttestationConveyancePreferenceIndirect, webauthn.IndirectAttestationUnverified, nil, nil, protocol.AttestationTypeBasic},
+		{"Unverified with required attestation", protocol.AttestationConveyancePreferenceIndirect, webauthn.IndirectAttestationUnverified, []webauthn.Option{webauthn.WithAttestationPolicy(webauthn.AttestationPolicyRequireAttestation)}, webauthn.ErrUntrustedAttestation, ""},
+		{"None", protocol.AttestationConveyancePreferenceIndirect, webauthn.IndirectAttestationNone, nil, nil, protocol.AttestationTypeNone},
+		{"Direct", protocol.AttestationConveyancePreferenceDirect, webauthn.IndirectAttestationUnverified, nil, protocol.ErrCertChainInvalid, ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			// The root of the chain vector is not configured, as if it was issued by an unknown Anonymization CA
+			opts := append([]webauthn.Option{webauthn.WithAttestationRoots(x509.NewCertPool()), webauthn.WithIndirectAttestationPolicy(test.policy)}, test.opts...)
+			rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp := &protocol.AttestationResponse{}
+			if err := json.Unmarshal([]byte(chainRegistrationResponse), resp); err != nil {
+				t.Fatal(err)
+			}
+			credential, err := rp.FinishRegistration(&webauthn.SessionData{Challenge: r.PublicKey.Challenge, Attestation: test.attestation}, resp)
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Fatalf("expected %v, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				e := protocol.ToWebAuthnError(err)
+				t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+			}
+
+			if credential.AttestationType != test.attestationType {
+				t.Fatalf("expected attestation type %q, got %q", test.attestationType, credential.AttestationType)
+			}
+			if (test.attestationType == protocol.AttestationTypeBasic) != (len(credential.AttestationTrustPath) > 0) {
+				t.Fatalf("invalid attestation trust path of length %d", len(credential.AttestationTrustPath))
+			}
+		})
+	}
+
+	t.Run("Session", func(t *testing.T) {
+		rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, session, err := rp.BeginRegistration(&testUser{id: []byte("test")}, webauthn.WithAttestationConveyance(protocol.AttestationConveyancePreferenceIndirect))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if session.Attestation != protocol.AttestationConveyancePreferenceIndirect {
+			t.Fatalf("expected indirect attestation in session, got %q", session.Attestation)
+		}
+	})
+}
+
+func TestFinishRegistrationAttestationDecider(t *testing.T) {
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(attestationRoot)) {
+		t.Fatal("unable to parse attestation root")
+	}
+
+	errVendor := errors.New("unexpected vendor")
+
+	for _, test := range []struct {
+		name string
+		err  error
+	}{
+		{"Accepted", nil},
+		{"Rejected", errVendor},
+		{"Rejected with protocol error", webauthn.ErrAAGUIDNotAllowed},
+		{"Rejected with wrapped protocol error", fmt.Errorf("vendor policy: %w", webauthn.ErrAAGUIDNotAllowed)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var ctx *webauthn.VerificationContext
+			rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost",
+				webauthn.WithAttestationRoots(roots),
+				webauthn.WithAttestationDecider(func(c *webauthn.VerificationContext) error {
+					ctx = c
+					return test.err
+				}),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			credential, err := finishRegistration(rp, chainRegistrationRequest, chainRegistrationResponse)
+			if ctx == nil {
+				t.Fatal("decider is not called")
+			}
+			if ctx.AttestationType != protocol.AttestationTypeBasic || !ctx.Verified || len(ctx.Chain) != 3 || ctx.Metadata != nil {
+				t.Fatalf("unexpected verification context %+v", ctx)
+			}
+			if ctx.Chain[0].Subject.OrganizationalUnit[0] != "Authenticator Attestation" {
+				t.Fatalf("unexpected attestation certificate %v", ctx.Chain[0].Subject)
+			}
+
+			switch test.err {
+			case nil:
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				if !bytes.Equal(ctx.AAGUID, credential.AAGUID) {
+					t.Fatalf("expected AAGUID %x, got %x", credential.AAGUID, ctx.AAGUID)
+				}
+			case errVendor:
+				if !errors.Is(err, webauthn.ErrUntrustedAttestation) || !errors.Is(err, errVendor) {
+					t.Fatalf("expected ErrUntrustedAttestation caused by the decider, got %v", err)
+				}
+			default:
+				if err != webauthn.ErrAAGUIDNotAllowed {
+					t.Fatalf("expected %v, got %v", webauthn.ErrAAGUIDNotAllowed, err)
+				}
+			}
+		})
+	}
+}
+
+func TestFinishRegistrationAttestationPolicy(t *testing.T) {
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(attestationRoot)) {
+		t.Fatal("unable to parse attestation root")
+	}
+
+	vectors := []struct {
+		name              string
+		id, origin        string
+		request, response string
+		attestationType   protocol.AttestationType
+	}{
+		{"None", "example.com", "https://app.example.com", subdomainRegistrationRequest, subdomainRegistrationResponse, protocol.AttestationTypeNone},
+		{"Self", "localhost", "https://localhost", registrationRequest, registrationResponse, protocol.AttestationTypeSelf},
+		{"Basic", "localhost", "https://localhost", chainRegistrationRequest, chainRegistrationResponse, protocol.AttestationTypeBasic},
+	}
+
+	for _, test := range []struct {
+		name     string
+		policy   webauthn.AttestationPolicy
+		roots    *x509.CertPool
+		accepted []bool
+	}{
+		{"Accept untrusted", webauthn.AttestationPolicyAcceptUntrusted, nil, []bool{true, true, true}},
+		{"Reject untrusted", webauthn.AttestationPolicyRejectUntrusted, nil, []bool{false, false, true}},
+		{"Require attestation", webauthn.AttestationPolicyRequireAttestation, nil, []bool{false, false, false}},
+		{"Require attestation with roots", webauthn.AttestationPolicyRequireAttestation, roots, []bool{false, false, true}},
+	} {
+		for i, vector := range vectors {
+			t.Run(test.name+"/"+vector.name, func(t *testing.T) {
+				opts := []webauthn.Option{webauthn.WithAttestationPolicy(test.policy)}
+				if test.roots != nil {
+					opts = append(opts, webauthn.WithAttestationRoots(test.roots))
+				}
+
+				rp, err := webauthn.NewRelyingParty("webauthn-test", vector.id, vector.origin, opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				credential, err := finishRegistration(rp, vector.request, vector.response)
+				if !test.accepted[i] {
+					// With roots, attestations without a chain are already rejected when verifying the chain
+					if err == nil || (test.roots == nil && protocol.ToWebAuthnError(err).Name != webauthn.ErrUntrustedAttestation.Name) {
+						t.Fatalf("expected untrusted attestation, got %v", err)
+					}
+					return
+				}
+				if err != nil {
+					e := protocol.ToWebAuthnError(err)
+					t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+				}
+
+				if credential.AttestationType != vector.attestationType {
+					t.Fatalf("expected attestation type %q, got %q", vector.attestationType, credential.AttestationType)
+				}
+				if (vector.attestationType == protocol.AttestationTypeBasic) != (len(credential.AttestationTrustPath) > 0) {
+					t.Fatalf("invalid attestation trust path of length %d", len(credential.AttestationTrustPath))
+				}
+			})
+		}
+	}
+}
+
+func TestFinishRegistrationAAGUIDs(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The chain vector has a random AAGUID, the self attestation vector the all-zero AAGUID
+	aaguid := register(t, rp, chainRegistrationRequest, chainRegistrationResponse).AAGUID
+	zero := make([]byte, 16)
+
+	for _, test := range []struct {
+		name     string
+		opts     []webauthn.Option
+		request  string
+		response string
+		allowed  bool
+	}{
+		{"Allowed", []webauthn.Option{webauthn.WithAllowedAAGUIDs([][]byte{aaguid})}, chainRegistrationRequest, chainRegistrationResponse, true},
+		{"Not allowed", []webauthn.Option{webauthn.WithAllowedAAGUIDs([][]byte{zero})}, chainRegistrationRequest, chainRegistrationResponse, false},
+		{"Blocked", []webauthn.Option{webauthn.WithBlockedAAGUIDs([][]byte{aaguid})}, chainRegistrationRequest, chainRegistrationResponse, false},
+		{"Allowed and blocked", []webauthn.Option{webauthn.WithAllowedAAGUIDs([][]byte{aaguid}), webauthn.WithBlockedAAGUIDs([][]byte{aaguid})}, chainRegistrationRequest, chainRegistrationResponse, false},
+		{"Zero not allowed", []webauthn.Option{webauthn.WithAllowedAAGUIDs([][]byte{aaguid})}, registrationRequest, registrationResponse, false},
+		{"Zero allowed", []webauthn.Option{webauthn.WithAllowedAAGUIDs([][]byte{aaguid}), webauthn.WithAllowZeroAAGUID(true)}, registrationRequest, registrationResponse, true},
+		{"Required", []webauthn.Option{webauthn.WithRequireAAGUID()}, chainRegistrationRequest, chainRegistrationResponse, true},
+		{"Required and zero", []webauthn.Option{webauthn.WithRequireAAGUID()}, registrationRequest, registrationResponse, false},
+		{"Required and zero allowed", []webauthn.Option{webauthn.WithRequireAAGUID(), webauthn.WithAllowedAAGUIDs([][]byte{zero}), webauthn.WithAllowZeroAAGUID(true)}, registrationRequest, registrationResponse, false},
+		{"Allowed string", []webauthn.Option{webauthn.WithAllowedAAGUIDStrings([]string{strings.ToUpper(protocol.FormatAAGUID(aaguid))})}, chainRegistrationRequest, chainRegistrationResponse, true},
+		{"Blocked string", []webauthn.Option{webauthn.WithBlockedAAGUIDStrings([]string{protocol.FormatAAGUID(aaguid)})}, chainRegistrationRequest, chainRegistrationResponse, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = finishRegistration(rp, test.request, test.response)
+			if test.allowed && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			} else if !test.allowed && err != webauthn.ErrAAGUIDNotAllowed {
+				t.Fatalf("expected ErrAAGUIDNotAllowed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFinishRegistrationBasicAttestationAAGUID(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Country:            []string{"NL"},
+			Organization:       []string{"WebAuthn Test"},
+			OrganizationalUnit: []string{"Authenticator Attestation"},
+			CommonName:         "WebAuthn Test Batch",
+		},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter:  time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name        string
+		aaguid      []byte
+		attestation bool
+		valid       bool
+	}{
+		{"Basic attestation", bytes.Repeat([]byte{0x42}, 16), true, true},
+		{"Basic attestation with zero AAGUID", make([]byte, 16), true, false},
+		{"Self attestation with zero AAGUID", make([]byte, 16), false, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithRequireBasicAttestationAAGUID())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			authenticator, err := testutil.NewVirtualAuthenticator()
+			if err != nil {
+				t.Fatal(err)
+			}
+			authenticator.AAGUID = test.aaguid
+			if test.attestation {
+				authenticator.AttestationKey, authenticator.AttestationCertificates = key, [][]byte{der}
+			}
+
+			options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			attestation, err := authenticator.Register("https://localhost", options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = rp.FinishRegistration(session, attestation)
+			if test.valid && err != nil {
+				e := protocol.ToWebAuthnError(err)
+				t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+			} else if !test.valid && protocol.ToWebAuthnError(err).Step != protocol.AttestationStepAAGUID {
+				t.Fatalf("expected error at step %q, got %v", protocol.AttestationStepAAGUID, err)
+			}
+		})
+	}
+}
+
+func TestAAGUIDStrings(t *testing.T) {
+	for _, opt := range []webauthn.Option{
+		webauthn.WithAllowedAAGUIDStrings([]string{"cb69481e-8ff7-4039-93ec-0a2729a154a8", "cb69481e8ff7403993ec0a2729a154a8"}),
+		webauthn.WithBlockedAAGUIDStrings([]string{"cb69481e-8ff7-4039-93ec-0a2729a154"}),
+	} {
+		if _, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", opt); err == nil {
+			t.Fatal("expected error for invalid AAGUID")
+		}
+	}
+}
+
+func TestFinishRegistrationBlockedStatuses(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aaguid := register(t, rp, chainRegistrationRequest, chainRegistrationResponse).AAGUID
+
+	for _, test := range []struct {
+		name    string
+		reports []metadata.StatusReport
+		status  metadata.AuthenticatorStatus
+	}{
+		{"Certified", []metadata.StatusReport{{Status: metadata.StatusFIDOCertified, EffectiveDate: "2020-01-01"}}, metadata.StatusFIDOCertified},
+		{"Revoked", []metadata.StatusReport{
+			{Status: metadata.StatusFIDOCertified, EffectiveDate: "2020-01-01"},
+			{Status: metadata.StatusRevoked, EffectiveDate: "2021-01-01"},
+		}, metadata.StatusRevoked},
+		{"Compromise fixed", []metadata.StatusReport{
+			{Status: metadata.StatusUpdateAvailable, EffectiveDate: "2021-06-01"},
+			{Status: metadata.StatusUserVerificationBypass, EffectiveDate: "2021-01-01"},
+		}, metadata.StatusUpdateAvailable},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			server, roots := newMetadataServer(t, []metadata.Entry{{AAGUID: protocol.FormatAAGUID(aaguid), StatusReports: test.reports}})
+			defer server.Close()
+
+			rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost",
+				webauthn.WithMetadata(&metadata.Service{URL: server.URL, Roots: roots}),
+				webauthn.WithBlockedStatuses(metadata.CompromisedStatuses...),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			credential, err := finishRegistration(rp, chainRegistrationRequest, chainRegistrationResponse)
+			if test.status == metadata.StatusRevoked {
+				if !errors.Is(err, webauthn.ErrAuthenticatorStatusNotAllowed) {
+					t.Fatalf("expected ErrAuthenticatorStatusNotAllowed, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if credential.AuthenticatorStatus != test.status {
+				t.Fatalf("expected status %s, got %s", test.status, credential.AuthenticatorStatus)
+			}
+		})
+	}
+
+	// Authenticators that are not listed are not affected
+	server, roots := newMetadataServer(t, nil)
+	defer server.Close()
+
+	rp, err = webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost",
+		webauthn.WithMetadata(&metadata.Service{URL: server.URL, Roots: roots}),
+		webauthn.WithBlockedStatuses(metadata.CompromisedStatuses...),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credential := register(t, rp, chainRegistrationRequest, chainRegistrationResponse); credential.AuthenticatorStatus != "" {
+		t.Fatalf("expected no status, got %s", credential.AuthenticatorStatus)
+	}
+}
+
+func TestFinishRegistrationAuthenticatorModel(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aaguid := register(t, rp, chainRegistrationRequest, chainRegistrationResponse).AAGUID
+
+	block, _ := pem.Decode([]byte(attestationRoot))
+	server, roots := newMetadataServer(t, []metadata.Entry{{
+		AAGUID: protocol.FormatAAGUID(aaguid),
+		MetadataStatement: &metadata.MetadataStatement{
+			AAGUID:                      protocol.FormatAAGUID(aaguid),
+			Description:                 "WebAuthn Test Authenticator",
+			Icon:                        "data:image/png;base64,iVBORw0KGgo=",
+			AuthenticationAlgorithms:    []string{"secp256r1_ecdsa_sha256_raw"},
+			AttestationRootCertificates: []string{base64.StdEncoding.EncodeToString(block.Bytes)},
+		},
+		StatusReports: []metadata.StatusReport{
+			{Status: metadata.StatusFIDOCertifiedL1, EffectiveDate: "2020-01-01"},
+			{Status: metadata.StatusUpdateAvailable, EffectiveDate: "2021-01-01"},
+		},
+	}})
+	defer server.Close()
+
+	rp, err = webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithMetadata(&metadata.Service{URL: server.URL, Roots: roots}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	credential := register(t, rp, chainRegistrationRequest, chainRegistrationResponse)
+	expected := &webauthn.AuthenticatorModel{
+		Name:                     "WebAuthn Test Authenticator",
+		Icon:                     "data:image/png;base64,iVBORw0KGgo=",
+		AuthenticationAlgorithms: []string{"secp256r1_ecdsa_sha256_raw"},
+		CertificationLevel:       metadata.StatusFIDOCertifiedL1,
+	}
+	if !reflect.DeepEqual(credential.Authenticator, expected) {
+		t.Fatalf("expected authenticator %+v, got %+v", expected, credential.Authenticator)
+	}
+
+	// Authenticators that are not listed are not described
+	if credential := register(t, rp, registrationRequest, registrationResponse); credential.Authenticator != nil {
+		t.Fatalf("expected no authenticator, got %+v", credential.Authenticator)
+	}
+}
+
+func TestFinishRegistrationMetadataAlgorithms(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aaguid := register(t, rp, chainRegistrationRequest, chainRegistrationResponse).AAGUID
+	block, _ := pem.Decode([]byte(attestationRoot))
+
+	for _, test := range []struct {
+		name       string
+		algorithms []string
+		err        error
+	}{
+		{"Supported algorithm", []string{"rsassa_pkcsv15_sha256_raw", "secp256r1_ecdsa_sha256_raw"}, nil},
+		{"Unsupported algorithm", []string{"rsassa_pkcsv15_sha256_raw"}, webauthn.ErrAlgorithmNotAllowed},
+		{"Unknown algorithms", []string{"sm2_sm3_raw"}, nil},
+		{"No algorithms", nil, nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			server, roots := newMetadataServer(t, []metadata.Entry{{
+				AAGUID: protocol.FormatAAGUID(aaguid),
+				MetadataStatement: &metadata.MetadataStatement{
+					AAGUID:                      protocol.FormatAAGUID(aaguid),
+					AuthenticationAlgorithms:    test.algorithms,
+					AttestationRootCertificates: []string{base64.StdEncoding.EncodeToString(block.Bytes)},
+				},
+			}})
+			defer server.Close()
+
+			rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost",
+				webauthn.WithMetadata(&metadata.Service{URL: server.URL, Roots: roots}),
+				webauthn.WithMetadataAlgorithms(),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := finishRegistration(rp, chainRegistrationRequest, chainRegistrationResponse); !errors.Is(err, test.err) {
+				t.Fatalf("expected %v, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestFinishRegistrationResult(t *testing.T) {
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(attestationRoot)) {
+		t.Fatal("invalid attestation root")
+	}
+	server, metadataRoots := newMetadataServer(t, nil)
+	defer server.Close()
+
+	for _, test := range []struct {
+		name            string
+		opts            []webauthn.Option
+		request         string
+		response        string
+		attestationType protocol.AttestationType
+		verified        bool
+		warnings        []webauthn.Warning
+	}{
+		{"Self attestation", nil, registrationRequest, registrationResponse, protocol.AttestationTypeSelf, false, []webauthn.Warning{webauthn.WarningUntrustedAttestation}},
+		{"Unverified attestation", nil, chainRegistrationRequest, chainRegistrationResponse, protocol.AttestationTypeBasic, false, []webauthn.Warning{webauthn.WarningUnverifiedAttestation}},
+		{"Verified attestation", []webauthn.Option{webauthn.WithAttestationRoots(roots)}, chainRegistrationRequest, chainRegistrationResponse, protocol.AttestationTypeBasic, true, nil},
+		{"Not listed in metadata", []webauthn.Option{
+			webauthn.WithAttestationRoots(roots),
+			webauthn.WithMetadata(&metadata.Service{URL: server.URL, Roots: metadataRoots}),
+		}, chainRegistrationRequest, chainRegistrationResponse, protocol.AttestationTypeBasic, true, []webauthn.Warning{webauthn.WarningAuthenticatorNotListed}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := protocol.CredentialCreationOptions{}
+			if err := json.Unmarshal([]byte(test.request), &r); err != nil {
+				t.Fatal(err)
+			}
+			resp := &protocol.AttestationResponse{}
+			if err := json.Unmarshal([]byte(test.response), resp); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := rp.FinishRegistrationResult(&webauthn.SessionData{Challenge: r.PublicKey.Challenge}, resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Credential == nil || !bytes.Equal(result.AAGUID, result.Credential.AAGUID) {
+				t.Fatalf("expected credential with AAGUID %x, got %+v", result.AAGUID, result.Credential)
+			}
+			if result.AttestationType != test.attestationType || result.Verified != test.verified {
+				t.Fatalf("expected %s attestation with verified %t, got %s with verified %t", test.attestationType, test.verified, result.AttestationType, result.Verified)
+			}
+			if test.attestationType == protocol.AttestationTypeBasic && len(result.TrustPath) == 0 {
+				t.Fatal("expected trust path")
+			}
+			if !result.Flags.UserPresent() {
+				t.Fatal("expected user present flag")
+			}
+			if !reflect.DeepEqual(result.Warnings, test.warnings) {
+				t.Fatalf("expected warnings %v, got %v", test.warnings, result.Warnings)
+			}
+		})
+	}
+}
+
+func TestFinishRegistrationSelfAttestationPredicate(t *testing.T) {
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(attestationRoot)) {
+		t.Fatal("unable to parse attestation root")
+	}
+
+	rejectUntrusted, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost",
+		webauthn.WithAttestationPolicy(webauthn.AttestationPolicyRejectUntrusted),
+		webauthn.WithSelfAttestationPredicate(webauthn.PlatformAuthenticator),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Self attestations can not chain up to the roots, so they are decided by the predicate as well
+	requireAttestation, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost",
+		webauthn.WithAttestationRoots(roots),
+		webauthn.WithAttestationPolicy(webauthn.AttestationPolicyRequireAttestation),
+		webauthn.WithSelfAttestationPredicate(webauthn.PlatformAuthenticator),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rp := range []struct {
+		name string
+		rp   *webauthn.RelyingParty
+	}{
+		{"Reject untrusted", rejectUntrusted},
+		{"Require attestation", requireAttestation},
+	} {
+		t.Run(rp.name, func(t *testing.T) {
+			testSelfAttestationPredicate(t, rp.rp)
+		})
+	}
+
+	// Attestations with a certificate chain are decided by the attestation policy
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost",
+		webauthn.WithSelfAttestationPredicate(func(ctx *webauthn.VerificationContext) bool { return false }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	register(t, rp, chainRegistrationRequest, chainRegistrationResponse)
+	if _, err := finishRegistration(requireAttestation, chainRegistrationRequest, chainRegistrationResponse); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testSelfAttestationPredicate registers self attestations with rp, of which the self attestation predicate is
+// PlatformAuthenticator.
+func testSelfAttestationPredicate(t *testing.T, rp *webauthn.RelyingParty) {
+	for _, test := range []struct {
+		name       string
+		transports []protocol.AuthenticatorTransport
+		flags      protocol.AuthenticatorDataFlags
+		accepted   bool
+	}{
+		{"Platform authenticator", []protocol.AuthenticatorTransport{protocol.AuthenticatorTransportInternal}, 0, true},
+		{"Platform authenticator with hybrid", []protocol.AuthenticatorTransport{protocol.AuthenticatorTransportInternal, protocol.AuthenticatorTransportHybrid}, 0, true},
+		{"Roaming authenticator", []protocol.AuthenticatorTransport{protocol.AuthenticatorTransportUSB}, 0, false},
+		{"Roaming authenticator with internal", []protocol.AuthenticatorTransport{protocol.AuthenticatorTransportInternal, protocol.AuthenticatorTransportNFC}, 0, false},
+		{"Backup eligible without transports", nil, protocol.AuthenticatorDataFlagBackupEligible, true},
+		{"No transports", nil, 0, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			authenticator, err := testutil.NewVirtualAuthenticator()
+			if err != nil {
+				t.Fatal(err)
+			}
+			authenticator.Flags |= test.flags
+
+			options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			attestation, err := authenticator.Register("https://localhost", options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			attestation.Response.Transports = test.transports
+
+			_, err = rp.FinishRegistration(session, attestation)
+			if test.accepted && err != nil {
+				t.Fatal(err)
+			}
+			if !test.accepted && !errors.Is(err, webauthn.ErrUntrustedAttestation) {
+				t.Fatalf("expected ErrUntrustedAttestation, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFinishRegistrationCredentialProtection(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options, _, err := rp.BeginRegistration(&testUser{id: []byte("test")}, webauthn.WithCredentialProtection(protocol.CredentialProtectionUserVerificationRequired, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy := options.PublicKey.Extensions["credentialProtectionPolicy"]; policy != protocol.CredentialProtectionUserVerificationRequired {
+		t.Fatalf("invalid credentialProtectionPolicy %v", policy)
+	}
+	if enforce := options.PublicKey.Extensions["enforceCredentialProtectionPolicy"]; enforce != true {
+		t.Fatalf("invalid enforceCredentialProtectionPolicy %v", enforce)
+	}
+
+	credential := register(t, rp, credProtectRegistrationRequest, credProtectRegistrationResponse)
+	if credential.CredentialProtection != protocol.CredentialProtectionUserVerificationRequired {
+		t.Fatalf("expected credential protection %q, got %q", protocol.CredentialProtectionUserVerificationRequired, credential.CredentialProtection)
+	}
+
+	// Authenticators that do not support the extension do not report a policy
+	if credential := register(t, rp, registrationRequest, registrationResponse); credential.CredentialProtection != "" {
+		t.Fatalf("expected no credential protection, got %q", credential.CredentialProtection)
+	}
+}
+
+func TestFinishRegistrationMinPINLength(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name       string
+		extensions map[string]interface{}
+		length     int
+	}{
+		{"Reported", map[string]interface{}{"minPinLength": uint64(8)}, 8},
+		{"Not reported", nil, 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			authenticator, err := testutil.NewVirtualAuthenticator()
+			if err != nil {
+				t.Fatal(err)
+			}
+			authenticator.Extensions = test.extensions
+
+			options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")}, webauthn.WithMinPINLength())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if requested := options.PublicKey.Extensions["minPinLength"]; requested != true {
+				t.Fatalf("invalid minPinLength %v", requested)
+			}
+
+			attestation, err := authenticator.Register("https://localhost", options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			credential, err := rp.FinishRegistration(session, attestation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if credential.MinPINLength != test.length {
+				t.Fatalf("expected minimum PIN length %d, got %d", test.length, credential.MinPINLength)
+			}
+		})
+	}
+}
+
+func TestFinishRegistrationCredentialProperties(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithoutChallengeConsumer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := protocol.CredentialCreationOptions{}
+	if err := json.Unmarshal([]byte(registrationRequest), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name        string
+		results     string
+		residentKey bool
+		valid       bool
+	}{
+		{"Resident key", `{"credProps":{"rk":true}}`, true, true},
+		{"Non-resident key", `{"credProps":{"rk":false}}`, false, true},
+		{"Unknown", `{"credProps":{}}`, false, true},
+		{"Absent", `{}`, false, true},
+		{"Invalid", `{"credProps":{"rk":"true"}}`, false, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			resp := &protocol.AttestationResponse{}
+			if err := json.Unmarshal([]byte(registrationResponse), resp); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(test.results), &resp.ClientExtensionResults); err != nil {
+				t.Fatal(err)
+			}
+
+			credential, err := rp.FinishRegistration(&webauthn.SessionData{Challenge: r.PublicKey.Challenge}, resp)
+			if !test.valid {
+				if err == nil {
+					t.Fatal("expected error for invalid credProps")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if credential.ResidentKey != test.residentKey {
+				t.Fatalf("expected resident key %t, got %t", test.residentKey, credential.ResidentKey)
+			}
+		})
+	}
+}
+
+func TestFinishRegistrationLargeBlob(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithoutChallengeConsumer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := protocol.CredentialCreationOptions{}
+	if err := json.Unmarshal([]byte(registrationRequest), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name      string
+		results   string
+		largeBlob bool
+		valid     bool
+	}{
+		{"Supported", `{"largeBlob":{"supported":true}}`, true, true},
+		{"Unsupported", `{"largeBlob":{"supported":false}}`, false, true},
+		{"Absent", `{}`, false, true},
+		{"Invalid", `{"largeBlob":{"supported":"true"}}`, false, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			resp := &protocol.AttestationResponse{}
+			if err := json.Unmarshal([]byte(registrationResponse), resp); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(test.results), &resp.ClientExtensionResults); err != nil {
+				t.Fatal(err)
+			}
+
+			credential, err := rp.FinishRegistration(&webauthn.SessionData{Challenge: r.PublicKey.Challenge}, resp)
+			if !test.valid {
+				if err == nil {
+					t.Fatal("expected error for invalid largeBlob")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if credential.LargeBlob != test.largeBlob {
+				t.Fatalf("expected large blob %t, got %t", test.largeBlob, credential.LargeBlob)
+			}
+		})
+	}
+}
+
+func TestFinishRegistrationEnterpriseAttestation(t *testing.T) {
+	user := &testUser{id: []byte("test")}
+
+	t.Run("Disabled", func(t *testing.T) {
+		rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _, err = rp.BeginRegistration(user, webauthn.WithAttestationConveyance(protocol.AttestationConveyancePreferenceEnterprise))
+		if err == nil {
+			t.Fatal("expected error for enterprise attestation that is not enabled")
+		}
+	})
+
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithEnterpriseAttestation(true), webauthn.WithoutChallengeConsumer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, session, err := rp.BeginRegistration(user, webauthn.WithAttestationConveyance(protocol.AttestationConveyancePreferenceEnterprise))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !session.EnterpriseAttestation {
+		t.Fatal("enterprise attestation is not stored in session")
+	}
+
+	r := protocol.CredentialCreationOptions{}
+	if err := json.Unmarshal([]byte(enterpriseRegistrationRequest), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := &protocol.AttestationResponse{}
+	if err := json.Unmarshal([]byte(enterpriseRegistrationResponse), resp); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name         string
+		enterprise   bool
+		serialNumber string
+	}{
+		{"Requested", true, "ENT-0123456789"},
+		{"Not requested", false, ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			session := &webauthn.SessionData{Challenge: r.PublicKey.Challenge, EnterpriseAttestation: test.enterprise}
+
+			credential, err := rp.FinishRegistration(session, resp)
+			if err != nil {
+				e := protocol.ToWebAuthnError(err)
+				t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+			}
+
+			if credential.SerialNumber != test.serialNumber {
+				t.Fatalf("expected serial number %q, got %q", test.serialNumber, credential.SerialNumber)
+			}
+		})
+	}
+}
+
+func TestFinishRegistrationOrigins(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		origin string
+		opts   []webauthn.Option
+		valid  bool
+	}{
+		{"Default", "https://example.com", nil, false},
+		{"Allowed origin", "https://example.com", []webauthn.Option{webauthn.WithAllowedOrigins([]string{"https://app.example.com"})}, true},
+		{"Subdomain", "https://example.com", []webauthn.Option{webauthn.WithSubdomainOrigins(true)}, true},
+		{"Subdomain with other scheme", "http://example.com", []webauthn.Option{webauthn.WithSubdomainOrigins(true)}, false},
+		{"Subdomain with other port", "https://example.com:8443", []webauthn.Option{webauthn.WithSubdomainOrigins(true)}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rp, err := webauthn.NewRelyingParty("webauthn-test", "example.com", test.origin, test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = finishRegistration(rp, subdomainRegistrationRequest, subdomainRegistrationResponse)
+			if test.valid && err != nil {
+				e := protocol.ToWebAuthnError(err)
+				t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+			} else if !test.valid && (err == nil || protocol.ToWebAuthnError(err).Name != protocol.ErrInvalidOrigin.Name) {
+				t.Fatalf("expected invalid origin, got %v", err)
+			}
+		})
+	}
+}
+
+func TestFinishRegistrationExpired(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := protocol.CredentialCreationOptions{}
+	if err := json.Unmarshal([]byte(registrationRequest), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := &protocol.AttestationResponse{}
+	if err := json.Unmarshal([]byte(registrationResponse), resp); err != nil {
+		t.Fatal(err)
+	}
+
+	session := &webauthn.SessionData{Challenge: r.PublicKey.Challenge, Expires: time.Now().Add(-time.Second)}
+	if _, err := rp.FinishRegistration(session, resp); err != webauthn.ErrCeremonyExpired {
+		t.Fatalf("expected ErrCeremonyExpired, got %v", err)
+	}
+
+	// The expiry is checked against the clock of the RelyingParty
+	clock := time.Now()
+	rp, err = webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithClock(func() time.Time { return clock }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, session, err = rp.BeginRegistration(&testUser{id: []byte("test")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !session.Expires.Equal(clock.Add(60 * time.Second)) {
+		t.Fatalf("expected expiry %v, got %v", clock.Add(60*time.Second), session.Expires)
+	}
+	clock = clock.Add(61 * time.Second)
+	session.Challenge = r.PublicKey.Challenge
+	if _, err := rp.FinishRegistration(session, resp); err != webauthn.ErrCeremonyExpired {
+		t.Fatalf("expected ErrCeremonyExpired, got %v", err)
+	}
+}
+
+func TestFinishRegistrationContext(t *testing.T) {
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM([]byte(attestationRoot)) {
+		t.Fatal("unable to parse attestation root")
+	}
+
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithAttestationRoots(roots), webauthn.WithRevocationCheck(revocation.ModeSoftFail), webauthn.WithoutChallengeConsumer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := protocol.CredentialCreationOptions{}
+	if err := json.Unmarshal([]byte(chainRegistrationRequest), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := &protocol.AttestationResponse{}
+	if err := json.Unmarshal([]byte(chainRegistrationResponse), resp); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	session := &webauthn.SessionData{Challenge: r.PublicKey.Challenge}
+	if _, err := rp.FinishRegistrationContext(ctx, session, resp); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if _, err := rp.FinishRegistrationResultContext(ctx, session, resp); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if _, err := rp.FinishRegistrationContext(context.Background(), session, resp); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+func TestFinishRegistrationRevocationUnverified(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		rw.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	// The chain is not rooted in any attestation root, and its OCSP responder and CRL distribution point are chosen by
+	// the client
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Unrooted CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject: pkix.Name{
+			Country:            []string{"NL"},
+			Organization:       []string{"WebAuthn Test"},
+			OrganizationalUnit: []string{"Authenticator Attestation"},
+			CommonName:         "WebAuthn Test Batch",
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		OCSPServer:            []string{server.URL + "/ocsp"},
+		CRLDistributionPoints: []string{server.URL + "/crl"},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leaf, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, mode := range []revocation.Mode{revocation.ModeSoftFail, revocation.ModeHardFail} {
+		rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithRevocationCheck(mode))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		authenticator, err := testutil.NewVirtualAuthenticator()
+		if err != nil {
+			t.Fatal(err)
+		}
+		authenticator.AttestationKey, authenticator.AttestationCertificates = key, [][]byte{leafDER, caDER}
+
+		options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		attestation, err := authenticator.Register("https://localhost", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := rp.FinishRegistrationResult(session, attestation)
+		switch mode {
+		case revocation.ModeSoftFail:
+			if err != nil {
+				e := protocol.ToWebAuthnError(err)
+				t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+			}
+			if !reflect.DeepEqual(result.Warnings, []webauthn.Warning{webauthn.WarningUnverifiedAttestation, webauthn.WarningRevocationUnknown}) {
+				t.Fatalf("unexpected warnings %v", result.Warnings)
+			}
+		case revocation.ModeHardFail:
+			if !errors.Is(err, protocol.ErrInvalidAttestation) {
+				t.Fatalf("expected ErrInvalidAttestation, got %v", err)
+			}
+		}
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Fatalf("expected the revocation URLs of the unverified chain not to be contacted, got %d requests", n)
+	}
+}
+
+func TestFinishRegistrationSyncedPasskey(t *testing.T) {
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Passkeys that are synced by Google Password Manager and used over the hybrid transport are backed up, do not
+	// implement a signature counter and report both the hybrid and internal transports
+	authenticator, err := testutil.NewVirtualAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator.AAGUID, _ = hex.DecodeString("ea9b8d664d011d213ce4b6b48cb575d4")
+	authenticator.SignCount = 0
+	authenticator.Flags |= protocol.AuthenticatorDataFlagBackupEligible | protocol.AuthenticatorDataFlagBackupState
+
+	options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestation, err := authenticator.Register("https://localhost", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestation.Response.Transports = []protocol.AuthenticatorTransport{protocol.AuthenticatorTransportHybrid, protocol.AuthenticatorTransportInternal}
+
+	credential, err := rp.FinishRegistration(session, attestation)
+	if err != nil {
+		e := protocol.ToWebAuthnError(err)
+		t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+	}
+	if !credential.BackupEligible || !credential.BackupState {
+		t.Fatalf("expected backup eligible and backed up credential, got %v and %v", credential.BackupEligible, credential.BackupState)
+	}
+	if len(credential.Transports) != 2 || credential.Transports[0] != protocol.AuthenticatorTransportHybrid {
+		t.Fatalf("unexpected transports %v", credential.Transports)
+	}
+
+	// The backup state may change after registration
+	for _, flags := range []protocol.AuthenticatorDataFlags{
+		protocol.AuthenticatorDataFlagBackupEligible | protocol.AuthenticatorDataFlagBackupState,
+		protocol.AuthenticatorDataFlagBackupEligible,
+	} {
+		authenticator.Flags = protocol.AuthenticatorDataFlagUserPresent | protocol.AuthenticatorDataFlagUserVerified | flags
+
+		options, session, err := rp.BeginLogin(webauthn.WithUserCredentials(credential))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertion, err := authenticator.Login("https://localhost", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signCount, err := rp.FinishLogin(session, credential, assertion)
+		if err != nil {
+			e := protocol.ToWebAuthnError(err)
+			t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+		}
+		if err := credential.UpdateCounter(signCount); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Backed up but not eligible", func(t *testing.T) {
+		authenticator.Flags = protocol.AuthenticatorDataFlagUserPresent | protocol.AuthenticatorDataFlagBackupState
+
+		options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		attestation, err := authenticator.Register("https://localhost", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rp.FinishRegistration(session, attestation); !errors.Is(err, protocol.ErrInvalidRequest) {
+			t.Fatalf("expected ErrInvalidRequest, got %v", err)
+		}
+	})
+}
+
+// register finishes the registration of the given vector and returns the credential.
+func register(t *testing.T, rp *webauthn.RelyingParty, request, response string) *webauthn.Credential {
+	credential, err := finishRegistration(rp, request, response)
+	if err != nil {
+		e := protocol.ToWebAuthnError(err)
+		t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+	}
+
+	return credential
+}
+
+// finishRegistration finishes the registration of the given vector for the user of the request.
+func finishRegistration(rp *webauthn.RelyingParty, request, response string) (*webauthn.Credential, error) {
+	r := protocol.CredentialCreationOptions{}
+	if err := json.Unmarshal([]byte(request), &r); err != nil {
+		return nil, err
+	}
+
+	resp := &protocol.AttestationResponse{}
+	if err := json.Unmarshal([]byte(response), resp); err != nil {
+		return nil, err
+	}
+
+	return rp.FinishRegistration(&webauthn.SessionData{Challenge: r.PublicKey.Challenge, UserID: r.PublicKey.User.ID}, resp)
+}
+
+// Self attestation ES256
+var registrationRequest = `{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"3XWnUb2JpXdLFnVo4Qv83+OEfyKjrU4wNVCESwAanPY=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`
+
+var registrationResponse = `{"id":"YMKrgk-QHJ3oIVu2IMUd1mzonVHUIXBprX32i02JoDc","rawId":"YMKrgk+QHJ3oIVu2IMUd1mzonVHUIXBprX32i02JoDc=","response":{"attestationObject":"o2dhdHRTdG10omNhbGcmY3NpZ1hHMEUCIHK17gxWS7o8RbIPh1KADxr+7MnoHoY5OpiHkfvvqyweAiEArwMYrBXJUjUURD3PwcxbV7WLKXbibyOBw8Gzrh9zN5FoYXV0aERhdGFYpEmWDeWIDoxodDQXD2R2YFuP5K65ooYyx5lc87qDHZdjRQAAAAAAAAAAAAAAAAAAAAAAAAAAACBgwquCT5AcneghW7YgxR3WbOidUdQhcGmtffaLTYmgN6UiWCDCOz88O2U3NIiQxMBwUkjYv0iYy9Iz+wLcGBqVZLsYnyFYIP+7ohl6nXO9Kub0d6t1lFjW7Sz+JIl4nJcab8YSd96mIAEBAgMmY2ZtdGZwYWNrZWQ=","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoiM1hXblViMkpwWGRMRm5WbzRRdjgzLU9FZnlLanJVNHdOVkNFU3dBYW5QWSIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`
+
+// Basic attestation with an intermediate certificate, which is issued by attestationRoot
+var chainRegistrationRequest = `{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"/j1Ch0xsLuYGTktW7wDzoaC44qG8Wi+1KfXjjuXL4yA=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`
+
+var chainRegistrationResponse = `{"id":"QuRZK8QCq209Sc5VzhgJeSjgsHSbbDCDfJ5AWdFCIik","rawId":"QuRZK8QCq209Sc5VzhgJeSjgsHSbbDCDfJ5AWdFCIik=","response":{"attestationObject":"o2dhdHRTdG10o2NhbGcmY3NpZ1hGMEQCICGttVVf7WEx3tL5e60wyU2HF/LmZmEHfzcfuiZvdoQQAiBHlgkw+Jl6zERBRd0fEhHNU2ikXQXscUmfTjj05jPV8GN4NWOCWQHUMIIB0DCCAXegAwIBAgIBAzAKBggqhkjOPQQDAjBKMQswCQYDVQQGEwJOTDEWMBQGA1UEChMNV2ViQXV0aG4gVGVzdDEjMCEGA1UEAxMaV2ViQXV0aG4gVGVzdCBJbnRlcm1lZGlhdGUwHhcNMjAwMTAxMDAwMDAwWhcNNDAwMTAxMDAwMDAwWjBnMQswCQYDVQQGEwJOTDEWMBQGA1UEChMNV2ViQXV0aG4gVGVzdDEiMCAGA1UECxMZQXV0aGVudGljYXRvciBBdHRlc3RhdGlvbjEcMBoGA1UEAxMTV2ViQXV0aG4gVGVzdCBCYXRjaDBZMBMGByqGSM49AgEGCCqGSM49AwEHA0IABJ/mfXgiHIjNiSyi5vx+zz6V29FGF6aK1D3W7f63O+Dz8RtHbaTbhznBznmdf4f+l87euFV6R4usREDBR+Z1KuCjMTAvMAwGA1UdEwEB/wQCMAAwHwYDVR0jBBgwFoAUV++RYgWtBPOIWEj6+nnlr2KoBhswCgYIKoZIzj0EAwIDRwAwRAIgfWJoUQ9SobWTzef6Z0eVntlN+CbZqqwJ7/OggX/3KHcCIE6TZD3pZ6jqx/9SoGcHoHUF8gmE3i8x3XB7VWbZpR/WWQKjMIICnzCCAYegAwIBAgIBAjANBgkqhkiG9w0BAQsFADBCMQswCQYDVQQGEwJOTDEWMBQGA1UEChMNV2ViQXV0aG4gVGVzdDEbMBkGA1UEAxMSV2ViQXV0aG4gVGVzdCBSb290MB4XDTIwMDEwMTAwMDAwMFoXDTQ1MDEwMTAwMDAwMFowSjELMAkGA1UEBhMCTkwxFjAUBgNVBAoTDVdlYkF1dGhuIFRlc3QxIzAhBgNVBAMTGldlYkF1dGhuIFRlc3QgSW50ZXJtZWRpYXRlMFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEff2iwCPXWUWqQkHskToVfLvgH797Rfqt1/YdSdHIIqm6Pt42sySfzub6k9puY9CwEcUadHm58EE3bHG81M0jbaNjMGEwDgYDVR0PAQH/BAQDAgIEMA8GA1UdEwEB/wQFMAMBAf8wHQYDVR0OBBYEFFfvkWIFrQTziFhI+vp55a9iqAYbMB8GA1UdIwQYMBaAFOM+TF5KUlATfUA8Zfwq1KK+YUL2MA0GCSqGSIb3DQEBCwUAA4IBAQBn7FwkOwTZdSigCgY3d41d78hti2lhtPc76CV67snGImZTGv/B11Q3DVeevE7VZt6IpIDMDBuYY8RLGajvP1JNuHOQeWkWvpRyCQEhGio5VWJt6GTNeipYt+0RooTRuP7JfJHq0qNJm9btxNx9B4ef65Sv8L9ix76sd8rcZL8m8WoxJg4nwmPa3gvqa53BhAjy4ZYicB7wa8DVrWsRPGL6df7xv4rdZ26L6eNNQeZDwoBs6xkKKBE2OFWz/od/pzzDphGZ/5SoBgp0i+Aq2khE2CzCCg/GCvE3uhBoBxDUduZliC4NWMROLagLzHEl7zUbyGN3hEiEEbNeEpc9v/rtaGF1dGhEYXRhWKRJlg3liA6MaHQ0Fw9kdmBbj+SuuaKGMseZXPO6gx2XY0UAAAAAOnbwxBi+HYJnaOErKCo2rAAgQuRZK8QCq209Sc5VzhgJeSjgsHSbbDCDfJ5AWdFCIimlIlggypfHEIwpX5HRWpO9n3lQTO4789VDPdN3F8f4Nps0K9ghWCAEjcq+3yfu9AcKCynZ5a59/F8k0liDOs1hcR1/+555kCABAQIDJmNmbXRmcGFja2Vk","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoiX2oxQ2gweHNMdVlHVGt0Vzd3RHpvYUM0NHFHOFdpLTFLZlhqanVYTDR5QSIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`
+
+// None attestation from https://app.example.com for the RP ID example.com
+var subdomainRegistrationRequest = `{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"iV4VSStgv+NEKvBUiBy0ljCWd5NxgPwSPs2ugpqP8pQ=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`
+
+var subdomainRegistrationResponse = `{"id":"l6xj8qNQ5UIod2EGgjGNhRFHQwEAeEShXzP4JQX4QEM","rawId":"l6xj8qNQ5UIod2EGgjGNhRFHQwEAeEShXzP4JQX4QEM=","response":{"attestationObject":"o2dhdHRTdG10oGhhdXRoRGF0YViko3mm9u6vuaVeN4wRgDTidR5oL6ufLTCrE9ISVYbOGUdFAAAAAAAAAAAAAAAAAAAAAAAAAAAAIJesY/KjUOVCKHdhBoIxjYURR0MBAHhEoV8z+CUF+EBDpSJYIB8wkQUlUJdMHUyLAUFrG9YBmXaavnZQaBPPAK2pwcrRIVggnbnvRu4FHYzfULuiAZcEiwvQwkmduBjzXMUbUTaXH8YgAQECAyZjZm10ZG5vbmU=","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoiaVY0VlNTdGd2LU5FS3ZCVWlCeTBsakNXZDVOeGdQd1NQczJ1Z3BxUDhwUSIsIm9yaWdpbiI6Imh0dHBzOi8vYXBwLmV4YW1wbGUuY29tIn0="},"type":"public-key"}`
+
+// Basic attestation with a certificate of which the subject contains a device serial number
+var enterpriseRegistrationRequest = `{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"iacGN1S6BPPDRODfaSvXp+FAosQS8jZ04/ysRjGzz00=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`
+
+var enterpriseRegistrationResponse = `{"id":"Upt2fjzakWcePs8sUeJ2shDZNfNXvsrdmArInrot6NI","rawId":"Upt2fjzakWcePs8sUeJ2shDZNfNXvsrdmArInrot6NI=","response":{"attestationObject":"o2dhdHRTdG10o2NhbGcmY3NpZ1hHMEUCIFdKfZV+U8TwJnZsQhJTrPks5nGyi8GI5ue1iNl4XkYBAiEAxTVZoFC+z1ElnmrDJFSRzff1NMD78qWUgCT0555kt09jeDVjgVkCrTCCAqkwggGRoAMCAQICAQQwDQYJKoZIhvcNAQELBQAwQjELMAkGA1UEBhMCTkwxFjAUBgNVBAoTDVdlYkF1dGhuIFRlc3QxGzAZBgNVBAMTEldlYkF1dGhuIFRlc3QgUm9vdDAeFw0yMDAxMDEwMDAwMDBaFw00MDAxMDEwMDAwMDBaMIGFMQswCQYDVQQGEwJOTDEWMBQGA1UEChMNV2ViQXV0aG4gVGVzdDEiMCAGA1UECxMZQXV0aGVudGljYXRvciBBdHRlc3RhdGlvbjEhMB8GA1UEAxMYV2ViQXV0aG4gVGVzdCBFbnRlcnByaXNlMRcwFQYDVQQFEw5FTlQtMDEyMzQ1Njc4OTBZMBMGByqGSM49AgEGCCqGSM49AwEHA0IABPTLfNFO76uDrlBd6p9Eu3sgSMBh10dPf8x5MFuBaw2aRzha9uxQbEJCIREkHiN4MH8GVzK2z3R57Ds65YqEjJGjMTAvMAwGA1UdEwEB/wQCMAAwHwYDVR0jBBgwFoAUKtscy7NX2TozsPtmHNycbLihuzcwDQYJKoZIhvcNAQELBQADggEBADcb7ICiPTCRoKd+Y82SJt4hRNG4UFuz7nZUzv2wbMFdckFOJiqS9+8rKsZkb863xWWsIhGrzh9ZmqUDzJUcBuVQ2Bj1PfDHVgAz72VN9K0+WrX+cZWJtP8xSWYBa1c3xj7Ow0jS4kJakjJeUETSwpK6Q4PkzfuY5rQzFG4BvYWCKMLPxSzKPzkOCwwmNdoJfp+B4XOl1Yj6wsUlViFEPOLXMM5Vh4sItChrDYjtN5WXrns/+DZW/f8NbdsV8gQMt28fhkRaQYF/yMcgsxtn08ba/xhdwf9MEFSRLdnsE+iWzZFbpS7SE29DcZTdQohC8GZABUaAx4x74MLxyd/bQRxoYXV0aERhdGFYpEmWDeWIDoxodDQXD2R2YFuP5K65ooYyx5lc87qDHZdjRQAAAAC8xjroled9VEHwaWIsTgxMACBSm3Z+PNqRZx4+zyxR4nayENk181e+yt2YCsieui3o0qUiWCCu+/HytuqcXyCIksfdsYEPuieKIO5FLbOhWhh2RYVMgCFYIHDuuOATbJXQqUQZcvE9k14cyAYtZJ+hNDywJ/rWiRu/IAEBAgMmY2ZtdGZwYWNrZWQ=","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoiaWFjR04xUzZCUFBEUk9EZmFTdlhwLUZBb3NRUzhqWjA0X3lzUmpHenowMCIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`
+
+// None attestation of which the authenticator data contains the credProtect extension output userVerificationRequired
+var credProtectRegistrationRequest = `{"publicKey":{"rp":{"name":"webauthn-test"},"user":{"name":"test","id":"dGVzdA==","displayName":"test"},"challenge":"roOmob7xDcKukvGJ1f+CckaG/zasMROds0sBuXQ+vnk=","pubKeyCredParams":[{"type":"public-key","alg":-7},{"type":"public-key","alg":-257}],"timeout":30000,"attestation":"direct"}}`
+
+var credProtectRegistrationResponse = `{"id":"kf5QV8n_RxXr4wqm0je7Myz0xROmZTpFbmpPMOgcqYs","rawId":"kf5QV8n/RxXr4wqm0je7Myz0xROmZTpFbmpPMOgcqYs=","response":{"attestationObject":"o2dhdHRTdG10oGhhdXRoRGF0YViySZYN5YgOjGh0NBcPZHZgW4/krrmihjLHmVzzuoMdl2PFAAAAAAAAAAAAAAAAAAAAAAAAAAAAIJH+UFfJ/0cV6+MKptI3uzMs9MUTpmU6RW5qTzDoHKmLpSJYIONSWBm1OMJ5uM4xU5QqAVChcl4T0UGHwP96ZoGwN1kDIVggHNWB/55YC5cEPYmuIbmrRD8gtAKXvfNQwXZQhhjKOQQgAQECAyaha2NyZWRQcm90ZWN0A2NmbXRkbm9uZQ==","clientDataJSON":"eyJ0eXBlIjoid2ViYXV0aG4uY3JlYXRlIiwiY2hhbGxlbmdlIjoicm9PbW9iN3hEY0t1a3ZHSjFmLUNja2FHX3phc01ST2RzMHNCdVhRLXZuayIsIm9yaWdpbiI6Imh0dHBzOi8vbG9jYWxob3N0In0="},"type":"public-key"}`
+
+var attestationRoot = `-----BEGIN CERTIFICATE-----
+MIIDQTCCAimgAwIBAgIBATANBgkqhkiG9w0BAQsFADBCMQswCQYDVQQGEwJOTDEW
+MBQGA1UEChMNV2ViQXV0aG4gVGVzdDEbMBkGA1UEAxMSV2ViQXV0aG4gVGVzdCBS
+b290MB4XDTIwMDEwMTAwMDAwMFoXDTQ1MDEwMTAwMDAwMFowQjELMAkGA1UEBhMC
+TkwxFjAUBgNVBAoTDVdlYkF1dGhuIFRlc3QxGzAZBgNVBAMTEldlYkF1dGhuIFRl
+c3QgUm9vdDCCASIwDQYJKoZIhvcNAQEBBQADggEPADCCAQoCggEBAMsqbff5dIKE
+N4CgSu6nk2wu82iH2wc1FExKzU0yxmfiH0Rx2Q2/U/EEcNP7uPeWilSnLDhk03SV
+EAMV7sI70Ej7m7ElG7dm74Yel8mO2Lh8ILLs5rwoAuDt752bfMxteHboOQX1xSXE
+XoCcYR/HGtu3xB6m33XWPeqbAI+SrPxtrT7BlMYTr9etFPICXHy+1ZeLxzg8XSMv
+z2+E05LkyEV3Hw8twYz+pqKEzWj7Iv3KvPSgomY8lfZQKkY6hRcWt8E5/gLIn+nR
+p/2O52kq5y1GtO0iD40FOgmXnwS7F7BeZdiHYdJIMvHpGnVwLI2H3H95t6o7kr9T
+bcRzxWdemKkCAwEAAaNCMEAwDgYDVR0PAQH/BAQDAgIEMA8GA1UdEwEB/wQFMAMB
+Af8wHQYDVR0OBBYEFOM+TF5KUlATfUA8Zfwq1KK+YUL2MA0GCSqGSIb3DQEBCwUA
+A4IBAQBMF1se+4k/Pyf7Kim/3xrJSgB0w+YvZXd9P0dD4b6O8CCIkhMrk81D7dCZ
+4WR6ipqhJRoaS+n96QGXhwoZ6SVVb1e3YWcEf+SlFFqCpR612FxlsU0cR/pJLPvP
+pZPKKE6Vko2xS2mm0EZxTLFsgttnPEuF+aV+s2Z+0lZPltbx67gZ370668AuCtt8
+ailmiGnP4d05d9K5xaiCxSh/QfnA99el7HkptCcJK0Iin9XzolW8pp2SRLeC2cg9
+yXvwbichRe+fw3hk1kO744hYM/dp8hTahwEOooThB4bNCuZdwt8N/YflAvvZh+kb
+K1DFG9udSBJ0gn+y5hxHql3Fvggb
+-----END CERTIFICATE-----`
+
+// newMetadataServer returns a server that serves a BLOB with the given entries, and the root to which the certificate
+// chain of the BLOB verifies.
+func newMetadataServer(t *testing.T, entries []metadata.Entry) (*httptest.Server, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "MDS Test Signer"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	opts := (&jose.SignerOptions{}).WithHeader("x5c", []string{base64.StdEncoding.EncodeToString(der)})
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := json.Marshal(&metadata.BLOB{Number: 1, NextUpdate: "2999-01-01", Entries: entries})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blob, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte(blob))
+	})), roots
+}
@@ -0,0 +1,682 @@
+package webauthn
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/keycloud/webauthn/facets"
+	"github.com/keycloud/webauthn/metadata"
+	"github.com/keycloud/webauthn/protocol"
+	"github.com/keycloud/webauthn/revocation"
+)
+
+// defaultTimeout is the default amount of time in milliseconds the user will be permitted to perform a ceremony.
+const defaultTimeout = 60000
+
+// defaultCredentialParameters are the default credential types and algorithms that are requested on registration, in
+// order of preference.
+var defaultCredentialParameters = []protocol.PublicKeyCredentialParameters{
+	{Type: protocol.PublicKeyCredentialTypePublicKey, Algorithm: protocol.ES256},
+	{Type: protocol.PublicKeyCredentialTypePublicKey, Algorithm: protocol.RS256},
+	{Type: protocol.PublicKeyCredentialTypePublicKey, Algorithm: protocol.EdDSA},
+}
+
+// RelyingParty performs the registration and login ceremonies of a Relying Party. Unlike WebAuthn, it does not depend
+// on a Session or an AuthenticatorStore: the SessionData that is returned at the start of a ceremony should be stored
+// server-side by the caller and passed back when finishing the ceremony.
+type RelyingParty struct {
+	name    string
+	id      string
+	origin  string
+	timeout uint
+	clock   func() time.Time
+
+	relyingPartyIDs  []string
+	allowedOrigins   []string
+	subdomainOrigins bool
+
+	challengeGenerator       ChallengeGenerator
+	challengeConsumer        ChallengeConsumer
+	withoutChallengeConsumer bool
+
+	attestationPolicy         AttestationPolicy
+	indirectAttestationPolicy IndirectAttestationPolicy
+	attestationDecider        func(*VerificationContext) error
+	selfAttestationPredicate  func(*VerificationContext) bool
+	strictAttStmt             bool
+	lenientSignatureAlgorithm bool
+	requireCTSProfileMatch    bool
+	safetyNetMaxResponseAge   time.Duration
+	attestationRoots          *x509.CertPool
+	metadata                  *metadata.Service
+	blockedStatuses           []metadata.AuthenticatorStatus
+	metadataAlgorithms        bool
+	nonStandardAlgorithms     bool
+
+	allowedAAGUIDs  [][]byte
+	blockedAAGUIDs  [][]byte
+	allowZeroAAGUID bool
+	requireAAGUID   bool
+
+	requireBasicAttestationAAGUID bool
+
+	revocationMode    revocation.Mode
+	revocationChecker *revocation.Checker
+
+	enterpriseAttestation bool
+
+	appID       string
+	appIDFacets *facets.Resolver
+
+	securePaymentConfirmation bool
+
+	eventHandler EventHandler
+
+	credentialStore      CredentialStore
+	allowCounterRollback bool
+
+	// err is the first error of the options, which is returned by NewRelyingParty
+	err error
+}
+
+// Option configures a RelyingParty.
+type Option func(*RelyingParty)
+
+// WithTimeout sets the amount of time the user will be permitted to perform a ceremony, which is sent to the client as
+// a hint and after which FinishRegistration and FinishLogin reject the ceremony with ErrCeremonyExpired. The default is
+// 60 seconds.
+func WithTimeout(timeout time.Duration) Option {
+	return func(rp *RelyingParty) {
+		rp.timeout = uint(timeout / time.Millisecond)
+	}
+}
+
+// WithClock sets the function that returns the current time, against which ceremonies expire and attestation
+// certificates are verified. The default is time.Now. The expiry of sessions that are stored in a MemorySessionStore
+// is still checked against time.Now.
+func WithClock(clock func() time.Time) Option {
+	return func(rp *RelyingParty) {
+		rp.clock = clock
+	}
+}
+
+// WithRelyingPartyIDs sets additional RP IDs that credentials may be scoped to, besides the RP ID that is passed to
+// NewRelyingParty, for example "accounts.example.com", such that a single RelyingParty can serve several domains. The
+// RP ID hash of a response is accepted if it matches any of them, and the matching RP ID is reported by
+// Credential.RPID. The ceremonies use the RP ID that is passed to NewRelyingParty, unless the returned options are
+// changed, i.e. options.PublicKey.RP.ID for registration and options.PublicKey.RPID for login. The origins of the
+// other domains must be allowed by WithAllowedOrigins.
+//
+// Every RP ID in the set is trusted equally: a credential that is registered on one domain is only accepted for its own
+// RP ID on login, but an attacker that controls any of the domains, or the content that they serve, can register
+// credentials and perform ceremonies on behalf of all of them. Only add RP IDs that are operated with the same security
+// requirements.
+func WithRelyingPartyIDs(ids []string) Option {
+	return func(rp *RelyingParty) {
+		rp.relyingPartyIDs = ids
+	}
+}
+
+// WithAllowedOrigins sets additional origins that authenticator responses are accepted from, besides the origin that
+// is passed to NewRelyingParty, for example "https://app.example.com".
+func WithAllowedOrigins(origins []string) Option {
+	return func(rp *RelyingParty) {
+		rp.allowedOrigins = origins
+	}
+}
+
+// WithSubdomainOrigins sets whether authenticator responses are accepted from subdomains of the RP ID. If it is set,
+// an origin is accepted if its host is the RP ID or a subdomain thereof, and its scheme and port match those of the
+// origin that is passed to NewRelyingParty. For example, "https://app.example.com" is accepted for the RP ID
+// "example.com" and origin "https://example.com". By default, subdomains are not accepted.
+func WithSubdomainOrigins(allow bool) Option {
+	return func(rp *RelyingParty) {
+		rp.subdomainOrigins = allow
+	}
+}
+
+// WithChallengeGenerator sets the ChallengeGenerator that is used to generate the challenges of ceremonies, for
+// instance to use a hardware security module, or to bind challenges to server-side state. By default, challenges of
+// protocol.ChallengeSize bytes are generated using crypto/rand.
+func WithChallengeGenerator(g ChallengeGenerator) Option {
+	return func(rp *RelyingParty) {
+		rp.challengeGenerator = g
+	}
+}
+
+// WithChallengeConsumer sets the ChallengeConsumer that makes the challenges of ceremonies single-use, for instance a
+// MemorySessionStore, or a store that is shared by several servers. By default, the consumed challenges are remembered
+// in the memory of the RelyingParty, which only prevents replays on a single server.
+func WithChallengeConsumer(c ChallengeConsumer) Option {
+	return func(rp *RelyingParty) {
+		rp.challengeConsumer = c
+		rp.withoutChallengeConsumer = false
+	}
+}
+
+// WithoutChallengeConsumer disables consuming the challenges of ceremonies. The caller must then ensure that a
+// SessionData is only used once, by deleting it from the session before calling FinishRegistration or FinishLogin.
+func WithoutChallengeConsumer() Option {
+	return func(rp *RelyingParty) {
+		rp.challengeConsumer = nil
+		rp.withoutChallengeConsumer = true
+	}
+}
+
+// AttestationPolicy determines which attestations are accepted on registration.
+type AttestationPolicy int
+
+const (
+	// AttestationPolicyAcceptUntrusted accepts all valid attestations, including none and self attestation, which do
+	// not prove the authenticator model. This is the default.
+	AttestationPolicyAcceptUntrusted AttestationPolicy = iota
+	// AttestationPolicyRejectUntrusted rejects none and self attestation. Attestations with a certificate chain are
+	// accepted, even if the chain is not verified because no roots are configured.
+	AttestationPolicyRejectUntrusted
+	// AttestationPolicyRequireAttestation only accepts attestations with a certificate chain that chains up to the
+	// roots that are configured by WithAttestationRoots or WithMetadata.
+	AttestationPolicyRequireAttestation
+)
+
+// WithAttestationPolicy sets the policy that determines which attestations are accepted on registration.
+// Attestations that are not accepted are rejected with ErrUntrustedAttestation. The type of an accepted attestation is
+// reported by Credential.AttestationType. By default, all valid attestations are accepted.
+func WithAttestationPolicy(policy AttestationPolicy) Option {
+	return func(rp *RelyingParty) {
+		rp.attestationPolicy = policy
+	}
+}
+
+// IndirectAttestationPolicy determines how the trust paths of attestations are treated on registrations that requested
+// indirect attestation conveyance. The client may then replace the attestation statement of the authenticator by one
+// that is generated by an Anonymization CA, of which the certificate does not identify the authenticator model, and
+// the AAGUID may be all-zero.
+type IndirectAttestationPolicy int
+
+const (
+	// IndirectAttestationVerify verifies the trust paths of indirect attestations like those of direct attestations,
+	// so the roots that are configured by WithAttestationRoots or WithMetadata must include those of the accepted
+	// Anonymization CAs. This is the default.
+	IndirectAttestationVerify IndirectAttestationPolicy = iota
+	// IndirectAttestationUnverified accepts the trust paths of indirect attestations without verifying them against
+	// the configured roots. The attestation is reported as unverified, so it is rejected by
+	// AttestationPolicyRequireAttestation.
+	IndirectAttestationUnverified
+	// IndirectAttestationNone discards the trust paths of indirect attestations, which are then treated as none
+	// attestation.
+	IndirectAttestationNone
+)
+
+// WithIndirectAttestationPolicy sets the policy that determines how the trust paths of attestations are treated on
+// registrations that requested indirect attestation by WithAttestationConveyance. Registrations that requested direct
+// or enterprise attestation are not affected. By default, indirect attestations are verified like direct attestations.
+func WithIndirectAttestationPolicy(policy IndirectAttestationPolicy) Option {
+	return func(rp *RelyingParty) {
+		rp.indirectAttestationPolicy = policy
+	}
+}
+
+// VerificationContext contains the verified attestation of a registration, which is passed to the decider that is set
+// by WithAttestationDecider.
+type VerificationContext struct {
+	// Attestation is the attestation of the registration.
+	Attestation protocol.Attestation
+	// AttestationType is the type of the attestation.
+	AttestationType protocol.AttestationType
+	// Chain is the attestation certificate chain, of which the first certificate is the attestation certificate. If
+	// Verified is set, it is the verified chain up to one of the roots. It is empty for none and self attestation.
+	Chain []*x509.Certificate
+	// Verified indicates whether the chain has been verified against the configured attestation roots.
+	Verified bool
+	// Indirect indicates whether indirect attestation was requested, in which case the chain may have been issued by
+	// an Anonymization CA rather than the vendor of the authenticator.
+	Indirect bool
+	// AAGUID is the AAGUID of the authenticator.
+	AAGUID []byte
+	// Transports contains the transports of the authenticator as reported by the client, which may be empty. Since
+	// they are not covered by the attestation, they are only hints.
+	Transports []protocol.AuthenticatorTransport
+	// Flags are the flags of the authenticator data.
+	Flags protocol.AuthenticatorDataFlags
+	// Metadata is the metadata statement of the authenticator, or nil if no metadata service is configured or the
+	// authenticator is not listed.
+	Metadata *metadata.MetadataStatement
+	// Status is the current status of the authenticator in the metadata, or empty if no metadata service is
+	// configured or the authenticator is not listed.
+	Status metadata.AuthenticatorStatus
+}
+
+// WithAttestationDecider sets a function that decides whether a verified attestation is accepted on registration, to
+// implement a custom attestation policy, for instance to only accept authenticators of a specific vendor. It is called
+// after all other checks have passed. If it returns an error, the registration is aborted with the error if it is a
+// *protocol.Error, or ErrUntrustedAttestation otherwise.
+func WithAttestationDecider(decider func(ctx *VerificationContext) error) Option {
+	return func(rp *RelyingParty) {
+		rp.attestationDecider = decider
+	}
+}
+
+// WithSelfAttestationPredicate sets a predicate that decides whether a self attestation is accepted on registration,
+// for instance PlatformAuthenticator to accept self attestations of platform authenticators such as Touch ID, while
+// requiring attestations with a certificate chain from roaming authenticators by AttestationPolicyRejectUntrusted or
+// AttestationPolicyRequireAttestation. If it is set, it takes precedence over the attestation policy for self
+// attestations, which are rejected with ErrUntrustedAttestation if it returns false. Other attestation types are not
+// affected.
+func WithSelfAttestationPredicate(predicate func(ctx *VerificationContext) bool) Option {
+	return func(rp *RelyingParty) {
+		rp.selfAttestationPredicate = predicate
+	}
+}
+
+// PlatformAuthenticator returns whether the attestation is likely from a platform authenticator, for use with
+// WithSelfAttestationPredicate. This is the case if the client reported the internal transport and no transport of a
+// roaming authenticator, such as usb or nfc, or, if it did not report any transports, if the credential is backup
+// eligible, as synced passkeys are. Since neither the transports nor the flags identify the authenticator model, this
+// is a heuristic rather than proof.
+func PlatformAuthenticator(ctx *VerificationContext) bool {
+	if len(ctx.Transports) == 0 {
+		return ctx.Flags.BackupEligible()
+	}
+
+	internal := false
+	for _, transport := range ctx.Transports {
+		switch transport {
+		case protocol.AuthenticatorTransportInternal:
+			internal = true
+		case protocol.AuthenticatorTransportHybrid:
+			// Hybrid is reported alongside internal by platform authenticators that can be used from other devices
+		default:
+			return false
+		}
+	}
+	return internal
+}
+
+// WithStrictAttStmt rejects attestation statements that contain keys that are not defined by their format, for
+// instance a packed attestation statement with a key other than alg, sig, x5c and ecdaaKeyId, with
+// protocol.ErrUnexpectedAttStmtField. This may be required for conformance, but authenticators that add vendor-specific
+// keys are rejected as well. By default, unexpected keys are ignored.
+func WithStrictAttStmt() Option {
+	return func(rp *RelyingParty) {
+		rp.strictAttStmt = true
+	}
+}
+
+// WithLenientSignatureAlgorithm accepts packed basic attestation signatures that are invalid for the algorithm
+// specified in alg, if they are valid for the signature algorithm of the attestation certificate or for ES256. This
+// accommodates authenticators, such as some of Yubico's keys, that specify the wrong algorithm, but also accepts
+// attestations of which alg does not match the signature. By default, the signature must be valid for alg.
+func WithLenientSignatureAlgorithm() Option {
+	return func(rp *RelyingParty) {
+		rp.lenientSignatureAlgorithm = true
+	}
+}
+
+// WithRequireCTSProfileMatch sets whether the ctsProfileMatch attribute of android-safetynet attestations must be true,
+// which means that the device passed the Android compatibility tests. If it is false, it suffices that the
+// basicIntegrity attribute is true, which also accepts devices that have not been tampered with but fail the
+// compatibility tests, such as devices with an unlocked bootloader or an uncertified custom ROM. The default is true.
+func WithRequireCTSProfileMatch(require bool) Option {
+	return func(rp *RelyingParty) {
+		rp.requireCTSProfileMatch = require
+	}
+}
+
+// WithSafetyNetMaxResponseAge sets the maximum difference between the timestamp of an android-safetynet attestation
+// and the time of the registration, such that stale responses are not accepted. The default is 60 seconds.
+func WithSafetyNetMaxResponseAge(age time.Duration) Option {
+	return func(rp *RelyingParty) {
+		rp.safetyNetMaxResponseAge = age
+	}
+}
+
+// WithAttestationRoots sets the root certificates that attestation certificate chains must chain up to on
+// registration. If it is set, only attestations with a certificate chain, i.e. basic and attestation CA attestations,
+// are accepted, unless WithIndirectAttestationPolicy disables the verification of indirect attestations or
+// WithSelfAttestationPredicate accepts self attestations.
+func WithAttestationRoots(roots *x509.CertPool) Option {
+	return func(rp *RelyingParty) {
+		rp.attestationRoots = roots
+	}
+}
+
+// WithMetadata sets the metadata service that is used on registration. If the authenticator is listed in the metadata,
+// the attestation certificate chain must chain up to one of the root certificates of its metadata statement instead of
+// the roots that are set by WithAttestationRoots.
+func WithMetadata(s *metadata.Service) Option {
+	return func(rp *RelyingParty) {
+		rp.metadata = s
+	}
+}
+
+// WithBlockedStatuses sets the statuses of authenticators that are not allowed to register, for instance
+// metadata.CompromisedStatuses. Authenticators of which the current status in the metadata, as set by WithMetadata, is
+// one of them are rejected with ErrAuthenticatorStatusNotAllowed. Authenticators that are not listed in the metadata
+// are not affected.
+func WithBlockedStatuses(statuses ...metadata.AuthenticatorStatus) Option {
+	return func(rp *RelyingParty) {
+		rp.blockedStatuses = statuses
+	}
+}
+
+// WithMetadataAlgorithms rejects credentials of which the algorithm of the credential public key is not one of the
+// authentication algorithms of the authenticator in the metadata, as set by WithMetadata, with ErrAlgorithmNotAllowed.
+// This detects attestations that claim to be from an authenticator model that never uses the algorithm. Authenticators
+// without a metadata statement, or of which the metadata statement lists no known algorithms, are not affected.
+func WithMetadataAlgorithms() Option {
+	return func(rp *RelyingParty) {
+		rp.metadataAlgorithms = true
+	}
+}
+
+// WithNonStandardAlgorithms accepts credentials of the non-standard algorithms on registration, i.e. ES256K and EdDSA
+// on the Ed448 curve, see cose.IsNonStandardKey. Since they are not commonly used with WebAuthn, they are rejected with
+// ErrAlgorithmNotAllowed by default, even if they are requested by WithCredentialAlgorithms.
+func WithNonStandardAlgorithms() Option {
+	return func(rp *RelyingParty) {
+		rp.nonStandardAlgorithms = true
+	}
+}
+
+// WithAllowedAAGUIDs sets the AAGUIDs of the authenticator models that are allowed to register. If it is set, all
+// other authenticators are rejected with ErrAAGUIDNotAllowed. The all-zero AAGUID, which is used by authenticators
+// with self or none attestation, is only allowed if it is in the list or if WithAllowZeroAAGUID is set.
+func WithAllowedAAGUIDs(aaguids [][]byte) Option {
+	return func(rp *RelyingParty) {
+		rp.allowedAAGUIDs = aaguids
+	}
+}
+
+// WithBlockedAAGUIDs sets the AAGUIDs of the authenticator models that are not allowed to register. These are rejected
+// with ErrAAGUIDNotAllowed, even if they are allowed by WithAllowedAAGUIDs.
+func WithBlockedAAGUIDs(aaguids [][]byte) Option {
+	return func(rp *RelyingParty) {
+		rp.blockedAAGUIDs = aaguids
+	}
+}
+
+// WithAllowedAAGUIDStrings is like WithAllowedAAGUIDs, but the AAGUIDs are in the UUID form of protocol.FormatAAGUID, for
+// instance "cb69481e-8ff7-4039-93ec-0a2729a154a8". NewRelyingParty returns an error if one of them is invalid.
+func WithAllowedAAGUIDStrings(aaguids []string) Option {
+	return func(rp *RelyingParty) {
+		rp.allowedAAGUIDs = rp.parseAAGUIDs(aaguids)
+	}
+}
+
+// WithBlockedAAGUIDStrings is like WithBlockedAAGUIDs, but the AAGUIDs are in the UUID form of protocol.FormatAAGUID.
+// NewRelyingParty returns an error if one of them is invalid.
+func WithBlockedAAGUIDStrings(aaguids []string) Option {
+	return func(rp *RelyingParty) {
+		rp.blockedAAGUIDs = rp.parseAAGUIDs(aaguids)
+	}
+}
+
+// parseAAGUIDs parses AAGUIDs in the UUID form for the options. If one of them is invalid, the error is recorded such
+// that it is returned by NewRelyingParty.
+func (rp *RelyingParty) parseAAGUIDs(aaguids []string) [][]byte {
+	parsed := make([][]byte, 0, len(aaguids))
+	for _, s := range aaguids {
+		aaguid, err := protocol.ParseAAGUID(s)
+		if err != nil {
+			if rp.err == nil {
+				rp.err = err
+			}
+			continue
+		}
+		parsed = append(parsed, aaguid)
+	}
+	return parsed
+}
+
+// WithAllowZeroAAGUID sets whether the all-zero AAGUID is allowed to register if WithAllowedAAGUIDs is set. Since
+// authenticators with self or none attestation commonly report the all-zero AAGUID, this decides whether those are
+// accepted alongside the allowed authenticator models.
+func WithAllowZeroAAGUID(allow bool) Option {
+	return func(rp *RelyingParty) {
+		rp.allowZeroAAGUID = allow
+	}
+}
+
+// WithRequireAAGUID rejects authenticators that report the all-zero AAGUID with ErrAAGUIDNotAllowed, such that only
+// identifiable authenticator models are allowed to register. This takes precedence over WithAllowedAAGUIDs and
+// WithAllowZeroAAGUID, so the all-zero AAGUID is rejected even if it is allowed by them.
+func WithRequireAAGUID() Option {
+	return func(rp *RelyingParty) {
+		rp.requireAAGUID = true
+	}
+}
+
+// WithRequireBasicAttestationAAGUID rejects basic attestations of which the authenticator data contains the all-zero
+// AAGUID with protocol.ErrInvalidAttestation. An authenticator that provides an attestation certificate should identify
+// its model, so an all-zero AAGUID suggests that the attestation has not been created by a genuine authenticator.
+// Unlike WithRequireAAGUID, self and none attestations with the all-zero AAGUID are still accepted.
+func WithRequireBasicAttestationAAGUID() Option {
+	return func(rp *RelyingParty) {
+		rp.requireBasicAttestationAAGUID = true
+	}
+}
+
+// WithRevocationCheck enables checking whether the attestation certificate has been revoked on registration, using
+// the OCSP responders and CRL distribution points in the certificate. The mode determines whether attestation
+// certificates of which the revocation status could not be determined are accepted. Revocation is only checked for
+// certificate chains that have been verified against the attestation roots, since the URLs in an unverified chain are
+// chosen by the client; the revocation status of an unverified chain is unknown. By default, revocation is not
+// checked.
+func WithRevocationCheck(mode revocation.Mode) Option {
+	return func(rp *RelyingParty) {
+		rp.revocationMode = mode
+	}
+}
+
+// WithRevocationChecker sets the revocation.Checker that is used if WithRevocationCheck is set, for instance to use a
+// custom HTTP client or cache.
+func WithRevocationChecker(c *revocation.Checker) Option {
+	return func(rp *RelyingParty) {
+		rp.revocationChecker = c
+	}
+}
+
+// WithEnterpriseAttestation sets whether enterprise attestation may be requested on registration using
+// WithAttestationConveyance. Since enterprise attestation may identify individual authenticators, it should only be
+// enabled for managed authenticators. If it is requested while it is not enabled, BeginRegistration returns an error.
+// By default, enterprise attestation is not enabled.
+func WithEnterpriseAttestation(enable bool) Option {
+	return func(rp *RelyingParty) {
+		rp.enterpriseAttestation = enable
+	}
+}
+
+// WithAppID sets the AppID of a legacy FIDO U2F application, for instance "https://login.example.com/app-id.json",
+// and requests the appid extension on login. This allows credentials that have been registered using the FIDO U2F
+// JavaScript API to be used for login.
+func WithAppID(appID string) Option {
+	return func(rp *RelyingParty) {
+		rp.appID = appID
+	}
+}
+
+// WithAppIDFacets resolves the trusted facets of the AppID that is set by WithAppID on login. If the client used the
+// AppID, the origin of the assertion must be one of the trusted facets of the AppID, which are accepted as origins
+// even if they are not allowed otherwise. This allows the origins of a legacy FIDO U2F application that is listed in
+// its trusted facets document to be migrated. Since the document is downloaded from the AppID URL, facets are only
+// resolved if this option is set.
+func WithAppIDFacets(r *facets.Resolver) Option {
+	return func(rp *RelyingParty) {
+		rp.appIDFacets = r
+	}
+}
+
+// WithSecurePaymentConfirmation sets whether Secure Payment Confirmation assertions may be verified using
+// FinishPaymentConfirmation. Since such assertions may be requested on the origin of a merchant instead of the Relying
+// Party, this should only be enabled by Relying Parties that confirm payments. By default, it is not enabled.
+func WithSecurePaymentConfirmation(enable bool) Option {
+	return func(rp *RelyingParty) {
+		rp.securePaymentConfirmation = enable
+	}
+}
+
+// WithEventHandler sets the EventHandler that receives the events of the ceremonies, such as the start, success and
+// failure of a registration, for instance to integrate with structured logging. By default, no events are emitted.
+func WithEventHandler(h EventHandler) Option {
+	return func(rp *RelyingParty) {
+		rp.eventHandler = h
+	}
+}
+
+// WithCredentialStore sets the CredentialStore that persists credentials. If it is set, FinishRegistration rejects
+// credentials that are already stored with ErrCredentialExcluded and saves the registered credential,
+// FinishDiscoverableLogin looks up the credential in it if no CredentialLookup is passed, and a successful login updates
+// the signature counter of the credential using Credential.UpdateCounter and saves it. By default, the caller stores
+// the credentials.
+func WithCredentialStore(s CredentialStore) Option {
+	return func(rp *RelyingParty) {
+		rp.credentialStore = s
+	}
+}
+
+// WithAllowCounterRollback accepts logins of which the signature counter did not increase when the signature counter
+// is updated in the CredentialStore. The credential is not saved in that case and LoginResult.CounterRollback is set,
+// such that the Relying Party may, for instance, require another factor. By default, such logins are rejected with
+// ErrCounterRollback.
+func WithAllowCounterRollback() Option {
+	return func(rp *RelyingParty) {
+		rp.allowCounterRollback = true
+	}
+}
+
+// NewRelyingParty creates a new RelyingParty. The name is a human-palatable identifier for the Relying Party, intended
+// only for display. The id is the RP ID, which must be a valid domain string, for example "login.example.com", and the
+// origin is the origin that authenticator responses will be compared with, for example
+// "https://login.example.com:1337". See Config for a more detailed description of these values. The RP ID is not
+// validated against the origin, which may be done using protocol.ValidateRPID.
+func NewRelyingParty(name, id, origin string, opts ...Option) (*RelyingParty, error) {
+	if name == "" {
+		return nil, fmt.Errorf("missing relying party name")
+	}
+	if id == "" {
+		return nil, fmt.Errorf("missing relying party ID")
+	}
+	if origin == "" {
+		return nil, fmt.Errorf("missing relying party origin")
+	}
+
+	rp := &RelyingParty{
+		name:    name,
+		id:      id,
+		origin:  origin,
+		timeout: defaultTimeout,
+		clock:   time.Now,
+
+		challengeGenerator:     randomChallengeGenerator{},
+		revocationChecker:      &revocation.Checker{},
+		requireCTSProfileMatch: true,
+	}
+
+	for _, opt := range opts {
+		opt(rp)
+	}
+	if rp.err != nil {
+		return nil, rp.err
+	}
+	if rp.challengeConsumer == nil && !rp.withoutChallengeConsumer {
+		rp.challengeConsumer = newMemoryChallengeConsumer(rp.clock, time.Duration(rp.timeout)*time.Millisecond)
+	}
+
+	return rp, nil
+}
+
+// expires returns when a ceremony with the given timeout in milliseconds, which is started now, expires. If the timeout
+// is zero, the ceremony does not expire.
+func (rp *RelyingParty) expires(timeout uint) time.Time {
+	if timeout == 0 {
+		return time.Time{}
+	}
+	return rp.clock().Add(time.Duration(timeout) * time.Millisecond)
+}
+
+// attestationOptions returns the options that are passed to the verification procedures of attestation formats. The
+// time of the verification is fixed, such that the certificate chain is verified against the same time.
+func (rp *RelyingParty) attestationOptions() protocol.AttestationVerificationOptions {
+	return protocol.AttestationVerificationOptions{
+		CurrentTime:               rp.clock(),
+		LenientSignatureAlgorithm: rp.lenientSignatureAlgorithm,
+		AllowBasicIntegrity:       !rp.requireCTSProfileMatch,
+		SafetyNetMaxResponseAge:   rp.safetyNetMaxResponseAge,
+	}
+}
+
+// expectedOrigin returns the origin that the origin of an authenticator response is compared with. This is the origin
+// itself if it is allowed, or the origin of the Relying Party otherwise, such that it is rejected with
+// protocol.ErrInvalidOrigin.
+func (rp *RelyingParty) expectedOrigin(origin string) string {
+	if origin == rp.origin {
+		return origin
+	}
+
+	for _, allowed := range rp.allowedOrigins {
+		if origin == allowed {
+			return origin
+		}
+	}
+
+	if rp.subdomainOrigins && rp.isSubdomainOrigin(origin) {
+		return origin
+	}
+
+	return rp.origin
+}
+
+// expectedRPID returns the RP ID that the RP ID hash of an authenticator response is compared with. This is the RP ID
+// of which the hash is rpIDHash if it is accepted, or the RP ID of the Relying Party otherwise, such that it is
+// rejected with protocol.ErrRPIDHashMismatch.
+func (rp *RelyingParty) expectedRPID(rpIDHash []byte) string {
+	for _, id := range rp.relyingPartyIDs {
+		hash := sha256.Sum256([]byte(id))
+		if subtle.ConstantTimeCompare(hash[:], rpIDHash) == 1 {
+			return id
+		}
+	}
+
+	return rp.id
+}
+
+// acceptsRPID returns whether id is the RP ID of the Relying Party or one of the RP IDs that are set by
+// WithRelyingPartyIDs.
+func (rp *RelyingParty) acceptsRPID(id string) bool {
+	if id == rp.id {
+		return true
+	}
+	for _, accepted := range rp.relyingPartyIDs {
+		if id == accepted {
+			return true
+		}
+	}
+	return false
+}
+
+// isSubdomainOrigin returns whether the host of origin is the RP ID or one of its subdomains, and its scheme and port
+// match those of the origin of the Relying Party.
+func (rp *RelyingParty) isSubdomainOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return false
+	}
+
+	expected, err := url.Parse(rp.origin)
+	if err != nil {
+		return false
+	}
+
+	if u.Scheme != expected.Scheme || u.Port() != expected.Port() {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	id := strings.ToLower(rp.id)
+	return host == id || strings.HasSuffix(host, "."+id)
+}
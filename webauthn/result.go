@@ -0,0 +1,101 @@
+package webauthn
+
+import (
+	"crypto/x509"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+// Warning describes a weakness of a registration that has been accepted, for instance because the attestation policy
+// accepts untrusted attestations. Warnings do not cause the registration to fail, but can be logged or shown for
+// auditing.
+type Warning string
+
+const (
+	// WarningUntrustedAttestation means that the attestation is a self or none attestation, which does not prove the
+	// authenticator model, and has been accepted by the attestation policy.
+	WarningUntrustedAttestation Warning = "untrusted_attestation"
+	// WarningUnverifiedAttestation means that the certificate chain of the attestation has not been verified, because
+	// no attestation roots are configured or WithIndirectAttestationPolicy disables the verification.
+	WarningUnverifiedAttestation Warning = "unverified_attestation"
+	// WarningRevocationUnknown means that the revocation status of the attestation certificate could not be
+	// determined, and has been accepted by revocation.ModeSoftFail.
+	WarningRevocationUnknown Warning = "revocation_unknown"
+	// WarningAuthenticatorNotListed means that the authenticator is not listed in the metadata, as set by
+	// WithMetadata, so its status and attestation roots are unknown.
+	WarningAuthenticatorNotListed Warning = "authenticator_not_listed"
+)
+
+// RegistrationResult is the result of a successful registration, as returned by
+// RelyingParty.FinishRegistrationResult. Besides the registered credential, it describes how the attestation has been
+// verified.
+type RegistrationResult struct {
+	// Credential is the registered credential, which should be stored by the caller.
+	Credential *Credential
+	// AttestationType is the type of the attestation.
+	AttestationType protocol.AttestationType
+	// TrustPath is the certificate chain of the attestation, which is the verified chain up to the root if Verified is
+	// set. It is empty for self and none attestations.
+	TrustPath []*x509.Certificate
+	// Verified is set if the certificate chain of the attestation has been verified up to the attestation roots.
+	Verified bool
+	// AAGUID is the AAGUID of the authenticator.
+	AAGUID []byte
+	// Flags are the flags of the authenticator data.
+	Flags protocol.AuthenticatorDataFlags
+	// Warnings contains the weaknesses of the registration that have been accepted, in the order in which they were
+	// encountered.
+	Warnings []Warning
+}
+
+// HasWarning returns whether the result contains the warning w.
+func (r *RegistrationResult) HasWarning(w Warning) bool {
+	for _, warning := range r.Warnings {
+		if warning == w {
+			return true
+		}
+	}
+	return false
+}
+
+// LoginResult is the result of a successful login, as returned by RelyingParty.FinishLoginResult.
+type LoginResult struct {
+	// SignCount is the new signature counter, which should be stored with the credential using
+	// Credential.UpdateCounter.
+	SignCount uint32
+	// Flags are the flags of the authenticator data.
+	Flags protocol.AuthenticatorDataFlags
+	// Extensions contains the authenticator extension outputs of the authenticator data, which are covered by the
+	// signature of the assertion.
+	Extensions protocol.AuthenticationExtensionsAuthenticatorOutputs
+	// ClientExtensionResults contains the client extension outputs, which are not signed by the authenticator.
+	ClientExtensionResults protocol.AuthenticationExtensionsClientOutputs
+	// UVM contains the factors that were used to verify the user, if the authenticator returned the output of the uvm
+	// extension.
+	UVM []protocol.UVMEntry
+	// DevicePubKey is the output of the devicePubKey extension, if the authenticator returned it.
+	DevicePubKey *protocol.DevicePublicKeyOutput
+	// CounterRollback is set if the signature counter did not increase, which is only accepted if WithAllowCounterRollback
+	// is set.
+	CounterRollback bool
+}
+
+// newLoginResult returns the result of a verified assertion.
+func newLoginResult(p *protocol.ParsedAssertionResponse) (*LoginResult, error) {
+	authData := p.Response.AuthData
+	result := &LoginResult{
+		SignCount:              authData.SignCount,
+		Flags:                  authData.Flags,
+		Extensions:             authData.Extensions,
+		ClientExtensionResults: p.ClientExtensionResults,
+	}
+
+	var err error
+	if result.UVM, err = authData.Extensions.UVM(); err != nil {
+		return nil, err
+	}
+	if result.DevicePubKey, err = authData.Extensions.DevicePubKey(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -1,5 +1,30 @@
 package webauthn
 
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+// ErrCeremonyExpired is returned by RelyingParty.FinishRegistration and RelyingParty.FinishLogin if the ceremony has
+// not been finished within its timeout.
+var ErrCeremonyExpired = &protocol.Error{
+	Name:        "ceremony_expired",
+	Description: "The ceremony has expired",
+	Hint:        "Start the ceremony again",
+	Code:        http.StatusBadRequest,
+}
+
+// ErrSessionNotFound is returned by SessionStore.Get if no session is stored under the key, or it has expired.
+var ErrSessionNotFound = &protocol.Error{
+	Name:        "session_not_found",
+	Description: "The ceremony has not been started or has already been finished",
+	Hint:        "Start the ceremony again",
+	Code:        http.StatusBadRequest,
+}
+
 // Session will be used by the request handlers to save temporary data, such as the challenge and user ID.
 type Session interface {
 	Set(name string, value interface{}) error
@@ -31,3 +56,130 @@ func (s *mapSession) Delete(name string) error {
 func WrapMap(values map[interface{}]interface{}) Session {
 	return &mapSession{values}
 }
+
+// SessionData holds the state of a ceremony started by RelyingParty. It should be stored server-side, for instance
+// JSON encoded in a session store, between the start and the finish of a ceremony and must not be supplied by clients.
+type SessionData struct {
+	// Challenge is the challenge that was sent to the client.
+	Challenge []byte `json:"challenge"`
+	// UserID is the ID of the user that is being registered or logged in, if known.
+	UserID []byte `json:"userId,omitempty"`
+	// UserVerification is the user verification requirement that was sent to the client.
+	UserVerification protocol.UserVerificationRequirement `json:"userVerification,omitempty"`
+	// AllowCredentials are the IDs of the credentials that were allowed for login.
+	AllowCredentials [][]byte `json:"allowCredentials,omitempty"`
+	// ExcludeCredentials are the IDs of the credentials that were excluded from registration.
+	ExcludeCredentials [][]byte `json:"excludeCredentials,omitempty"`
+	// CredentialAlgorithms are the algorithms of the credential public key that were requested for registration. If
+	// it is empty, all algorithms are allowed.
+	CredentialAlgorithms []protocol.COSEAlgorithmIdentifier `json:"credentialAlgorithms,omitempty"`
+	// TokenBindingID is the Token Binding ID of the TLS connection over which the response is received. It is not set
+	// by the ceremony, but may be set before finishing it, in which case the token binding of the client data must
+	// match it. If it is nil, the token binding is ignored.
+	TokenBindingID []byte `json:"tokenBindingId,omitempty"`
+	// Attestation is the attestation conveyance preference that was sent to the client. If it is empty, direct
+	// attestation is assumed.
+	Attestation protocol.AttestationConveyancePreference `json:"attestation,omitempty"`
+	// EnterpriseAttestation indicates whether enterprise attestation was requested.
+	EnterpriseAttestation bool `json:"enterpriseAttestation,omitempty"`
+	// Expires is the time at which the ceremony expires, based on the timeout that was sent to the client. If it is
+	// zero, the ceremony does not expire.
+	Expires time.Time `json:"expires"`
+}
+
+// expired returns whether the ceremony has expired at the given time.
+func (s *SessionData) expired(now time.Time) bool {
+	return !s.Expires.IsZero() && now.After(s.Expires)
+}
+
+// SessionStore stores the SessionData of ceremonies server-side between their start and finish, by a key that
+// identifies the ceremony, such as a random ID in a cookie.
+type SessionStore interface {
+	// Set stores the session under the key.
+	Set(key string, session *SessionData) error
+	// Get returns the session that is stored under the key, or ErrSessionNotFound if there is none.
+	Get(key string) (*SessionData, error)
+	// Delete deletes the session that is stored under the key, if any.
+	Delete(key string) error
+}
+
+var _ SessionStore = (*MemorySessionStore)(nil)
+var _ ChallengeConsumer = (*MemorySessionStore)(nil)
+
+// MemorySessionStore is a SessionStore that keeps sessions in memory, which is suitable for a single server. It is also
+// a ChallengeConsumer: if it is passed to WithChallengeConsumer instead of the default, finishing a ceremony deletes
+// its session, such that it can not be replayed, and only ceremonies of which the session is stored can be finished. Expired sessions are removed when new sessions are stored.
+type MemorySessionStore struct {
+	mu         sync.Mutex
+	sessions   map[string]*SessionData
+	challenges map[string]string
+}
+
+// NewMemorySessionStore creates an empty MemorySessionStore.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions:   make(map[string]*SessionData),
+		challenges: make(map[string]string),
+	}
+}
+
+// Set implements the SessionStore interface. A session that is already stored under the key is replaced.
+func (s *MemorySessionStore) Set(key string, session *SessionData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, v := range s.sessions {
+		if v.expired(time.Now()) {
+			s.delete(k)
+		}
+	}
+
+	s.delete(key)
+	s.sessions[key] = session
+	s.challenges[string(session.Challenge)] = key
+	return nil
+}
+
+// Get implements the SessionStore interface.
+func (s *MemorySessionStore) Get(key string) (*SessionData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[key]
+	if !ok || session.expired(time.Now()) {
+		return nil, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+// Delete implements the SessionStore interface.
+func (s *MemorySessionStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.delete(key)
+	return nil
+}
+
+// ConsumeChallenge implements the ChallengeConsumer interface by deleting the session with the challenge. It returns
+// ErrChallengeConsumed if no session with the challenge is stored.
+func (s *MemorySessionStore) ConsumeChallenge(challenge []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.challenges[string(challenge)]
+	if !ok {
+		return ErrChallengeConsumed
+	}
+	s.delete(key)
+	return nil
+}
+
+func (s *MemorySessionStore) delete(key string) {
+	if session, ok := s.sessions[key]; ok {
+		if s.challenges[string(session.Challenge)] == key {
+			delete(s.challenges, string(session.Challenge))
+		}
+		delete(s.sessions, key)
+	}
+}
@@ -0,0 +1,206 @@
+package webauthn_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/keycloud/webauthn/protocol"
+	"github.com/keycloud/webauthn/testutil"
+	"github.com/keycloud/webauthn/webauthn"
+)
+
+func TestMemorySessionStore(t *testing.T) {
+	store := webauthn.NewMemorySessionStore()
+
+	session := &webauthn.SessionData{Challenge: []byte("challenge"), Expires: time.Now().Add(time.Minute)}
+	if err := store.Set("key", session); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := store.Get("key"); err != nil || s != session {
+		t.Fatalf("expected session, got %v, %v", s, err)
+	}
+	if _, err := store.Get("other"); err != webauthn.ErrSessionNotFound {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+
+	if err := store.ConsumeChallenge([]byte("challenge")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ConsumeChallenge([]byte("challenge")); err != webauthn.ErrChallengeConsumed {
+		t.Fatalf("expected ErrChallengeConsumed, got %v", err)
+	}
+	if _, err := store.Get("key"); err != webauthn.ErrSessionNotFound {
+		t.Fatalf("expected consumed session to be deleted, got %v", err)
+	}
+
+	expired := &webauthn.SessionData{Challenge: []byte("expired"), Expires: time.Now().Add(-time.Minute)}
+	if err := store.Set("expired", expired); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("expired"); err != webauthn.ErrSessionNotFound {
+		t.Fatalf("expected expired session not to be returned, got %v", err)
+	}
+
+	if err := store.Set("key", session); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ConsumeChallenge([]byte("challenge")); err != webauthn.ErrChallengeConsumed {
+		t.Fatalf("expected deleted session not to be consumable, got %v", err)
+	}
+}
+
+func TestChallengeConsumer(t *testing.T) {
+	store := webauthn.NewMemorySessionStore()
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithChallengeConsumer(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator, err := testutil.NewVirtualAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creationOptions, session, err := rp.BeginRegistration(&testUser{id: []byte("test")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("registration", session); err != nil {
+		t.Fatal(err)
+	}
+	attestation, err := authenticator.Register("https://localhost", creationOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := rp.FinishRegistration(session, attestation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.FinishRegistration(session, attestation); !errors.Is(err, webauthn.ErrChallengeConsumed) {
+		t.Fatalf("expected ErrChallengeConsumed for replayed registration, got %v", err)
+	}
+
+	requestOptions, session, err := rp.BeginLogin(webauthn.WithUserCredentials(credential))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertion, err := authenticator.Login("https://localhost", requestOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sessions that are not stored can not be finished
+	if _, err := rp.FinishLogin(session, credential, assertion); !errors.Is(err, webauthn.ErrChallengeConsumed) {
+		t.Fatalf("expected ErrChallengeConsumed for unknown session, got %v", err)
+	}
+
+	if err := store.Set("login", session); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.FinishLogin(session, credential, assertion); err != nil {
+		e := protocol.ToWebAuthnError(err)
+		t.Fatalf("%s, %s: %s", e.Name, e.Description, e.Debug)
+	}
+	if _, err := rp.FinishLogin(session, credential, assertion); !errors.Is(err, webauthn.ErrChallengeConsumed) {
+		t.Fatalf("expected ErrChallengeConsumed for replayed login, got %v", err)
+	}
+}
+
+func TestDefaultChallengeConsumer(t *testing.T) {
+	authenticator, err := testutil.NewVirtualAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name   string
+		opts   []webauthn.Option
+		replay bool
+	}{
+		{"Default", nil, false},
+		{"Disabled", []webauthn.Option{webauthn.WithoutChallengeConsumer()}, true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", test.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			creationOptions, session, err := rp.BeginRegistration(&testUser{id: []byte("test")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			attestation, err := authenticator.Register("https://localhost", creationOptions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			credential, err := rp.FinishRegistration(session, attestation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = rp.FinishRegistration(session, attestation)
+			if test.replay && err != nil {
+				t.Fatalf("expected replayed registration to be accepted, got %v", err)
+			} else if !test.replay && !errors.Is(err, webauthn.ErrChallengeConsumed) {
+				t.Fatalf("expected ErrChallengeConsumed for replayed registration, got %v", err)
+			}
+
+			requestOptions, session, err := rp.BeginLogin(webauthn.WithUserCredentials(credential))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertion, err := authenticator.Login("https://localhost", requestOptions)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := rp.FinishLogin(session, credential, assertion); err != nil {
+				t.Fatal(err)
+			}
+			_, err = rp.FinishLogin(session, credential, assertion)
+			if test.replay && err != nil {
+				t.Fatalf("expected replayed login to be accepted, got %v", err)
+			} else if !test.replay && !errors.Is(err, webauthn.ErrChallengeConsumed) {
+				t.Fatalf("expected ErrChallengeConsumed for replayed login, got %v", err)
+			}
+		})
+	}
+}
+
+type challengeConsumerFunc func(challenge []byte) error
+
+func (f challengeConsumerFunc) ConsumeChallenge(challenge []byte) error {
+	return f(challenge)
+}
+
+func TestChallengeConsumerWrappedError(t *testing.T) {
+	consumer := challengeConsumerFunc(func(challenge []byte) error {
+		return fmt.Errorf("session backend: %w", protocol.ErrInvalidRequest.WithDebug("backend unavailable"))
+	})
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithChallengeConsumer(consumer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator, err := testutil.NewVirtualAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestation, err := authenticator.Register("https://localhost", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = rp.FinishRegistration(session, attestation)
+	if e := protocol.ToWebAuthnError(err); e.Name != protocol.ErrInvalidRequest.Name || e.Debug != "backend unavailable" {
+		t.Fatalf("expected the wrapped ErrInvalidRequest, got %v", err)
+	}
+}
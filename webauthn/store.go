@@ -0,0 +1,128 @@
+package webauthn
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/keycloud/webauthn/protocol"
+)
+
+// ErrCredentialNotFound is returned by CredentialStore.GetByCredentialID if no credential with the ID is stored.
+var ErrCredentialNotFound = &protocol.Error{
+	Name:        "credential_not_found",
+	Description: "The credential is not registered",
+	Hint:        "Use a registered credential",
+	Code:        http.StatusUnauthorized,
+}
+
+// CredentialStore stores the credentials that have been registered by a RelyingParty, indexed by their ID and by the
+// ID of their user. If it is passed to WithCredentialStore, the RelyingParty saves registered credentials and updates
+// their signature counters on login.
+type CredentialStore interface {
+	// Save stores the credential. A credential with the same ID that is already stored is replaced.
+	Save(credential *Credential) error
+	// GetByCredentialID returns the credential with the ID, or ErrCredentialNotFound if there is none. The error may be
+	// wrapped or carry debug information, as long as errors.Is reports it as ErrCredentialNotFound.
+	GetByCredentialID(id []byte) (*Credential, error)
+	// GetByUser returns the credentials of the user with the ID, in the order in which they were first saved. It
+	// returns no credentials if the user has none.
+	GetByUser(userID []byte) ([]*Credential, error)
+	// Delete deletes the credential with the ID, if any.
+	Delete(id []byte) error
+}
+
+var _ CredentialStore = (*MemoryCredentialStore)(nil)
+
+// MemoryCredentialStore is a CredentialStore that keeps credentials in memory, which is suitable for demos and tests.
+// The credentials are copied when they are saved and returned, so changes to a returned credential are only stored by
+// saving it again.
+type MemoryCredentialStore struct {
+	mu          sync.Mutex
+	credentials map[string]*Credential
+	users       map[string][]string
+}
+
+// NewMemoryCredentialStore creates an empty MemoryCredentialStore.
+func NewMemoryCredentialStore() *MemoryCredentialStore {
+	return &MemoryCredentialStore{
+		credentials: make(map[string]*Credential),
+		users:       make(map[string][]string),
+	}
+}
+
+// Save implements the CredentialStore interface.
+func (s *MemoryCredentialStore) Save(credential *Credential) error {
+	if len(credential.ID) == 0 {
+		return fmt.Errorf("missing credential ID")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := string(credential.ID)
+	if stored, ok := s.credentials[id]; !ok || string(stored.UserID) != string(credential.UserID) {
+		s.delete(id)
+		s.users[string(credential.UserID)] = append(s.users[string(credential.UserID)], id)
+	}
+	c := *credential
+	s.credentials[id] = &c
+	return nil
+}
+
+// GetByCredentialID implements the CredentialStore interface.
+func (s *MemoryCredentialStore) GetByCredentialID(id []byte) (*Credential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.credentials[string(id)]
+	if !ok {
+		return nil, ErrCredentialNotFound
+	}
+	c := *stored
+	return &c, nil
+}
+
+// GetByUser implements the CredentialStore interface.
+func (s *MemoryCredentialStore) GetByUser(userID []byte) ([]*Credential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var credentials []*Credential
+	for _, id := range s.users[string(userID)] {
+		c := *s.credentials[id]
+		credentials = append(credentials, &c)
+	}
+	return credentials, nil
+}
+
+// Delete implements the CredentialStore interface.
+func (s *MemoryCredentialStore) Delete(id []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.delete(string(id))
+	return nil
+}
+
+func (s *MemoryCredentialStore) delete(id string) {
+	stored, ok := s.credentials[id]
+	if !ok {
+		return
+	}
+
+	user := string(stored.UserID)
+	ids := s.users[user]
+	for i, v := range ids {
+		if v == id {
+			ids = append(ids[:i:i], ids[i+1:]...)
+			break
+		}
+	}
+	if len(ids) == 0 {
+		delete(s.users, user)
+	} else {
+		s.users[user] = ids
+	}
+	delete(s.credentials, id)
+}
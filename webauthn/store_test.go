@@ -0,0 +1,314 @@
+package webauthn_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/keycloud/webauthn/testutil"
+	"github.com/keycloud/webauthn/webauthn"
+)
+
+func TestMemoryCredentialStore(t *testing.T) {
+	store := webauthn.NewMemoryCredentialStore()
+
+	for _, c := range []*webauthn.Credential{
+		{ID: []byte("a"), UserID: []byte("alice")},
+		{ID: []byte("b"), UserID: []byte("bob")},
+		{ID: []byte("c"), UserID: []byte("alice")},
+	} {
+		if err := store.Save(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Save(&webauthn.Credential{UserID: []byte("alice")}); err == nil {
+		t.Fatal("expected error for missing credential ID")
+	}
+
+	credentialIDs := func(userID string) string {
+		credentials, err := store.GetByUser([]byte(userID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []byte
+		for _, c := range credentials {
+			ids = append(ids, c.ID...)
+		}
+		return string(ids)
+	}
+	if ids := credentialIDs("alice"); ids != "ac" {
+		t.Fatalf("expected credentials ac, got %q", ids)
+	}
+
+	c, err := store.GetByCredentialID([]byte("b"))
+	if err != nil || !bytes.Equal(c.UserID, []byte("bob")) {
+		t.Fatalf("expected credential of bob, got %v, %v", c, err)
+	}
+	if _, err := store.GetByCredentialID([]byte("other")); err != webauthn.ErrCredentialNotFound {
+		t.Fatalf("expected ErrCredentialNotFound, got %v", err)
+	}
+
+	// Returned credentials are copies
+	c.SignCount = 10
+	if c, _ := store.GetByCredentialID([]byte("b")); c.SignCount != 0 {
+		t.Fatal("expected changes to returned credential not to be stored")
+	}
+
+	// Saving a credential of another user moves it to that user
+	if err := store.Save(&webauthn.Credential{ID: []byte("a"), UserID: []byte("bob")}); err != nil {
+		t.Fatal(err)
+	}
+	if ids := credentialIDs("alice"); ids != "c" {
+		t.Fatalf("expected credentials c, got %q", ids)
+	}
+	if ids := credentialIDs("bob"); ids != "ba" {
+		t.Fatalf("expected credentials ba, got %q", ids)
+	}
+
+	if err := store.Delete([]byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetByCredentialID([]byte("c")); err != webauthn.ErrCredentialNotFound {
+		t.Fatalf("expected deleted credential not to be found, got %v", err)
+	}
+	if ids := credentialIDs("alice"); ids != "" {
+		t.Fatalf("expected no credentials, got %q", ids)
+	}
+}
+
+func TestCredentialStore(t *testing.T) {
+	store := webauthn.NewMemoryCredentialStore()
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithCredentialStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator, err := testutil.NewVirtualAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestation, err := authenticator.Register("https://localhost", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.FinishRegistration(session, attestation); err != nil {
+		t.Fatal(err)
+	}
+
+	credentials, err := store.GetByUser([]byte("test"))
+	if err != nil || len(credentials) != 1 || !bytes.Equal(credentials[0].ID, authenticator.CredentialID) {
+		t.Fatalf("expected registered credential to be stored, got %v, %v", credentials, err)
+	}
+
+	// The credential ID is already registered
+	options, session, err = rp.BeginRegistration(&testUser{id: []byte("other")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attestation, err = authenticator.Register("https://localhost", options); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rp.FinishRegistration(session, attestation); !errors.Is(err, webauthn.ErrCredentialExcluded) {
+		t.Fatalf("expected ErrCredentialExcluded, got %v", err)
+	}
+
+	loginOptions, loginSession, err := rp.BeginLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator.UserHandle = []byte("test")
+	assertion, err := authenticator.Login("https://localhost", loginOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, signCount, err := rp.FinishDiscoverableLogin(loginSession, nil, assertion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(credential.ID, authenticator.CredentialID) {
+		t.Fatal("expected stored credential to be returned")
+	}
+	if stored, err := store.GetByCredentialID(credential.ID); err != nil || stored.SignCount != signCount {
+		t.Fatalf("expected stored signature counter %d, got %v, %v", signCount, stored, err)
+	}
+
+	// The signature counter did not increase
+	authenticator.SignCount = 1
+	if loginOptions, loginSession, err = rp.BeginLogin(); err != nil {
+		t.Fatal(err)
+	}
+	if assertion, err = authenticator.Login("https://localhost", loginOptions); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := rp.FinishDiscoverableLogin(loginSession, nil, assertion); err != webauthn.ErrCounterRollback {
+		t.Fatalf("expected ErrCounterRollback, got %v", err)
+	}
+	if stored, _ := store.GetByCredentialID(credential.ID); stored.SignCount != signCount {
+		t.Fatalf("expected stored signature counter %d, got %d", signCount, stored.SignCount)
+	}
+}
+
+// wrappingCredentialStore is a CredentialStore that returns the errors of a MemoryCredentialStore as wrapped by wrap.
+type wrappingCredentialStore struct {
+	*webauthn.MemoryCredentialStore
+	wrap func(err error) error
+}
+
+func (s *wrappingCredentialStore) GetByCredentialID(id []byte) (*webauthn.Credential, error) {
+	credential, err := s.MemoryCredentialStore.GetByCredentialID(id)
+	if err != nil {
+		return nil, s.wrap(err)
+	}
+	return credential, nil
+}
+
+func TestCredentialStoreWrappedNotFound(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		wrap func(err error) error
+	}{
+		{"Debug", func(err error) error { return webauthn.ErrCredentialNotFound.WithDebug("not in database") }},
+		{"Wrapped", func(err error) error { return fmt.Errorf("database: %w", err) }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			store := &wrappingCredentialStore{MemoryCredentialStore: webauthn.NewMemoryCredentialStore(), wrap: test.wrap}
+			rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithCredentialStore(store))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			authenticator, err := testutil.NewVirtualAuthenticator()
+			if err != nil {
+				t.Fatal(err)
+			}
+			options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			attestation, err := authenticator.Register("https://localhost", options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := rp.FinishRegistration(session, attestation); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCredentialStoreAllowCounterRollback(t *testing.T) {
+	store := webauthn.NewMemoryCredentialStore()
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithCredentialStore(store), webauthn.WithAllowCounterRollback())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator, err := testutil.NewVirtualAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestation, err := authenticator.Register("https://localhost", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := rp.FinishRegistration(session, attestation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential.SignCount = 5
+	if err := store.Save(credential); err != nil {
+		t.Fatal(err)
+	}
+
+	loginOptions, loginSession, err := rp.BeginLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator.UserHandle = []byte("test")
+	authenticator.SignCount = 1
+	assertion, err := authenticator.Login("https://localhost", loginOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := rp.FinishLoginResult(loginSession, credential, assertion)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !result.CounterRollback {
+		t.Fatal("expected counter rollback to be reported")
+	}
+	if stored, _ := store.GetByCredentialID(credential.ID); stored.SignCount != 5 {
+		t.Fatalf("expected stored signature counter 5, got %d", stored.SignCount)
+	}
+}
+
+// counterCheckingCredentialStore is a CredentialStore that rejects saving a signature counter that is not greater than
+// the stored one, as a database could to detect concurrent logins, with a wrapped ErrCounterRollback.
+type counterCheckingCredentialStore struct {
+	*webauthn.MemoryCredentialStore
+}
+
+func (s *counterCheckingCredentialStore) Save(credential *webauthn.Credential) error {
+	if stored, err := s.GetByCredentialID(credential.ID); err == nil && stored.SignCount >= credential.SignCount {
+		return fmt.Errorf("database: %w", webauthn.ErrCounterRollback)
+	}
+	return s.MemoryCredentialStore.Save(credential)
+}
+
+func TestCredentialStoreWrappedCounterRollback(t *testing.T) {
+	store := &counterCheckingCredentialStore{MemoryCredentialStore: webauthn.NewMemoryCredentialStore()}
+	rp, err := webauthn.NewRelyingParty("webauthn-test", "localhost", "https://localhost", webauthn.WithCredentialStore(store), webauthn.WithAllowCounterRollback())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authenticator, err := testutil.NewVirtualAuthenticator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, session, err := rp.BeginRegistration(&testUser{id: []byte("test")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attestation, err := authenticator.Register("https://localhost", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := rp.FinishRegistration(session, attestation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The stored counter has been increased by a concurrent login, so the credential of the login is outdated
+	stored := *credential
+	stored.SignCount = 5
+	if err := store.MemoryCredentialStore.Save(&stored); err != nil {
+		t.Fatal(err)
+	}
+
+	loginOptions, loginSession, err := rp.BeginLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticator.SignCount = 3
+	assertion, err := authenticator.Login("https://localhost", loginOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := rp.FinishLoginResult(loginSession, credential, assertion)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !result.CounterRollback {
+		t.Fatal("expected counter rollback to be reported")
+	}
+}